@@ -1,11 +1,17 @@
 package app
 
 import (
+	"compress/gzip"
 	"net/http"
+	"strings"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
+	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
+	"github.com/weaveworks/common/mtime"
+
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
@@ -13,23 +19,73 @@ import (
 // Raw report handler
 func makeRawReportHandler(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-		report, err := rep.Report(ctx)
+		rpt, err := rep.Report(ctx)
 		if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
-		respondWith(w, http.StatusOK, report)
+		// Stream the report rather than going through respondWith, as reports
+		// can be large enough that encoding them into memory hurts.
+		if strings.Contains(r.Header.Get("Accept"), report.ProtobufContentType) {
+			w.Header().Set("Content-Type", report.ProtobufContentType)
+			w.Header().Add("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			if err := rpt.WriteProto(w, gzip.NoCompression); err != nil {
+				log.Errorf("Error streaming report: %v", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rpt.WriteJSON(w); err != nil {
+			// Too late to change the status code; the client will see a
+			// truncated document.
+			log.Errorf("Error streaming report: %v", err)
+		}
+	}
+}
+
+// Unmerged report handler: the last report received from the probe on the
+// host given by the host query parameter, for telling probe-side problems
+// from merge problems.
+func makeProbeReportHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		index, ok := rep.(HostIndex)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		rpt, ok := index.ProbeReport(ctx, r.URL.Query().Get("host"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rpt.WriteJSON(w); err != nil {
+			log.Errorf("Error streaming report: %v", err)
+		}
 	}
 }
 
 type probeDesc struct {
 	ID       string    `json:"id"`
+	HostID   string    `json:"hostID,omitempty"`
 	Hostname string    `json:"hostname"`
 	Version  string    `json:"version"`
 	LastSeen time.Time `json:"lastSeen"`
+	Reports  int       `json:"reports,omitempty"`
+	Active   bool      `json:"active"`
+
+	ClockSkewSeconds float64 `json:"clockSkewSeconds,omitempty"`
+	ClockSkewed      bool    `json:"clockSkewed,omitempty"`
 }
 
-// Probe handler
+// Probe handler. If the reporter keeps a HostIndex the probes are listed from
+// that, including those which have recently stopped reporting; otherwise
+// they're taken from the host topology of the current report.
 func makeProbeHandler(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		rpt, err := rep.Report(ctx)
@@ -37,18 +93,105 @@ func makeProbeHandler(rep Reporter) CtxHandlerFunc {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
+		index, ok := rep.(HostIndex)
+		if !ok {
+			result := []probeDesc{}
+			for _, n := range rpt.Host.Nodes {
+				id, _ := n.Latest.Lookup(report.ControlProbeID)
+				hostname, _ := n.Latest.Lookup(host.HostName)
+				version, dt, _ := n.Latest.LookupEntry(host.ScopeVersion)
+				result = append(result, probeDesc{
+					ID:       id,
+					Hostname: hostname,
+					Version:  version,
+					LastSeen: dt,
+					Active:   true,
+				})
+			}
+			respondWith(w, http.StatusOK, result)
+			return
+		}
+
 		result := []probeDesc{}
+		for _, h := range index.Hosts(ctx) {
+			desc := probeDesc{
+				HostID:           h.HostID,
+				Hostname:         h.Hostname,
+				Version:          h.Version,
+				LastSeen:         h.LastSeen,
+				Reports:          h.Reports,
+				Active:           h.Active,
+				ClockSkewSeconds: h.ClockSkew.Seconds(),
+				ClockSkewed:      h.ClockSkewed,
+			}
+			// Stale hosts will have dropped out of the report, so these are
+			// only known for active ones. Probes which predate
+			// Report.ProbeVersion only have their version on the host node.
+			if n, ok := rpt.Host.Nodes[report.MakeHostNodeID(h.HostID)]; ok {
+				desc.ID, _ = n.Latest.Lookup(report.ControlProbeID)
+				if desc.Version == "" {
+					desc.Version, _ = n.Latest.Lookup(host.ScopeVersion)
+				}
+			}
+			result = append(result, desc)
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
+
+// HealthStaleness is how recently a probe must have reported for /api/health
+// to consider the app healthy.
+const HealthStaleness = 1 * time.Minute
+
+type healthDesc struct {
+	Healthy              bool      `json:"healthy"`
+	Probes               int       `json:"probes"`
+	LastReport           time.Time `json:"lastReport"`
+	LastReportAgeSeconds float64   `json:"lastReportAgeSeconds"`
+}
+
+// Health handler, reporting how fresh the data from the probes is. It
+// responds with 503 if no probe has reported in the last HealthStaleness.
+func makeHealthHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		rpt, err := rep.Report(ctx)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		var (
+			result = healthDesc{}
+			probes = map[string]struct{}{}
+		)
 		for _, n := range rpt.Host.Nodes {
 			id, _ := n.Latest.Lookup(report.ControlProbeID)
-			hostname, _ := n.Latest.Lookup(host.HostName)
-			version, dt, _ := n.Latest.LookupEntry(host.ScopeVersion)
-			result = append(result, probeDesc{
-				ID:       id,
-				Hostname: hostname,
-				Version:  version,
-				LastSeen: dt,
-			})
+			probes[id] = struct{}{}
+		}
+		result.Probes = len(probes)
+		result.LastReport = rpt.Timestamp
+		// Probes which predate Report.Timestamp don't set it, so count
+		// their reports as of when they were received.
+		if receiver, ok := rep.(Receiver); ok {
+			if received := receiver.LastReceived(ctx); received.After(result.LastReport) {
+				result.LastReport = received
+			}
+		}
+
+		code := http.StatusServiceUnavailable
+		if !result.LastReport.IsZero() {
+			age := mtime.Now().Sub(result.LastReport)
+			result.LastReportAgeSeconds = age.Seconds()
+			if age <= HealthStaleness {
+				result.Healthy = true
+				code = http.StatusOK
+			}
+		}
+		// Not respondWith, which would log every failed health check as an error.
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Cache-Control", "no-cache")
+		w.WriteHeader(code)
+		if err := codec.NewEncoder(w, &codec.JsonHandle{}).Encode(result); err != nil {
+			log.Errorf("Error encoding response: %v", err)
 		}
-		respondWith(w, http.StatusOK, result)
 	}
 }
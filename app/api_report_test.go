@@ -1,13 +1,19 @@
 package app_test
 
 import (
+	"bytes"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
@@ -33,3 +39,181 @@ func TestAPIReport(t *testing.T) {
 		t.Fatalf("JSON parse error: %s", err)
 	}
 }
+
+func TestAPIReportProtobuf(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/api/report", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", report.ProtobufContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if have := resp.Header.Get("Content-Type"); have != report.ProtobufContentType {
+		t.Fatalf("Content-Type: %q", have)
+	}
+	var r report.Report
+	if err := r.ReadProto(resp.Body, false); err != nil {
+		t.Fatalf("protobuf parse error: %s", err)
+	}
+	if want, have := len(fixture.Report.Endpoint.Nodes), len(r.Endpoint.Nodes); want != have {
+		t.Fatalf("want %d endpoints, have %d", want, have)
+	}
+}
+
+func TestAPIHealth(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	rpt := report.MakeReport()
+	rpt.Host = rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID("host1"), map[string]string{
+		report.ControlProbeID: "probe1",
+		host.ScopeVersion:     "1.0",
+	}))
+	rpt.Timestamp = mtime.Now()
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var health struct {
+		Healthy bool `json:"healthy"`
+		Probes  int  `json:"probes"`
+	}
+	res, body := checkGet(t, ts, "/api/health")
+	equals(t, http.StatusOK, res.StatusCode)
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&health))
+	equals(t, true, health.Healthy)
+	equals(t, 1, health.Probes)
+
+	mtime.NowForce(now.Add(app.HealthStaleness + time.Second))
+	res, body = checkGet(t, ts, "/api/health")
+	equals(t, http.StatusServiceUnavailable, res.StatusCode)
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&health))
+	equals(t, false, health.Healthy)
+}
+
+func TestAPIHealthWithoutTimestamp(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	// Probes which predate Report.Timestamp are as fresh as their reports
+	// are to the collector.
+	c := app.NewCollector(time.Hour)
+	ok(t, c.Add(context.Background(), report.MakeReport(), nil))
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, _ := checkGet(t, ts, "/api/health")
+	equals(t, http.StatusOK, res.StatusCode)
+
+	mtime.NowForce(now.Add(app.HealthStaleness + time.Second))
+	res, _ = checkGet(t, ts, "/api/health")
+	equals(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestAPIHealthNoReports(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, app.StaticCollector(report.MakeReport()))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, _ := checkGet(t, ts, "/api/health")
+	equals(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestAPIProbes(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	window := 15 * time.Second
+	c := app.NewCollector(window)
+	add := func(hostID, hostname string) {
+		rpt := report.MakeReport()
+		rpt.HostID, rpt.Timestamp = hostID, mtime.Now()
+		rpt.ProbeVersion = "1.0"
+		rpt.Host = rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID(hostID), map[string]string{
+			report.ControlProbeID: "probe-" + hostID,
+			host.HostName:         hostname,
+			host.ScopeVersion:     "1.0",
+		}))
+		ok(t, c.Add(context.Background(), rpt, nil))
+	}
+	add("host1", "stale.example.com")
+	mtime.NowForce(now.Add(window))
+	add("host2", "active.example.com")
+	add("host2", "active.example.com")
+
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var probes []struct {
+		ID       string `json:"id"`
+		HostID   string `json:"hostID"`
+		Hostname string `json:"hostname"`
+		Version  string `json:"version"`
+		Reports  int    `json:"reports"`
+		Active   bool   `json:"active"`
+	}
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/probes"), &codec.JsonHandle{}).Decode(&probes))
+	equals(t, 2, len(probes))
+
+	stale, active := probes[0], probes[1]
+	equals(t, "host1", stale.HostID)
+	equals(t, "stale.example.com", stale.Hostname)
+	equals(t, 1, stale.Reports)
+	equals(t, false, stale.Active)
+	equals(t, "", stale.ID)
+	equals(t, "1.0", stale.Version)
+
+	equals(t, "host2", active.HostID)
+	equals(t, "active.example.com", active.Hostname)
+	equals(t, 2, active.Reports)
+	equals(t, true, active.Active)
+	equals(t, "probe-host2", active.ID)
+
+	// Once the grace period is over, the stale probe is no longer listed.
+	mtime.NowForce(now.Add(window + app.ProbeGracePeriod))
+	probes = nil
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/probes"), &codec.JsonHandle{}).Decode(&probes))
+	equals(t, 1, len(probes))
+	equals(t, "host2", probes[0].HostID)
+}
+
+func TestAPIProbeReport(t *testing.T) {
+	c := app.NewCollector(15 * time.Second)
+	probeReport := func(hostID, addr string) report.Report {
+		rpt := report.MakeReport()
+		rpt.HostID = hostID
+		nodeID := report.MakeEndpointNodeID(hostID, "", addr, "80")
+		rpt.Endpoint = rpt.Endpoint.AddNode(report.MakeNodeWith(nodeID, map[string]string{"addr": addr}))
+		return rpt
+	}
+	submitted := probeReport("host1", "10.0.0.1")
+	ok(t, c.Add(context.Background(), submitted, nil))
+	ok(t, c.Add(context.Background(), probeReport("host2", "10.0.0.2"), nil))
+
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var want bytes.Buffer
+	ok(t, submitted.WriteJSON(&want))
+	equals(t, want.String(), string(getRawJSON(t, ts, "/api/report/raw?host=host1")))
+
+	is404(t, ts, "/api/report/raw?host=unknown")
+	is404(t, ts, "/api/report/raw")
+}
@@ -5,13 +5,17 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/bluele/gcache"
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
 
+	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
@@ -21,10 +25,13 @@ const (
 	apiTopologyURL         = "/api/topology/"
 	processesID            = "processes"
 	processesByNameID      = "processes-by-name"
+	processesByUnitID      = "processes-by-unit"
 	systemGroupID          = "system"
 	containersID           = "containers"
 	containersByHostnameID = "containers-by-hostname"
 	containersByImageID    = "containers-by-image"
+	containersByLabelID    = "containers-by-label"
+	containerPlacementID   = "container-placement"
 	podsID                 = "pods"
 	replicaSetsID          = "replica-sets"
 	deploymentsID          = "deployments"
@@ -33,6 +40,85 @@ const (
 	weaveID                = "weave"
 	ecsTasksID             = "ecs-tasks"
 	ecsServicesID          = "ecs-services"
+
+	// pseudoStrategyParam selects a render.PseudoStrategy for the views which
+	// support it. Its values don't overlap with the "pseudo" filter options
+	// of other views.
+	pseudoStrategyParam = "pseudo"
+
+	// edgeAgeParam restricts a view to the connections first seen within the
+	// given duration (e.g. "5m"), or, for negative durations (e.g. "-5m"), to
+	// those seen before then.
+	edgeAgeParam = "age"
+
+	// minBytesParam drops the edges over which fewer bytes than given went,
+	// either way, and the nodes left without any (see
+	// render.MakeMinBytesDecorator).
+	minBytesParam = "min_bytes"
+
+	// portsParam keeps only the nodes with endpoints on the given ports and
+	// ranges of ports (e.g. "5432,6379,8000-9000"), those connected to them,
+	// and the edges between, along with the pseudo nodes (see
+	// render.MakePortsDecorator).
+	portsParam = "ports"
+
+	// filterParam keeps only the nodes matching an expression (see
+	// render.ParseFilterExpression), e.g. "docker_image_name~redis AND
+	// memory>1000000", and pseudo nodes. highlightParam keeps every node,
+	// but marks the matching ones as highlighted.
+	filterParam    = "filter"
+	highlightParam = "highlight"
+
+	// componentParam marks each node with the connected component it is in;
+	// given a component ID, only that component is kept.
+	componentParam = "component"
+
+	// rankParam marks each node with its centrality (see
+	// render.MakeCentralityDecorator): render.DegreeCentrality if empty, or
+	// render.BytesCentrality.
+	rankParam = "rank"
+
+	// undirectedParam, if true, merges the adjacencies between each pair of
+	// nodes in both directions into one (see render.Undirected).
+	undirectedParam = "undirected"
+
+	// collapseParam, given collapsePseudo, merges the pseudo nodes other than
+	// the internet and address class ones into a single "others" node.
+	collapseParam  = "collapse"
+	collapsePseudo = "pseudo"
+
+	// selfEdgesParam, if false, drops the adjacencies from nodes to
+	// themselves (see render.HideSelfEdges).
+	selfEdgesParam = "self_edges"
+
+	// hostLinksParam, if true, adds a node for each host linked to its
+	// endpoints and processes (see render.LinkHosts).
+	hostLinksParam = "host_links"
+
+	// ephemeralParam, if true, collapses the endpoints on ephemeral ports
+	// into one per process and address (see
+	// render.MakeEphemeralPortsDecorator); it may also give the range of
+	// ephemeral ports, e.g. "49152-65535", in place of
+	// render.DefaultEphemeralPorts.
+	ephemeralParam = "ephemeral"
+
+	// labelParam selects the Docker label to group containers by, for the
+	// views which support it.
+	labelParam        = "label"
+	defaultGroupLabel = "com.docker.compose.service"
+
+	// imageGroupingParam selects the parts of image names containers are
+	// grouped by (see docker.ImageGrouping), for the views which support it.
+	imageGroupingParam = "image_grouping"
+
+	// How many rendered topologies to keep, across all topologies and
+	// request parameters.
+	renderCacheSize = 100
+
+	// Beyond these many pseudo nodes, a view shows a single "others" node
+	// instead.
+	processPseudoThreshold   = 30
+	containerPseudoThreshold = 30
 )
 
 var (
@@ -88,7 +174,7 @@ func updateFilters(rpt report.Report, topologies []APITopologyDesc) []APITopolog
 	sort.Strings(ns)
 	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
 	for i, t := range topologies {
-		if t.id == containersID || t.id == containersByImageID || t.id == containersByHostnameID || t.id == podsID || t.id == servicesID || t.id == deploymentsID || t.id == replicaSetsID {
+		if t.id == containersID || t.id == containersByImageID || t.id == containersByHostnameID || t.id == containersByLabelID || t.id == podsID || t.id == servicesID || t.id == deploymentsID || t.id == replicaSetsID {
 			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{
 				kubernetesFilters(ns...),
 			})
@@ -117,12 +203,23 @@ func MakeAPITopologyOption(value string, label string, filterFunc render.FilterF
 type Registry struct {
 	sync.RWMutex
 	items map[string]APITopologyDesc
+
+	// renderCache holds rendered topologies, keyed by topology, request
+	// parameters and report ID. Entries for old reports are never hit again,
+	// and get evicted as new reports come in.
+	renderCache gcache.Cache
+}
+
+// containerLabelRenderer groups containers by the given Docker label.
+func containerLabelRenderer(label string) render.Renderer {
+	return render.CollapsePseudo(containerPseudoThreshold, render.ContainerLabelRenderer(label))
 }
 
 // MakeRegistry returns a new Registry
 func MakeRegistry() *Registry {
 	registry := &Registry{
-		items: map[string]APITopologyDesc{},
+		items:       map[string]APITopologyDesc{},
+		renderCache: gcache.New(renderCacheSize).LRU().Build(),
 	}
 	containerFilters := []APITopologyOptionGroup{
 		{
@@ -164,28 +261,57 @@ func MakeRegistry() *Registry {
 		},
 	}
 
+	// The process views can represent unattributed endpoints differently on
+	// request, see pseudoStrategyParam.
+	processes := map[render.PseudoStrategy]render.Renderer{}
+	processesByName := map[render.PseudoStrategy]render.Renderer{}
+	processesByUnit := map[render.PseudoStrategy]render.Renderer{}
+	for _, strategy := range render.PseudoStrategies {
+		processes[strategy] = render.CollapsePseudo(processPseudoThreshold, render.FilterUnconnected(render.ProcessWithContainerNameRendererWith(strategy)))
+		processesByName[strategy] = render.CollapsePseudo(processPseudoThreshold, render.FilterUnconnected(render.ProcessNameRendererWith(strategy)))
+		processesByUnit[strategy] = render.CollapsePseudo(processPseudoThreshold, render.FilterUnconnected(render.ProcessSystemdUnitRendererWith(strategy)))
+	}
+
+	// The containers by image view can group images by their repository, tag
+	// or digest on request, see imageGroupingParam.
+	containersByImage := map[docker.ImageGrouping]render.Renderer{}
+	for _, grouping := range []docker.ImageGrouping{docker.ImageGroupByRepo, docker.ImageGroupByRepoTag, docker.ImageGroupByDigest} {
+		containersByImage[grouping] = render.CollapsePseudo(containerPseudoThreshold, render.ContainerImageRendererBy(grouping))
+	}
+
 	// Topology option labels should tell the current state. The first item must
 	// be the verb to get to that state
 	registry.Add(
 		APITopologyDesc{
-			id:          processesID,
-			renderer:    render.FilterUnconnected(render.ProcessWithContainerNameRenderer),
-			Name:        "Processes",
-			Rank:        1,
-			Options:     unconnectedFilter,
-			HideIfEmpty: true,
+			id:              processesID,
+			renderer:        processes[render.PseudoInternetOnly],
+			pseudoRenderers: processes,
+			Name:            "Processes",
+			Rank:            1,
+			Options:         unconnectedFilter,
+			HideIfEmpty:     true,
 		},
 		APITopologyDesc{
-			id:          processesByNameID,
-			parent:      processesID,
-			renderer:    render.FilterUnconnected(render.ProcessNameRenderer),
-			Name:        "by name",
-			Options:     unconnectedFilter,
-			HideIfEmpty: true,
+			id:              processesByNameID,
+			parent:          processesID,
+			renderer:        processesByName[render.PseudoInternetOnly],
+			pseudoRenderers: processesByName,
+			Name:            "by name",
+			Options:         unconnectedFilter,
+			HideIfEmpty:     true,
+		},
+		APITopologyDesc{
+			id:              processesByUnitID,
+			parent:          processesID,
+			renderer:        processesByUnit[render.PseudoInternetOnly],
+			pseudoRenderers: processesByUnit,
+			Name:            "by systemd unit",
+			Options:         unconnectedFilter,
+			HideIfEmpty:     true,
 		},
 		APITopologyDesc{
 			id:       containersID,
-			renderer: render.ContainerWithImageNameRenderer,
+			renderer: render.CollapsePseudo(containerPseudoThreshold, render.ContainerWithImageNameRenderer),
 			Name:     "Containers",
 			Rank:     2,
 			Options:  containerFilters,
@@ -193,16 +319,34 @@ func MakeRegistry() *Registry {
 		APITopologyDesc{
 			id:       containersByHostnameID,
 			parent:   containersID,
-			renderer: render.ContainerHostnameRenderer,
+			renderer: render.CollapsePseudo(containerPseudoThreshold, render.ContainerHostnameRenderer),
 			Name:     "by DNS name",
 			Options:  containerFilters,
 		},
 		APITopologyDesc{
-			id:       containersByImageID,
+			id:             containersByImageID,
+			parent:         containersID,
+			renderer:       containersByImage[docker.ImageGroupByRepo],
+			imageRenderers: containersByImage,
+			Name:           "by image",
+			Options:        containerFilters,
+		},
+		APITopologyDesc{
+			id:       containerPlacementID,
 			parent:   containersID,
-			renderer: render.ContainerImageRenderer,
-			Name:     "by image",
-			Options:  containerFilters,
+			renderer: render.ContainerPlacementRenderer,
+			Name:     "by host",
+			// Not the uncontained filter, which would hide the unknown host.
+			Options: containerFilters[:2],
+		},
+		APITopologyDesc{
+			id:            containersByLabelID,
+			parent:        containersID,
+			renderer:      containerLabelRenderer(defaultGroupLabel),
+			labelRenderer: containerLabelRenderer,
+			Name:          "by label",
+			Options:       containerFilters,
+			HideIfEmpty:   true,
 		},
 		APITopologyDesc{
 			id:          podsID,
@@ -275,6 +419,18 @@ type APITopologyDesc struct {
 	parent   string
 	renderer render.Renderer
 
+	// pseudoRenderers, if set, are alternatives to renderer which can be
+	// selected with pseudoStrategyParam.
+	pseudoRenderers map[render.PseudoStrategy]render.Renderer
+
+	// labelRenderer, if set, makes the renderer for the label given with
+	// labelParam.
+	labelRenderer func(label string) render.Renderer
+
+	// imageRenderers, if set, are alternatives to renderer which can be
+	// selected with imageGroupingParam.
+	imageRenderers map[docker.ImageGrouping]render.Renderer
+
 	Name        string                   `json:"name"`
 	Rank        int                      `json:"rank"`
 	HideIfEmpty bool                     `json:"hide_if_empty"`
@@ -376,7 +532,7 @@ func AddContainerFilters(newFilters ...APITopologyOption) {
 func (r *Registry) AddContainerFilters(newFilters ...APITopologyOption) {
 	r.Lock()
 	defer r.Unlock()
-	for _, key := range []string{containersID, containersByHostnameID, containersByImageID} {
+	for _, key := range []string{containersID, containersByHostnameID, containersByImageID, containersByLabelID} {
 		for i := range r.items[key].Options {
 			if r.items[key].Options[i].ID == systemGroupID {
 				r.items[key].Options[i].Options = append(r.items[key].Options[i].Options, newFilters...)
@@ -475,6 +631,112 @@ func decorateWithStats(rpt report.Report, renderer render.Renderer, decorator re
 	}
 }
 
+// decoratorPipeline turns the request parameters which apply to every view
+// into decorators, composed in this order after the view's own filters.
+var decoratorPipeline = render.Pipeline{
+	{Param: edgeAgeParam, Make: func(value string) (render.Decorator, error) {
+		age, err := time.ParseDuration(value)
+		switch {
+		case err != nil || age == 0:
+			return nil, nil
+		case age < 0:
+			return render.MakeEdgeAgeDecorator(-age, false), nil
+		}
+		return render.MakeEdgeAgeDecorator(age, true), nil
+	}},
+	{Param: minBytesParam, Make: func(value string) (render.Decorator, error) {
+		if minBytes, err := strconv.ParseUint(value, 10, 64); err == nil && minBytes > 0 {
+			return render.MakeMinBytesDecorator(minBytes), nil
+		}
+		return nil, nil
+	}},
+	{Param: portsParam, Make: func(value string) (render.Decorator, error) {
+		if value == "" {
+			return nil, nil
+		}
+		ranges, err := render.ParsePortRanges(value)
+		if err != nil {
+			return nil, err
+		}
+		return render.MakePortsDecorator(ranges), nil
+	}},
+	{Param: filterParam, Make: makeExpressionStage(render.MakeFilterDecorator)},
+	{Param: highlightParam, Make: makeExpressionStage(render.MakeHighlightDecorator)},
+	{Param: componentParam, Make: func(value string) (render.Decorator, error) {
+		return render.MakeComponentDecorator(value), nil
+	}},
+	{Param: collapseParam, Make: func(value string) (render.Decorator, error) {
+		switch value {
+		case "":
+			return nil, nil
+		case collapsePseudo:
+			return func(r render.Renderer) render.Renderer { return render.CollapsePseudo(0, r) }, nil
+		}
+		return nil, &render.ParamError{Param: collapseParam, Value: value}
+	}},
+	{Param: selfEdgesParam, Make: func(value string) (render.Decorator, error) {
+		if selfEdges, err := strconv.ParseBool(value); err == nil && !selfEdges {
+			return render.HideSelfEdges, nil
+		}
+		return nil, nil
+	}},
+	{Param: undirectedParam, Make: makeBoolStage(render.Undirected)},
+	{Param: rankParam, Make: func(value string) (render.Decorator, error) {
+		if value == "" {
+			value = render.DegreeCentrality
+		}
+		dct, ok := render.MakeCentralityDecorator(value)
+		if !ok {
+			return nil, &render.ParamError{Param: rankParam, Value: value}
+		}
+		return dct, nil
+	}},
+	{Param: hostLinksParam, Make: makeBoolStage(render.LinkHosts)},
+	// Last, so the stages before it see the collapsed endpoints too.
+	{Param: ephemeralParam, Make: func(value string) (render.Decorator, error) {
+		if ephemeral, err := strconv.ParseBool(value); err == nil {
+			if !ephemeral {
+				return nil, nil
+			}
+			return render.MakeEphemeralPortsDecorator(render.DefaultEphemeralPorts), nil
+		}
+		ranges, err := render.ParsePortRanges(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranges) != 1 {
+			return nil, &render.ParamError{Param: ephemeralParam, Value: value}
+		}
+		return render.MakeEphemeralPortsDecorator(ranges[0]), nil
+	}},
+}
+
+// makeExpressionStage makes a pipeline stage for a parameter holding a
+// filter expression.
+func makeExpressionStage(makeDecorator func(render.FilterFunc) render.Decorator) func(string) (render.Decorator, error) {
+	return func(value string) (render.Decorator, error) {
+		if value == "" {
+			return nil, nil
+		}
+		f, err := render.ParseFilterExpression(value)
+		if err != nil {
+			return nil, err
+		}
+		return makeDecorator(f), nil
+	}
+}
+
+// makeBoolStage makes a pipeline stage for a parameter which, if true,
+// applies decorator.
+func makeBoolStage(decorator render.Decorator) func(string) (render.Decorator, error) {
+	return func(value string) (render.Decorator, error) {
+		if b, _ := strconv.ParseBool(value); b {
+			return decorator, nil
+		}
+		return nil, nil
+	}
+}
+
 // RendererForTopology ..
 func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt report.Report) (render.Renderer, render.Decorator, error) {
 	topology, ok := r.get(topologyID)
@@ -488,6 +750,21 @@ func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt
 		return topology.renderer, nil, nil
 	}
 
+	renderer := topology.renderer
+	if label := values.Get(labelParam); label != "" && topology.labelRenderer != nil {
+		renderer = topology.labelRenderer(label)
+	}
+	if grouping := values.Get(imageGroupingParam); grouping != "" {
+		if r, ok := topology.imageRenderers[docker.ImageGrouping(grouping)]; ok {
+			renderer = r
+		}
+	}
+	if strategy := values.Get(pseudoStrategyParam); strategy != "" {
+		if r, ok := topology.pseudoRenderers[render.PseudoStrategy(strategy)]; ok {
+			renderer = r
+		}
+	}
+
 	var decorators []render.Decorator
 	for _, group := range topology.Options {
 		value := values.Get(group.ID)
@@ -495,13 +772,20 @@ func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt
 			decorators = append(decorators, decorator)
 		}
 	}
+	decorator, err := decoratorPipeline.Decorator(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decorator != nil {
+		decorators = append(decorators, decorator)
+	}
 	if len(decorators) > 0 {
 		// Here we tell the topology renderer to apply the filtering decorator
 		// that we construct as a composition of all the selected filters.
 		composedFilterDecorator := render.ComposeDecorators(decorators...)
-		return render.ApplyDecorator(topology.renderer), composedFilterDecorator, nil
+		return render.ApplyDecorator(renderer), composedFilterDecorator, nil
 	}
-	return topology.renderer, nil, nil
+	return renderer, nil, nil
 }
 
 type reporterHandler func(context.Context, Reporter, http.ResponseWriter, *http.Request)
@@ -527,11 +811,28 @@ func (r *Registry) captureRenderer(rep Reporter, f rendererHandler) CtxHandlerFu
 			return
 		}
 		req.ParseForm()
+		begin := time.Now()
+		defer func() {
+			renderDuration.WithLabelValues(topologyID).Observe(time.Since(begin).Seconds())
+		}()
 		renderer, decorator, err := r.RendererForTopology(topologyID, req.Form, rpt)
-		if err != nil {
+		if isBadRequest(err) {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		} else if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
 		f(ctx, renderer, decorator, rpt, w, req)
 	}
 }
+
+// isBadRequest tells whether err, from RendererForTopology, is down to the
+// request's parameters.
+func isBadRequest(err error) bool {
+	switch err.(type) {
+	case *render.ExpressionError, *render.PortRangeError, *render.ParamError:
+		return true
+	}
+	return false
+}
@@ -127,6 +127,105 @@ func TestRendererForTopologyWithFiltering(t *testing.T) {
 	}
 }
 
+func TestRendererForTopologyPseudoStrategy(t *testing.T) {
+	topologyRegistry := app.MakeRegistry()
+	for strategy, want := range map[string]map[string]bool{
+		"": {
+			render.IncomingInternetID:     true,
+			expected.UnknownPseudoNode1ID: false,
+		},
+		"internet-only": {
+			render.IncomingInternetID:     true,
+			expected.UnknownPseudoNode1ID: false,
+		},
+		"generic": {
+			render.IncomingInternetID:     true,
+			expected.UnknownPseudoNode1ID: true,
+			expected.UnknownPseudoNode2ID: true,
+		},
+		"none": {
+			render.IncomingInternetID:     false,
+			expected.UnknownPseudoNode1ID: false,
+		},
+	} {
+		urlvalues := url.Values{}
+		urlvalues.Set("unconnected", "hide")
+		if strategy != "" {
+			urlvalues.Set("pseudo", strategy)
+		}
+		renderer, decorator, err := topologyRegistry.RendererForTopology("processes", urlvalues, fixture.Report)
+		if err != nil {
+			t.Fatalf("Topology Registry Report error: %s", err)
+		}
+		have := renderer.Render(fixture.Report, decorator)
+		for id, present := range want {
+			if _, ok := have[id]; ok != present {
+				t.Errorf("pseudo=%q: expected presence of %s to be %v", strategy, id, present)
+			}
+		}
+		if _, ok := have[fixture.ServerProcessNodeID]; !ok {
+			t.Errorf("pseudo=%q: expected server process to be rendered", strategy)
+		}
+		if strategy == "none" {
+			for id, node := range have {
+				if node.Topology == render.Pseudo {
+					t.Errorf("pseudo=none: unexpected pseudo node %s", id)
+				}
+				for _, adj := range node.Adjacency {
+					if _, ok := have[adj]; !ok {
+						t.Errorf("pseudo=none: dangling adjacency %s -> %s", id, adj)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRendererForTopologyImageGrouping(t *testing.T) {
+	// Both containers run the same image, one by tag and one by digest.
+	rpt := fixture.Report.Copy()
+	rpt.ID = "image-grouping"
+	for id, name := range map[string]string{
+		fixture.ClientContainerImageNodeID: "registry:5000/image/server:1.0",
+		fixture.ServerContainerImageNodeID: "image/server@sha256:abc",
+	} {
+		rpt.ContainerImage.Nodes[id] = rpt.ContainerImage.Nodes[id].WithLatests(map[string]string{docker.ImageName: name})
+	}
+
+	topologyRegistry := app.MakeRegistry()
+	for grouping, want := range map[string][]string{
+		"":       {"image/server"},
+		"repo":   {"image/server"},
+		"tag":    {"image/server", "image/server:1.0"},
+		"digest": {"image/server:1.0", "image/server@sha256:abc"},
+		"other":  {"image/server"},
+	} {
+		urlvalues := url.Values{}
+		if grouping != "" {
+			urlvalues.Set("image_grouping", grouping)
+		}
+		renderer, decorator, err := topologyRegistry.RendererForTopology("containers-by-image", urlvalues, rpt)
+		if err != nil {
+			t.Fatalf("Topology Registry Report error: %s", err)
+		}
+		have := renderer.Render(rpt, decorator)
+		for _, name := range want {
+			if _, ok := have[report.MakeContainerImageNodeID(name)]; !ok {
+				t.Errorf("image_grouping=%q: expected %s to be rendered, have %v", grouping, name, have)
+			}
+		}
+		images := 0
+		for _, node := range have {
+			if node.Topology == report.ContainerImage {
+				images++
+			}
+		}
+		if images != len(want) {
+			t.Errorf("image_grouping=%q: want %d images, have %d", grouping, len(want), images)
+		}
+	}
+}
+
 func TestRendererForTopologyNoFiltering(t *testing.T) {
 	ts := topologyServer()
 	defer ts.Close()
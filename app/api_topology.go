@@ -1,11 +1,20 @@
 package app
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/common/xfer"
@@ -16,40 +25,364 @@ import (
 
 const (
 	websocketLoop = 1 * time.Second
+
+	// resumeParam, given the resume token of the last update a websocket
+	// client got, sends it only what changed since, if the server still has
+	// the topology it was sent then.
+	resumeParam = "resume"
+
+	// formatParam selects an alternative response format for a topology:
+	// "edges" for a list of APIEdges, "dot" for a GraphViz graph, "csv" for
+	// a CSV table of the edges, or "matrix" for an APIMatrix.
+	formatParam = "format"
 )
 
 // APITopology is returned by the /api/topology/{name} handler.
 type APITopology struct {
-	Nodes detailed.NodeSummaries `json:"nodes"`
+	Nodes     detailed.NodeSummaries `json:"nodes"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// APIEdge is returned, in a list, by the /api/topology/{name}?format=edges
+// handler. Connections in both directions between two nodes are reported as
+// a single edge, with metadata from the point of view of Source.
+type APIEdge struct {
+	Source        string              `json:"source"`
+	Target        string              `json:"target"`
+	Bidirectional bool                `json:"bidirectional,omitempty"`
+	Metadata      report.EdgeMetadata `json:"metadata"`
 }
 
 // APINode is returned by the /api/topology/{name}/{id} handler.
 type APINode struct {
 	Node detailed.Node `json:"node"`
+	// ReportedBy lists the host IDs of the probes which reported the node or
+	// its children, if asked for with attributionParam.
+	ReportedBy []string `json:"reportedBy,omitempty"`
+}
+
+// APINodes is returned by the batch /api/topology/{name}/nodes handler. IDs
+// which weren't found are listed in NotFound.
+type APINodes struct {
+	Nodes    map[string]APINode `json:"nodes"`
+	NotFound []string           `json:"notFound,omitempty"`
+}
+
+// APINodesRequest is what is posted to the /api/topology/{name}/nodes
+// handler.
+type APINodesRequest struct {
+	IDs []string `json:"ids"`
 }
 
 // Full topology.
 func handleTopology(ctx context.Context, renderer render.Renderer, decorator render.Decorator, report report.Report, w http.ResponseWriter, r *http.Request) {
+	topologyID := mux.Vars(r)["topology"]
+	switch r.Form.Get(formatParam) {
+	case "edges":
+		nodes := renderer.Render(report, decorator)
+		respondWith(w, http.StatusOK, topologyEdges(nodes, topologyEdgeTraffic(report, nodes)))
+		return
+	case "dot":
+		respondWithDOT(w, topologyID, report, renderer.Render(report, decorator))
+		return
+	case "csv":
+		respondWithCSV(w, topologyID, report, renderer.Render(report, decorator))
+		return
+	case "matrix":
+		nodes := renderer.Render(report, decorator)
+		matrix, err := topologyMatrix(nodes, topologyEdgeTraffic(report, nodes))
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		respondWith(w, http.StatusOK, matrix)
+		return
+	}
+	nodes := topologyRegistry.summaries(topologyID, r.Form, report, renderer, decorator)
+	etag, err := topologyETag(nodes)
+	if err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	respondWith(w, http.StatusOK, APITopology{
-		Nodes: detailed.Summaries(report, renderer.Render(report, decorator)),
+		Nodes:     nodes,
+		Timestamp: report.Timestamp,
 	})
 }
 
-// Individual nodes.
-func handleNode(ctx context.Context, renderer render.Renderer, decorator render.Decorator, report report.Report, w http.ResponseWriter, r *http.Request) {
-	var (
-		vars             = mux.Vars(r)
-		topologyID       = vars["topology"]
-		nodeID           = vars["id"]
-		preciousRenderer = render.PreciousNodeRenderer{PreciousNodeID: nodeID, Renderer: renderer}
-		rendered         = preciousRenderer.Render(report, decorator)
-		node, ok         = rendered[nodeID]
-	)
+// topologyETag hashes the node summaries of a rendered topology, in node ID
+// order. The report timestamp is left out, as it changes with every report
+// even when the topology doesn't, so the ETag is a weak one: responses with
+// the same ETag have the same nodes, but not byte for byte the same body.
+func topologyETag(nodes detailed.NodeSummaries) (string, error) {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	encoder := codec.NewEncoder(h, &codec.JsonHandle{})
+	for _, id := range ids {
+		if err := encoder.Encode(nodes[id]); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("W/%q", hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. ETags
+// are compared weakly, as RFC 7232 asks of If-None-Match.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderedTopology is a topology rendered for a request, along with the
+// node summaries sent for it.
+type renderedTopology struct {
+	nodes     report.Nodes
+	summaries detailed.NodeSummaries
+}
+
+// summaries renders the node summaries of a topology, reusing the result of
+// an earlier identical request against the same report.
+func (r *Registry) summaries(topologyID string, values url.Values, rpt report.Report, renderer render.Renderer, decorator render.Decorator) detailed.NodeSummaries {
+	return r.rendered(topologyID, values, rpt, renderer, decorator).summaries
+}
+
+// rendered renders a topology, reusing the result of an earlier identical
+// request against the same report. Views filtered by edge age depend on the
+// current time as well, so they are always rendered.
+func (r *Registry) rendered(topologyID string, values url.Values, rpt report.Report, renderer render.Renderer, decorator render.Decorator) renderedTopology {
+	if r.renderCache == nil || values.Get(edgeAgeParam) != "" {
+		return renderTopology(rpt, renderer, decorator)
+	}
+	key := summariesKey(topologyID, values, rpt.ID)
+	if cached, err := r.renderCache.Get(key); err == nil {
+		return cached.(renderedTopology)
+	}
+	result := renderTopology(rpt, renderer, decorator)
+	r.renderCache.Set(key, result)
+	return result
+}
+
+// cachedRender returns a topology rendered earlier from the report with the
+// given ID, if it is still cached.
+func (r *Registry) cachedRender(topologyID string, values url.Values, reportID string) (renderedTopology, bool) {
+	if r.renderCache == nil || values.Get(edgeAgeParam) != "" {
+		return renderedTopology{}, false
+	}
+	cached, err := r.renderCache.Get(summariesKey(topologyID, values, reportID))
+	if err != nil {
+		return renderedTopology{}, false
+	}
+	return cached.(renderedTopology), true
+}
+
+func renderTopology(rpt report.Report, renderer render.Renderer, decorator render.Decorator) renderedTopology {
+	nodes := renderer.Render(rpt, decorator)
+	return renderedTopology{
+		nodes:     nodes,
+		summaries: detailed.Summaries(rpt, nodes),
+	}
+}
+
+func summariesKey(topologyID string, values url.Values, reportID string) string {
+	return fmt.Sprintf("%s?%s@%s", topologyID, values.Encode(), reportID)
+}
+
+// edgeTraffic is the traffic from one rendered node to another.
+type edgeTraffic struct {
+	metadata    report.EdgeMetadata
+	connections uint64
+}
+
+// topologyEdgeTraffic totals the edge metadata of the endpoint edges between
+// the endpoints making up each pair of rendered nodes, keyed by source and
+// target node, from the point of view of the source. As with
+// topologyTraffic, the rendered nodes don't carry edge metadata themselves.
+// Edges without connection counts count as a single connection.
+func topologyEdgeTraffic(rpt report.Report, nodes report.Nodes) map[[2]string]edgeTraffic {
+	result := map[[2]string]edgeTraffic{}
+	render.WalkEndpointEdges(rpt, nodes, func(_, _ string, md report.EdgeMetadata, srcOwners, dstOwners []string) {
+		for _, source := range srcOwners {
+			for _, target := range dstOwners {
+				if source == target {
+					continue
+				}
+				key := [2]string{source, target}
+				t, ok := result[key]
+				if ok {
+					t.metadata = t.metadata.Flatten(md)
+				} else {
+					t.metadata = md.Copy()
+				}
+				t.connections += edgeConnections(md)
+				result[key] = t
+			}
+		}
+	})
+	return result
+}
+
+// topologyEdges lists the edges between the given nodes, sorted by source
+// and target, with their metadata from traffic. Adjacencies to missing nodes
+// and to the node itself are ignored.
+func topologyEdges(nodes report.Nodes, traffic map[[2]string]edgeTraffic) []APIEdge {
+	edges := map[[2]string]APIEdge{}
+	for id, node := range nodes {
+		for _, adj := range node.Adjacency {
+			if _, ok := nodes[adj]; !ok || adj == id {
+				continue
+			}
+			source, target := id, adj
+			md := traffic[[2]string{source, target}].metadata
+			if target < source {
+				source, target = target, source
+				md = md.Reversed()
+			}
+			key := [2]string{source, target}
+			if edge, ok := edges[key]; ok {
+				edge.Bidirectional = true
+				edge.Metadata = edge.Metadata.Flatten(md)
+				edges[key] = edge
+			} else {
+				edges[key] = APIEdge{Source: source, Target: target, Metadata: md}
+			}
+		}
+	}
+	result := make([]APIEdge, 0, len(edges))
+	for _, edge := range edges {
+		result = append(result, edge)
+	}
+	sort.Sort(bySourceTarget(result))
+	return result
+}
+
+type bySourceTarget []APIEdge
+
+func (e bySourceTarget) Len() int      { return len(e) }
+func (e bySourceTarget) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e bySourceTarget) Less(i, j int) bool {
+	if e[i].Source != e[j].Source {
+		return e[i].Source < e[j].Source
+	}
+	return e[i].Target < e[j].Target
+}
+
+// attributionParam asks for the probes which reported a node, where the
+// collector keeps track of them.
+const attributionParam = "attribution"
+
+// Individual nodes. rep is only asked which probes reported the node.
+func handleNode(rep Reporter) rendererHandler {
+	return func(ctx context.Context, renderer render.Renderer, decorator render.Decorator, report report.Report, w http.ResponseWriter, r *http.Request) {
+		var (
+			vars             = mux.Vars(r)
+			topologyID       = vars["topology"]
+			nodeID           = vars["id"]
+			preciousRenderer = render.PreciousNodeRenderer{PreciousNodeID: nodeID, Renderer: renderer}
+			rendered         = preciousRenderer.Render(report, decorator)
+			node, ok         = rendered[nodeID]
+		)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, makeAPINode(topologyID, report, rendered, node, requestedAttribution(ctx, rep, r)))
+	}
+}
+
+// Details of many nodes, rendered once.
+func handleNodes(rep Reporter) rendererHandler {
+	return func(ctx context.Context, renderer render.Renderer, decorator render.Decorator, report report.Report, w http.ResponseWriter, r *http.Request) {
+		var req APINodesRequest
+		defer r.Body.Close()
+		if err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&req); err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		var (
+			topologyID       = mux.Vars(r)["topology"]
+			preciousRenderer = render.PreciousNodesRenderer{PreciousNodeIDs: req.IDs, Renderer: renderer}
+			rendered         = preciousRenderer.Render(report, decorator)
+			attribution      = requestedAttribution(ctx, rep, r)
+			result           = APINodes{Nodes: map[string]APINode{}}
+		)
+		for _, id := range req.IDs {
+			if _, ok := result.Nodes[id]; ok {
+				continue
+			}
+			node, ok := rendered[id]
+			if !ok {
+				result.NotFound = append(result.NotFound, id)
+				continue
+			}
+			result.Nodes[id] = makeAPINode(topologyID, report, rendered, node, attribution)
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
+
+// makeAPINode details a rendered node, and lists the probes which reported
+// it if given their attribution.
+func makeAPINode(topologyID string, report report.Report, rendered report.Nodes, node report.Node, attribution report.Attribution) APINode {
+	result := APINode{Node: detailed.MakeNode(topologyID, report, rendered, node)}
+	if attribution != nil {
+		result.ReportedBy = reportedBy(attribution, node)
+	}
+	return result
+}
+
+// requestedAttribution returns the attribution of the probes' reports if
+// asked for with attributionParam, and nil otherwise.
+func requestedAttribution(ctx context.Context, rep Reporter, r *http.Request) report.Attribution {
+	attributor, ok := rep.(Attributor)
 	if !ok {
-		http.NotFound(w, r)
-		return
+		return nil
+	}
+	if attributed, _ := strconv.ParseBool(r.Form.Get(attributionParam)); !attributed {
+		return nil
+	}
+	attribution := attributor.Attribution(ctx)
+	if attribution == nil {
+		attribution = report.Attribution{}
 	}
-	respondWith(w, http.StatusOK, APINode{Node: detailed.MakeNode(topologyID, report, rendered, node)})
+	return attribution
+}
+
+// reportedBy returns the host IDs of the probes which reported a rendered
+// node, or any of the nodes it was rendered from, sorted.
+func reportedBy(attribution report.Attribution, node report.Node) []string {
+	result := attribution[node.ID].Copy()
+	node.Children.ForEach(func(child report.Node) {
+		result = result.Merge(attribution[child.ID])
+	})
+	return result
+}
+
+// websocketUpdate is what a topology websocket sends: the node summaries
+// changed since the topology it sent before, or, if Reset, since nothing, so
+// the client should drop the nodes it has. A client reconnecting can pass
+// Resume back as resumeParam.
+type websocketUpdate struct {
+	Add    []detailed.NodeSummary `json:"add"`
+	Update []detailed.NodeSummary `json:"update"`
+	Remove []string               `json:"remove"`
+	Reset  bool                   `json:"reset,omitempty"`
+	Resume string                 `json:"resume"`
 }
 
 // Websocket for the full topology.
@@ -93,11 +426,19 @@ func handleWebsocket(
 	}(conn)
 
 	var (
-		previousTopo detailed.NodeSummaries
-		tick         = time.Tick(loop)
-		wait         = make(chan struct{}, 1)
-		topologyID   = mux.Vars(r)["topology"]
+		previous   renderedTopology
+		reset      = true
+		tick       = time.Tick(loop)
+		wait       = make(chan struct{}, 1)
+		topologyID = mux.Vars(r)["topology"]
 	)
+	if token := r.Form.Get(resumeParam); token != "" {
+		// The token isn't part of what is rendered.
+		r.Form.Del(resumeParam)
+		var ok bool
+		previous, ok = topologyRegistry.cachedRender(topologyID, r.Form, token)
+		reset = !ok
+	}
 	rep.WaitOn(ctx, wait)
 	defer rep.UnWait(ctx, wait)
 
@@ -112,11 +453,12 @@ func handleWebsocket(
 			log.Errorf("Error generating report: %v", err)
 			return
 		}
-		newTopo := detailed.Summaries(report, renderer.Render(report, decorator))
-		diff := detailed.TopoDiff(previousTopo, newTopo)
-		previousTopo = newTopo
+		current := topologyRegistry.rendered(topologyID, r.Form, report, renderer, decorator)
+		update := websocketDelta(previous, current)
+		update.Reset, update.Resume = reset, report.ID
+		previous, reset = current, false
 
-		if err := conn.WriteJSON(diff); err != nil {
+		if err := conn.WriteJSON(update); err != nil {
 			if !xfer.IsExpectedWSCloseError(err) {
 				log.Errorf("cannot serialize topology diff: %s", err)
 			}
@@ -131,3 +473,40 @@ func handleWebsocket(
 		}
 	}
 }
+
+// websocketDelta turns the render.Diff between two renders of a topology into
+// the node summaries to send. Updated nodes whose summaries didn't change are
+// left out, as are nodes without a summary. Each list is sorted by node ID.
+func websocketDelta(previous, current renderedTopology) websocketUpdate {
+	var (
+		delta  = render.Diff(previous.nodes, current.nodes)
+		update = websocketUpdate{}
+		ids    = make([]string, 0, len(delta.Added)+len(delta.Updated))
+	)
+	for id := range delta.Added {
+		ids = append(ids, id)
+	}
+	for id := range delta.Updated {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		old, had := previous.summaries[id]
+		summary, has := current.summaries[id]
+		switch {
+		case has && !had:
+			update.Add = append(update.Add, summary)
+		case has && !reflect.DeepEqual(old, summary):
+			update.Update = append(update.Update, summary)
+		case had && !has:
+			update.Remove = append(update.Remove, id)
+		}
+	}
+	for _, id := range delta.Removed {
+		if _, had := previous.summaries[id]; had {
+			update.Remove = append(update.Remove, id)
+		}
+	}
+	sort.Strings(update.Remove)
+	return update
+}
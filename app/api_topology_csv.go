@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/scope/report"
+)
+
+var csvHeader = []string{"source", "destination", "bytes_ingress", "bytes_egress", "conn_count"}
+
+// respondWithCSV writes the edges between the rendered nodes of a topology
+// as CSV, e.g. for spreadsheets.
+func respondWithCSV(w http.ResponseWriter, topologyID string, rpt report.Report, nodes report.Nodes) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+topologyID+`.csv"`)
+	w.Header().Add("Cache-Control", "no-cache")
+	if err := writeCSV(w, nodes, topologyEdgeTraffic(rpt, nodes)); err != nil {
+		log.Errorf("Error writing CSV: %v", err)
+	}
+}
+
+// writeCSV writes a header and a row per edge, as listed by topologyEdges,
+// with the traffic both ways between its nodes. Byte counts are left empty
+// when unknown; connections without counts are counted once, as for the top
+// nodes.
+func writeCSV(w io.Writer, nodes report.Nodes, traffic map[[2]string]edgeTraffic) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, edge := range topologyEdges(nodes, traffic) {
+		connections := traffic[[2]string{edge.Source, edge.Target}].connections +
+			traffic[[2]string{edge.Target, edge.Source}].connections
+		if connections == 0 {
+			connections = 1
+		}
+		if err := out.Write([]string{
+			edge.Source,
+			edge.Target,
+			csvCount(edge.Metadata.IngressByteCount),
+			csvCount(edge.Metadata.EgressByteCount),
+			strconv.FormatUint(connections, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func csvCount(count *uint64) string {
+	if count == nil {
+		return ""
+	}
+	return strconv.FormatUint(*count, 10)
+}
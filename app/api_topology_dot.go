@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// respondWithDOT writes the rendered nodes of a topology as a GraphViz DOT
+// digraph, e.g. for `dot -Tpng`.
+func respondWithDOT(w http.ResponseWriter, topologyID string, rpt report.Report, nodes report.Nodes) {
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	w.Header().Add("Cache-Control", "no-cache")
+	if err := writeDOT(w, topologyID, rpt, nodes); err != nil {
+		log.Errorf("Error writing DOT: %v", err)
+	}
+}
+
+// writeDOT writes a digraph with a node per rendered node, labelled like the
+// UI labels it, and an edge per pair of connected nodes, labelled with the
+// bytes transferred if known and the connections both ways, counted as for
+// the CSV format. Pseudo nodes are drawn as dashed boxes.
+func writeDOT(w io.Writer, topologyID string, rpt report.Report, nodes report.Nodes) error {
+	summaries := detailed.Summaries(rpt, nodes)
+	ids := make([]string, 0, len(summaries))
+	for id := range summaries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if _, err := fmt.Fprintf(w, "digraph %s {\n", dotQuote(topologyID)); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		summary := summaries[id]
+		attrs := []string{"label=" + dotQuote(summary.Label)}
+		if summary.Pseudo {
+			attrs = append(attrs, "shape=box", "style=dashed")
+		}
+		if _, err := fmt.Fprintf(w, "\t%s [%s];\n", dotQuote(id), strings.Join(attrs, ", ")); err != nil {
+			return err
+		}
+	}
+	traffic := topologyEdgeTraffic(rpt, nodes)
+	for _, edge := range topologyEdges(nodes, traffic) {
+		if _, ok := summaries[edge.Source]; !ok {
+			continue
+		}
+		if _, ok := summaries[edge.Target]; !ok {
+			continue
+		}
+		connections := traffic[[2]string{edge.Source, edge.Target}].connections +
+			traffic[[2]string{edge.Target, edge.Source}].connections
+		if connections == 0 {
+			connections = 1
+		}
+		label := fmt.Sprintf("%d connections", connections)
+		if connections == 1 {
+			label = "1 connection"
+		}
+		if bytes, ok := edgeBytes(edge.Metadata); ok {
+			label = fmt.Sprintf("%d bytes, %s", bytes, label)
+		}
+		attrs := []string{"label=" + dotQuote(label)}
+		if edge.Bidirectional {
+			attrs = append(attrs, "dir=both")
+		}
+		if _, err := fmt.Fprintf(w, "\t%s -> %s [%s];\n", dotQuote(edge.Source), dotQuote(edge.Target), strings.Join(attrs, ", ")); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// edgeBytes returns the total bytes sent in either direction over an edge.
+func edgeBytes(md report.EdgeMetadata) (uint64, bool) {
+	if md.EgressByteCount == nil && md.IngressByteCount == nil {
+		return 0, false
+	}
+	var total uint64
+	if md.EgressByteCount != nil {
+		total += *md.EgressByteCount
+	}
+	if md.IngressByteCount != nil {
+		total += *md.IngressByteCount
+	}
+	return total, true
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// A single edge, between the nodes {id} and {target}. As with the edges
+// format of a topology, connections in both directions are reported
+// together, with metadata from the point of view of {id}, merged from the
+// endpoint edges between the two nodes. The metadata includes the sample of
+// the connections behind the edge, if the probes were asked to report them.
+func handleEdge(ctx context.Context, renderer render.Renderer, decorator render.Decorator, rpt report.Report, w http.ResponseWriter, r *http.Request) {
+	var (
+		vars     = mux.Vars(r)
+		source   = vars["id"]
+		target   = vars["target"]
+		rendered = renderer.Render(rpt, decorator)
+	)
+	src, ok := rendered[source]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	dst, ok := rendered[target]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		edge    = APIEdge{Source: source, Target: target}
+		traffic = topologyEdgeTraffic(rpt, report.Nodes{source: src, target: dst})
+		found   = false
+	)
+	if src.Adjacency.Contains(target) {
+		edge.Metadata = traffic[[2]string{source, target}].metadata
+		found = true
+	}
+	if dst.Adjacency.Contains(source) {
+		md := traffic[[2]string{target, source}].metadata.Reversed()
+		if found {
+			edge.Bidirectional = true
+			edge.Metadata = edge.Metadata.Flatten(md)
+		} else {
+			edge.Metadata = md
+		}
+		found = true
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	respondWith(w, http.StatusOK, edge)
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+type countingRenderer struct {
+	render.Renderer
+	renders int
+}
+
+func (c *countingRenderer) Render(rpt report.Report, dct render.Decorator) report.Nodes {
+	c.renders++
+	return c.Renderer.Render(rpt, dct)
+}
+
+func TestSummariesCachedPerReport(t *testing.T) {
+	var (
+		renderer = &countingRenderer{Renderer: render.ConstantRenderer(report.Nodes{
+			"foo": report.MakeNode("foo").WithTopology(report.Host),
+		})}
+		registry = MakeRegistry()
+		values   = url.Values{"foo": []string{"bar"}}
+		rpt      = report.MakeReport()
+	)
+	registry.Add(APITopologyDesc{id: "test", renderer: renderer})
+
+	for i := 0; i < 2; i++ {
+		r, dct, err := registry.RendererForTopology("test", values, rpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := registry.summaries("test", values, rpt, r, dct); len(have) != 1 {
+			t.Fatalf("expected one node, got %v", have)
+		}
+	}
+	if renderer.renders != 1 {
+		t.Errorf("expected identical requests to render once, rendered %d times", renderer.renders)
+	}
+
+	rpt = report.MakeReport()
+	r, dct, _ := registry.RendererForTopology("test", values, rpt)
+	registry.summaries("test", values, rpt, r, dct)
+	if renderer.renders != 2 {
+		t.Errorf("expected a new report to be rendered, rendered %d times", renderer.renders)
+	}
+}
+
+func TestTopologyEdgeTraffic(t *testing.T) {
+	egress := func(n uint64) report.EdgeMetadata { return report.EdgeMetadata{EgressByteCount: &n} }
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNode("a1").WithTopology(report.Endpoint).WithEdge("b1", egress(10)))
+	rpt.Endpoint.AddNode(report.MakeNode("a2").WithTopology(report.Endpoint).WithEdge("b1", egress(5)).WithEdge("a1", egress(1)))
+	rpt.Endpoint.AddNode(report.MakeNode("b1").WithTopology(report.Endpoint).WithEdge("a1", egress(3)))
+
+	child := func(id string) report.Node { return report.MakeNode(id).WithTopology(report.Endpoint) }
+	nodes := report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b").WithChildren(report.MakeNodeSet(child("a1"), child("a2"))),
+		"b": report.MakeNode("b").WithAdjacent("a").WithChildren(report.MakeNodeSet(child("b1"))),
+	}
+	traffic := topologyEdgeTraffic(rpt, nodes)
+	if len(traffic) != 2 {
+		t.Fatalf("expected traffic a->b and b->a, got %v", traffic)
+	}
+	if ab := traffic[[2]string{"a", "b"}]; ab.connections != 2 || *ab.metadata.EgressByteCount != 15 {
+		t.Errorf("expected 15 bytes over 2 connections from a to b, got %v", ab)
+	}
+	if ba := traffic[[2]string{"b", "a"}]; ba.connections != 1 || *ba.metadata.EgressByteCount != 3 {
+		t.Errorf("expected 3 bytes over 1 connection from b to a, got %v", ba)
+	}
+}
+
+func TestTopologyEdgesBidirectional(t *testing.T) {
+	egress := func(n uint64) edgeTraffic {
+		return edgeTraffic{metadata: report.EdgeMetadata{EgressByteCount: &n}, connections: 1}
+	}
+	nodes := report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b"),
+		"b": report.MakeNode("b").WithAdjacent("a").WithAdjacent("c"),
+		"c": report.MakeNode("c").WithAdjacent("c"),
+	}
+	traffic := map[[2]string]edgeTraffic{
+		{"a", "b"}: egress(10),
+		{"b", "a"}: egress(3),
+	}
+	edges := topologyEdges(nodes, traffic)
+	if len(edges) != 2 {
+		t.Fatalf("expected edges a-b and b-c, got %v", edges)
+	}
+	ab := edges[0]
+	if ab.Source != "a" || ab.Target != "b" || !ab.Bidirectional {
+		t.Errorf("unexpected edge %v", ab)
+	}
+	if md := ab.Metadata; md.EgressByteCount == nil || *md.EgressByteCount != 10 ||
+		md.IngressByteCount == nil || *md.IngressByteCount != 3 {
+		t.Errorf("expected 10 bytes out and 3 bytes in, got %v", md)
+	}
+	if bc := edges[1]; bc.Source != "b" || bc.Target != "c" || bc.Bidirectional {
+		t.Errorf("unexpected edge %v", bc)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var (
+		egress = uint64(10)
+		buf    bytes.Buffer
+	)
+	nodes := report.Nodes{
+		"a,1": report.MakeNode("a,1").WithAdjacent("b"),
+		"b":   report.MakeNode("b").WithAdjacent("c"),
+		"c":   report.MakeNode("c"),
+	}
+	traffic := map[[2]string]edgeTraffic{
+		{"a,1", "b"}: {metadata: report.EdgeMetadata{EgressByteCount: &egress}, connections: 2},
+	}
+	if err := writeCSV(&buf, nodes, traffic); err != nil {
+		t.Fatal(err)
+	}
+	want := "source,destination,bytes_ingress,bytes_egress,conn_count\n" +
+		"\"a,1\",b,,10,2\n" +
+		"b,c,,,1\n"
+	if have := buf.String(); have != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, have)
+	}
+}
+
+func TestTopologyMatrix(t *testing.T) {
+	egress, ingress := uint64(10), uint64(3)
+	topology := report.NewTopologyBuilder().
+		AddEdge("c", "a", report.EdgeMetadata{}).
+		AddEdge("a", "b", report.EdgeMetadata{}).
+		AddNode("b", nil).
+		Build()
+	traffic := map[[2]string]edgeTraffic{
+		{"c", "a"}: {metadata: report.EdgeMetadata{EgressByteCount: &egress, IngressByteCount: &ingress}},
+	}
+
+	matrix, err := topologyMatrix(topology.Nodes, traffic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only c connects to a, and a to b, so the reverse weights are null.
+	w := func(n uint64) *uint64 { return &n }
+	want := APIMatrix{
+		Nodes: []string{"a", "b", "c"},
+		Weights: [][]*uint64{
+			{nil, w(0), nil},
+			{nil, nil, nil},
+			{w(13), nil, nil},
+		},
+	}
+	if !reflect.DeepEqual(want, matrix) {
+		t.Error(test.Diff(want, matrix))
+	}
+
+	nodes := report.Nodes{}
+	for i := 0; i <= maxMatrixNodes; i++ {
+		id := strconv.Itoa(i)
+		nodes[id] = report.MakeNode(id)
+	}
+	if _, err := topologyMatrix(nodes, nil); err == nil {
+		t.Errorf("expected an error for %d nodes", len(nodes))
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// maxMatrixNodes is the most nodes a topology can have to be returned as a
+// matrix, which grows with the square of them.
+const maxMatrixNodes = 200
+
+// APIMatrix is returned by the /api/topology/{name}?format=matrix handler:
+// the adjacency matrix of the topology, e.g. for heatmaps. Weights[i][j] is
+// the number of bytes sent either way over the connections from Nodes[i] to
+// Nodes[j], zero if unknown, and null if there are none. The matrix is only
+// symmetric where the nodes connect to each other.
+type APIMatrix struct {
+	Nodes   []string    `json:"nodes"`
+	Weights [][]*uint64 `json:"weights"`
+}
+
+// topologyMatrix makes the adjacency matrix of the given nodes, in node ID
+// order, from their adjacencies and the traffic between them. It is an error
+// if there are more than maxMatrixNodes nodes.
+func topologyMatrix(nodes report.Nodes, traffic map[[2]string]edgeTraffic) (APIMatrix, error) {
+	if len(nodes) > maxMatrixNodes {
+		return APIMatrix{}, fmt.Errorf("too many nodes for a matrix: %d, at most %d", len(nodes), maxMatrixNodes)
+	}
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	weights := make([][]*uint64, len(ids))
+	for i := range weights {
+		weights[i] = make([]*uint64, len(ids))
+	}
+	for source, node := range nodes {
+		for _, target := range node.Adjacency {
+			if _, ok := nodes[target]; !ok || target == source {
+				continue
+			}
+			md := traffic[[2]string{source, target}].metadata
+			bytes, _ := edgeBytes(md)
+			weights[index[source]][index[target]] = &bytes
+		}
+	}
+	return APIMatrix{Nodes: ids, Weights: weights}, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/mtime"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// seriesMetricParam selects what is charted: "bytes" or "connections".
+	seriesMetricParam = "metric"
+	// seriesRangeParam is how far back the series goes, as a duration.
+	seriesRangeParam = "range"
+
+	defaultSeriesRange = time.Hour
+	// seriesPoints is how many points a series is split into, unless that
+	// would make them shorter than the quantisation interval.
+	seriesPoints = 60
+)
+
+// APISeriesPoint is one point of the series returned by the
+// /api/topology/{name}/{id}/series handler. Value is nil where there were no
+// reports, or the node wasn't in them.
+type APISeriesPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     *uint64   `json:"value"`
+}
+
+// Series of a node's traffic over time, from the reports kept by the
+// collector, e.g. for a sparkline. The reports are merged into equal time
+// buckets, and the topology rendered for each.
+func handleSeries(ctx context.Context, rep Reporter, w http.ResponseWriter, r *http.Request) {
+	var (
+		vars       = mux.Vars(r)
+		topologyID = vars["topology"]
+		nodeID     = vars["id"]
+	)
+	if _, ok := topologyRegistry.get(topologyID); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	history, ok := rep.(ReportHistory)
+	if !ok {
+		respondWith(w, http.StatusNotImplemented, fmt.Errorf("no report history is kept"))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	value, ok := trafficValue(r.Form.Get(seriesMetricParam))
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", seriesMetricParam, r.Form.Get(seriesMetricParam)))
+		return
+	}
+	length := defaultSeriesRange
+	if s := r.Form.Get(seriesRangeParam); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", seriesRangeParam, s))
+			return
+		}
+		length = d
+	}
+	step := length / seriesPoints
+	if step < reportQuantisationInterval {
+		step = reportQuantisationInterval
+	}
+
+	var (
+		since            = mtime.Now().Add(-length)
+		timestamps, rpts = history.ReportsSince(ctx, since)
+		buckets          = make([][]report.Report, (length+step-1)/step)
+		merger           = NewSmartMerger()
+		result           = make([]APISeriesPoint, len(buckets))
+	)
+	for i, ts := range timestamps {
+		bucket := int(ts.Sub(since) / step)
+		if bucket >= len(buckets) {
+			bucket = len(buckets) - 1
+		}
+		buckets[bucket] = append(buckets[bucket], rpts[i])
+	}
+	for i, bucket := range buckets {
+		result[i].Timestamp = since.Add(time.Duration(i) * step)
+		if len(bucket) == 0 {
+			continue
+		}
+		rpt := merger.Merge(bucket)
+		renderer, decorator, err := topologyRegistry.RendererForTopology(topologyID, r.Form, rpt)
+		if isBadRequest(err) {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		nodes := renderer.Render(rpt, decorator)
+		if _, ok := nodes[nodeID]; !ok {
+			continue
+		}
+		v := value(topologyTraffic(rpt, nodes)[nodeID])
+		result[i].Value = &v
+	}
+	respondWith(w, http.StatusOK, result)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// APISubgraph is returned by the /api/topology/{name}/{id}/subgraph handler:
+// a node and everything it is directly connected to. For containers,
+// Endpoints lists the container's own endpoints and those they connect to.
+type APISubgraph struct {
+	Root      string                 `json:"root"`
+	Nodes     detailed.NodeSummaries `json:"nodes"`
+	Edges     []APIEdge              `json:"edges"`
+	Endpoints []string               `json:"endpoints,omitempty"`
+}
+
+// Subgraph rooted at a single node. Pseudo nodes connected to the root, such
+// as the internet, are kept as its peers.
+func handleSubgraph(ctx context.Context, renderer render.Renderer, decorator render.Decorator, rpt report.Report, w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeID           = mux.Vars(r)["id"]
+		preciousRenderer = render.PreciousNodeRenderer{PreciousNodeID: nodeID, Renderer: renderer}
+		rendered         = preciousRenderer.Render(rpt, decorator)
+		root, ok         = rendered[nodeID]
+	)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	subgraph := report.Nodes{nodeID: root}
+	for id, node := range rendered {
+		if root.Adjacency.Contains(id) || node.Adjacency.Contains(nodeID) {
+			subgraph[id] = node
+		}
+	}
+	// Leave out adjacencies to nodes outside the subgraph, so the summaries
+	// agree with the edges.
+	for id, node := range subgraph {
+		adjacency := report.MakeIDList()
+		for _, dst := range node.Adjacency {
+			if _, ok := subgraph[dst]; ok {
+				adjacency = adjacency.Add(dst)
+			}
+		}
+		node.Adjacency = adjacency
+		subgraph[id] = node
+	}
+
+	result := APISubgraph{
+		Root:  nodeID,
+		Nodes: detailed.Summaries(rpt, subgraph),
+		Edges: topologyEdges(subgraph, topologyEdgeTraffic(rpt, subgraph)),
+	}
+	if containerID, ok := root.Latest.Lookup(docker.ContainerID); ok && root.Topology == report.Container {
+		result.Endpoints = containerEndpoints(rpt, containerID)
+	}
+	respondWith(w, http.StatusOK, result)
+}
+
+// containerEndpoints lists the endpoints attributed to a container, either
+// directly or through the process owning them, together with the endpoints
+// they are connected to, sorted by ID.
+func containerEndpoints(rpt report.Report, containerID string) []string {
+	inContainer := func(n report.Node) bool {
+		if id, ok := n.Latest.Lookup(docker.ContainerID); ok {
+			return id == containerID
+		}
+		pid, ok := n.Latest.Lookup(process.PID)
+		if !ok {
+			return false
+		}
+		hostNodeID, _ := n.Latest.Lookup(report.HostNodeID)
+		hostID, _ := report.ParseHostNodeID(hostNodeID)
+		p, ok := rpt.Process.Nodes[report.MakeProcessNodeID(hostID, pid)]
+		if !ok {
+			return false
+		}
+		id, _ := p.Latest.Lookup(docker.ContainerID)
+		return id == containerID
+	}
+
+	own := map[string]struct{}{}
+	for id, n := range rpt.Endpoint.Nodes {
+		if inContainer(n) {
+			own[id] = struct{}{}
+		}
+	}
+	result := map[string]struct{}{}
+	for id, n := range rpt.Endpoint.Nodes {
+		if _, ok := own[id]; ok {
+			result[id] = struct{}{}
+			for _, dst := range n.Adjacency {
+				result[dst] = struct{}{}
+			}
+			continue
+		}
+		for _, dst := range n.Adjacency {
+			if _, ok := own[dst]; ok {
+				result[id] = struct{}{}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(result))
+	for id := range result {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
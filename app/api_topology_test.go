@@ -1,16 +1,32 @@
 package app_test
 
 import (
+	"bytes"
+	"encoding/csv"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -117,6 +133,156 @@ func TestAPITopologyHosts(t *testing.T) {
 	}
 }
 
+func TestAPITopologyTimestamp(t *testing.T) {
+	when := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	mtime.NowForce(when)
+	defer mtime.NowReset()
+
+	rpt := report.MakeReport()
+	rpt.Host = rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID("host1"), map[string]string{
+		host.HostName:     "host1",
+		host.ScopeVersion: "1.0",
+	}))
+	rpt.Timestamp = mtime.Now()
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	body := getRawJSON(t, ts, "/api/topology/hosts")
+	var topo app.APITopology
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	if !topo.Timestamp.Equal(when) {
+		t.Errorf("want timestamp %v, have %v", when, topo.Timestamp)
+	}
+}
+
+func TestAPITopologyEdges(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var topo app.APITopology
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes"), &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	var edges []app.APIEdge
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes?format=edges"), &codec.JsonHandle{}).Decode(&edges); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every adjacency should show up as exactly one edge, whichever way
+	// round it was reported.
+	want := map[[2]string]bool{}
+	for id, node := range topo.Nodes {
+		for _, adj := range node.Adjacency {
+			if _, ok := topo.Nodes[adj]; !ok || adj == id {
+				continue
+			}
+			if adj < id {
+				want[[2]string{adj, id}] = true
+			} else {
+				want[[2]string{id, adj}] = true
+			}
+		}
+	}
+	if len(want) == 0 {
+		t.Fatal("expected the fixture to have some edges")
+	}
+	have := map[[2]string]bool{}
+	for _, edge := range edges {
+		key := [2]string{edge.Source, edge.Target}
+		if have[key] {
+			t.Errorf("duplicate edge %v", key)
+		}
+		have[key] = true
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want edges %v, have %v", want, have)
+	}
+}
+
+func TestAPITopologyDOT(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	res, body := checkGet(t, ts, "/api/topology/processes?format=dot")
+	equals(t, 200, res.StatusCode)
+	equals(t, "text/vnd.graphviz", res.Header.Get("Content-Type"))
+	dot := string(body)
+	for _, want := range []string{
+		`digraph "processes" {`,
+		fmt.Sprintf(`"%s" [label="apache"];`, fixture.ServerProcessNodeID),
+		fmt.Sprintf(`"%s" -> "%s" [label="100 bytes, 1 connection"];`, fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID),
+		fmt.Sprintf(`"%s" -> "%s" [label="1 connection"];`, render.OutgoingInternetID, fixture.NonContainerProcessNodeID),
+		fmt.Sprintf(`"%s" [label="The Internet", shape=box, style=dashed];`, render.IncomingInternetID),
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected DOT output to contain %s, got:\n%s", want, dot)
+		}
+	}
+}
+
+func TestAPITopologyCSV(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	res, body := checkGet(t, ts, "/api/topology/processes?format=csv")
+	equals(t, 200, res.StatusCode)
+	equals(t, "text/csv", res.Header.Get("Content-Type"))
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 {
+		t.Fatal("expected a header")
+	}
+	equals(t, []string{"source", "destination", "bytes_ingress", "bytes_egress", "conn_count"}, rows[0])
+	have := map[[2]string][]string{}
+	for _, row := range rows[1:] {
+		have[[2]string{row[0], row[1]}] = row[2:]
+	}
+	// The bytes are totalled from the endpoint edges in the fixture.
+	for edge, want := range map[[2]string][]string{
+		{fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID}: {"", "100", "1"},
+		{fixture.ClientProcess2NodeID, fixture.ServerProcessNodeID}: {"", "200", "1"},
+		{render.IncomingInternetID, fixture.ServerProcessNodeID}:    {"", "600", "1"},
+	} {
+		if !reflect.DeepEqual(want, have[edge]) {
+			t.Errorf("%s -> %s: want %v, have %v", edge[0], edge[1], want, have[edge])
+		}
+	}
+}
+
+func TestAPITopologyMatrix(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var matrix app.APIMatrix
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes?format=matrix"), &codec.JsonHandle{}).Decode(&matrix); err != nil {
+		t.Fatal(err)
+	}
+	if !sort.StringsAreSorted(matrix.Nodes) {
+		t.Errorf("expected the nodes in order, got %v", matrix.Nodes)
+	}
+	index := map[string]int{}
+	for i, id := range matrix.Nodes {
+		index[id] = i
+	}
+	equals(t, len(matrix.Nodes), len(matrix.Weights))
+	for _, row := range matrix.Weights {
+		equals(t, len(matrix.Nodes), len(row))
+	}
+	client, server := index[fixture.ClientProcess1NodeID], index[fixture.ServerProcessNodeID]
+	if weight := matrix.Weights[client][server]; weight == nil || *weight != 100 {
+		t.Errorf("expected 100 bytes from the client process to the server process, got %v", weight)
+	}
+	if weight := matrix.Weights[server][client]; weight != nil {
+		t.Errorf("expected no connections from the server process to the client process, got %v", *weight)
+	}
+}
+
 // Basic websocket test
 func TestAPITopologyWebsocket(t *testing.T) {
 	ts := topologyServer()
@@ -152,4 +318,593 @@ func TestAPITopologyWebsocket(t *testing.T) {
 	equals(t, 0, len(d.Remove))
 }
 
+func TestAPITopologyWebsocketResume(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	url := "ws" + ts.URL[len("http"):] + "/api/topology/processes/ws"
+
+	type update struct {
+		Add    []detailed.NodeSummary `json:"add"`
+		Update []detailed.NodeSummary `json:"update"`
+		Remove []string               `json:"remove"`
+		Reset  bool                   `json:"reset"`
+		Resume string                 `json:"resume"`
+	}
+	first := func(url string) update {
+		ws, _, err := (&websocket.Dialer{}).Dial(url, nil)
+		ok(t, err)
+		defer ws.Close()
+		_, p, err := ws.ReadMessage()
+		ok(t, err)
+		var u update
+		if err := codec.NewDecoderBytes(p, &codec.JsonHandle{}).Decode(&u); err != nil {
+			t.Fatalf("JSON parse error: %s", err)
+		}
+		return u
+	}
+
+	full := first(url)
+	if !full.Reset || len(full.Add) != 6 || full.Resume == "" {
+		t.Fatalf("expected a full topology and a resume token, got %+v", full)
+	}
+
+	// Reconnecting with the token only sends what changed since: nothing.
+	delta := first(url + "?resume=" + full.Resume)
+	if delta.Reset || len(delta.Add) != 0 || len(delta.Update) != 0 || len(delta.Remove) != 0 {
+		t.Errorf("expected an empty delta, got %+v", delta)
+	}
+
+	// Without the topology for the token, the client starts again.
+	unknown := first(url + "?resume=unknown")
+	if !unknown.Reset || len(unknown.Add) != 6 {
+		t.Errorf("expected a full topology, got %+v", unknown)
+	}
+}
+
 func newu64(value uint64) *uint64 { return &value }
+
+func TestAPITopologyHighlight(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var topo app.APITopology
+	path := "/api/topology/containers?highlight=" + url.QueryEscape(docker.ContainerName+"="+fixture.ServerContainerName)
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, path), &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.Nodes) < 2 {
+		t.Fatalf("expected unmatched nodes to be kept, got %v", topo.Nodes)
+	}
+	for id, node := range topo.Nodes {
+		if want := id == fixture.ServerContainerNodeID; node.Highlighted != want {
+			t.Errorf("expected %s highlighted=%v", id, want)
+		}
+	}
+
+	res, _ := checkGet(t, ts, "/api/topology/containers?highlight=memory")
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request for invalid expression, got %d", res.StatusCode)
+	}
+}
+
+func TestAPITopologyComponent(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var topo app.APITopology
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/containers?component="), &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	server, ok := topo.Nodes[fixture.ServerContainerNodeID]
+	if !ok || server.Component == "" {
+		t.Fatalf("expected server container to be in a component, got %v", topo.Nodes)
+	}
+
+	var filtered app.APITopology
+	path := "/api/topology/containers?component=" + url.QueryEscape(server.Component)
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, path), &codec.JsonHandle{}).Decode(&filtered); err != nil {
+		t.Fatal(err)
+	}
+	for id, node := range filtered.Nodes {
+		if node.Component != server.Component {
+			t.Errorf("expected %s in component %s, got %s", id, server.Component, node.Component)
+		}
+	}
+	if _, ok := filtered.Nodes[fixture.ServerContainerNodeID]; !ok {
+		t.Errorf("expected server container to be kept, got %v", filtered.Nodes)
+	}
+}
+
+func TestAPITopologyFilterExpression(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var topo app.APITopology
+	expr := fmt.Sprintf("(%s=%s OR %s=%s) AND %s=%s",
+		docker.ContainerName, fixture.ServerContainerName,
+		docker.ContainerName, "nonexistent",
+		docker.ImageID, fixture.ServerContainerImageID)
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/containers?filter="+url.QueryEscape(expr)), &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	for id, node := range topo.Nodes {
+		if id != fixture.ServerContainerNodeID && !node.Pseudo {
+			t.Errorf("unexpected node %s", id)
+		}
+	}
+	if _, ok := topo.Nodes[fixture.ServerContainerNodeID]; !ok {
+		t.Errorf("expected %s to match", fixture.ServerContainerNodeID)
+	}
+
+	res, body := checkGet(t, ts, "/api/topology/containers?filter="+url.QueryEscape("memory>1 AND"))
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request for invalid expression, got %d", res.StatusCode)
+	}
+	if !strings.Contains(string(body), "position 12") {
+		t.Errorf("expected the error position in %q", body)
+	}
+}
+
+func TestAPITopologyPorts(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	// Only the random client connects from port 12345, from the internet to
+	// the server.
+	var topo app.APITopology
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes?ports=12345"), &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for id := range topo.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	// The internet nodes are kept whatever their ports, but only with the
+	// edges on them.
+	equals(t, []string{render.IncomingInternetID, render.OutgoingInternetID, fixture.ServerProcessNodeID}, ids)
+	equals(t, report.MakeIDList(fixture.ServerProcessNodeID), topo.Nodes[render.IncomingInternetID].Adjacency)
+	equals(t, report.MakeIDList(), topo.Nodes[render.OutgoingInternetID].Adjacency)
+
+	for _, ports := range []string{"http", "9000-8000", "80,"} {
+		res, body := checkGet(t, ts, "/api/topology/processes?ports="+url.QueryEscape(ports))
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: expected bad request, got %d", ports, res.StatusCode)
+		}
+		if !strings.Contains(string(body), "invalid port range") {
+			t.Errorf("%q: expected the error in %q", ports, body)
+		}
+	}
+}
+
+func TestAPITopologyEphemeral(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	nodeIDs := func(path string) []string {
+		var topo app.APITopology
+		if err := codec.NewDecoderBytes(getRawJSON(t, ts, path), &codec.JsonHandle{}).Decode(&topo); err != nil {
+			t.Fatal(err)
+		}
+		// The internet nodes are kept by ?ports= whatever their ports.
+		ids := []string{}
+		for id, node := range topo.Nodes {
+			if !node.Pseudo {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// curl connects from port 54001, which is ephemeral, so once collapsed
+	// no endpoint is left on it.
+	equals(t, []string{fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID}, nodeIDs("/api/topology/processes?ports=54001"))
+	equals(t, []string{}, nodeIDs("/api/topology/processes?ports=54001&ephemeral=true"))
+	equals(t, []string{fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID}, nodeIDs("/api/topology/processes?ports=54001&ephemeral=false"))
+	equals(t, []string{fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID}, nodeIDs("/api/topology/processes?ports=54001&ephemeral=40000-50000"))
+
+	// Collapsing doesn't change which processes are connected.
+	equals(t, nodeIDs("/api/topology/processes"), nodeIDs("/api/topology/processes?ephemeral=true"))
+
+	for _, ephemeral := range []string{"some", "60999-32768", "32768-40000,50000-60999"} {
+		is400(t, ts, "/api/topology/processes?ephemeral="+url.QueryEscape(ephemeral))
+	}
+}
+
+func TestAPITopologyCollapse(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var topo app.APITopology
+	body := getRawJSON(t, ts, "/api/topology/containers?collapse=pseudo")
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topo); err != nil {
+		t.Fatal(err)
+	}
+	for id, node := range topo.Nodes {
+		if node.Pseudo && id != render.OthersPseudoID && id != render.IncomingInternetID && id != render.OutgoingInternetID {
+			t.Errorf("expected %s to be collapsed", id)
+		}
+	}
+
+	is400(t, ts, "/api/topology/containers?collapse=everything")
+}
+
+func TestAPITopologyETag(t *testing.T) {
+	mtime.NowForce(fixture.Now)
+	defer mtime.NowReset()
+
+	c := app.NewCollector(time.Minute)
+	c.Add(context.Background(), fixture.Report, nil)
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	get := func(etag string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+"/api/topology/containers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	res := get("")
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d with %q", res.StatusCode, etag)
+	}
+	// The body has the report timestamp, which the ETag leaves out.
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("expected a weak ETag, got %s", etag)
+	}
+	if res = get(etag); res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for a repeated request, got %d", res.StatusCode)
+	}
+	if res = get(strings.TrimPrefix(etag, "W/")); res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for the strong form of the ETag, got %d", res.StatusCode)
+	}
+
+	// A metadata-only change makes for a new ETag.
+	mtime.NowForce(fixture.Now.Add(time.Second))
+	rpt := report.MakeReport()
+	rpt.Container.AddNode(report.MakeNodeWith(fixture.ServerContainerNodeID, map[string]string{
+		docker.ContainerStateHuman: "Restarting",
+	}))
+	c.Add(context.Background(), rpt, nil)
+
+	res = get(etag)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 after a new report, got %d", res.StatusCode)
+	}
+	if newETag := res.Header.Get("ETag"); newETag == etag {
+		t.Errorf("expected the ETag to change, still %s", newETag)
+	}
+}
+
+func TestAPITopologySubgraph(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is404(t, ts, "/api/topology/containers/"+url.QueryEscape("foo;<container>")+"/subgraph")
+
+	body := getRawJSON(t, ts, "/api/topology/containers/"+url.QueryEscape(fixture.ServerContainerNodeID)+"/subgraph")
+	var subgraph app.APISubgraph
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&subgraph); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+
+	// The server container talks to the client container, and is reached from
+	// the internet; the internet stays a pseudo node.
+	equals(t, fixture.ServerContainerNodeID, subgraph.Root)
+	ids := []string{}
+	for id := range subgraph.Nodes {
+		ids = append(ids, id)
+	}
+	want := []string{fixture.ClientContainerNodeID, render.IncomingInternetID, fixture.ServerContainerNodeID}
+	sort.Strings(ids)
+	sort.Strings(want)
+	equals(t, want, ids)
+	equals(t, true, subgraph.Nodes[render.IncomingInternetID].Pseudo)
+	equals(t, 2, len(subgraph.Edges))
+	for _, edge := range subgraph.Edges {
+		if edge.Source != fixture.ServerContainerNodeID && edge.Target != fixture.ServerContainerNodeID {
+			t.Errorf("unexpected edge %v", edge)
+		}
+	}
+
+	// Its endpoints, and those they're connected to, across the endpoint topology.
+	for _, id := range []string{fixture.Server80NodeID, fixture.Client54001NodeID, fixture.RandomClientNodeID} {
+		if i := sort.SearchStrings(subgraph.Endpoints, id); i == len(subgraph.Endpoints) || subgraph.Endpoints[i] != id {
+			t.Errorf("expected endpoint %s in %v", id, subgraph.Endpoints)
+		}
+	}
+}
+
+func TestAPITopologyEdge(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client.WithEdge(fixture.Server80NodeID, report.EdgeMetadata{
+		ConnectionSamples: []report.ConnectionSample{{LocalPort: 54001, RemotePort: 80, State: "ESTABLISHED"}},
+	})
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	edgeURL := func(source, target string) string {
+		return "/api/topology/containers/" + url.QueryEscape(source) + "/edge/" + url.QueryEscape(target)
+	}
+	is404(t, ts, edgeURL(fixture.ClientContainerNodeID, "foo;<container>"))
+	is404(t, ts, edgeURL(fixture.ClientContainerNodeID, fixture.ClientContainerNodeID))
+
+	// The edge is reported the same way round whichever end is asked for,
+	// with the metadata of the endpoint edges behind it.
+	for _, c := range []struct {
+		source, target  string
+		egress, ingress *uint64
+		sample          report.ConnectionSample
+	}{
+		{fixture.ClientContainerNodeID, fixture.ServerContainerNodeID, newu64(300), nil, report.ConnectionSample{LocalPort: 54001, RemotePort: 80, State: "ESTABLISHED"}},
+		{fixture.ServerContainerNodeID, fixture.ClientContainerNodeID, nil, newu64(300), report.ConnectionSample{LocalPort: 80, RemotePort: 54001, State: "ESTABLISHED"}},
+	} {
+		var edge app.APIEdge
+		if err := codec.NewDecoderBytes(getRawJSON(t, ts, edgeURL(c.source, c.target)), &codec.JsonHandle{}).Decode(&edge); err != nil {
+			t.Fatalf("JSON parse error: %s", err)
+		}
+		equals(t, c.source, edge.Source)
+		equals(t, c.target, edge.Target)
+		equals(t, c.egress, edge.Metadata.EgressByteCount)
+		equals(t, c.ingress, edge.Metadata.IngressByteCount)
+		equals(t, []report.ConnectionSample{c.sample}, edge.Metadata.ConnectionSamples)
+	}
+}
+
+func TestAPITopologyTop(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	top := func(query string) []app.APITopNode {
+		var result []app.APITopNode
+		if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes/top?"+query), &codec.JsonHandle{}).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for _, tc := range []struct {
+		query     string
+		limit     int
+		ascending bool
+		first     string
+		value     uint64
+	}{
+		// The server process is at the far end of every client's traffic.
+		{"by=bytes&limit=3", 3, false, fixture.ServerProcessNodeID, 2100},
+		{"by=connections&limit=2", 2, false, fixture.ServerProcessNodeID, 6},
+		{"by=bytes&order=asc&limit=1", 1, true, "", 0},
+	} {
+		result := top(tc.query)
+		if len(result) != tc.limit {
+			t.Fatalf("%s: expected %d nodes, got %v", tc.query, tc.limit, result)
+		}
+		if tc.first != "" && result[0].Node.ID != tc.first {
+			t.Errorf("%s: expected %s first, got %v", tc.query, tc.first, result)
+		}
+		if result[0].Value != tc.value {
+			t.Errorf("%s: expected %d first, got %v", tc.query, tc.value, result)
+		}
+		for i := 1; i < len(result); i++ {
+			if inOrder := result[i-1].Value <= result[i].Value; result[i-1].Value != result[i].Value && inOrder != tc.ascending {
+				t.Errorf("%s: out of order: %v", tc.query, result)
+			}
+		}
+	}
+
+	if all := top("limit=100"); len(all) < 3 {
+		t.Errorf("expected all nodes, got %v", all)
+	}
+	for _, query := range []string{"by=memory", "limit=-1", "order=up"} {
+		if res, _ := checkGet(t, ts, "/api/topology/processes/top?"+query); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected bad request, got %d", query, res.StatusCode)
+		}
+	}
+}
+
+func TestAPITopologyDeterministic(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	// Map iteration order is random, so a few tries are enough to catch
+	// nodes being serialized in map order.
+	want := getRawJSON(t, ts, "/api/topology/processes")
+	for i := 0; i < 10; i++ {
+		if have := getRawJSON(t, ts, "/api/topology/processes"); string(have) != string(want) {
+			t.Fatalf("serialization differs between identical requests:\n%s\nvs\n%s", want, have)
+		}
+	}
+}
+
+func TestAPITopologySeries(t *testing.T) {
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer mtime.NowReset()
+
+	c := app.NewCollector(time.Hour)
+	for _, ago := range []time.Duration{
+		50 * time.Minute,
+		30*time.Minute + 30*time.Second,
+		30*time.Minute + 20*time.Second, // same minute as the one before
+		5 * time.Minute,
+	} {
+		mtime.NowForce(now.Add(-ago))
+		if err := c.Add(context.Background(), fixture.Report, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime.NowForce(now)
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	path := fmt.Sprintf("/api/topology/processes/%s/series?metric=bytes&range=1h", url.QueryEscape(fixture.ServerProcessNodeID))
+	var series []app.APISeriesPoint
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, path), &codec.JsonHandle{}).Decode(&series); err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 60 {
+		t.Fatalf("expected a point a minute, got %d", len(series))
+	}
+	want := map[int]uint64{
+		10: 2100,
+		29: 4200, // two reports merged
+		55: 2100,
+	}
+	for i, point := range series {
+		if !point.Timestamp.Equal(now.Add(-time.Hour + time.Duration(i)*time.Minute)) {
+			t.Errorf("point %d: unexpected timestamp %v", i, point.Timestamp)
+		}
+		value, ok := want[i]
+		switch {
+		case !ok && point.Value != nil:
+			t.Errorf("point %d: expected a gap, got %d", i, *point.Value)
+		case ok && (point.Value == nil || *point.Value != value):
+			t.Errorf("point %d: expected %d, got %v", i, value, point.Value)
+		}
+	}
+
+	is404(t, ts, "/api/topology/foo/bar/series")
+	is400(t, ts, "/api/topology/processes/foo/series?metric=foo")
+	is400(t, ts, "/api/topology/processes/foo/series?range=foo")
+}
+
+// countingAttributor counts the calls to its collector's Attribution.
+type countingAttributor struct {
+	app.Collector
+	calls int32
+}
+
+func (c *countingAttributor) Attribution(ctx context.Context) report.Attribution {
+	atomic.AddInt32(&c.calls, 1)
+	return c.Collector.(app.Attributor).Attribution(ctx)
+}
+
+func TestAPITopologyNodeAttribution(t *testing.T) {
+	c := &countingAttributor{Collector: app.NewCollector(time.Minute)}
+	for _, hostID := range []string{"probe2", "probe1"} {
+		rpt := fixture.Report.Copy()
+		rpt.HostID = hostID
+		if err := c.Add(context.Background(), rpt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, c)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	path := "/api/topology/processes/" + url.QueryEscape(fixture.ServerProcessNodeID)
+	for query, want := range map[string][]string{
+		"":                  nil,
+		"?attribution=true": {"probe1", "probe2"},
+	} {
+		var node app.APINode
+		if err := codec.NewDecoderBytes(getRawJSON(t, ts, path+query), &codec.JsonHandle{}).Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, node.ReportedBy) {
+			t.Errorf("%q: want reported by %v, got %v", query, want, node.ReportedBy)
+		}
+	}
+
+	// The attribution is worked out once per request, however many nodes
+	// are asked for.
+	atomic.StoreInt32(&c.calls, 0)
+	var body []byte
+	if err := codec.NewEncoderBytes(&body, &codec.JsonHandle{}).Encode(app.APINodesRequest{
+		IDs: []string{fixture.ServerProcessNodeID, fixture.ClientProcess1NodeID, fixture.ClientProcess2NodeID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Post(ts.URL+"/api/topology/processes/nodes?attribution=true", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var nodes app.APINodes
+	if err := codec.NewDecoder(res.Body, &codec.JsonHandle{}).Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	for id, node := range nodes.Nodes {
+		if want := []string{"probe1", "probe2"}; !reflect.DeepEqual(want, node.ReportedBy) {
+			t.Errorf("%s: want reported by %v, got %v", id, want, node.ReportedBy)
+		}
+	}
+	if calls := atomic.LoadInt32(&c.calls); calls != 1 {
+		t.Errorf("expected the attribution to be worked out once, got %d times", calls)
+	}
+}
+
+func TestAPITopologyNodes(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var (
+		known   = []string{fixture.ServerProcessNodeID, fixture.ClientProcess1NodeID}
+		unknown = "unknown;process"
+		body    []byte
+	)
+	if err := codec.NewEncoderBytes(&body, &codec.JsonHandle{}).Encode(app.APINodesRequest{
+		IDs: []string{known[0], unknown, known[1], known[0]},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Post(ts.URL+"/api/topology/processes/nodes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK, got %d", res.StatusCode)
+	}
+	var nodes app.APINodes
+	if err := codec.NewDecoder(res.Body, &codec.JsonHandle{}).Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes.Nodes) != len(known) {
+		t.Errorf("expected %d nodes, got %v", len(known), nodes.Nodes)
+	}
+	for _, id := range known {
+		// Each node has the same details as when asked for on its own.
+		var node app.APINode
+		if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/topology/processes/"+url.QueryEscape(id)), &codec.JsonHandle{}).Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		if have, ok := nodes.Nodes[id]; !ok {
+			t.Errorf("expected %s in the results", id)
+		} else if !reflect.DeepEqual(node, have) {
+			t.Errorf("%s: want %v, have %v", id, node, have)
+		}
+	}
+	if want := []string{unknown}; !reflect.DeepEqual(want, nodes.NotFound) {
+		t.Errorf("want not found %v, have %v", want, nodes.NotFound)
+	}
+
+	res, err = http.Post(ts.URL+"/api/topology/processes/nodes", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request for an invalid body, got %d", res.StatusCode)
+	}
+}
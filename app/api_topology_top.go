@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// topByParam selects what the top nodes are ranked by: "bytes" or
+	// "connections".
+	topByParam    = "by"
+	topLimitParam = "limit"
+	// topOrderParam is "desc" (the default) for the busiest nodes first, or
+	// "asc" for the quietest.
+	topOrderParam = "order"
+
+	defaultTopLimit = 10
+)
+
+// APITopNode is returned, in a list, by the /api/topology/{name}/top handler.
+type APITopNode struct {
+	Node  detailed.NodeSummary `json:"node"`
+	Value uint64               `json:"value"`
+}
+
+// nodeTraffic is the traffic through a rendered node's endpoints.
+type nodeTraffic struct {
+	bytes, connections uint64
+}
+
+// trafficValue returns the function picking the named value out of a node's
+// traffic: "bytes", the default, or "connections".
+func trafficValue(name string) (func(nodeTraffic) uint64, bool) {
+	switch name {
+	case "", "bytes":
+		return func(t nodeTraffic) uint64 { return t.bytes }, true
+	case "connections":
+		return func(t nodeTraffic) uint64 { return t.connections }, true
+	}
+	return nil, false
+}
+
+// Top nodes of a topology by traffic, so clients don't have to fetch and
+// sort the whole topology to find them.
+func handleTop(ctx context.Context, renderer render.Renderer, decorator render.Decorator, rpt report.Report, w http.ResponseWriter, r *http.Request) {
+	value, ok := trafficValue(r.Form.Get(topByParam))
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", topByParam, r.Form.Get(topByParam)))
+		return
+	}
+	limit := defaultTopLimit
+	if s := r.Form.Get(topLimitParam); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", topLimitParam, s))
+			return
+		}
+		limit = n
+	}
+	var ascending bool
+	switch order := r.Form.Get(topOrderParam); order {
+	case "", "desc":
+	case "asc":
+		ascending = true
+	default:
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %q", topOrderParam, order))
+		return
+	}
+
+	var (
+		nodes     = renderer.Render(rpt, decorator)
+		traffic   = topologyTraffic(rpt, nodes)
+		summaries = detailed.Summaries(rpt, nodes)
+		result    = make(topNodes, 0, len(summaries))
+	)
+	for id, summary := range summaries {
+		result = append(result, APITopNode{Node: summary, Value: value(traffic[id])})
+	}
+	sort.Sort(result)
+	if !ascending {
+		// Reverse the values but keep ties in ID order.
+		sort.Stable(sort.Reverse(byValue{result}))
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	respondWith(w, http.StatusOK, result)
+}
+
+// topologyTraffic totals the edge metadata of the endpoints making up each
+// rendered node. The rendered nodes don't carry edge metadata themselves, so
+// each endpoint edge in the report is counted against the nodes holding
+// either end of it, once per node. Edges without connection counts count as
+// a single connection.
+func topologyTraffic(rpt report.Report, nodes report.Nodes) map[string]nodeTraffic {
+	result := map[string]nodeTraffic{}
+	render.WalkEndpointEdges(rpt, nodes, func(_, _ string, md report.EdgeMetadata, srcOwners, dstOwners []string) {
+		bytes, _ := edgeBytes(md)
+		connections := edgeConnections(md)
+		counted := map[string]struct{}{}
+		for _, id := range append(append([]string{}, srcOwners...), dstOwners...) {
+			if _, ok := counted[id]; ok {
+				continue
+			}
+			counted[id] = struct{}{}
+			t := result[id]
+			t.bytes += bytes
+			t.connections += connections
+			result[id] = t
+		}
+	})
+	return result
+}
+
+func edgeConnections(md report.EdgeMetadata) uint64 {
+	if md.TCPConnections == nil && md.UDPFlows == nil {
+		return 1
+	}
+	var total uint64
+	if md.TCPConnections != nil {
+		total += *md.TCPConnections
+	}
+	if md.UDPFlows != nil {
+		total += *md.UDPFlows
+	}
+	return total
+}
+
+// topNodes sorts by value, then by node ID.
+type topNodes []APITopNode
+
+func (t topNodes) Len() int      { return len(t) }
+func (t topNodes) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t topNodes) Less(i, j int) bool {
+	if t[i].Value != t[j].Value {
+		return t[i].Value < t[j].Value
+	}
+	return t[i].Node.ID < t[j].Node.ID
+}
+
+// byValue sorts by value alone.
+type byValue struct{ topNodes }
+
+func (b byValue) Less(i, j int) bool { return b.topNodes[i].Value < b.topNodes[j].Value }
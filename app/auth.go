@@ -0,0 +1,87 @@
+package app
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	authRealm       = "scope"
+	apiPathPrefix   = "/api"
+	bearerPrefix    = "Bearer "
+	probeAuthPrefix = "Scope-Probe token="
+)
+
+// AuthConfig describes the (optional) authentication required to access the
+// app. Authentication is disabled unless a token or a username is set.
+type AuthConfig struct {
+	// Token is accepted as a bearer token ("Authorization: Bearer <token>").
+	// Probes present it as "Authorization: Scope-Probe token=<token>", so the
+	// same value can be passed to them with --probe.token.
+	Token string
+
+	// Username and Password enable HTTP basic authentication.
+	Username string
+	Password string
+
+	// GateStatic also requires authentication for everything outside /api,
+	// i.e. the static UI assets.
+	GateStatic bool
+}
+
+// Enabled returns true if any authentication method is configured.
+func (c AuthConfig) Enabled() bool {
+	return c.Token != "" || c.Username != ""
+}
+
+// Wrap implements middleware.Interface
+func (c AuthConfig) Wrap(next http.Handler) http.Handler {
+	if !c.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (c.GateStatic || isAPIPath(r.URL.Path)) && !c.authorized(r) {
+			c.challenge(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAPIPath(path string) bool {
+	return path == apiPathPrefix || strings.HasPrefix(path, apiPathPrefix+"/")
+}
+
+func (c AuthConfig) authorized(r *http.Request) bool {
+	if c.Username != "" {
+		if username, password, ok := r.BasicAuth(); ok &&
+			secureCompare(username, c.Username) && secureCompare(password, c.Password) {
+			return true
+		}
+	}
+	if c.Token != "" {
+		header := r.Header.Get("Authorization")
+		for _, prefix := range []string{bearerPrefix, probeAuthPrefix} {
+			if strings.HasPrefix(header, prefix) && secureCompare(strings.TrimPrefix(header, prefix), c.Token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c AuthConfig) challenge(w http.ResponseWriter) {
+	if c.Username != "" {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", authRealm))
+	}
+	if c.Token != "" {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", authRealm))
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
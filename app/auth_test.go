@@ -0,0 +1,93 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func authServer(config app.AuthConfig) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return httptest.NewServer(config.Wrap(handler))
+}
+
+func authGet(t *testing.T, ts *httptest.Server, path string, setAuth func(*http.Request)) *http.Response {
+	req, err := http.NewRequest("GET", ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setAuth != nil {
+		setAuth(req)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestAuthDisabled(t *testing.T) {
+	ts := authServer(app.AuthConfig{})
+	defer ts.Close()
+
+	for _, path := range []string{"/api", "/api/topology", "/"} {
+		res := authGet(t, ts, path, nil)
+		equals(t, http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	ts := authServer(app.AuthConfig{Token: "secret"})
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/api/topology", "", http.StatusUnauthorized},
+		{"/api/topology", "Bearer wrong", http.StatusUnauthorized},
+		{"/api/topology", "Bearer secret", http.StatusOK},
+		{"/api/report", "Scope-Probe token=secret", http.StatusOK},
+		{"/api/report", "Scope-Probe token=wrong", http.StatusUnauthorized},
+		{"/", "", http.StatusOK},
+		{"/apiary", "", http.StatusOK},
+	} {
+		res := authGet(t, ts, tc.path, func(r *http.Request) {
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+		})
+		if res.StatusCode != tc.want {
+			t.Errorf("%s with %q: want %d, have %d", tc.path, tc.header, tc.want, res.StatusCode)
+		}
+		if tc.want == http.StatusUnauthorized {
+			equals(t, `Bearer realm="scope"`, res.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAuthBasic(t *testing.T) {
+	ts := authServer(app.AuthConfig{Username: "user", Password: "pass", GateStatic: true})
+	defer ts.Close()
+
+	res := authGet(t, ts, "/api/topology", nil)
+	equals(t, http.StatusUnauthorized, res.StatusCode)
+	equals(t, `Basic realm="scope"`, res.Header.Get("WWW-Authenticate"))
+
+	res = authGet(t, ts, "/", nil)
+	equals(t, http.StatusUnauthorized, res.StatusCode)
+
+	res = authGet(t, ts, "/api/topology", func(r *http.Request) { r.SetBasicAuth("user", "wrong") })
+	equals(t, http.StatusUnauthorized, res.StatusCode)
+
+	for _, path := range []string{"/api/topology", "/"} {
+		res = authGet(t, ts, path, func(r *http.Request) { r.SetBasicAuth("user", "pass") })
+		equals(t, http.StatusOK, res.StatusCode)
+	}
+}
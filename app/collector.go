@@ -3,18 +3,24 @@ package app
 import (
 	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/logging"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -28,6 +34,42 @@ import (
 // as soon as there is more than one probe.
 const reportQuantisationInterval = 3 * time.Second
 
+// downsamplingTiers bound the memory used by long windows. Reports older
+// than a tier's age are merged into buckets of its resolution (see
+// downsample). Reports younger than the first tier keep their full
+// resolution.
+var downsamplingTiers = []struct {
+	age, resolution time.Duration
+}{
+	{time.Minute, time.Minute},
+	{time.Hour, time.Hour},
+}
+
+var reportMergeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "scope",
+	Subsystem: "app",
+	Name:      "report_merge_duration_seconds",
+	Help:      "Time in seconds spent merging the collected reports.",
+	Buckets:   prometheus.DefBuckets,
+})
+
+func init() {
+	prometheus.MustRegister(reportMergeDuration)
+}
+
+// MaxClockSkew is how far a probe's clock may be from the app's before the
+// probe is flagged, and the times in its reports corrected. The skew is
+// measured as a report arrives, so it includes the time taken to send it.
+const MaxClockSkew = 10 * time.Second
+
+// skewLog warns about probes with skewed clocks, without repeating itself on
+// every report.
+var skewLog = logging.NewRateLimited(time.Minute)
+
+// ProbeGracePeriod is how long the collector keeps listing a probe after it
+// has stopped reporting, beyond the window.
+const ProbeGracePeriod = 5 * time.Minute
+
 // Reporter is something that can produce reports on demand. It's a convenient
 // interface for parts of the app, and several experimental components.
 type Reporter interface {
@@ -46,6 +88,56 @@ type Adder interface {
 	Add(context.Context, report.Report, []byte) error
 }
 
+// HostIndex is something which knows when each probe last reported, by the
+// HostID of its reports.
+type HostIndex interface {
+	HostTimestamps(context.Context) map[string]time.Time
+	Hosts(context.Context) []HostStatus
+	ProbeReport(ctx context.Context, hostID string) (report.Report, bool)
+}
+
+// Receiver is something which knows when it last received a report, which
+// for reports from probes which predate Report.Timestamp is the only way to
+// tell how fresh they are.
+type Receiver interface {
+	LastReceived(context.Context) time.Time
+}
+
+// ReportHistory is something which keeps the reports it was given, as they
+// were given, along with when.
+type ReportHistory interface {
+	ReportsSince(ctx context.Context, since time.Time) ([]time.Time, []report.Report)
+}
+
+// Attributor is something which knows which probes reported each node.
+type Attributor interface {
+	Attribution(context.Context) report.Attribution
+}
+
+// HostStatus describes the reports received from a single probe host.
+// Inactive hosts haven't reported within the window, but are still within
+// the ProbeGracePeriod. ClockSkew is how far ahead of the app's clock the
+// probe's was, going by its last report, and ClockSkewed whether that is
+// more than MaxClockSkew.
+type HostStatus struct {
+	HostID      string
+	Hostname    string
+	Version     string
+	LastSeen    time.Time
+	Reports     int
+	Active      bool
+	ClockSkew   time.Duration
+	ClockSkewed bool
+}
+
+type hostRecord struct {
+	hostname  string
+	lastSeen  time.Time
+	reports   int
+	clockSkew time.Duration
+	latest    report.Report // as received, bar clock correction, before any merging
+}
+
 // A Collector is a Reporter and an Adder
 type Collector interface {
 	Reporter
@@ -61,9 +153,18 @@ type collector struct {
 	window     time.Duration
 	cached     *report.Report
 	merger     Merger
+	hosts      map[string]*hostRecord // by HostID
+	store      Store                  // may be nil
+	expired    time.Time              // when the store was last expired
+	received   time.Time              // when the last report was added
 	waitableCondition
 }
 
+// storeExpireInterval is how often reports past the window are expired from
+// the store. Expiring them can mean listing a directory, so it isn't done on
+// every Add.
+const storeExpireInterval = 10 * time.Second
+
 type waitableCondition struct {
 	sync.Mutex
 	waiters map[chan struct{}]struct{}
@@ -101,22 +202,110 @@ func NewCollector(window time.Duration) Collector {
 			waiters: map[chan struct{}]struct{}{},
 		},
 		merger: NewSmartMerger(),
+		hosts:  map[string]*hostRecord{},
+	}
+}
+
+// NewCollectorWithStore returns a collector which also keeps every report
+// in the given store, as it was added, for the window. The collector starts
+// off with the reports already in the store, so if it outlives the app, so
+// does the history.
+func NewCollectorWithStore(window time.Duration, store Store) (Collector, error) {
+	c := NewCollector(window).(*collector)
+	now := mtime.Now()
+	timestamps, reports, err := store.Range(context.Background(), now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+	for i, rpt := range reports {
+		c.add(timestamps[i], rpt, clockSkew(timestamps[i], rpt))
 	}
+	c.store = store
+	return c, nil
 }
 
 // Add adds a report to the collector's internal state. It implements Adder.
-func (c *collector) Add(_ context.Context, rpt report.Report, _ []byte) error {
+// The times in reports from probes whose clocks are more than MaxClockSkew
+// off are corrected first.
+func (c *collector) Add(ctx context.Context, rpt report.Report, _ []byte) error {
+	now := mtime.Now()
+	skew := clockSkew(now, rpt)
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		skewLog.Warnf("Clock on probe %s is %v out; correcting the times in its reports", rpt.HostID, skew)
+		rpt = report.ShiftTime(rpt, -skew)
+	}
+	if c.store != nil {
+		if err := c.store.Add(ctx, now, rpt); err != nil {
+			return err
+		}
+		if err := c.expireStore(ctx, now); err != nil {
+			return err
+		}
+	}
+	c.add(now, rpt, skew)
+	if rpt.Shortcut {
+		c.Broadcast()
+	}
+	return nil
+}
+
+// expireStore expires the reports past the window from the store, unless
+// that was done less than storeExpireInterval ago.
+func (c *collector) expireStore(ctx context.Context, now time.Time) error {
+	c.mtx.Lock()
+	due := now.Sub(c.expired) >= storeExpireInterval
+	if due {
+		c.expired = now
+	}
+	c.mtx.Unlock()
+	if !due {
+		return nil
+	}
+	return c.store.Expire(ctx, now.Add(-c.window))
+}
+
+// clockSkew is how far ahead of now a report's timestamp is, or zero for
+// reports from probes which predate Timestamp.
+func clockSkew(now time.Time, rpt report.Report) time.Duration {
+	if rpt.Timestamp.IsZero() {
+		return 0
+	}
+	return rpt.Timestamp.Sub(now)
+}
+
+func (c *collector) add(now time.Time, rpt report.Report, skew time.Duration) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 	c.reports = append(c.reports, rpt)
-	c.timestamps = append(c.timestamps, mtime.Now())
+	c.timestamps = append(c.timestamps, now)
+	c.received = now
+	if rpt.HostID != "" {
+		// Reports from probes which predate Timestamp count as current.
+		ts := rpt.Timestamp
+		if ts.IsZero() {
+			ts = now
+		}
+		record, ok := c.hosts[rpt.HostID]
+		if !ok {
+			record = &hostRecord{}
+			c.hosts[rpt.HostID] = record
+		}
+		record.reports++
+		record.clockSkew = skew
+		if ts.After(record.lastSeen) {
+			record.lastSeen = ts
+		}
+		record.latest = rpt
+		if n, ok := rpt.Host.Nodes[report.MakeHostNodeID(rpt.HostID)]; ok {
+			if hostname, ok := n.Latest.Lookup(host.HostName); ok {
+				record.hostname = hostname
+			}
+		}
+	}
 
 	c.clean()
+	c.downsample()
 	c.cached = nil
-	if rpt.Shortcut {
-		c.Broadcast()
-	}
-	return nil
 }
 
 // Report returns a merged report over all added reports. It implements
@@ -134,14 +323,140 @@ func (c *collector) Report(_ context.Context) (report.Report, error) {
 		}
 	}
 
+	begin := time.Now()
 	c.clean()
 	c.quantise()
 
 	rpt := c.merger.Merge(c.reports).Upgrade()
+	reportMergeDuration.Observe(time.Since(begin).Seconds())
 	c.cached = &rpt
 	return rpt, nil
 }
 
+// LastReceived returns when the last report was added. It implements
+// Receiver.
+func (c *collector) LastReceived(_ context.Context) time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.received
+}
+
+// HostTimestamps returns the timestamp of the latest report from each host
+// which has reported within the window. It implements HostIndex.
+func (c *collector) HostTimestamps(_ context.Context) map[string]time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	var (
+		result = make(map[string]time.Time, len(c.hosts))
+		oldest = mtime.Now().Add(-c.window)
+	)
+	for hostID, record := range c.hosts {
+		if record.lastSeen.After(oldest) {
+			result[hostID] = record.lastSeen
+		}
+	}
+	return result
+}
+
+// Hosts returns the status of every host which has reported within the
+// window plus the ProbeGracePeriod, sorted by HostID. It implements
+// HostIndex.
+func (c *collector) Hosts(_ context.Context) []HostStatus {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	var (
+		result = make([]HostStatus, 0, len(c.hosts))
+		oldest = mtime.Now().Add(-c.window)
+	)
+	for hostID, record := range c.hosts {
+		result = append(result, HostStatus{
+			HostID:      hostID,
+			Hostname:    record.hostname,
+			Version:     record.latest.ProbeVersion,
+			LastSeen:    record.lastSeen,
+			Reports:     record.reports,
+			Active:      record.lastSeen.After(oldest),
+			ClockSkew:   record.clockSkew,
+			ClockSkewed: record.clockSkew > MaxClockSkew || record.clockSkew < -MaxClockSkew,
+		})
+	}
+	sort.Sort(hostsByID(result))
+	return result
+}
+
+// ProbeReport returns the last report received from the given host, as it
+// was received, for as long as the host is listed by Hosts. It implements
+// HostIndex.
+func (c *collector) ProbeReport(_ context.Context, hostID string) (report.Report, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	record, ok := c.hosts[hostID]
+	if !ok {
+		return report.Report{}, false
+	}
+	return record.latest, true
+}
+
+// Attribution returns which of the probes which have reported within the
+// window reported each node, going by the last report from each. It
+// implements Attributor.
+func (c *collector) Attribution(_ context.Context) report.Attribution {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	var (
+		result = report.Attribution{}
+		oldest = mtime.Now().Add(-c.window)
+	)
+	for _, record := range c.hosts {
+		if record.lastSeen.After(oldest) {
+			result.Add(record.latest)
+		}
+	}
+	return result
+}
+
+// ReportsSince returns the reports added after since which are still within
+// the window, oldest first, along with when they were added. Unless they
+// come from the store, older reports will have been downsampled, so there
+// are fewer of them. It implements ReportHistory.
+func (c *collector) ReportsSince(ctx context.Context, since time.Time) ([]time.Time, []report.Report) {
+	if c.store != nil {
+		now := mtime.Now()
+		if oldest := now.Add(-c.window); since.Before(oldest) {
+			since = oldest
+		}
+		timestamps, reports, err := c.store.Range(ctx, since, now)
+		if err == nil {
+			return timestamps, reports
+		}
+		log.Warnf("Error reading reports from store: %v", err)
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	var (
+		timestamps []time.Time
+		reports    []report.Report
+	)
+	for i, ts := range c.timestamps {
+		if ts.After(since) {
+			timestamps = append(timestamps, ts)
+			reports = append(reports, c.reports[i])
+		}
+	}
+	return timestamps, reports
+}
+
+type hostsByID []HostStatus
+
+func (h hostsByID) Len() int           { return len(h) }
+func (h hostsByID) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h hostsByID) Less(i, j int) bool { return h[i].HostID < h[j].HostID }
+
 // remove reports older than the app.window
 func (c *collector) clean() {
 	var (
@@ -157,36 +472,88 @@ func (c *collector) clean() {
 	}
 	c.reports = cleanedReports
 	c.timestamps = cleanedTimestamps
+	forgotten := oldest.Add(-ProbeGracePeriod)
+	for hostID, record := range c.hosts {
+		if !record.lastSeen.After(forgotten) {
+			delete(c.hosts, hostID)
+		}
+	}
+}
+
+// Merge the reports of each downsampling tier into buckets of its
+// resolution. Like quantise, a bucket starts with the oldest report not yet
+// in one, and takes its timestamp, so the oldest bucket never reaches back
+// past the start of the window, and expires as a whole when its first report
+// would have.
+func (c *collector) downsample() {
+	var (
+		now                   = mtime.Now()
+		downsampledReports    = make([]report.Report, 0, len(c.reports))
+		downsampledTimestamps = make([]time.Time, 0, len(c.timestamps))
+	)
+	for i := 0; i < len(c.reports); {
+		resolution := downsamplingResolution(now.Sub(c.timestamps[i]))
+		j := i + 1
+		for j < len(c.reports) && downsamplingResolution(now.Sub(c.timestamps[j])) == resolution {
+			j++
+		}
+		reports, timestamps := c.reports[i:j], c.timestamps[i:j]
+		if resolution > 0 {
+			reports, timestamps = quantise(c.merger, reports, timestamps, resolution)
+		}
+		downsampledReports = append(downsampledReports, reports...)
+		downsampledTimestamps = append(downsampledTimestamps, timestamps...)
+		i = j
+	}
+	c.reports = downsampledReports
+	c.timestamps = downsampledTimestamps
+}
+
+// downsamplingResolution returns the bucket size for reports of the given
+// age, or zero if they should be kept as they are.
+func downsamplingResolution(age time.Duration) time.Duration {
+	var resolution time.Duration
+	for _, tier := range downsamplingTiers {
+		if age >= tier.age {
+			resolution = tier.resolution
+		}
+	}
+	return resolution
 }
 
 // Merge reports received within the same reportQuantisationInterval.
+func (c *collector) quantise() {
+	c.reports, c.timestamps = quantise(c.merger, c.reports, c.timestamps, reportQuantisationInterval)
+}
+
+// quantise merges reports received within the same interval.
 //
 // Quantisation is relative to the time of the first report in a given
-// interval, rather than absolute time. So, for example, with a
-// reportQuantisationInterval of 3s and reports with timestamps [0, 1,
-// 2, 5, 6, 7], the result contains merged reports with
-// timestamps/content of [0:{0,1,2}, 5:{5,6,7}].
-func (c *collector) quantise() {
-	if len(c.reports) == 0 {
-		return
+// interval, rather than absolute time. So, for example, with an interval of
+// 3s and reports with timestamps [0, 1, 2, 5, 6, 7], the result contains
+// merged reports with timestamps/content of [0:{0,1,2}, 5:{5,6,7}].
+func quantise(merger Merger, reports []report.Report, timestamps []time.Time, interval time.Duration) ([]report.Report, []time.Time) {
+	if len(reports) == 0 {
+		return reports, timestamps
 	}
 	var (
-		quantisedReports    = make([]report.Report, 0, len(c.reports))
-		quantisedTimestamps = make([]time.Time, 0, len(c.timestamps))
+		quantisedReports    = make([]report.Report, 0, len(reports))
+		quantisedTimestamps = make([]time.Time, 0, len(timestamps))
 	)
 	quantumStartIdx := 0
-	quantumStartTimestamp := c.timestamps[0]
-	for i, t := range c.timestamps {
-		if t.Sub(quantumStartTimestamp) < reportQuantisationInterval {
+	quantumStartTimestamp := timestamps[0]
+	for i, t := range timestamps {
+		if t.Sub(quantumStartTimestamp) < interval {
 			continue
 		}
-		quantisedReports = append(quantisedReports, c.merger.Merge(c.reports[quantumStartIdx:i]))
+		quantisedReports = append(quantisedReports, merger.Merge(reports[quantumStartIdx:i]))
 		quantisedTimestamps = append(quantisedTimestamps, quantumStartTimestamp)
 		quantumStartIdx = i
 		quantumStartTimestamp = t
 	}
-	c.reports = append(quantisedReports, c.merger.Merge(c.reports[quantumStartIdx:]))
-	c.timestamps = append(quantisedTimestamps, c.timestamps[quantumStartIdx])
+	quantisedReports = append(quantisedReports, merger.Merge(reports[quantumStartIdx:]))
+	quantisedTimestamps = append(quantisedTimestamps, quantumStartTimestamp)
+	return quantisedReports, quantisedTimestamps
 }
 
 // StaticCollector always returns the given report.
@@ -336,3 +703,54 @@ func replay(a Adder, timestamps []time.Time, reports []report.Report) {
 		}
 	}
 }
+
+// NewStreamCollector reads the newline-delimited JSON reports in the file at
+// path, as written by the probe's report file sink, and feeds them to a new
+// collector in order. If realtime is set, reports are added with the same
+// spacing as their embedded timestamps; otherwise they are all added as fast
+// as possible. Either way the file is only replayed once, so reports age out
+// of the collector's window as usual.
+func NewStreamCollector(path string, window time.Duration, realtime bool) (Collector, error) {
+	timestamps, reports, err := readStream(path)
+	if err != nil {
+		return nil, err
+	}
+	collector := NewCollector(window)
+	go replayStream(collector, timestamps, reports, realtime)
+	return collector, nil
+}
+
+func readStream(path string) ([]time.Time, []report.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var (
+		timestamps []time.Time
+		reports    []report.Report
+		r          = report.NewStreamReader(f)
+	)
+	for {
+		ts, rpt, err := r.Read()
+		if err == io.EOF {
+			return timestamps, reports, nil
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		timestamps = append(timestamps, ts)
+		reports = append(reports, rpt)
+	}
+}
+
+func replayStream(a Adder, timestamps []time.Time, reports []report.Report, realtime bool) {
+	for i, r := range reports {
+		if realtime && i > 0 {
+			if delay := timestamps[i].Sub(timestamps[i-1]); delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+		a.Add(nil, r, nil)
+	}
+}
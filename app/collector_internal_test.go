@@ -0,0 +1,77 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCollectorDownsample(t *testing.T) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	c := NewCollector(3 * time.Hour).(*collector)
+
+	// A report every 10s for two hours, each counting one connection.
+	const interval, n = 10 * time.Second, 720
+	for i := 0; i < n; i++ {
+		mtime.NowForce(start.Add(time.Duration(i) * interval))
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNode("host").WithCounters(map[string]int{"connections": 1}))
+		c.Add(ctx, rpt, nil)
+	}
+
+	// Now is 13:59:50. The last minute is kept as is, everything from 13:00
+	// to 13:58 in minute buckets, and everything before 13:00 in a single
+	// hour bucket.
+	if want, have := 6+59+1, len(c.reports); want != have {
+		t.Fatalf("want %d reports, have %d: %v", want, have, c.timestamps)
+	}
+	connections := func(rpt report.Report) int {
+		count, _ := rpt.Host.Nodes["host"].Counters.Lookup("connections")
+		return count
+	}
+	if want, have := 360, connections(c.reports[0]); want != have {
+		t.Errorf("hour bucket: want %d connections, have %d", want, have)
+	}
+	if want, have := start, c.timestamps[0]; !want.Equal(have) {
+		t.Errorf("hour bucket: want timestamp %v, have %v", want, have)
+	}
+	for i := 1; i < 60; i++ {
+		if want, have := 6, connections(c.reports[i]); want != have {
+			t.Errorf("minute bucket %v: want %d connections, have %d", c.timestamps[i], want, have)
+		}
+	}
+	for i := 60; i < len(c.reports); i++ {
+		if want, have := 1, connections(c.reports[i]); want != have {
+			t.Errorf("report %v: want %d connections, have %d", c.timestamps[i], want, have)
+		}
+	}
+
+	rpt, err := c.Report(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := n, connections(rpt); want != have {
+		t.Errorf("merged report: want %d connections, have %d", want, have)
+	}
+
+	// Once the window no longer reaches back to the start of the hour
+	// bucket, the whole bucket goes, rather than outliving the window.
+	mtime.NowForce(start.Add(3*time.Hour + time.Second))
+	c.Add(ctx, report.MakeReport(), nil)
+	oldest := mtime.Now().Add(-3 * time.Hour)
+	for i, ts := range c.timestamps {
+		if !ts.After(oldest) {
+			t.Errorf("bucket %d starts before the window, at %v", i, ts)
+		}
+	}
+	if rpt, _ := c.Report(ctx); connections(rpt) != n-360 {
+		t.Errorf("expected the hour bucket to have expired, have %d connections", connections(rpt))
+	}
+}
@@ -1,6 +1,8 @@
 package app_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -113,3 +115,134 @@ func TestCollectorWait(t *testing.T) {
 		t.Fatal("Didn't unblock")
 	}
 }
+
+func TestStreamCollector(t *testing.T) {
+	f, err := ioutil.TempFile("", "scope-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	w := report.NewStreamWriter(f)
+	now := time.Now()
+	for i, id := range []string{"foo", "bar", "baz"} {
+		rpt := report.MakeReport()
+		rpt.Endpoint.AddNode(report.MakeNode(id))
+		if err := w.Write(now.Add(time.Duration(i)*time.Hour), rpt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	// Replaying as fast as possible ignores the hour-long gaps.
+	c, err := app.NewStreamCollector(f.Name(), time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rpt, err := c.Report(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rpt.Endpoint.Nodes) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for replayed reports, have %v", rpt.Endpoint.Nodes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCollectorHostTimestamps(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	c := app.NewCollector(window)
+
+	add := func(hostID string, ts time.Time) {
+		rpt := report.MakeReport()
+		rpt.HostID, rpt.Timestamp = hostID, ts
+		c.Add(ctx, rpt, nil)
+	}
+	add("host-a", now.Add(-2*time.Second))
+	add("host-a", now.Add(-time.Second))
+	add("host-b", time.Time{})
+	add("", now)
+
+	hosts := c.(app.HostIndex)
+	want := map[string]time.Time{
+		"host-a": now.Add(-time.Second),
+		"host-b": now,
+	}
+	if have := hosts.HostTimestamps(ctx); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	// Hosts which haven't reported within the window are forgotten.
+	mtime.NowForce(now.Add(window))
+	add("host-b", now.Add(window))
+	want = map[string]time.Time{"host-b": now.Add(window)}
+	if have := hosts.HostTimestamps(ctx); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestCollectorClockSkew(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	c := app.NewCollector(time.Minute)
+
+	// Both probes saw a connection start a minute ago, but the clock on
+	// host-b is an hour fast.
+	add := func(hostID string, skew time.Duration) {
+		mtime.NowForce(now.Add(skew)) // as on the probe
+		rpt := report.MakeReport()
+		rpt.HostID, rpt.Timestamp = hostID, now.Add(skew)
+		rpt.Endpoint.AddNode(report.MakeNode(hostID+";1.2.3.4;80").
+			WithLatests(map[string]string{"pid": "1"}).
+			WithEdge("dst", report.EdgeMetadata{FirstSeen: now.Add(skew - time.Minute)}))
+		mtime.NowForce(now)
+		c.Add(ctx, rpt, nil)
+	}
+	add("host-a", time.Second)
+	add("host-b", time.Hour)
+
+	hosts := c.(app.HostIndex).Hosts(ctx)
+	if len(hosts) != 2 {
+		t.Fatalf("expected two hosts, got %v", hosts)
+	}
+	for i, want := range []struct {
+		skew   time.Duration
+		skewed bool
+	}{{time.Second, false}, {time.Hour, true}} {
+		if hosts[i].ClockSkew != want.skew || hosts[i].ClockSkewed != want.skewed {
+			t.Errorf("%s: want skew %v (%v), have %v (%v)", hosts[i].HostID, want.skew, want.skewed, hosts[i].ClockSkew, hosts[i].ClockSkewed)
+		}
+	}
+
+	// The times in host-b's report are brought into line with the app's
+	// clock; host-a's are left as they are.
+	rpt, err := c.Report(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const tolerance = 2 * time.Second
+	for _, hostID := range []string{"host-a", "host-b"} {
+		node := rpt.Endpoint.Nodes[hostID+";1.2.3.4;80"]
+		edge, _ := node.Edges.Lookup("dst")
+		if d := edge.FirstSeen.Sub(now.Add(-time.Minute)); d < -tolerance || d > tolerance {
+			t.Errorf("%s: expected the connection first seen a minute ago, got %v", hostID, edge.FirstSeen)
+		}
+		if _, ts, _ := node.Latest.LookupEntry("pid"); ts.Sub(now) < -tolerance || ts.Sub(now) > tolerance {
+			t.Errorf("%s: expected the latest values timestamped now, got %v", hostID, ts)
+		}
+	}
+}
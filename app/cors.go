@@ -0,0 +1,123 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CORSConfig describes which cross-origin requests are allowed to the API.
+// The zero value only allows same-origin requests.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins (e.g. "https://dash.example.com")
+	// allowed to make cross-origin requests; "*" allows any origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+}
+
+// Default CORS methods and headers, used when none are configured.
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "DELETE"}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type"}
+)
+
+// Wrap implements middleware.Interface. It answers preflight requests for
+// /api paths, adds CORS headers for allowed origins, and rejects every
+// request from disallowed origins, as browsers still send simple requests
+// and websocket upgrades cross-origin, only hiding the response.
+func (c CORSConfig) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !isAPIPath(r.URL.Path) || sameOrigin(origin, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.allowsOrigin(origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Only explicitly listed origins may make credentialed requests; any
+		// other origin allowed by "*" gets the literal wildcard, which
+		// browsers never combine with cookies or auth headers.
+		if c.listsOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		w.Header().Add("Vary", "Origin")
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.allowsMethod(r.Header.Get("Access-Control-Request-Method")) {
+			http.Error(w, "method not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.methods(), ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.headers(), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return c.listsOrigin(origin)
+}
+
+// listsOrigin is true if the origin is explicitly listed, rather than only
+// allowed by "*".
+func (c CORSConfig) listsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) allowsMethod(method string) bool {
+	for _, allowed := range c.methods() {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) methods() []string {
+	if len(c.AllowedMethods) == 0 {
+		return DefaultCORSMethods
+	}
+	return c.AllowedMethods
+}
+
+func (c CORSConfig) headers() []string {
+	if len(c.AllowedHeaders) == 0 {
+		return DefaultCORSHeaders
+	}
+	return c.AllowedHeaders
+}
+
+// sameOrigin compares the host of the Origin header with the host the request
+// was addressed to, taking reverse proxies into account.
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	forwarded := r.Header.Get("X-Forwarded-Host")
+	return forwarded != "" && strings.EqualFold(u.Host, forwarded)
+}
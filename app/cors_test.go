@@ -0,0 +1,98 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func corsRequest(t *testing.T, ts *httptest.Server, method, path, origin string, header http.Header) *http.Response {
+	req, err := http.NewRequest(method, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Origin", origin)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestCORSPreflight(t *testing.T) {
+	ts := httptest.NewServer(app.CORSConfig{
+		AllowedOrigins: []string{"http://dash.example.com"},
+	}.Wrap(http.NotFoundHandler()))
+	defer ts.Close()
+
+	preflight := http.Header{"Access-Control-Request-Method": []string{"GET"}}
+	res := corsRequest(t, ts, "OPTIONS", "/api/topology", "http://dash.example.com", preflight)
+	equals(t, http.StatusNoContent, res.StatusCode)
+	equals(t, "http://dash.example.com", res.Header.Get("Access-Control-Allow-Origin"))
+	equals(t, "GET, POST, DELETE", res.Header.Get("Access-Control-Allow-Methods"))
+	equals(t, "Authorization, Content-Type", res.Header.Get("Access-Control-Allow-Headers"))
+
+	preflight = http.Header{"Access-Control-Request-Method": []string{"PUT"}}
+	res = corsRequest(t, ts, "OPTIONS", "/api/topology", "http://dash.example.com", preflight)
+	equals(t, http.StatusForbidden, res.StatusCode)
+
+	// Actual requests from an allowed origin get the CORS headers too.
+	res = corsRequest(t, ts, "GET", "/api/topology", "http://dash.example.com", nil)
+	equals(t, http.StatusNotFound, res.StatusCode)
+	equals(t, "http://dash.example.com", res.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	ts := httptest.NewServer(app.CORSConfig{}.Wrap(http.NotFoundHandler()))
+	defer ts.Close()
+
+	preflight := http.Header{"Access-Control-Request-Method": []string{"GET"}}
+	res := corsRequest(t, ts, "OPTIONS", "/api/topology", "http://evil.example.com", preflight)
+	equals(t, http.StatusForbidden, res.StatusCode)
+	equals(t, "", res.Header.Get("Access-Control-Allow-Origin"))
+
+	// Simple requests are sent by browsers without a preflight, so they
+	// must be rejected too.
+	for _, method := range []string{"GET", "POST", "DELETE"} {
+		res = corsRequest(t, ts, method, "/api/topology", "http://evil.example.com", nil)
+		equals(t, http.StatusForbidden, res.StatusCode)
+		equals(t, "", res.Header.Get("Access-Control-Allow-Origin"))
+	}
+
+	upgrade := http.Header{"Upgrade": []string{"websocket"}, "Connection": []string{"Upgrade"}}
+	res = corsRequest(t, ts, "GET", "/api/topology/hosts/ws", "http://evil.example.com", upgrade)
+	equals(t, http.StatusForbidden, res.StatusCode)
+
+	// Same-origin websockets are still allowed through.
+	res = corsRequest(t, ts, "GET", "/api/topology/hosts/ws", ts.URL, upgrade)
+	equals(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	ts := httptest.NewServer(app.CORSConfig{
+		AllowedOrigins: []string{"*", "http://dash.example.com"},
+	}.Wrap(http.NotFoundHandler()))
+	defer ts.Close()
+
+	// Origins only allowed by the wildcard never get credentials.
+	preflight := http.Header{"Access-Control-Request-Method": []string{"GET"}}
+	res := corsRequest(t, ts, "OPTIONS", "/api/topology", "http://other.example.com", preflight)
+	equals(t, http.StatusNoContent, res.StatusCode)
+	equals(t, "*", res.Header.Get("Access-Control-Allow-Origin"))
+	equals(t, "", res.Header.Get("Access-Control-Allow-Credentials"))
+
+	res = corsRequest(t, ts, "GET", "/api/topology", "http://other.example.com", nil)
+	equals(t, "*", res.Header.Get("Access-Control-Allow-Origin"))
+	equals(t, "", res.Header.Get("Access-Control-Allow-Credentials"))
+
+	// Explicitly listed origins still do.
+	res = corsRequest(t, ts, "GET", "/api/topology", "http://dash.example.com", nil)
+	equals(t, "http://dash.example.com", res.Header.Get("Access-Control-Allow-Origin"))
+	equals(t, "true", res.Header.Get("Access-Control-Allow-Credentials"))
+}
@@ -9,14 +9,17 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/ghost/handlers"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/common/hostname"
+	"github.com/weaveworks/scope/common/logging"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/report"
 )
@@ -27,8 +30,26 @@ var (
 
 	// UniqueID - set at runtime.
 	UniqueID = "0"
+
+	renderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "scope",
+		Subsystem: "app",
+		Name:      "render_duration_seconds",
+		Help:      "Time in seconds spent rendering and serving a topology.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topology"})
 )
 
+func init() {
+	prometheus.MustRegister(renderDuration)
+}
+
+// RegisterMetricsRoute exposes the registered Prometheus metrics at /metrics.
+// It must be registered before any catch-all routes.
+func RegisterMetricsRoute(router *mux.Router) {
+	router.Methods("GET").Path("/metrics").Handler(prometheus.Handler())
+}
+
 // contextKey is a wrapper type for use in context.WithValue() to satisfy golint
 // https://github.com/golang/go/issues/17293
 // https://github.com/golang/lint/pull/245
@@ -104,16 +125,44 @@ func RegisterTopologyRoutes(router *mux.Router, r Reporter) {
 		HandleFunc("/api/topology/{topology}/ws",
 			requestContextDecorator(captureReporter(r, handleWebsocket))). // NB not gzip!
 		Name("api_topology_topology_ws")
+	get.
+		HandleFunc("/api/topology/{topology}/top",
+			gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleTop)))).
+		Name("api_topology_topology_top")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/series")).HandlerFunc(
+		gzipHandler(requestContextDecorator(captureReporter(r, handleSeries)))).
+		Name("api_topology_topology_id_series")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/subgraph")).HandlerFunc(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleSubgraph)))).
+		Name("api_topology_topology_id_subgraph")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/edge/{target}")).HandlerFunc(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleEdge)))).
+		Name("api_topology_topology_id_edge")
 	get.
 		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).HandlerFunc(
-		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNode)))).
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNode(r))))).
 		Name("api_topology_topology_id")
+	router.Methods("POST").
+		Path("/api/topology/{topology}/nodes").
+		HandlerFunc(gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNodes(r))))).
+		Name("api_topology_topology_nodes")
 	get.HandleFunc("/api/report",
 		gzipHandler(requestContextDecorator(makeRawReportHandler(r))))
+	get.HandleFunc("/api/report/raw",
+		gzipHandler(requestContextDecorator(makeProbeReportHandler(r))))
 	get.HandleFunc("/api/probes",
 		gzipHandler(requestContextDecorator(makeProbeHandler(r))))
+	get.HandleFunc("/api/health",
+		gzipHandler(requestContextDecorator(makeHealthHandler(r))))
 }
 
+// versionLog warns about probes publishing reports in an incompatible
+// format, without repeating itself on every report.
+var versionLog = logging.NewRateLimited(time.Minute)
+
 // RegisterReportPostHandler registers the handler for report submission
 func RegisterReportPostHandler(a Adder, router *mux.Router) {
 	post := router.Methods("POST").Subrouter()
@@ -131,24 +180,30 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 
 		contentType := r.Header.Get("Content-Type")
 		isMsgpack := strings.HasPrefix(contentType, "application/msgpack")
-		var handle codec.Handle
+		var err error
 		switch {
 		case strings.HasPrefix(contentType, "application/json"):
-			handle = &codec.JsonHandle{}
+			err = rpt.ReadBinary(reader, gzipped, &codec.JsonHandle{})
 		case isMsgpack:
-			handle = &codec.MsgpackHandle{}
+			err = rpt.ReadBinary(reader, gzipped, &codec.MsgpackHandle{})
+		case strings.HasPrefix(contentType, report.ProtobufContentType):
+			err = rpt.ReadProto(reader, gzipped)
 		default:
 			respondWith(w, http.StatusBadRequest, fmt.Errorf("Unsupported Content-Type: %v", contentType))
 			return
 		}
-
-		if err := rpt.ReadBinary(reader, gzipped, handle); err != nil {
+		if err != nil {
 			respondWith(w, http.StatusBadRequest, err)
 			return
 		}
+		if err := rpt.CheckSchemaVersion(); err != nil {
+			versionLog.Warnf("%v", err)
+		}
+		upgraded := rpt.SchemaVersion < report.SchemaVersion
+		rpt = rpt.UpgradeSchema()
 
-		// a.Add(..., buf) assumes buf is gzip'd msgpack
-		if !isMsgpack {
+		// a.Add(..., buf) assumes buf is gzip'd msgpack of rpt
+		if !isMsgpack || upgraded {
 			buf = bytes.Buffer{}
 			rpt.WriteBinary(&buf, gzip.BestCompression)
 		}
@@ -2,6 +2,7 @@ package app_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -10,11 +11,14 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -93,4 +97,77 @@ func TestReportPostHandler(t *testing.T) {
 		err := codec.NewEncoder(buf, &codec.MsgpackHandle{}).Encode(v)
 		return buf.Bytes(), err
 	})
+	test(report.ProtobufContentType, func(v interface{}) ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := v.(report.Report).WriteProto(buf, gzip.NoCompression)
+		return buf.Bytes(), err
+	})
+}
+
+func TestReportPostHandlerUpgradesSchema(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// A report from a probe which wrote the endpoint keys unnamespaced.
+	rpt := report.MakeReport()
+	rpt.SchemaVersion = 1
+	rpt.Endpoint.AddNode(report.MakeNodeWith(fixture.Client54001NodeID, map[string]string{
+		"addr": fixture.ClientIP,
+		"port": fixture.ClientPort54001,
+	}))
+	buf := &bytes.Buffer{}
+	if err := codec.NewEncoder(buf, &codec.MsgpackHandle{}).Encode(rpt); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL+"/api/report", "application/msgpack", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	equals(t, http.StatusOK, resp.StatusCode)
+
+	have, err := c.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := have.Endpoint.Nodes[fixture.Client54001NodeID]
+	if addr, _ := node.Latest.Lookup(endpoint.Addr); addr != fixture.ClientIP {
+		t.Errorf("expected the address under %s, got %v", endpoint.Addr, node.Latest)
+	}
+	equals(t, report.SchemaVersion, have.SchemaVersion)
+}
+
+func TestMetricsRoute(t *testing.T) {
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterMetricsRoute(router)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(fixture.Report))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	getRawJSON(t, ts, "/api/topology/containers")
+	c := app.NewCollector(time.Minute)
+	c.Add(context.Background(), fixture.Report, nil)
+	c.Report(context.Background())
+
+	res, body := checkGet(t, ts, "/metrics")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.StatusCode, body)
+	}
+	for _, name := range []string{
+		`scope_app_render_duration_seconds_count{topology="containers"}`,
+		"scope_app_report_merge_duration_seconds_count",
+	} {
+		if !bytes.Contains(body, []byte(name)) {
+			t.Errorf("expected %s in %s", name, body)
+		}
+	}
+
+	// The probe's metrics are only reported once observed, but should be
+	// registered already.
+	if err := prometheus.Register(endpoint.SpyDuration); err == nil {
+		t.Error("expected the spy duration to be registered already")
+	}
 }
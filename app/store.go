@@ -0,0 +1,245 @@
+package app
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Store keeps the reports added to a collector, as they were added, by when.
+type Store interface {
+	// Add stores a report added at the given time.
+	Add(ctx context.Context, ts time.Time, rpt report.Report) error
+	// Get returns the latest report added at or before the given time.
+	Get(ctx context.Context, ts time.Time) (report.Report, bool, error)
+	// Range returns the reports added after from and up to to, oldest
+	// first, along with when they were added.
+	Range(ctx context.Context, from, to time.Time) ([]time.Time, []report.Report, error)
+	// Expire drops the reports added at or before the given time.
+	Expire(ctx context.Context, before time.Time) error
+}
+
+// NewStore makes the store named by the given URL: "memory" for one which
+// keeps the reports in memory, or file:///path for one which keeps them in
+// the given directory, where they outlive the app. Either keeps every report
+// as it was added, on top of the collector's own downsampled copies.
+func NewStore(storeURL string) (Store, error) {
+	if storeURL == "memory" {
+		return NewMemoryStore(), nil
+	}
+	if strings.HasPrefix(storeURL, "file://") {
+		return NewDiskStore(strings.TrimPrefix(storeURL, "file://"))
+	}
+	return nil, fmt.Errorf("Invalid store '%s'", storeURL)
+}
+
+type memoryStore struct {
+	mtx        sync.Mutex
+	timestamps []time.Time
+	reports    []report.Report
+}
+
+// NewMemoryStore makes a Store which keeps the reports in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Add(_ context.Context, ts time.Time, rpt report.Report) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	// Reports are almost always added in order, so find the place from the end.
+	i := len(s.timestamps)
+	for i > 0 && s.timestamps[i-1].After(ts) {
+		i--
+	}
+	s.timestamps = append(s.timestamps, time.Time{})
+	s.reports = append(s.reports, report.Report{})
+	copy(s.timestamps[i+1:], s.timestamps[i:])
+	copy(s.reports[i+1:], s.reports[i:])
+	s.timestamps[i], s.reports[i] = ts, rpt
+	return nil
+}
+
+func (s *memoryStore) Get(_ context.Context, ts time.Time) (report.Report, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	i := sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].After(ts) })
+	if i == 0 {
+		return report.Report{}, false, nil
+	}
+	return s.reports[i-1], true, nil
+}
+
+func (s *memoryStore) Range(_ context.Context, from, to time.Time) ([]time.Time, []report.Report, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var (
+		begin = sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].After(from) })
+		end   = sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].After(to) })
+	)
+	if begin >= end {
+		return nil, nil, nil
+	}
+	timestamps := append([]time.Time(nil), s.timestamps[begin:end]...)
+	reports := append([]report.Report(nil), s.reports[begin:end]...)
+	return timestamps, reports, nil
+}
+
+func (s *memoryStore) Expire(_ context.Context, before time.Time) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	i := sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].After(before) })
+	s.timestamps = append([]time.Time(nil), s.timestamps[i:]...)
+	s.reports = append([]report.Report(nil), s.reports[i:]...)
+	return nil
+}
+
+// diskStoreExt is the extension of the files a diskStore writes, which makes
+// them readable by NewFileCollector too.
+const diskStoreExt = ".msgpack.gz"
+
+type diskStore struct {
+	dir string
+}
+
+// NewDiskStore makes a Store which keeps each report in its own file in dir,
+// named by when it was added, in nanoseconds since the epoch. The reports
+// are written compactly, as they can add up to a lot over a long window.
+func NewDiskStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return diskStore{dir: dir}, nil
+}
+
+func (s diskStore) Add(_ context.Context, ts time.Time, rpt report.Report) error {
+	// Write to a temporary file first, so a crash never leaves a partial
+	// report behind for Range to trip over.
+	f, err := ioutil.TempFile(s.dir, ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := rpt.WriteBinaryCompact(f, gzip.BestCompression); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(ts))
+}
+
+func (s diskStore) Get(_ context.Context, ts time.Time) (report.Report, bool, error) {
+	timestamps, err := s.timestamps()
+	if err != nil {
+		return report.Report{}, false, err
+	}
+	i := sort.Search(len(timestamps), func(i int) bool { return timestamps[i].After(ts) })
+	if i == 0 {
+		return report.Report{}, false, nil
+	}
+	rpt, err := s.read(timestamps[i-1])
+	if err != nil {
+		return report.Report{}, false, err
+	}
+	return rpt, true, nil
+}
+
+func (s diskStore) Range(ctx context.Context, from, to time.Time) ([]time.Time, []report.Report, error) {
+	all, err := s.timestamps()
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		timestamps []time.Time
+		reports    []report.Report
+	)
+	for _, ts := range all {
+		if !ts.After(from) || ts.After(to) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		rpt, err := s.read(ts)
+		if os.IsNotExist(err) {
+			// Expired since we listed the directory.
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		timestamps = append(timestamps, ts)
+		reports = append(reports, rpt)
+	}
+	return timestamps, reports, nil
+}
+
+func (s diskStore) Expire(_ context.Context, before time.Time) error {
+	timestamps, err := s.timestamps()
+	if err != nil {
+		return err
+	}
+	for _, ts := range timestamps {
+		if ts.After(before) {
+			break
+		}
+		if err := os.Remove(s.path(ts)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// timestamps lists when the stored reports were added, oldest first.
+// Files which weren't written by the store are ignored.
+func (s diskStore) timestamps() ([]time.Time, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+diskStoreExt))
+	if err != nil {
+		return nil, err
+	}
+	var timestamps []time.Time
+	for _, name := range names {
+		ts, err := timestampFromFilepath(name)
+		if err != nil {
+			continue
+		}
+		timestamps = append(timestamps, ts)
+	}
+	sort.Sort(timesByValue(timestamps))
+	return timestamps, nil
+}
+
+func (s diskStore) path(ts time.Time) string {
+	return filepath.Join(s.dir, strconv.FormatInt(ts.UnixNano(), 10)+diskStoreExt)
+}
+
+func (s diskStore) read(ts time.Time) (report.Report, error) {
+	f, err := os.Open(s.path(ts))
+	if err != nil {
+		return report.Report{}, err
+	}
+	defer f.Close()
+	rpt, err := report.MakeFromBinary(f)
+	if err != nil {
+		return report.Report{}, err
+	}
+	return *rpt, nil
+}
+
+type timesByValue []time.Time
+
+func (t timesByValue) Len() int           { return len(t) }
+func (t timesByValue) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t timesByValue) Less(i, j int) bool { return t[i].Before(t[j]) }
@@ -0,0 +1,181 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+// storeImplementations are run through the same tests, as every Store must
+// behave the same.
+var storeImplementations = []struct {
+	name string
+	make func(t *testing.T) (app.Store, func())
+}{
+	{"memory", func(*testing.T) (app.Store, func()) {
+		return app.NewMemoryStore(), func() {}
+	}},
+	{"disk", func(t *testing.T) (app.Store, func()) {
+		dir, err := ioutil.TempDir("", "scope-store")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := app.NewDiskStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store, func() { os.RemoveAll(dir) }
+	}},
+}
+
+func storeReport(id string) report.Report {
+	r := report.MakeReport()
+	r.ID = id
+	r.Endpoint.AddNode(report.MakeNode(id))
+	return r
+}
+
+func reportIDs(reports []report.Report) []string {
+	ids := []string{}
+	for _, r := range reports {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestStore(t *testing.T) {
+	var (
+		ctx = context.Background()
+		t0  = time.Unix(1000, 0)
+		t1  = t0.Add(time.Second)
+		t2  = t0.Add(2 * time.Second)
+		t3  = t0.Add(3 * time.Second)
+	)
+	for _, impl := range storeImplementations {
+		store, cleanup := impl.make(t)
+		defer cleanup()
+
+		if _, ok, err := store.Get(ctx, t3); err != nil || ok {
+			t.Errorf("%s: empty store Get: %v, %v", impl.name, ok, err)
+		}
+
+		// Out of order, as reports can be.
+		for _, add := range []struct {
+			ts time.Time
+			id string
+		}{{t0, "a"}, {t2, "c"}, {t1, "b"}} {
+			if err := store.Add(ctx, add.ts, storeReport(add.id)); err != nil {
+				t.Fatalf("%s: %v", impl.name, err)
+			}
+		}
+
+		for _, c := range []struct {
+			ts   time.Time
+			want string
+		}{{t0, "a"}, {t1.Add(time.Millisecond), "b"}, {t3, "c"}} {
+			have, ok, err := store.Get(ctx, c.ts)
+			if err != nil || !ok || have.ID != c.want {
+				t.Errorf("%s: Get(%v): %q, %v, %v, want %q", impl.name, c.ts, have.ID, ok, err, c.want)
+			}
+			if _, ok := have.Endpoint.Nodes[c.want]; !ok {
+				t.Errorf("%s: Get(%v): lost the nodes", impl.name, c.ts)
+			}
+		}
+		if _, ok, err := store.Get(ctx, t0.Add(-time.Millisecond)); err != nil || ok {
+			t.Errorf("%s: Get before the first report: %v, %v", impl.name, ok, err)
+		}
+
+		timestamps, reports, err := store.Range(ctx, t0, t2)
+		if err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		if want, have := []string{"b", "c"}, reportIDs(reports); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: Range: want %v, have %v", impl.name, want, have)
+		}
+		if len(timestamps) != 2 || !timestamps[0].Equal(t1) || !timestamps[1].Equal(t2) {
+			t.Errorf("%s: Range timestamps: %v", impl.name, timestamps)
+		}
+
+		if err := store.Expire(ctx, t1); err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		_, reports, err = store.Range(ctx, time.Time{}, t3)
+		if err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		if want, have := []string{"c"}, reportIDs(reports); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: Range after Expire: want %v, have %v", impl.name, want, have)
+		}
+	}
+}
+
+func storeIDs(ctx context.Context, store app.Store, now time.Time) []string {
+	_, reports, _ := store.Range(ctx, time.Time{}, now.Add(time.Hour))
+	return reportIDs(reports)
+}
+
+func TestCollectorWithStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	for _, impl := range storeImplementations {
+		store, cleanup := impl.make(t)
+		defer cleanup()
+
+		c, err := app.NewCollectorWithStore(time.Minute, store)
+		if err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		c.Add(ctx, storeReport("a"), nil)
+		mtime.NowForce(now.Add(time.Second))
+		c.Add(ctx, storeReport("b"), nil)
+
+		// As if the app had restarted.
+		c, err = app.NewCollectorWithStore(time.Minute, store)
+		if err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		rpt, err := c.Report(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", impl.name, err)
+		}
+		for _, id := range []string{"a", "b"} {
+			if _, ok := rpt.Endpoint.Nodes[id]; !ok {
+				t.Errorf("%s: lost report %q", impl.name, id)
+			}
+		}
+		_, reports := c.(app.ReportHistory).ReportsSince(ctx, time.Time{})
+		if want, have := []string{"a", "b"}, reportIDs(reports); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: ReportsSince: want %v, have %v", impl.name, want, have)
+		}
+
+		// Reports past the window are expired from the store too, but only
+		// every so often.
+		mtime.NowForce(now.Add(time.Minute + time.Second/2))
+		c.Add(ctx, storeReport("c"), nil)
+		if _, reports, _ := store.Range(ctx, time.Time{}, now.Add(time.Hour)); len(reports) != 2 {
+			t.Errorf("%s: want 2 reports kept, have %v", impl.name, reportIDs(reports))
+		}
+		mtime.NowForce(now.Add(time.Minute + 5*time.Second))
+		c.Add(ctx, storeReport("d"), nil)
+		if _, reports, _ := store.Range(ctx, time.Time{}, now.Add(time.Hour)); len(reports) != 3 {
+			t.Errorf("%s: want 3 reports kept until the next expiry, have %v", impl.name, reportIDs(reports))
+		}
+		mtime.NowForce(now.Add(time.Minute + 11*time.Second))
+		c.Add(ctx, storeReport("e"), nil)
+		if want, have := []string{"c", "d", "e"}, storeIDs(ctx, store, now); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: want %v kept, have %v", impl.name, want, have)
+		}
+		mtime.NowForce(now)
+	}
+}
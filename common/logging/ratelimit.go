@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+)
+
+// RateLimited is a logger for loops which may hit the same error over and
+// over, e.g. every report. Each distinct message is logged at most once per
+// window; repeats are counted instead, and logged as a single summary line
+// once the window is over. It is safe for concurrent use.
+type RateLimited struct {
+	// Logger is where messages go; it defaults to the standard logger.
+	Logger *log.Logger
+
+	window   time.Duration
+	mtx      sync.Mutex
+	messages map[message]*occurrences
+}
+
+type message struct {
+	level log.Level
+	text  string
+}
+
+type occurrences struct {
+	since      time.Time
+	suppressed int
+}
+
+// NewRateLimited makes a new RateLimited logger.
+func NewRateLimited(window time.Duration) *RateLimited {
+	return &RateLimited{
+		Logger:   log.StandardLogger(),
+		window:   window,
+		messages: map[message]*occurrences{},
+	}
+}
+
+// Errorf logs a message at error level, unless it was already logged within
+// the window.
+func (r *RateLimited) Errorf(format string, args ...interface{}) {
+	r.logf(log.ErrorLevel, format, args...)
+}
+
+// Warnf logs a message at warning level, unless it was already logged within
+// the window.
+func (r *RateLimited) Warnf(format string, args ...interface{}) {
+	r.logf(log.WarnLevel, format, args...)
+}
+
+// Flush logs the summaries of messages whose window is over. Summaries are
+// also flushed whenever something is logged, so call this periodically if
+// logging may stop altogether.
+func (r *RateLimited) Flush() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.expire(mtime.Now())
+}
+
+func (r *RateLimited) logf(level log.Level, format string, args ...interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	now := mtime.Now()
+	r.expire(now)
+
+	key := message{level: level, text: fmt.Sprintf(format, args...)}
+	if o, ok := r.messages[key]; ok {
+		o.suppressed++
+		return
+	}
+	r.messages[key] = &occurrences{since: now}
+	r.emit(level, key.text)
+}
+
+func (r *RateLimited) expire(now time.Time) {
+	for key, o := range r.messages {
+		if now.Sub(o.since) < r.window {
+			continue
+		}
+		if o.suppressed > 0 {
+			r.emit(key.level, fmt.Sprintf("%s (%d occurrences suppressed)", key.text, o.suppressed))
+		}
+		delete(r.messages, key)
+	}
+}
+
+func (r *RateLimited) emit(level log.Level, text string) {
+	switch level {
+	case log.ErrorLevel:
+		r.Logger.Error(text)
+	default:
+		r.Logger.Warn(text)
+	}
+}
@@ -0,0 +1,54 @@
+package logging_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/common/logging"
+)
+
+func TestRateLimited(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	buf := &bytes.Buffer{}
+	logger := logging.NewRateLimited(time.Minute)
+	logger.Logger = &log.Logger{
+		Out:       buf,
+		Formatter: &log.TextFormatter{DisableTimestamp: true},
+		Level:     log.InfoLevel,
+	}
+	lines := func() []string {
+		return strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.Errorf("conntrack missing: %v", "exec: not found")
+		mtime.NowForce(now.Add(time.Duration(i) * 100 * time.Millisecond))
+	}
+	logger.Flush()
+	if have := lines(); len(have) != 1 || !strings.Contains(have[0], "conntrack missing: exec: not found") {
+		t.Fatalf("expected a single line within the window, got %q", have)
+	}
+
+	mtime.NowForce(now.Add(time.Minute))
+	logger.Flush()
+	have := lines()
+	if len(have) != 2 || !strings.Contains(have[1], "99 occurrences suppressed") {
+		t.Fatalf("expected a summary line after the window, got %q", have)
+	}
+
+	// Nothing more to summarise, and the message can be logged again.
+	logger.Flush()
+	logger.Errorf("conntrack missing: %v", "exec: not found")
+	logger.Warnf("something else")
+	if have := lines(); len(have) != 4 {
+		t.Errorf("expected 4 lines, got %q", have)
+	}
+}
@@ -1,9 +1,11 @@
 package appclient
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/rpc"
 	"net/url"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/armon/go-metrics"
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/ugorji/go/codec"
@@ -22,6 +25,13 @@ const (
 	httpClientTimeout = 4 * time.Second
 	initialBackoff    = 1 * time.Second
 	maxBackoff        = 60 * time.Second
+
+	// DefaultPublishRetries is how many times a failed report publish is
+	// retried by default.
+	DefaultPublishRetries = 3
+
+	// How long Stop waits for queued reports to be published.
+	stopFlushTimeout = 2 * time.Second
 )
 
 // AppClient is a client to an app for dealing with controls.
@@ -54,6 +64,7 @@ type appClient struct {
 
 	// For publish
 	publishLoop sync.Once
+	publishDone chan struct{}
 	readers     chan io.Reader
 
 	// For controls
@@ -77,9 +88,10 @@ func NewAppClient(pc ProbeConfig, hostname string, target url.URL, control xfer.
 			TLSClientConfig:  httpTransport.TLSClientConfig,
 			HandshakeTimeout: httpClientTimeout,
 		},
-		conns:   map[string]xfer.Websocket{},
-		readers: make(chan io.Reader, 2),
-		control: control,
+		conns:       map[string]xfer.Websocket{},
+		publishDone: make(chan struct{}),
+		readers:     make(chan io.Reader, 2),
+		control:     control,
 	}, nil
 }
 
@@ -140,10 +152,22 @@ func (c *appClient) releaseGoroutine() {
 	c.backgroundWait.Done()
 }
 
-// Stop stops the appClient.
+// Stop stops the appClient, after giving it a chance to publish the reports
+// it has queued.
 func (c *appClient) Stop() {
 	c.mtx.Lock()
 	close(c.readers)
+	c.mtx.Unlock()
+
+	// If the publish loop never started there's nothing to wait for.
+	c.publishLoop.Do(func() { close(c.publishDone) })
+	select {
+	case <-c.publishDone:
+	case <-time.After(stopFlushTimeout):
+		log.Warnf("Timed out publishing queued reports to %s", c.hostname)
+	}
+
+	c.mtx.Lock()
 	close(c.quit)
 	for _, conn := range c.conns {
 		conn.Close()
@@ -277,16 +301,61 @@ func (c *appClient) startPublishing() {
 	go func() {
 		log.Infof("Publish loop for %s starting", c.hostname)
 		defer log.Infof("Publish loop for %s exiting", c.hostname)
-		c.doWithBackoff("publish", func() (bool, error) {
-			r := <-c.readers
-			if r == nil {
-				return true, nil
-			}
-			return false, c.publish(r)
-		})
+		defer close(c.publishDone)
+		if !c.retainGoroutine() {
+			return
+		}
+		defer c.releaseGoroutine()
+
+		for r := <-c.readers; r != nil; {
+			r = c.publishWithRetries(r)
+		}
 	}()
 }
 
+// publishWithRetries publishes a report, retrying with backoff if that fails,
+// and returns the next report to publish (nil once we're stopping). A report
+// which arrives while we're waiting to retry supersedes the pending one, so
+// retries never build up behind each other.
+func (c *appClient) publishWithRetries(r io.Reader) io.Reader {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Errorf("Error reading report for %s: %v", c.hostname, err)
+		return <-c.readers
+	}
+
+	bc := c.PublishBackoff.withDefaults()
+	backoff := bc.Initial
+	for attempt := 0; ; attempt++ {
+		err := c.publish(bytes.NewReader(buf))
+		if err == nil {
+			return <-c.readers
+		}
+		if attempt >= bc.Retries {
+			log.Errorf("Error publishing to %s, giving up after %d attempt(s): %v", c.hostname, attempt+1, err)
+			metrics.IncrCounter([]string{"publish", "failures"}, 1)
+			return <-c.readers
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Warnf("Error publishing to %s, retrying in %s: %v", c.hostname, wait, err)
+		select {
+		case next := <-c.readers:
+			if next != nil {
+				log.Warnf("Abandoning retry of report to %s, superseded by a newer one", c.hostname)
+			}
+			return next
+		case <-time.After(wait):
+		case <-c.quit:
+			return nil
+		}
+		backoff *= 2
+		if backoff > bc.Max {
+			backoff = bc.Max
+		}
+	}
+}
+
 // Publish implements Publisher
 func (c *appClient) Publish(r io.Reader) error {
 	// Lazily start the background publishing loop.
@@ -3,11 +3,13 @@ package appclient
 import (
 	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -245,3 +247,60 @@ func TestStop(t *testing.T) {
 	// Let the server go so that the test can end
 	close(stopHanging)
 }
+
+func TestAppClientPublishRetries(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		attempts int
+		done     = make(chan []byte, 1)
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		attempts++
+		n := attempts
+		mtx.Unlock()
+		if n <= 2 {
+			http.Error(w, "flaky", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		done <- body
+	})
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := ProbeConfig{
+		PublishBackoff: BackoffConfig{
+			Initial: time.Millisecond,
+			Max:     5 * time.Millisecond,
+			Retries: 3,
+		},
+	}
+	p, err := NewAppClient(pc, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	if err := p.Publish(strings.NewReader("report")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-done:
+		if string(body) != "report" {
+			t.Errorf("want %q, have %q", "report", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for report to be retried")
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
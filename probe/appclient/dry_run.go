@@ -0,0 +1,41 @@
+package appclient
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// DryRunPublisher is a Publisher which, rather than sending reports to an
+// app, decodes them and writes them to w as indented JSON. As it is handed
+// the same bytes an app would be, it shows what the probe would have sent.
+type DryRunPublisher struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// NewDryRunPublisher makes a DryRunPublisher writing to w.
+func NewDryRunPublisher(w io.Writer) *DryRunPublisher {
+	return &DryRunPublisher{w: w}
+}
+
+// Publish implements Publisher.
+func (p *DryRunPublisher) Publish(r io.Reader) error {
+	rpt, err := report.MakeFromBinary(r)
+	if err != nil {
+		return err
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if err := codec.NewEncoder(p.w, &codec.JsonHandle{Indent: 2}).Encode(rpt); err != nil {
+		return err
+	}
+	_, err = io.WriteString(p.w, "\n")
+	return err
+}
+
+// Stop implements Publisher.
+func (p *DryRunPublisher) Stop() {}
@@ -35,6 +35,36 @@ type ProbeConfig struct {
 	ProbeVersion string
 	ProbeID      string
 	Insecure     bool
+
+	// PublishBackoff controls retrying failed report publishes. The zero
+	// value never retries.
+	PublishBackoff BackoffConfig
+}
+
+// BackoffConfig controls how many times, and how often, a failed report
+// publish is retried. Each retry waits twice as long as the previous one, up
+// to Max, less a random jitter of up to half. Zero durations take the
+// defaults.
+type BackoffConfig struct {
+	Initial time.Duration
+	Max     time.Duration
+	Retries int
+}
+
+func (bc BackoffConfig) withDefaults() BackoffConfig {
+	if bc.Initial <= 0 {
+		bc.Initial = initialBackoff
+	}
+	if bc.Max <= 0 {
+		bc.Max = maxBackoff
+	}
+	if bc.Max < bc.Initial {
+		bc.Max = bc.Initial
+	}
+	if bc.Retries < 0 {
+		bc.Retries = 0
+	}
+	return bc
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
@@ -9,6 +9,7 @@ import (
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 // TaskFamily is the key that stores the task family of an ECS Task
@@ -200,7 +201,7 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 }
 
 // Report needed for Reporter
-func (Reporter) Report() (report.Report, error) {
+func (Reporter) Report(context.Context) (report.Report, error) {
 	result := report.MakeReport()
 	taskTopology := report.MakeTopology().WithMetadataTemplates(taskMetadata)
 	result.ECSTask = result.ECSTask.Merge(taskTopology)
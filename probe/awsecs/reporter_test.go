@@ -9,6 +9,7 @@ import (
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -39,7 +40,7 @@ func getTestContainerNode() report.Node {
 func TestGetLabelInfo(t *testing.T) {
 	hr := controls.NewDefaultHandlerRegistry()
 	r := awsecs.Make(1e6, time.Hour, hr, "test-probe-id")
-	rpt, err := r.Report()
+	rpt, err := r.Report(context.Background())
 	if err != nil {
 		t.Fatalf("Error making report: %v", err)
 	}
@@ -123,7 +124,7 @@ func TestTagReport(t *testing.T) {
 		},
 	)
 
-	rpt, err := r.Report()
+	rpt, err := r.Report(context.Background())
 	if err != nil {
 		t.Fatalf("Error making report")
 	}
@@ -30,7 +30,9 @@ const (
 	ContainerStateHuman    = "docker_container_state_human"
 	ContainerUptime        = "docker_container_uptime"
 	ContainerRestartCount  = "docker_container_restart_count"
+	ContainerExitCode      = "docker_container_exit_code"
 	ContainerNetworkMode   = "docker_container_network_mode"
+	ContainerNetworkIPs    = "docker_container_network_ips" // network:IP pairs
 
 	NetworkRxDropped = "network_rx_dropped"
 	NetworkRxBytes   = "network_rx_bytes"
@@ -69,7 +71,6 @@ var (
 	StatePaused     = "paused"
 	StateRestarting = "restarting"
 	StateRunning    = "running"
-	StateDeleted    = "deleted"
 )
 
 // StatsGatherer gathers container stats
@@ -286,10 +287,12 @@ func (c *container) NetworkInfo(localAddrs []net.IP) report.Sets {
 	// Network topology, populate the network nodes with all of the details
 	// here, and provide foreign key links from nodes to networks.
 	networks := make([]string, 0, len(c.container.NetworkSettings.Networks))
+	networkIPs := make([]string, 0, len(c.container.NetworkSettings.Networks))
 	for name, settings := range c.container.NetworkSettings.Networks {
 		networks = append(networks, name)
 		if settings.IPAddress != "" {
 			ips = append(ips, settings.IPAddress)
+			networkIPs = append(networkIPs, name+":"+settings.IPAddress)
 		}
 	}
 
@@ -305,6 +308,7 @@ func (c *container) NetworkInfo(localAddrs []net.IP) report.Sets {
 
 	return report.EmptySets.
 		Add(ContainerNetworks, report.MakeStringSet(networks...)).
+		Add(ContainerNetworkIPs, report.MakeStringSet(networkIPs...)).
 		Add(ContainerPorts, c.ports(localAddrs)).
 		Add(ContainerIPs, report.MakeStringSet(ipv4s...)).
 		Add(ContainerIPsWithScopes, report.MakeStringSet(ipsWithScopes...))
@@ -421,6 +425,9 @@ func (c *container) GetNode() report.Node {
 		ContainerName:       strings.TrimPrefix(c.container.Name, "/"),
 		ContainerState:      c.StateString(),
 		ContainerStateHuman: c.State(),
+		// Reported whatever the state, so restart loops show up even
+		// while the container is down.
+		ContainerRestartCount: strconv.Itoa(c.container.RestartCount),
 	}
 	controls := c.controlsMap()
 
@@ -431,8 +438,9 @@ func (c *container) GetNode() report.Node {
 			networkMode = c.container.HostConfig.NetworkMode
 		}
 		latest[ContainerUptime] = uptime.String()
-		latest[ContainerRestartCount] = strconv.Itoa(c.container.RestartCount)
 		latest[ContainerNetworkMode] = networkMode
+	} else if !c.container.State.FinishedAt.IsZero() {
+		latest[ContainerExitCode] = strconv.Itoa(c.container.State.ExitCode)
 	}
 
 	result := c.baseNode.WithLatests(latest)
@@ -109,7 +109,8 @@ func TestContainer(t *testing.T) {
 			Add("docker_container_ips", report.MakeStringSet("5.6.7.8")).
 			Add("docker_container_ips_with_scopes", report.MakeStringSet(";1.2.3.4")).
 			Add("docker_container_ips_with_scopes", report.MakeStringSet(";5.6.7.8")).
-			Add("docker_container_networks", report.MakeStringSet("network1"))
+			Add("docker_container_networks", report.MakeStringSet("network1")).
+			Add("docker_container_network_ips", report.MakeStringSet("network1:5.6.7.8"))
 
 		test.Poll(t, 100*time.Millisecond, want, func() interface{} {
 			return c.NetworkInfo([]net.IP{})
@@ -128,6 +129,67 @@ func TestContainer(t *testing.T) {
 	}
 }
 
+func TestContainerMultipleNetworks(t *testing.T) {
+	c := docker.NewContainer(&client.Container{
+		ID:    "multi",
+		Name:  "multi",
+		State: client.State{Pid: 3, Running: true},
+		NetworkSettings: &client.NetworkSettings{
+			Networks: map[string]client.ContainerNetwork{
+				"frontend": {IPAddress: "10.0.1.2"},
+				"backend":  {IPAddress: "10.0.2.2"},
+			},
+		},
+		Config: &client.Config{},
+	}, "scope", false, false)
+
+	sets := c.NetworkInfo([]net.IP{})
+	for key, want := range map[string]report.StringSet{
+		docker.ContainerNetworks:      report.MakeStringSet("frontend", "backend"),
+		docker.ContainerIPs:           report.MakeStringSet("10.0.1.2", "10.0.2.2"),
+		docker.ContainerNetworkIPs:    report.MakeStringSet("frontend:10.0.1.2", "backend:10.0.2.2"),
+		docker.ContainerIPsWithScopes: report.MakeStringSet(";10.0.1.2", ";10.0.2.2"),
+	} {
+		if have, _ := sets.Lookup(key); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: want %v, have %v", key, want, have)
+		}
+	}
+}
+
+func TestContainerExitAndRestart(t *testing.T) {
+	now := time.Unix(12345, 67890).UTC()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	running := *container1
+	c := docker.NewContainer(&running, "scope", false, false)
+	check := func(state, restarts, exitCode string) {
+		node := c.GetNode()
+		if have, _ := node.Latest.Lookup(docker.ContainerState); have != state {
+			t.Errorf("state: %q != %q", have, state)
+		}
+		if have, _ := node.Latest.Lookup(docker.ContainerRestartCount); have != restarts {
+			t.Errorf("restart count: %q != %q", have, restarts)
+		}
+		if have, ok := node.Latest.Lookup(docker.ContainerExitCode); have != exitCode || ok != (exitCode != "") {
+			t.Errorf("exit code: %q != %q", have, exitCode)
+		}
+	}
+	check(docker.StateRunning, "0", "")
+
+	exited := running
+	exited.RestartCount = 2
+	exited.State = client.State{ExitCode: 137, StartedAt: startTime, FinishedAt: now}
+	c.UpdateState(&exited)
+	check(docker.StateExited, "2", "137")
+
+	restarted := exited
+	restarted.RestartCount = 3
+	restarted.State = client.State{Pid: 2, Running: true, StartedAt: now}
+	c.UpdateState(&restarted)
+	check(docker.StateRunning, "3", "")
+}
+
 func TestContainerHidingArgs(t *testing.T) {
 	const hostID = "scope"
 	c := docker.NewContainer(container1, hostID, true, false)
@@ -10,6 +10,7 @@ import (
 	"github.com/armon/go-radix"
 	docker_client "github.com/fsouza/go-dockerclient"
 
+	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
@@ -27,6 +28,10 @@ const (
 	NetworkDisconnectEvent = "network:disconnect"
 )
 
+// RemovedContainerGracePeriod is how long a removed container is still
+// reported for, marked as stopped, so that its exit remains visible.
+const RemovedContainerGracePeriod = 1 * time.Minute
+
 // Vars exported for testing.
 var (
 	NewDockerClientStub = newDockerClient
@@ -38,6 +43,7 @@ type Registry interface {
 	Stop()
 	LockedPIDLookup(f func(func(int) Container))
 	WalkContainers(f func(Container))
+	WalkRemovedContainers(f func(report.Node))
 	WalkImages(f func(docker_client.APIImages))
 	WalkNetworks(f func(docker_client.Network))
 	WatchContainerUpdates(ContainerUpdateWatcher)
@@ -64,6 +70,7 @@ type registry struct {
 	watchers        []ContainerUpdateWatcher
 	containers      *radix.Tree
 	containersByPID map[int]Container
+	removed         map[string]removedContainer
 	images          map[string]docker_client.APIImages
 	networks        []docker_client.Network
 	pipeIDToexecID  map[string]string
@@ -117,6 +124,7 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 	r := &registry{
 		containers:      radix.New(),
 		containersByPID: map[int]Container{},
+		removed:         map[string]removedContainer{},
 		images:          map[string]docker_client.APIImages{},
 		pipeIDToexecID:  map[string]string{},
 
@@ -261,7 +269,7 @@ func (r *registry) updateContainers() error {
 	}
 
 	for _, apiContainer := range apiContainers {
-		r.updateContainerState(apiContainer.ID, nil)
+		r.updateContainerState(apiContainer.ID, false)
 	}
 
 	return nil
@@ -300,20 +308,32 @@ func (r *registry) handleEvent(event *docker_client.APIEvents) {
 	// TODO: Send shortcut reports on networks being created/destroyed?
 	switch event.Status {
 	case CreateEvent, RenameEvent, StartEvent, DieEvent, DestroyEvent, PauseEvent, UnpauseEvent, NetworkConnectEvent, NetworkDisconnectEvent:
-		r.updateContainerState(event.ID, stateAfterEvent(event.Status))
+		r.updateContainerState(event.ID, event.Status == DestroyEvent)
 	}
 }
 
-func stateAfterEvent(event string) *string {
-	switch event {
-	case DestroyEvent:
-		return &StateDeleted
-	default:
-		return nil
+// removedContainer is a container which no longer exists, kept around for
+// the RemovedContainerGracePeriod.
+type removedContainer struct {
+	node    report.Node
+	removed time.Time
+}
+
+// removedContainerNode is how a container is reported once it has been
+// removed: stopped, and with none of its controls available.
+func removedContainerNode(c Container) report.Node {
+	latest := map[string]string{ContainerStateHuman: "Removed"}
+	if !ContainerIsStopped(c) {
+		latest[ContainerState] = StateExited
 	}
+	controls := map[string]report.NodeControlData{}
+	for _, control := range ContainerControls {
+		controls[control.ID] = report.NodeControlData{Dead: true}
+	}
+	return c.GetNode().WithLatests(latest).WithLatestControls(controls)
 }
 
-func (r *registry) updateContainerState(containerID string, intendedState *string) {
+func (r *registry) updateContainerState(containerID string, destroyed bool) {
 	r.Lock()
 	defer r.Unlock()
 
@@ -338,11 +358,9 @@ func (r *registry) updateContainerState(containerID string, intendedState *strin
 			container.StopGatheringStats()
 		}
 
-		if intendedState != nil {
-			node := report.MakeNodeWith(report.MakeContainerNodeID(containerID), map[string]string{
-				ContainerID:    containerID,
-				ContainerState: *intendedState,
-			})
+		node := removedContainerNode(container)
+		r.removed[containerID] = removedContainer{node: node, removed: mtime.Now()}
+		if destroyed {
 			// Trigger anyone watching for updates
 			for _, f := range r.watchers {
 				f(node)
@@ -414,6 +432,22 @@ func (r *registry) WalkContainers(f func(Container)) {
 	})
 }
 
+// WalkRemovedContainers runs f on the node of every container removed within
+// the RemovedContainerGracePeriod, and forgets those removed before that.
+func (r *registry) WalkRemovedContainers(f func(report.Node)) {
+	r.Lock()
+	defer r.Unlock()
+
+	oldest := mtime.Now().Add(-RemovedContainerGracePeriod)
+	for id, c := range r.removed {
+		if !c.removed.After(oldest) {
+			delete(r.removed, id)
+			continue
+		}
+		f(c.node)
+	}
+}
+
 func (r *registry) GetContainer(id string) (Container, bool) {
 	r.RLock()
 	defer r.RUnlock()
@@ -474,10 +508,44 @@ func (r *registry) WalkNetworks(f func(docker_client.Network)) {
 // ImageNameWithoutVersion splits the image name apart, returning the name
 // without the version, if possible
 func ImageNameWithoutVersion(name string) string {
+	return NormalizeImageName(name, ImageGroupByRepo)
+}
+
+// ImageGrouping says which parts of an image name are significant when
+// grouping containers by image.
+type ImageGrouping string
+
+// Supported image groupings
+const (
+	ImageGroupByRepo    ImageGrouping = "repo"   // foo/bar
+	ImageGroupByRepoTag ImageGrouping = "tag"    // foo/bar:baz
+	ImageGroupByDigest  ImageGrouping = "digest" // foo/bar@sha256:..., or foo/bar:baz without a digest
+)
+
+// NormalizeImageName reduces an image name to the parts significant for the
+// given grouping. As with ImageNameWithoutVersion, the first component of a
+// name with three or more, which is taken to be the registry host, is
+// dropped; the host of a two component name like quay.io/foo is kept.
+func NormalizeImageName(name string, grouping ImageGrouping) string {
 	parts := strings.SplitN(name, "/", 3)
 	if len(parts) == 3 {
 		name = fmt.Sprintf("%s/%s", parts[1], parts[2])
 	}
-	parts = strings.SplitN(name, ":", 2)
-	return parts[0]
+
+	var tag, digest string
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	// A colon before the last slash separates a registry port, not a tag.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+
+	switch {
+	case grouping == ImageGroupByDigest && digest != "":
+		return name + "@" + digest
+	case grouping != ImageGroupByRepo && tag != "":
+		return name + ":" + tag
+	}
+	return name
 }
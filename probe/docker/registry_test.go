@@ -500,22 +500,45 @@ func TestRegistryDelete(t *testing.T) {
 
 			check([]docker.Container{})
 
-			mtx.Lock()
-			want := []report.Node{
-				report.MakeNodeWith(report.MakeContainerNodeID("ping"), map[string]string{
-					docker.ContainerID:    "ping",
-					docker.ContainerState: "deleted",
-				}),
+			// The removed container is reported as stopped, with its
+			// controls dead, for the grace period.
+			controls := map[string]report.NodeControlData{}
+			for _, control := range docker.ContainerControls {
+				controls[control.ID] = report.NodeControlData{Dead: true}
 			}
+			removed := (&mockContainer{container1}).GetNode().WithLatests(map[string]string{
+				docker.ContainerState:      docker.StateExited,
+				docker.ContainerStateHuman: "Removed",
+			}).WithLatestControls(controls)
+
+			mtx.Lock()
+			want := []report.Node{removed}
 			if !reflect.DeepEqual(want, nodes) {
 				t.Errorf("Didn't get right container updates: %v", commonTest.Diff(want, nodes))
 			}
 			nodes = []report.Node{}
 			mtx.Unlock()
+
+			if have := removedContainers(registry); !reflect.DeepEqual(want, have) {
+				t.Errorf("Didn't get removed containers: %v", commonTest.Diff(want, have))
+			}
+
+			mtime.NowForce(mtime.Now().Add(docker.RemovedContainerGracePeriod))
+			if have := removedContainers(registry); len(have) != 0 {
+				t.Errorf("Expected removed containers to be forgotten, got %v", have)
+			}
 		}
 	})
 }
 
+func removedContainers(r docker.Registry) []report.Node {
+	result := []report.Node{}
+	r.WalkRemovedContainers(func(n report.Node) {
+		result = append(result, n)
+	})
+	return result
+}
+
 func TestDockerImageName(t *testing.T) {
 	for _, input := range []struct{ in, name string }{
 		{"foo/bar", "foo/bar"},
@@ -530,3 +553,27 @@ func TestDockerImageName(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeImageName(t *testing.T) {
+	for _, input := range []struct {
+		in                    string
+		repo, repoTag, digest string
+	}{
+		{"foo/bar", "foo/bar", "foo/bar", "foo/bar"},
+		{"foo/bar:baz", "foo/bar", "foo/bar:baz", "foo/bar:baz"},
+		{"foo/bar@sha256:123", "foo/bar", "foo/bar", "foo/bar@sha256:123"},
+		{"foo/bar:baz@sha256:123", "foo/bar", "foo/bar:baz", "foo/bar@sha256:123"},
+		{"reg:123/foo/bar:baz", "foo/bar", "foo/bar:baz", "foo/bar:baz"},
+		{"localhost:5000/foo", "localhost:5000/foo", "localhost:5000/foo", "localhost:5000/foo"},
+	} {
+		for grouping, want := range map[docker.ImageGrouping]string{
+			docker.ImageGroupByRepo:    input.repo,
+			docker.ImageGroupByRepoTag: input.repoTag,
+			docker.ImageGroupByDigest:  input.digest,
+		} {
+			if have := docker.NormalizeImageName(input.in, grouping); have != want {
+				t.Errorf("%s (%s): %s != %s", input.in, grouping, have, want)
+			}
+		}
+	}
+}
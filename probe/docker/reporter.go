@@ -6,6 +6,7 @@ import (
 
 	humanize "github.com/dustin/go-humanize"
 	docker_client "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/host"
@@ -36,6 +37,8 @@ var (
 		ContainerPorts:        {ID: ContainerPorts, Label: "Ports", From: report.FromSets, Priority: 8},
 		ContainerCreated:      {ID: ContainerCreated, Label: "Created", From: report.FromLatest, Datatype: "datetime", Priority: 9},
 		ContainerID:           {ID: ContainerID, Label: "ID", From: report.FromLatest, Truncate: 12, Priority: 10},
+		ContainerExitCode:     {ID: ContainerExitCode, Label: "Exit code", From: report.FromLatest, Datatype: "number", Priority: 11},
+		ContainerNetworkIPs:   {ID: ContainerNetworkIPs, Label: "Network IPs", From: report.FromSets, Priority: 12},
 	}
 
 	ContainerMetricTemplates = report.MetricTemplates{
@@ -167,7 +170,7 @@ func (r *Reporter) ContainerUpdated(n report.Node) {
 }
 
 // Report generates a Report containing Container and ContainerImage topologies
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	localAddrs, err := report.LocalAddresses()
 	if err != nil {
 		return report.MakeReport(), nil
@@ -207,6 +210,9 @@ func (r *Reporter) containerTopology(localAddrs []net.IP) report.Topology {
 	r.registry.WalkContainers(func(c Container) {
 		nodes = append(nodes, c.GetNode().WithLatests(metadata))
 	})
+	r.registry.WalkRemovedContainers(func(n report.Node) {
+		nodes = append(nodes, n.WithLatests(metadata))
+	})
 
 	// Copy the IP addresses from other containers where they share network
 	// namespaces & deal with containers in the host net namespace.  This
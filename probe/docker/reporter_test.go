@@ -8,6 +8,7 @@ import (
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 type mockRegistry struct {
@@ -30,6 +31,8 @@ func (r *mockRegistry) WalkContainers(f func(docker.Container)) {
 	}
 }
 
+func (r *mockRegistry) WalkRemovedContainers(f func(report.Node)) {}
+
 func (r *mockRegistry) WalkImages(f func(client.APIImages)) {
 	for _, i := range r.images {
 		f(i)
@@ -73,7 +76,7 @@ func TestReporter(t *testing.T) {
 	)
 
 	containerImageNodeID := report.MakeContainerImageNodeID(imageID)
-	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", controlProbeID, nil).Report()
+	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", controlProbeID, nil).Report(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
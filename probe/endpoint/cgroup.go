@@ -0,0 +1,83 @@
+package endpoint
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// containerIDRegexp matches the container id at the end of a cgroup path,
+// e.g. "/docker/<id>" or "/kubepods/.../<id>" (cgroupfs driver), and
+// "/system.slice/docker-<id>.scope" or ".../cri-containerd-<id>.scope"
+// (systemd driver).
+var containerIDRegexp = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// containerIDFromCgroup extracts the container id from the contents of
+// /proc/<pid>/cgroup. Each line is "hierarchy-id:controllers:path"; cgroup
+// v1 has a line per hierarchy, cgroup v2 a single "0::path" line. It returns
+// false for processes which aren't in a container.
+func containerIDFromCgroup(cgroup string) (string, bool) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if match := containerIDRegexp.FindStringSubmatch(fields[2]); match != nil {
+			return match[1], true
+		}
+	}
+	return "", false
+}
+
+// systemdUnitFromCgroup extracts the systemd service a process is in from
+// the contents of /proc/<pid>/cgroup, looking at the "name=systemd" hierarchy
+// on cgroup v1 and the unified one on v2. For nested units, e.g.
+// "/user.slice/user-1000.slice/user@1000.service/app.slice/foo.service", it
+// returns the innermost. Scopes aren't returned, as they hold containers and
+// login sessions rather than services.
+func systemdUnitFromCgroup(cgroup string) (string, bool) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(fields) != 3 || (fields[1] != "name=systemd" && !(fields[0] == "0" && fields[1] == "")) {
+			continue
+		}
+		segments := strings.Split(fields[2], "/")
+		for i := len(segments) - 1; i >= 0; i-- {
+			if strings.HasSuffix(segments[i], ".service") {
+				return segments[i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// processCgroup returns the contents of the cgroup file of process pid,
+// reading it at most once per report using cache. Unreadable cgroup files
+// are treated as empty.
+func (t *connectionTracker) processCgroup(pid int, cache map[int]string) string {
+	cgroup, ok := cache[pid]
+	if !ok {
+		buf, err := fs.ReadFile(path.Join(t.conf.ProcRoot, strconv.Itoa(pid), "cgroup"))
+		if err == nil {
+			cgroup = string(buf)
+		}
+		logProcReadError("cgroups", err)
+		cache[pid] = cgroup
+	}
+	return cgroup
+}
+
+// processContainerID returns the id of the container process pid is in, if
+// any, with the process' cgroups cached as for processCgroup.
+func (t *connectionTracker) processContainerID(pid int, cache map[int]string) (string, bool) {
+	return containerIDFromCgroup(t.processCgroup(pid, cache))
+}
+
+// processSystemdUnit returns the systemd service process pid is in, if any,
+// with the process' cgroups cached as for processCgroup.
+func (t *connectionTracker) processSystemdUnit(pid int, cache map[int]string) (string, bool) {
+	return systemdUnitFromCgroup(t.processCgroup(pid, cache))
+}
@@ -0,0 +1,136 @@
+package endpoint
+
+import (
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+)
+
+const testContainerID = "3f4e8c1b2a6d9e0f7c5b4a3928171615141312111009080706050403020100ff"
+
+func TestContainerIDFromCgroup(t *testing.T) {
+	for _, tc := range []struct {
+		name, cgroup, want string
+	}{
+		{
+			name: "v1 cgroupfs",
+			cgroup: "11:cpuset:/docker/" + testContainerID + "\n" +
+				"10:memory:/docker/" + testContainerID + "\n" +
+				"1:name=systemd:/docker/" + testContainerID + "\n",
+			want: testContainerID,
+		},
+		{
+			name: "v1 kubernetes",
+			cgroup: "12:pids:/kubepods/burstable/pod1b2c3d4e-0000-1111-2222-333344445555/" + testContainerID + "\n" +
+				"1:name=systemd:/kubepods/burstable/pod1b2c3d4e-0000-1111-2222-333344445555/" + testContainerID + "\n",
+			want: testContainerID,
+		},
+		{
+			name:   "v2 systemd docker",
+			cgroup: "0::/system.slice/docker-" + testContainerID + ".scope\n",
+			want:   testContainerID,
+		},
+		{
+			name:   "v2 containerd",
+			cgroup: "0::/kubepods.slice/kubepods-besteffort.slice/cri-containerd-" + testContainerID + ".scope\n",
+			want:   testContainerID,
+		},
+		{
+			name:   "v1 not in a container",
+			cgroup: "4:memory:/user.slice\n1:name=systemd:/user.slice/user-1000.slice/session-2.scope\n",
+		},
+		{
+			name:   "v2 not in a container",
+			cgroup: "0::/init.scope\n",
+		},
+	} {
+		have, ok := containerIDFromCgroup(tc.cgroup)
+		if have != tc.want || ok != (tc.want != "") {
+			t.Errorf("%s: want %q, have %q (%v)", tc.name, tc.want, have, ok)
+		}
+	}
+}
+
+func TestProcessContainerID(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("1", fs.File{FName: "cgroup", FContents: "0::/init.scope\n"}),
+			fs.Dir("2", fs.File{FName: "cgroup", FContents: "0::/docker/" + testContainerID + "\n"}),
+		),
+	))
+	defer fs_hook.Restore()
+
+	tracker := connectionTracker{conf: connectionTrackerConfig{ProcRoot: "/proc"}}
+	cache := map[int]string{}
+	if id, ok := tracker.processContainerID(1, cache); ok {
+		t.Errorf("expected pid 1 not to be in a container, got %q", id)
+	}
+	if id, ok := tracker.processContainerID(2, cache); !ok || id != testContainerID {
+		t.Errorf("expected pid 2 to be in container %s, got %q", testContainerID, id)
+	}
+	if id, ok := tracker.processContainerID(3, cache); ok {
+		t.Errorf("expected missing cgroup file to be ignored, got %q", id)
+	}
+}
+
+func TestSystemdUnitFromCgroup(t *testing.T) {
+	for _, tc := range []struct {
+		name, cgroup, want string
+	}{
+		{
+			name:   "v1 service",
+			cgroup: "4:memory:/system.slice/nginx.service\n1:name=systemd:/system.slice/nginx.service\n",
+			want:   "nginx.service",
+		},
+		{
+			name:   "v2 service",
+			cgroup: "0::/system.slice/sshd.service\n",
+			want:   "sshd.service",
+		},
+		{
+			name:   "v2 user service",
+			cgroup: "0::/user.slice/user-1000.slice/user@1000.service/app.slice/syncthing.service\n",
+			want:   "syncthing.service",
+		},
+		{
+			name:   "v1 only systemd hierarchy counts",
+			cgroup: "4:memory:/system.slice/nginx.service\n1:name=systemd:/user.slice/user-1000.slice/session-2.scope\n",
+		},
+		{
+			name:   "v2 container scope",
+			cgroup: "0::/system.slice/docker-" + testContainerID + ".scope\n",
+		},
+		{
+			name:   "v1 cgroupfs",
+			cgroup: "1:name=systemd:/docker/" + testContainerID + "\n",
+		},
+	} {
+		have, ok := systemdUnitFromCgroup(tc.cgroup)
+		if have != tc.want || ok != (tc.want != "") {
+			t.Errorf("%s: want %q, have %q (%v)", tc.name, tc.want, have, ok)
+		}
+	}
+}
+
+func TestProcessSystemdUnit(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("1", fs.File{FName: "cgroup", FContents: "0::/init.scope\n"}),
+			fs.Dir("2", fs.File{FName: "cgroup", FContents: "0::/system.slice/nginx.service\n"}),
+		),
+	))
+	defer fs_hook.Restore()
+
+	tracker := connectionTracker{conf: connectionTrackerConfig{ProcRoot: "/proc"}}
+	cache := map[int]string{}
+	if unit, ok := tracker.processSystemdUnit(1, cache); ok {
+		t.Errorf("expected pid 1 not to be in a service, got %q", unit)
+	}
+	if unit, ok := tracker.processSystemdUnit(2, cache); !ok || unit != "nginx.service" {
+		t.Errorf("expected pid 2 to be in nginx.service, got %q", unit)
+	}
+	if id, ok := tracker.processContainerID(2, cache); ok {
+		t.Errorf("expected pid 2 not to be in a container, got %q", id)
+	}
+}
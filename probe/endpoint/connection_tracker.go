@@ -1,12 +1,19 @@
 package endpoint
 
 import (
+	"errors"
+	"net"
 	"strconv"
+	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 // connectionTrackerConfig are the config options for the endpoint tracker.
@@ -21,7 +28,14 @@ type connectionTrackerConfig struct {
 	BufferSize   int
 	ProcessCache *process.CachingWalker
 	Scanner      procspy.ConnectionScanner
-	DNSSnooper   *DNSSnooper
+	// FallbackScanner is used if Scanner fails outright.
+	FallbackScanner procspy.ConnectionScanner
+	DNSSnooper      *DNSSnooper
+	EnvVars         []string
+	SpyTimeout      time.Duration
+
+	ExcludeLoopback   bool
+	ConnectionSamples bool
 }
 
 type connectionTracker struct {
@@ -29,17 +43,85 @@ type connectionTracker struct {
 	flowWalker      flowWalker // Interface
 	ebpfTracker     eventTracker
 	reverseResolver *reverseResolver
+	firstSeen       *firstSeenCache
+	users           *userCache
+	services        serviceNames
+	scan            *connectionScan // in flight, if the last one timed out
+}
+
+// connectionScan lists the connections from the Scanner in the background,
+// so it can be waited for with a timeout.
+type connectionScan struct {
+	mtx   sync.Mutex
+	conns []procspy.Connection
+	err   error
+	done  chan struct{}
+}
+
+func (t *connectionTracker) startScan() *connectionScan {
+	scan := &connectionScan{done: make(chan struct{})}
+	go func() {
+		iter, err := t.scanConnections()
+		if iter != nil {
+			for conn := iter.Next(); conn != nil; conn = iter.Next() {
+				scan.mtx.Lock()
+				scan.conns = append(scan.conns, *conn)
+				scan.mtx.Unlock()
+			}
+		}
+		scan.err = err
+		close(scan.done)
+	}()
+	return scan
+}
+
+// firstSeenCache remembers when each connection was first reported, so that
+// edges can carry their age. Connections not reported during a cycle are
+// forgotten at the start of the next one.
+type firstSeenCache struct {
+	current, previous map[string]time.Time
+}
+
+func newFirstSeenCache() *firstSeenCache {
+	return &firstSeenCache{current: map[string]time.Time{}}
+}
+
+func (c *firstSeenCache) cycle() {
+	if c == nil {
+		return
+	}
+	c.previous, c.current = c.current, map[string]time.Time{}
+}
+
+func (c *firstSeenCache) get(key string) time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	t, ok := c.current[key]
+	if !ok {
+		if t, ok = c.previous[key]; !ok {
+			t = mtime.Now()
+		}
+		c.current[key] = t
+	}
+	return t
 }
 
 func newProcfsConnectionTracker(conf connectionTrackerConfig) connectionTracker {
 	if conf.WalkProc && conf.Scanner == nil {
 		conf.Scanner = procspy.NewConnectionScanner(conf.ProcessCache)
 	}
+	if conf.WalkProc && conf.FallbackScanner == nil {
+		conf.FallbackScanner = procspy.NewProcNetScanner(conf.ProcRoot)
+	}
 	return connectionTracker{
 		conf:            conf,
 		flowWalker:      newConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, "--any-nat"),
 		ebpfTracker:     nil,
 		reverseResolver: newReverseResolver(),
+		firstSeen:       newFirstSeenCache(),
+		users:           newUserCache(lookupUsername),
+		services:        loadServiceNames(ServicesFile),
 	}
 }
 
@@ -59,6 +141,9 @@ func newConnectionTracker(conf connectionTrackerConfig) connectionTracker {
 		flowWalker:      nil,
 		ebpfTracker:     et,
 		reverseResolver: newReverseResolver(),
+		firstSeen:       newFirstSeenCache(),
+		users:           newUserCache(lookupUsername),
+		services:        loadServiceNames(ServicesFile),
 	}
 	go ct.getInitialState()
 	return ct
@@ -83,19 +168,24 @@ func flowToTuple(f flow) (ft fourTuple) {
 	return ft
 }
 
-// ReportConnections calls trackers according to the configuration.
-func (t *connectionTracker) ReportConnections(rpt *report.Report) {
+// ReportConnections calls trackers according to the configuration. It
+// returns an error if walking /proc failed outright.
+func (t *connectionTracker) ReportConnections(ctx context.Context, rpt *report.Report) error {
 	hostNodeID := report.MakeHostNodeID(t.conf.HostID)
+	t.firstSeen.cycle()
 
 	if t.ebpfTracker != nil {
 		if !t.ebpfTracker.isDead() {
 			t.performEbpfTrack(rpt, hostNodeID)
-			return
+			return nil
 		}
 		log.Warnf("ebpf tracker died, gently falling back to proc scanning")
 		if t.conf.WalkProc && t.conf.Scanner == nil {
 			t.conf.Scanner = procspy.NewConnectionScanner(t.conf.ProcessCache)
 		}
+		if t.conf.WalkProc && t.conf.FallbackScanner == nil {
+			t.conf.FallbackScanner = procspy.NewProcNetScanner(t.conf.ProcRoot)
+		}
 		if t.flowWalker == nil {
 			t.flowWalker = newConntrackFlowWalker(t.conf.UseConntrack, t.conf.ProcRoot, t.conf.BufferSize, "--any-nat")
 		}
@@ -107,12 +197,22 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 	if t.flowWalker != nil {
 		t.performFlowWalk(rpt, &seenTuples)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	// if eBPF was enabled but failed to initialize, Scanner will be nil.
 	// We can't recover from this, so don't walk proc in that case.
 	// TODO: implement fallback
 	if t.conf.WalkProc && t.conf.Scanner != nil {
-		t.performWalkProc(rpt, hostNodeID, &seenTuples)
+		if err := t.performWalkProc(ctx, rpt, hostNodeID, &seenTuples); err != nil {
+			if err == ctx.Err() {
+				return err
+			}
+			limitedLog.Errorf("Error walking /proc for connections: %v", err)
+			return err
+		}
 	}
+	return nil
 }
 
 func (t *connectionTracker) performFlowWalk(rpt *report.Report, seenTuples *map[string]fourTuple) {
@@ -123,16 +223,101 @@ func (t *connectionTracker) performFlowWalk(rpt *report.Report, seenTuples *map[
 	t.flowWalker.walkFlows(func(f flow, alive bool) {
 		tuple := flowToTuple(f)
 		(*seenTuples)[tuple.key()] = tuple
-		t.addConnection(rpt, tuple, "", extraNodeInfo, extraNodeInfo)
+		edge := report.EdgeMetadata{}
+		if t.conf.ConnectionSamples {
+			edge.ConnectionSamples = []report.ConnectionSample{{LocalPort: tuple.fromPort, RemotePort: tuple.toPort, State: f.Independent.State}}
+		}
+		t.addConnection(rpt, tuple, "", extraNodeInfo, extraNodeInfo, edge)
 	})
 }
 
-func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID string, seenTuples *map[string]fourTuple) error {
-	conns, err := t.conf.Scanner.Connections(t.conf.SpyProcs)
-	if err != nil {
+var errSpyTimeout = errors.New("timed out listing connections")
+
+// spyConnections lists the connections from the Scanner. If that takes
+// longer than the SpyTimeout, the connections listed so far are returned
+// along with errSpyTimeout, or just the error if there are none; the scan is
+// left to finish in the background. The same goes for ctx being done, except
+// that nothing is returned but ctx.Err(). Only one scan runs at a time: while
+// one left in the background is still running, later calls wait for it
+// rather than starting another.
+func (t *connectionTracker) spyConnections(ctx context.Context) (procspy.ConnIter, error) {
+	if t.conf.SpyTimeout <= 0 && ctx.Done() == nil {
+		return t.scanConnections()
+	}
+	if t.scan != nil {
+		select {
+		case <-t.scan.done:
+			// It finished since it was left, so its connections are stale.
+			t.scan = nil
+		default:
+		}
+	}
+	if t.scan == nil {
+		t.scan = t.startScan()
+	}
+	scan := t.scan
+
+	var timeout <-chan time.Time
+	if t.conf.SpyTimeout > 0 {
+		timeout = time.After(t.conf.SpyTimeout)
+	}
+	var err error
+	select {
+	case <-scan.done:
+		t.scan = nil
+		err = scan.err
+	case <-timeout:
+		err = errSpyTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	_, partial := err.(procspy.ProcessLookupError)
+	scan.mtx.Lock()
+	defer scan.mtx.Unlock()
+	if (err != nil && err != errSpyTimeout && !partial) || (err == errSpyTimeout && len(scan.conns) == 0) {
+		return nil, err
+	}
+	iter, _ := procspy.FixedScanner(append([]procspy.Connection(nil), scan.conns...)).Connections(t.conf.SpyProcs)
+	return iter, err
+}
+
+// scanConnections lists the connections with the Scanner, or with the
+// FallbackScanner if that fails outright.
+func (t *connectionTracker) scanConnections() (procspy.ConnIter, error) {
+	iter, err := t.conf.Scanner.Connections(t.conf.SpyProcs)
+	if _, partial := err.(procspy.ProcessLookupError); err == nil || partial || t.conf.FallbackScanner == nil {
+		return iter, err
+	}
+	limitedLog.Warnf("Error listing connections, reading them from %s/net instead: %v", t.conf.ProcRoot, err)
+	return t.conf.FallbackScanner.Connections(t.conf.SpyProcs)
+}
+
+func (t *connectionTracker) performWalkProc(ctx context.Context, rpt *report.Report, hostNodeID string, seenTuples *map[string]fourTuple) error {
+	conns, err := t.spyConnections(ctx)
+	if _, partial := err.(procspy.ProcessLookupError); partial || (err == errSpyTimeout && conns != nil) {
+		// Report the connections we've got, even if incomplete.
+		limitedLog.Warnf("Error walking /proc for connections: %v", err)
+		rpt.Host = rpt.Host.WithMetadataTemplates(HostMetadataTemplates)
+		rpt.Host = rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{
+			Warning: err.Error(),
+		}))
+	} else if err != nil {
 		return err
 	}
+	var (
+		envs       = map[int]map[string]string{}
+		cgroups    = map[int]string{}
+		uids       = map[int]string{}
+		startTimes = map[int]string{}
+	)
+	bootTime, err := readBootTime(t.conf.ProcRoot)
+	if err != nil {
+		limitedLog.Warnf("Error reading boot time, not reporting process start times: %v", err)
+	}
 	for conn := conns.Next(); conn != nil; conn = conns.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var (
 			namespaceID string
 			tuple       = fourTuple{
@@ -147,11 +332,27 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 		if conn.Proc.PID > 0 {
 			fromNodeInfo[process.PID] = strconv.FormatUint(uint64(conn.Proc.PID), 10)
 			fromNodeInfo[report.HostNodeID] = hostNodeID
+			t.processEnv(int(conn.Proc.PID), envs, fromNodeInfo)
+			t.processUser(int(conn.Proc.PID), uids, fromNodeInfo)
+			t.processStartTime(int(conn.Proc.PID), bootTime, startTimes, fromNodeInfo)
+			if id, ok := t.processContainerID(int(conn.Proc.PID), cgroups); ok {
+				fromNodeInfo[docker.ContainerID] = id
+			}
+			if unit, ok := t.processSystemdUnit(int(conn.Proc.PID), cgroups); ok {
+				fromNodeInfo[SystemdUnit] = unit
+			}
 		}
 
 		if conn.Proc.NetNamespaceID > 0 {
 			namespaceID = strconv.FormatUint(conn.Proc.NetNamespaceID, 10)
 		}
+		if conn.HasQueues {
+			fromNodeInfo[SendQueue] = strconv.FormatUint(conn.SendQueue, 10)
+			fromNodeInfo[ReceiveQueue] = strconv.FormatUint(conn.ReceiveQueue, 10)
+		}
+		if conn.CongestionWindow > 0 {
+			fromNodeInfo[CongestionWindow] = strconv.FormatUint(conn.CongestionWindow, 10)
+		}
 
 		// If we've already seen this connection, we should know the direction
 		// (or have already figured it out), so we normalize and use the
@@ -162,7 +363,12 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 			tuple.reverse()
 			toNodeInfo, fromNodeInfo = fromNodeInfo, toNodeInfo
 		}
-		t.addConnection(rpt, tuple, namespaceID, fromNodeInfo, toNodeInfo)
+		var edge report.EdgeMetadata
+		if conn.Transport == "udp" {
+			flows := uint64(1)
+			edge.WithUDP, edge.UDPFlows = true, &flows
+		}
+		t.addConnection(rpt, tuple, namespaceID, fromNodeInfo, toNodeInfo, edge)
 	}
 	return nil
 }
@@ -179,7 +385,7 @@ func (t *connectionTracker) getInitialState() {
 	// Consult the flowWalker to get the initial state
 	if err := IsConntrackSupported(t.conf.ProcRoot); t.conf.UseConntrack && err != nil {
 		log.Warnf("Not using conntrack: not supported by the kernel: %s", err)
-	} else if existingFlows, err := existingConnections([]string{"--any-nat"}); err != nil {
+	} else if existingFlows, err := existingConnections("", []string{"--any-nat"}); err != nil {
 		log.Errorf("conntrack existingConnections error: %v", err)
 	} else {
 		for _, f := range existingFlows {
@@ -198,6 +404,16 @@ func (t *connectionTracker) getInitialState() {
 }
 
 func (t *connectionTracker) performEbpfTrack(rpt *report.Report, hostNodeID string) error {
+	var (
+		envs       = map[int]map[string]string{}
+		cgroups    = map[int]string{}
+		uids       = map[int]string{}
+		startTimes = map[int]string{}
+	)
+	bootTime, err := readBootTime(t.conf.ProcRoot)
+	if err != nil {
+		limitedLog.Warnf("Error reading boot time, not reporting process start times: %v", err)
+	}
 	t.ebpfTracker.walkConnections(func(e ebpfConnection) {
 		fromNodeInfo := map[string]string{
 			EBPF: "true",
@@ -208,38 +424,85 @@ func (t *connectionTracker) performEbpfTrack(rpt *report.Report, hostNodeID stri
 		if e.pid > 0 {
 			fromNodeInfo[process.PID] = strconv.Itoa(e.pid)
 			fromNodeInfo[report.HostNodeID] = hostNodeID
+			t.processEnv(e.pid, envs, fromNodeInfo)
+			t.processUser(e.pid, uids, fromNodeInfo)
+			t.processStartTime(e.pid, bootTime, startTimes, fromNodeInfo)
+			if id, ok := t.processContainerID(e.pid, cgroups); ok {
+				fromNodeInfo[docker.ContainerID] = id
+			}
+			if unit, ok := t.processSystemdUnit(e.pid, cgroups); ok {
+				fromNodeInfo[SystemdUnit] = unit
+			}
 		}
 
 		if e.incoming {
-			t.addConnection(rpt, reverse(e.tuple), e.networkNamespace, toNodeInfo, fromNodeInfo)
+			t.addConnection(rpt, reverse(e.tuple), e.networkNamespace, toNodeInfo, fromNodeInfo, report.EdgeMetadata{})
 		} else {
-			t.addConnection(rpt, e.tuple, e.networkNamespace, fromNodeInfo, toNodeInfo)
+			t.addConnection(rpt, e.tuple, e.networkNamespace, fromNodeInfo, toNodeInfo, report.EdgeMetadata{})
 		}
 
 	})
 	return nil
 }
 
-func (t *connectionTracker) addConnection(rpt *report.Report, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string) {
+func (t *connectionTracker) addConnection(rpt *report.Report, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string, edge report.EdgeMetadata) {
+	if t.conf.ExcludeLoopback && isLoopback(ft.fromAddr) && isLoopback(ft.toAddr) {
+		return
+	}
 	var (
 		fromNode = t.makeEndpointNode(namespaceID, ft.fromAddr, ft.fromPort, extraFromNode)
 		toNode   = t.makeEndpointNode(namespaceID, ft.toAddr, ft.toPort, extraToNode)
 	)
-	rpt.Endpoint = rpt.Endpoint.AddNode(fromNode.WithEdge(toNode.ID, report.EdgeMetadata{}))
+	// Only the end connected to, listening, is on a service's port. The
+	// other end's port is usually ephemeral, so any service named after it
+	// would be a coincidence.
+	if name, ok := t.services.lookup(ft.toPort); ok {
+		toNode = toNode.Set(KeyNamespace, serviceKey, name)
+	}
+	edge.FirstSeen = t.firstSeen.get(ft.key())
+	edge.LastSeen = mtime.Now()
+	// Connections are TCP unless known to be UDP.
+	if !edge.WithUDP {
+		connections := uint64(1)
+		edge.WithTCP, edge.TCPConnections = true, &connections
+	}
+	// Without looking inside the connection, whether it is encrypted can
+	// only be guessed from the ports.
+	if isTLSPort(ft.fromPort) || isTLSPort(ft.toPort) {
+		edge.TLS = true
+	}
+	// The state is only known for connections from conntrack, which sample
+	// them before getting here.
+	if t.conf.ConnectionSamples && len(edge.ConnectionSamples) == 0 {
+		edge.ConnectionSamples = []report.ConnectionSample{{LocalPort: ft.fromPort, RemotePort: ft.toPort}}
+	}
+	rpt.Endpoint = rpt.Endpoint.AddNode(fromNode.WithEdge(toNode.ID, edge))
 	rpt.Endpoint = rpt.Endpoint.AddNode(toNode)
 }
 
+func isLoopback(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (t *connectionTracker) makeEndpointNode(namespaceID string, addr string, port uint16, extra map[string]string) report.Node {
 	portStr := strconv.Itoa(int(port))
+	latests := map[string]string{Addr: addr, Port: portStr}
+	if v4, ok := report.NAT64Embedded(net.ParseIP(addr)); ok {
+		latests[NAT64Addr] = v4.String()
+	}
 	node := report.MakeNodeWith(
 		report.MakeEndpointNodeID(t.conf.HostID, namespaceID, addr, portStr),
-		map[string]string{Addr: addr, Port: portStr})
+		latests)
 	if names := t.conf.DNSSnooper.CachedNamesForIP(addr); len(names) > 0 {
 		node = node.WithSet(SnoopedDNSNames, report.MakeStringSet(names...))
 	}
 	if names, err := t.reverseResolver.get(addr); err == nil && len(names) > 0 {
 		node = node.WithSet(ReverseDNSNames, report.MakeStringSet(names...))
 	}
+	if namespaceID != "" {
+		node = node.Set(KeyNamespace, networkNamespaceKey, namespaceID)
+	}
 	if extra != nil {
 		node = node.WithLatests(extra)
 	}
@@ -0,0 +1,369 @@
+package endpoint
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
+)
+
+func TestServiceNamesOnlyOnListeningPorts(t *testing.T) {
+	tracker := connectionTracker{
+		conf:            connectionTrackerConfig{HostID: "host1"},
+		reverseResolver: newReverseResolver(),
+		services:        wellKnownServices,
+	}
+	rpt := report.MakeReport()
+	// The client's ephemeral port happens to be mysql's.
+	tracker.addConnection(&rpt, fourTuple{"1.2.3.4", "5.6.7.8", 3306, 5432}, "", nil, nil, report.EdgeMetadata{})
+
+	var (
+		clientID = report.MakeEndpointNodeID("host1", "", "1.2.3.4", "3306")
+		serverID = report.MakeEndpointNodeID("host1", "", "5.6.7.8", "5432")
+	)
+	if have, ok := rpt.Endpoint.Nodes[serverID].Latest.Lookup(Service); !ok || have != "postgresql" {
+		t.Errorf("expected the server to be tagged postgresql, have %q", have)
+	}
+	if have, ok := rpt.Endpoint.Nodes[clientID].Latest.Lookup(Service); ok {
+		t.Errorf("expected the client not to be tagged, have %q", have)
+	}
+}
+
+func TestWalkProcEnvVars(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("42", fs.File{
+				FName:     "environ",
+				FContents: "PATH=/bin\000SERVICE_NAME=web\000DB_PASSWORD=hunter2\000",
+			}),
+		),
+	))
+	defer fs_hook.Restore()
+
+	connection := func(pid uint, port uint16) procspy.Connection {
+		return procspy.Connection{
+			Transport:     "tcp",
+			LocalAddress:  net.ParseIP("1.2.3.4"),
+			LocalPort:     port,
+			RemoteAddress: net.ParseIP("5.6.7.8"),
+			RemotePort:    80,
+			Proc:          procspy.Proc{PID: pid},
+		}
+	}
+	tracker := connectionTracker{
+		conf: connectionTrackerConfig{
+			HostID:   "host1",
+			SpyProcs: true,
+			WalkProc: true,
+			ProcRoot: "/proc",
+			Scanner: procspy.FixedScanner([]procspy.Connection{
+				connection(42, 12345),
+				connection(43, 12346), // no environ
+			}),
+			EnvVars: []string{"SERVICE_NAME", "UNSET"},
+		},
+		reverseResolver: newReverseResolver(),
+	}
+	rpt := report.MakeReport()
+	tracker.ReportConnections(context.Background(), &rpt)
+
+	node := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12345")]
+	if have, ok := node.Latest.Lookup(EnvPrefix + "SERVICE_NAME"); !ok || have != "web" {
+		t.Errorf("expected SERVICE_NAME to be captured, got %q", have)
+	}
+	node.Latest.ForEach(func(key string, _ time.Time, value string) {
+		if strings.HasPrefix(key, EnvPrefix) && key != EnvPrefix+"SERVICE_NAME" {
+			t.Errorf("unexpected environment variable %s=%s", key, value)
+		}
+	})
+
+	node, ok := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12346")]
+	if !ok {
+		t.Fatal("expected the endpoint of a process without an environ file")
+	}
+	if _, ok := node.Latest.Lookup(EnvPrefix + "SERVICE_NAME"); ok {
+		t.Error("expected no environment variables without an environ file")
+	}
+}
+
+func TestWalkProcSocketStats(t *testing.T) {
+	connection := func(port uint16, stats procspy.SocketStats) procspy.Connection {
+		return procspy.Connection{
+			Transport:     "tcp",
+			LocalAddress:  net.ParseIP("1.2.3.4"),
+			LocalPort:     port,
+			RemoteAddress: net.ParseIP("5.6.7.8"),
+			RemotePort:    80,
+			SocketStats:   stats,
+		}
+	}
+	tracker := connectionTracker{
+		conf: connectionTrackerConfig{
+			HostID:   "host1",
+			WalkProc: true,
+			Scanner: procspy.FixedScanner([]procspy.Connection{
+				connection(12345, procspy.SocketStats{HasQueues: true, SendQueue: 100, ReceiveQueue: 0, CongestionWindow: 10}),
+				connection(12346, procspy.SocketStats{}),
+			}),
+		},
+		reverseResolver: newReverseResolver(),
+	}
+	rpt := report.MakeReport()
+	tracker.ReportConnections(context.Background(), &rpt)
+
+	node := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12345")]
+	for key, want := range map[string]string{SendQueue: "100", ReceiveQueue: "0", CongestionWindow: "10"} {
+		if have, ok := node.Latest.Lookup(key); !ok || have != want {
+			t.Errorf("expected %s %q, got %q", key, want, have)
+		}
+	}
+
+	node, ok := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12346")]
+	if !ok {
+		t.Fatal("expected the endpoint of a connection without socket stats")
+	}
+	for _, key := range []string{SendQueue, ReceiveQueue, CongestionWindow} {
+		if have, ok := node.Latest.Lookup(key); ok {
+			t.Errorf("expected no %s, got %q", key, have)
+		}
+	}
+}
+
+func TestExcludeLoopback(t *testing.T) {
+	var (
+		loopbackToLoopback = fourTuple{"127.0.0.1", "127.0.0.1", 54321, 8080}
+		loopbackToRemote   = fourTuple{"127.0.0.1", "10.0.0.2", 54322, 80}
+		remoteToRemote     = fourTuple{"10.0.0.1", "10.0.0.2", 54323, 80}
+		endpointID         = func(addr string, port uint16) string {
+			return report.MakeEndpointNodeID("host1", "", addr, strconv.Itoa(int(port)))
+		}
+	)
+	for _, exclude := range []bool{false, true} {
+		tracker := connectionTracker{
+			conf:            connectionTrackerConfig{HostID: "host1", ExcludeLoopback: exclude},
+			reverseResolver: newReverseResolver(),
+		}
+		rpt := report.MakeReport()
+		for _, ft := range []fourTuple{loopbackToLoopback, loopbackToRemote, remoteToRemote} {
+			tracker.addConnection(&rpt, ft, "", nil, nil, report.EdgeMetadata{})
+		}
+
+		for ft, want := range map[fourTuple]bool{
+			loopbackToLoopback: !exclude,
+			loopbackToRemote:   true,
+			remoteToRemote:     true,
+		} {
+			node, ok := rpt.Endpoint.Nodes[endpointID(ft.fromAddr, ft.fromPort)]
+			have := ok && node.Adjacency.Contains(endpointID(ft.toAddr, ft.toPort))
+			if want != have {
+				t.Errorf("ExcludeLoopback=%v: %s: want reported %v, have %v", exclude, ft, want, have)
+			}
+		}
+	}
+}
+
+func TestEdgeTLSFromPorts(t *testing.T) {
+	tracker := connectionTracker{
+		conf:            connectionTrackerConfig{HostID: "host1"},
+		reverseResolver: newReverseResolver(),
+	}
+	for port, want := range map[uint16]bool{
+		443:  true,
+		8443: true,
+		993:  true,
+		6443: true,
+		80:   false,
+		8080: false,
+		5432: false,
+		25:   false,
+	} {
+		rpt := report.MakeReport()
+		ft := fourTuple{"10.0.0.1", "10.0.0.2", 54321, port}
+		tracker.addConnection(&rpt, ft, "", nil, nil, report.EdgeMetadata{})
+		from := report.MakeEndpointNodeID("host1", "", ft.fromAddr, strconv.Itoa(int(ft.fromPort)))
+		to := report.MakeEndpointNodeID("host1", "", ft.toAddr, strconv.Itoa(int(ft.toPort)))
+		edge, ok := rpt.Endpoint.Nodes[from].Edges.Lookup(to)
+		if !ok {
+			t.Fatalf("port %d: missing edge", port)
+		}
+		if edge.TLS != want {
+			t.Errorf("port %d: want TLS %v, have %v", port, want, edge.TLS)
+		}
+		if edge.TLSConfirmed {
+			t.Errorf("port %d: TLS from the port alone should only be inferred", port)
+		}
+	}
+}
+
+func TestEdgeConnectionSamples(t *testing.T) {
+	var (
+		ft   = fourTuple{"10.0.0.1", "10.0.0.2", 54321, 80}
+		from = report.MakeEndpointNodeID("host1", "", ft.fromAddr, strconv.Itoa(int(ft.fromPort)))
+		to   = report.MakeEndpointNodeID("host1", "", ft.toAddr, strconv.Itoa(int(ft.toPort)))
+	)
+	for _, sample := range []bool{false, true} {
+		tracker := connectionTracker{
+			conf:            connectionTrackerConfig{HostID: "host1", ConnectionSamples: sample},
+			reverseResolver: newReverseResolver(),
+		}
+		rpt := report.MakeReport()
+		tracker.addConnection(&rpt, ft, "", nil, nil, report.EdgeMetadata{})
+		edge, _ := rpt.Endpoint.Nodes[from].Edges.Lookup(to)
+		var want []report.ConnectionSample
+		if sample {
+			want = []report.ConnectionSample{{LocalPort: 54321, RemotePort: 80}}
+		}
+		if !reflect.DeepEqual(want, edge.ConnectionSamples) {
+			t.Errorf("ConnectionSamples=%v: want %v, have %v", sample, want, edge.ConnectionSamples)
+		}
+	}
+}
+
+func TestEndpointNAT64Addr(t *testing.T) {
+	tracker := connectionTracker{
+		conf:            connectionTrackerConfig{HostID: "host1"},
+		reverseResolver: newReverseResolver(),
+	}
+	rpt := report.MakeReport()
+	tracker.addConnection(&rpt, fourTuple{"2001:db8::1", "64:ff9b::808:808", 54321, 443}, "", nil, nil, report.EdgeMetadata{})
+
+	remote := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "64:ff9b::808:808", "443")]
+	if have, ok := remote.Latest.Lookup(NAT64Addr); !ok || have != "8.8.8.8" {
+		t.Errorf("expected the embedded IPv4 address 8.8.8.8, got %q", have)
+	}
+	local := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host1", "", "2001:db8::1", "54321")]
+	if have, ok := local.Latest.Lookup(NAT64Addr); ok {
+		t.Errorf("expected no IPv4 address for a plain IPv6 one, got %q", have)
+	}
+}
+
+func TestEdgeFirstAndLastSeen(t *testing.T) {
+	var (
+		start   = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		client  = report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12345")
+		server  = report.MakeEndpointNodeID("host1", "", "5.6.7.8", "80")
+		tracker = connectionTracker{
+			conf: connectionTrackerConfig{
+				HostID:   "host1",
+				WalkProc: true,
+				Scanner: procspy.FixedScanner([]procspy.Connection{{
+					Transport:     "tcp",
+					LocalAddress:  net.ParseIP("1.2.3.4"),
+					LocalPort:     12345,
+					RemoteAddress: net.ParseIP("5.6.7.8"),
+					RemotePort:    80,
+				}}),
+			},
+			reverseResolver: newReverseResolver(),
+			firstSeen:       newFirstSeenCache(),
+		}
+	)
+	defer mtime.NowReset()
+
+	for cycle := 0; cycle < 2; cycle++ {
+		now := start.Add(time.Duration(cycle) * 15 * time.Second)
+		mtime.NowForce(now)
+		rpt := report.MakeReport()
+		tracker.ReportConnections(context.Background(), &rpt)
+
+		edge, ok := rpt.Endpoint.Nodes[client].Edges.Lookup(server)
+		if !ok {
+			t.Fatalf("cycle %d: missing edge %s -> %s", cycle, client, server)
+		}
+		if !edge.FirstSeen.Equal(start) {
+			t.Errorf("cycle %d: want first seen %v, have %v", cycle, start, edge.FirstSeen)
+		}
+		if !edge.LastSeen.Equal(now) {
+			t.Errorf("cycle %d: want last seen %v, have %v", cycle, now, edge.LastSeen)
+		}
+	}
+}
+
+func TestEdgeConnectionsPerCycle(t *testing.T) {
+	var (
+		client  = report.MakeEndpointNodeID("host1", "", "1.2.3.4", "12345")
+		server  = report.MakeEndpointNodeID("host1", "", "5.6.7.8", "80")
+		tracker = connectionTracker{
+			conf: connectionTrackerConfig{
+				HostID:   "host1",
+				WalkProc: true,
+				Scanner: procspy.FixedScanner([]procspy.Connection{{
+					Transport:     "tcp",
+					LocalAddress:  net.ParseIP("1.2.3.4"),
+					LocalPort:     12345,
+					RemoteAddress: net.ParseIP("5.6.7.8"),
+					RemotePort:    80,
+				}}),
+			},
+			reverseResolver: newReverseResolver(),
+			firstSeen:       newFirstSeenCache(),
+		}
+	)
+
+	// The app merges the reports of successive cycles; the connection is
+	// still only one connection.
+	merged := report.MakeReport()
+	for cycle := 0; cycle < 2; cycle++ {
+		rpt := report.MakeReport()
+		tracker.ReportConnections(context.Background(), &rpt)
+		merged = merged.Merge(rpt)
+
+		for _, r := range []report.Report{rpt, merged} {
+			edge, _ := r.Endpoint.Nodes[client].Edges.Lookup(server)
+			if edge.TCPConnections == nil || *edge.TCPConnections != 1 {
+				t.Errorf("cycle %d: want 1 connection, have %v", cycle, edge)
+			}
+		}
+	}
+}
+
+type failingScanner struct{}
+
+func (failingScanner) Connections(bool) (procspy.ConnIter, error) {
+	return nil, errors.New("scan failed")
+}
+func (failingScanner) Stop() {}
+
+func TestFallbackScanner(t *testing.T) {
+	tracker := connectionTracker{
+		conf: connectionTrackerConfig{
+			HostID:   "host1",
+			WalkProc: true,
+			Scanner:  failingScanner{},
+			FallbackScanner: procspy.FixedScanner([]procspy.Connection{{
+				Transport:     "udp",
+				LocalAddress:  net.ParseIP("1.2.3.4"),
+				LocalPort:     54321,
+				RemoteAddress: net.ParseIP("5.6.7.8"),
+				RemotePort:    53,
+			}}),
+		},
+		reverseResolver: newReverseResolver(),
+	}
+	rpt := report.MakeReport()
+	if err := tracker.ReportConnections(context.Background(), &rpt); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		client = report.MakeEndpointNodeID("host1", "", "1.2.3.4", "54321")
+		server = report.MakeEndpointNodeID("host1", "", "5.6.7.8", "53")
+	)
+	edge, ok := rpt.Endpoint.Nodes[client].Edges.Lookup(server)
+	if !ok {
+		t.Fatalf("expected the fallback scanner's connection, got %v", rpt.Endpoint.Nodes)
+	}
+	if !edge.WithUDP || edge.WithTCP || edge.UDPFlows == nil || *edge.UDPFlows != 1 {
+		t.Errorf("expected a single UDP flow, got %v", edge)
+	}
+}
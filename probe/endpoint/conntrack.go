@@ -16,11 +16,13 @@ import (
 	log "github.com/Sirupsen/logrus"
 
 	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/common/fs"
 )
 
 const (
 	// From https://www.kernel.org/doc/Documentation/networking/nf_conntrack-sysctl.txt
-	eventsPath = "sys/net/netfilter/nf_conntrack_events"
+	eventsPath     = "sys/net/netfilter/nf_conntrack_events"
+	accountingPath = "sys/net/netfilter/nf_conntrack_acct"
 
 	timeWait    = "TIME_WAIT"
 	tcpProto    = "tcp"
@@ -74,6 +76,26 @@ type nilFlowWalker struct{}
 func (n nilFlowWalker) stop()                        {}
 func (n nilFlowWalker) walkFlows(f func(flow, bool)) {}
 
+// multiFlowWalker walks the flows of several flowWalkers in turn, e.g. one
+// per address family.
+type multiFlowWalker []flowWalker
+
+func (m multiFlowWalker) walkFlows(f func(flow, bool)) {
+	for _, fw := range m {
+		fw.walkFlows(f)
+	}
+}
+
+func (m multiFlowWalker) stop() {
+	for _, fw := range m {
+		fw.stop()
+	}
+}
+
+// conntrackFamilies are the address families conntrack keeps a table for.
+// Unless given one with -f, conntrack only lists the IPv4 table.
+var conntrackFamilies = []string{"ipv4", "ipv6"}
+
 // conntrackWalker uses the conntrack command to track network connections and
 // implement flowWalker.
 type conntrackWalker struct {
@@ -83,27 +105,79 @@ type conntrackWalker struct {
 	bufferedFlows []flow         // flows coming out of activeFlows spend 1 walk cycle here
 	bufferSize    int
 	args          []string
+	netns         string // path to the network namespace to watch; empty for our own
 	quit          chan struct{}
 }
 
 // newConntracker creates and starts a new conntracker.
 func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int, args ...string) flowWalker {
-	if !useConntrack {
+	if !conntrackUsable(useConntrack, procRoot) {
+		return nilFlowWalker{}
+	}
+	result := &conntrackWalker{
+		activeFlows: map[int64]flow{},
+		bufferSize:  bufferSize,
+		args:        args,
+		quit:        make(chan struct{}),
+	}
+	go result.loop()
+	return result
+}
+
+// newPerFamilyConntrackFlowWalker is like newConntrackFlowWalker, but walks
+// the flows of every address family, running a conntracker for each.
+func newPerFamilyConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int, args ...string) flowWalker {
+	if !conntrackUsable(useConntrack, procRoot) {
 		return nilFlowWalker{}
+	}
+	return newPerFamilyNamespacedConntrackFlowWalker("", bufferSize, args...)
+}
+
+func conntrackUsable(useConntrack bool, procRoot string) bool {
+	if !useConntrack {
+		return false
 	} else if err := IsConntrackSupported(procRoot); err != nil {
 		log.Warnf("Not using conntrack: not supported by the kernel: %s", err)
-		return nilFlowWalker{}
+		return false
 	}
+	return true
+}
+
+// newNamespacedConntrackFlowWalker creates and starts a new conntracker
+// watching the network namespace at netns (e.g. /proc/<pid>/ns/net) rather
+// than our own.
+func newNamespacedConntrackFlowWalker(netns string, bufferSize int, args ...string) flowWalker {
 	result := &conntrackWalker{
 		activeFlows: map[int64]flow{},
 		bufferSize:  bufferSize,
 		args:        args,
+		netns:       netns,
 		quit:        make(chan struct{}),
 	}
 	go result.loop()
 	return result
 }
 
+// newPerFamilyNamespacedConntrackFlowWalker is like
+// newNamespacedConntrackFlowWalker, but walks the flows of every address
+// family, running a conntracker for each.
+func newPerFamilyNamespacedConntrackFlowWalker(netns string, bufferSize int, args ...string) flowWalker {
+	var result multiFlowWalker
+	for _, family := range conntrackFamilies {
+		result = append(result, newNamespacedConntrackFlowWalker(netns, bufferSize, append([]string{"-f", family}, args...)...))
+	}
+	return result
+}
+
+// conntrackCommand runs conntrack, entering the network namespace at netns
+// first if given.
+func conntrackCommand(netns string, args ...string) exec.Cmd {
+	if netns == "" {
+		return exec.Command("conntrack", args...)
+	}
+	return exec.Command("nsenter", append([]string{"--net=" + netns, "conntrack"}, args...)...)
+}
+
 // IsConntrackSupported returns true if conntrack is suppported by the kernel
 var IsConntrackSupported = func(procRoot string) error {
 	// Make sure events are enabled, the conntrack CLI doesn't verify it
@@ -118,6 +192,36 @@ var IsConntrackSupported = func(procRoot string) error {
 	return nil
 }
 
+// conntrackAccounting says whether conntrack counts the packets and bytes of
+// each flow (nf_conntrack_acct), without which there are no byte counts. It
+// is checked once, warning if accounting is off or can't be told.
+type conntrackAccounting struct {
+	once     sync.Once
+	enabled  bool
+	procRoot string
+	warnf    func(format string, args ...interface{})
+}
+
+func newConntrackAccounting(procRoot string) *conntrackAccounting {
+	return &conntrackAccounting{procRoot: procRoot, warnf: log.Warnf}
+}
+
+func (a *conntrackAccounting) Enabled() bool {
+	a.once.Do(func() {
+		f := filepath.Join(a.procRoot, accountingPath)
+		contents, err := fs.ReadFile(f)
+		if err != nil {
+			a.warnf("Byte counts unavailable: can't tell if conntrack accounting is enabled: %v", err)
+			return
+		}
+		a.enabled = strings.TrimSpace(string(contents)) != "0"
+		if !a.enabled {
+			a.warnf("Byte counts unavailable: conntrack accounting (%s) is disabled; enable it with \"sysctl net.netfilter.nf_conntrack_acct=1\"", f)
+		}
+	})
+	return a.enabled
+}
+
 func (c *conntrackWalker) loop() {
 	// conntrack can sometimes fail with ENOBUFS, when there is a particularly
 	// high connection rate.  In these cases just retry in a loop, so we can
@@ -160,9 +264,9 @@ func logPipe(prefix string, reader io.Reader) {
 func (c *conntrackWalker) run() {
 	// Fork another conntrack, just to capture existing connections
 	// for which we don't get events
-	existingFlows, err := existingConnections(c.args)
+	existingFlows, err := existingConnections(c.netns, c.args)
 	if err != nil {
-		log.Errorf("conntrack existingConnections error: %v", err)
+		limitedLog.Errorf("conntrack existingConnections error: %v", err)
 		return
 	}
 	for _, flow := range existingFlows {
@@ -173,28 +277,28 @@ func (c *conntrackWalker) run() {
 		"--buffer-size", strconv.Itoa(c.bufferSize), "-E",
 		"-o", "id", "-p", "tcp"}, c.args...,
 	)
-	cmd := exec.Command("conntrack", args...)
+	cmd := conntrackCommand(c.netns, args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Errorf("conntrack error: %v", err)
+		limitedLog.Errorf("conntrack error: %v", err)
 		return
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		log.Errorf("conntrack error: %v", err)
+		limitedLog.Errorf("conntrack error: %v", err)
 		return
 	}
 	go logPipe("conntrack stderr:", stderr)
 
 	if err := cmd.Start(); err != nil {
-		log.Errorf("conntrack error: %v", err)
+		limitedLog.Errorf("conntrack error: %v", err)
 		return
 	}
 
 	defer func() {
 		if err := cmd.Wait(); err != nil {
-			log.Errorf("conntrack error: %v", err)
+			limitedLog.Errorf("conntrack error: %v", err)
 		}
 	}()
 
@@ -217,7 +321,7 @@ func (c *conntrackWalker) run() {
 	for {
 		f, err := decodeStreamedFlow(scanner)
 		if err != nil {
-			log.Errorf("conntrack error: %v", err)
+			limitedLog.Errorf("conntrack error: %v", err)
 			return
 		}
 		c.handleFlow(f, false)
@@ -354,9 +458,9 @@ func decodeStreamedFlow(scanner *bufio.Scanner) (flow, error) {
 	return f, nil
 }
 
-func existingConnections(conntrackWalkerArgs []string) ([]flow, error) {
+func existingConnections(netns string, conntrackWalkerArgs []string) ([]flow, error) {
 	args := append([]string{"-L", "-o", "id", "-p", "tcp"}, conntrackWalkerArgs...)
-	cmd := exec.Command("conntrack", args...)
+	cmd := conntrackCommand(netns, args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return []flow{}, err
@@ -366,7 +470,7 @@ func existingConnections(conntrackWalkerArgs []string) ([]flow, error) {
 	}
 	defer func() {
 		if err := cmd.Wait(); err != nil {
-			log.Errorf("conntrack existingConnections exit error: %v", err)
+			limitedLog.Errorf("conntrack existingConnections exit error: %v", err)
 		}
 	}()
 
@@ -2,11 +2,14 @@ package endpoint
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
 	"time"
 
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
 	"github.com/weaveworks/scope/test"
 )
 
@@ -251,3 +254,34 @@ var wantDumpedFlows = []flow{
 func TestDumpedFlowDecoding(t *testing.T) {
 	testFlowDecoding(t, dumpedFlowsSource, wantDumpedFlows, decodeDumpedFlow)
 }
+
+func TestConntrackAccounting(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		contents string
+		enabled  bool
+	}{
+		{"off", "0\n", false},
+		{"on", "1\n", true},
+	} {
+		fs_hook.Mock(fs.Dir("",
+			fs.Dir("proc", fs.Dir("sys", fs.Dir("net", fs.Dir("netfilter",
+				fs.File{FName: "nf_conntrack_acct", FContents: tc.contents},
+			)))),
+		))
+		var warnings []string
+		accounting := newConntrackAccounting("/proc")
+		accounting.warnf = func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		}
+		for i := 0; i < 2; i++ {
+			if have := accounting.Enabled(); have != tc.enabled {
+				t.Errorf("%s: want enabled %v, have %v", tc.name, tc.enabled, have)
+			}
+		}
+		if want := map[bool]int{true: 0, false: 1}[tc.enabled]; len(warnings) != want {
+			t.Errorf("%s: want %d warnings, have %v", tc.name, want, warnings)
+		}
+		fs_hook.Restore()
+	}
+}
@@ -0,0 +1,67 @@
+package endpoint
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// readEnv returns the values of the named variables from the environment of
+// the process with the given pid. Other variables are never kept, as the
+// environment may hold secrets. Variables which aren't set are left out.
+func readEnv(procRoot string, pid int, names []string) (map[string]string, error) {
+	buf, err := fs.ReadFile(path.Join(procRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]struct{}{}
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	result := map[string]string{}
+	for _, entry := range bytes.Split(buf, []byte{0}) {
+		i := bytes.IndexByte(entry, '=')
+		if i <= 0 {
+			continue
+		}
+		if _, ok := wanted[string(entry[:i])]; ok {
+			result[string(entry[:i])] = string(entry[i+1:])
+		}
+	}
+	return result, nil
+}
+
+// processEnv adds the allowlisted environment variables of process pid to
+// nodeInfo, under EnvPrefix. Environments are read at most once per report,
+// using cache; processes whose environment can't be read are skipped.
+func (t *connectionTracker) processEnv(pid int, cache map[int]map[string]string, nodeInfo map[string]string) {
+	if len(t.conf.EnvVars) == 0 {
+		return
+	}
+	env, ok := cache[pid]
+	if !ok {
+		var err error
+		env, err = readEnv(t.conf.ProcRoot, pid, t.conf.EnvVars)
+		logProcReadError("environment", err)
+		cache[pid] = env
+	}
+	for name, value := range env {
+		nodeInfo[EnvPrefix+name] = value
+	}
+}
+
+// logProcReadError logs failures to read what of a process from procfs,
+// other than because the process has exited. The path is left out, so that
+// e.g. being denied access is logged once, not once per process.
+func logProcReadError(what string, err error) {
+	if err == nil || os.IsNotExist(err) {
+		return
+	}
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	limitedLog.Warnf("Error reading process %s: %v", what, err)
+}
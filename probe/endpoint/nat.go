@@ -1,8 +1,13 @@
 package endpoint
 
 import (
+	"fmt"
+	"net"
+	"os"
+	"path"
 	"strconv"
 
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -19,10 +24,46 @@ type endpointMapping struct {
 // natMapper rewrites a report to deal with NAT'd connections.
 type natMapper struct {
 	flowWalker
+
+	// If namespaces is set, NAT done inside other network namespaces (e.g.
+	// by containers with their own iptables rules) is tracked too, with a
+	// flowWalker per namespace. Their rewrites only apply to endpoints seen
+	// from that namespace.
+	procRoot           string
+	hostNamespace      string
+	namespaces         map[string]flowWalker
+	newNamespaceWalker func(netns string) flowWalker
 }
 
 func makeNATMapper(fw flowWalker) natMapper {
-	return natMapper{fw}
+	return natMapper{flowWalker: fw}
+}
+
+// makeNamespacedNATMapper makes a natMapper which, as well as fw for the
+// host's network namespace (hostNamespace), starts a flowWalker for every
+// other network namespace endpoints are reported from.
+func makeNamespacedNATMapper(fw flowWalker, procRoot, hostNamespace string, newNamespaceWalker func(netns string) flowWalker) natMapper {
+	return natMapper{
+		flowWalker:         fw,
+		procRoot:           procRoot,
+		hostNamespace:      hostNamespace,
+		namespaces:         map[string]flowWalker{},
+		newNamespaceWalker: newNamespaceWalker,
+	}
+}
+
+// hostNetworkNamespace returns the ID of the network namespace of init, as
+// used for endpoint IDs.
+func hostNetworkNamespace(procRoot string) (string, error) {
+	link, err := os.Readlink(path.Join(procRoot, "1", "ns", "net"))
+	if err != nil {
+		return "", err
+	}
+	var id uint64
+	if _, err := fmt.Sscanf(link, "net:[%d]", &id); err != nil {
+		return "", fmt.Errorf("unexpected network namespace %q: %v", link, err)
+	}
+	return strconv.FormatUint(id, 10), nil
 }
 
 func toMapping(f flow) *endpointMapping {
@@ -46,26 +87,139 @@ func toMapping(f flow) *endpointMapping {
 	return &mapping
 }
 
+// ipFamily returns 4 or 6 for IPv4 or IPv6 addresses, and 0 otherwise.
+func ipFamily(addr string) int {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return 0
+	case ip.To4() != nil:
+		return 4
+	}
+	return 6
+}
+
+// natAliases maps the IDs of the endpoints copied by applyNAT to the IDs of
+// the endpoints they are copies of.
+type natAliases map[string]string
+
 // applyNAT duplicates Nodes in the endpoint topology of a report, based on
-// the NAT table.
+// the NAT table, then merges the edges to a copy into the edges to the
+// endpoint it is a copy of.
 func (n natMapper) applyNAT(rpt report.Report, scope string) {
-	n.flowWalker.walkFlows(func(f flow, active bool) {
+	aliases := natAliases{}
+	applyFlows(n.flowWalker, rpt, scope, "", aliases)
+	if n.namespaces != nil {
+		n.updateNamespaces(rpt)
+		for namespaceID, fw := range n.namespaces {
+			applyFlows(fw, rpt, scope, namespaceID, aliases)
+		}
+	}
+	mergeAliasEdges(rpt, aliases)
+}
+
+// applyFlows applies the NAT table of the network namespace namespaceID, or
+// of the host if that's empty, to the endpoints seen from it, recording the
+// copies it makes in aliases.
+func applyFlows(fw flowWalker, rpt report.Report, scope, namespaceID string, aliases natAliases) {
+	fw.walkFlows(func(f flow, active bool) {
 		mapping := toMapping(f)
+		// Translations between address families (NAT64) would make
+		// copies of endpoints in the other family; leave them be.
+		if ipFamily(mapping.originalIP) != ipFamily(mapping.rewrittenIP) {
+			return
+		}
 
 		realEndpointPort := strconv.Itoa(mapping.originalPort)
 		copyEndpointPort := strconv.Itoa(mapping.rewrittenPort)
-		realEndpointID := report.MakeEndpointNodeID(scope, "", mapping.originalIP, realEndpointPort)
-		copyEndpointID := report.MakeEndpointNodeID(scope, "", mapping.rewrittenIP, copyEndpointPort)
+		realEndpointID := report.MakeEndpointNodeID(scope, namespaceID, mapping.originalIP, realEndpointPort)
+		copyEndpointID := report.MakeEndpointNodeID(scope, namespaceID, mapping.rewrittenIP, copyEndpointPort)
 
 		node, ok := rpt.Endpoint.Nodes[realEndpointID]
 		if !ok {
 			return
 		}
+		// Only loopback endpoint IDs include the namespace, so check we
+		// haven't matched the same address in some other namespace.
+		if namespaceID != "" {
+			if id, _ := node.Get(KeyNamespace, networkNamespaceKey); id != namespaceID {
+				return
+			}
+		}
 
 		rpt.Endpoint.AddNode(node.WithID(copyEndpointID).WithLatests(map[string]string{
 			Addr:      mapping.rewrittenIP,
 			Port:      copyEndpointPort,
 			"copy_of": realEndpointID,
 		}))
+		aliases[copyEndpointID] = realEndpointID
 	})
 }
+
+// mergeAliasEdges finds the endpoints with edges to both an endpoint and its
+// NAT alias, i.e. which were seen connecting to it both before and after the
+// address was rewritten, and coalesces the edge to the alias into the other.
+// Otherwise, the one connection would be counted twice.
+func mergeAliasEdges(rpt report.Report, aliases natAliases) {
+	if len(aliases) == 0 {
+		return
+	}
+	for id, node := range rpt.Endpoint.Nodes {
+		var duplicates []string
+		for _, dst := range node.Adjacency {
+			if real, ok := aliases[dst]; ok && node.Adjacency.Contains(real) {
+				duplicates = append(duplicates, dst)
+			}
+		}
+		if len(duplicates) == 0 {
+			continue
+		}
+		node.Adjacency = node.Adjacency.Copy().Remove(duplicates...)
+		for _, dst := range duplicates {
+			md, ok := node.Edges.Lookup(dst)
+			if !ok {
+				continue
+			}
+			real := aliases[dst]
+			if realMD, ok := node.Edges.Lookup(real); ok {
+				md = realMD.Coalesce(md)
+			}
+			node.Edges = node.Edges.Delete(dst).Delete(real).Add(real, md)
+		}
+		rpt.Endpoint.Nodes[id] = node
+	}
+}
+
+// updateNamespaces starts a flowWalker for each network namespace, other
+// than the host's, with a process in the report, and stops the ones for
+// namespaces which have gone away.
+func (n natMapper) updateNamespaces(rpt report.Report) {
+	pids := map[string]string{}
+	for _, node := range rpt.Endpoint.Nodes {
+		namespaceID, ok := node.Get(KeyNamespace, networkNamespaceKey)
+		if !ok || namespaceID == n.hostNamespace {
+			continue
+		}
+		if pid, ok := node.Latest.Lookup(process.PID); ok {
+			pids[namespaceID] = pid
+		}
+	}
+	for namespaceID, fw := range n.namespaces {
+		if _, ok := pids[namespaceID]; !ok {
+			fw.stop()
+			delete(n.namespaces, namespaceID)
+		}
+	}
+	for namespaceID, pid := range pids {
+		if _, ok := n.namespaces[namespaceID]; !ok {
+			n.namespaces[namespaceID] = n.newNamespaceWalker(path.Join(n.procRoot, pid, "ns", "net"))
+		}
+	}
+}
+
+func (n natMapper) stop() {
+	n.flowWalker.stop()
+	for _, fw := range n.namespaces {
+		fw.stop()
+	}
+}
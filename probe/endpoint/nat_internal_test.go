@@ -5,6 +5,7 @@ import (
 
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/reflect"
 )
@@ -150,3 +151,180 @@ func TestNat(t *testing.T) {
 		}
 	}
 }
+
+// natFlow makes a flow for a connection from client to rewritten, which NAT
+// sent to original instead.
+func natFlow(id int64, client string, clientPort int, rewritten string, rewrittenPort int, original string, originalPort int) flow {
+	return flow{
+		Type: updateType,
+		Original: meta{
+			Layer3: layer3{SrcIP: client, DstIP: rewritten},
+			Layer4: layer4{SrcPort: clientPort, DstPort: rewrittenPort, Proto: "tcp"},
+		},
+		Reply: meta{
+			Layer3: layer3{SrcIP: original, DstIP: client},
+			Layer4: layer4{SrcPort: originalPort, DstPort: clientPort, Proto: "tcp"},
+		},
+		Independent: meta{ID: id},
+	}
+}
+
+func TestNatPerNamespace(t *testing.T) {
+	var (
+		// Both namespaces have a rule for 10.0.47.1:80, and for
+		// 127.0.0.1:15001, but only namespace 100 has a container listening
+		// on the former and only namespace 200 on the latter.
+		walkers = map[string]flowWalker{
+			"/proc/10/ns/net": &mockFlowWalker{flows: []flow{
+				natFlow(1, "2.3.4.5", 22222, "1.2.3.4", 80, "10.0.47.1", 80),
+				natFlow(2, "10.0.0.3", 33333, "10.0.0.3", 9090, "127.0.0.1", 15001),
+			}},
+			"/proc/20/ns/net": &mockFlowWalker{flows: []flow{
+				natFlow(3, "2.3.4.5", 22222, "5.6.7.8", 80, "10.0.47.1", 80),
+				natFlow(4, "10.0.0.2", 33333, "10.0.0.2", 9090, "127.0.0.1", 15001),
+			}},
+		}
+		mapper = makeNamespacedNATMapper(&mockFlowWalker{}, "/proc", "1", func(netns string) flowWalker {
+			return walkers[netns]
+		})
+		serverID   = report.MakeEndpointNodeID("host1", "100", "10.0.47.1", "80")
+		loopbackID = report.MakeEndpointNodeID("host1", "200", "127.0.0.1", "15001")
+		rpt        = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNodeWith(serverID, map[string]string{
+		Addr: "10.0.47.1", Port: "80", NetworkNamespace: "100", process.PID: "10",
+	}))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(loopbackID, map[string]string{
+		Addr: "127.0.0.1", Port: "15001", NetworkNamespace: "200", process.PID: "20",
+	}))
+
+	mapper.applyNAT(rpt, "host1")
+
+	for id, copyOf := range map[string]string{
+		report.MakeEndpointNodeID("host1", "100", "1.2.3.4", "80"):    serverID,
+		report.MakeEndpointNodeID("host1", "200", "10.0.0.2", "9090"): loopbackID,
+	} {
+		node, ok := rpt.Endpoint.Nodes[id]
+		if !ok {
+			t.Errorf("expected NAT copy %s", id)
+			continue
+		}
+		if have, _ := node.Latest.Lookup("copy_of"); have != copyOf {
+			t.Errorf("expected %s to be a copy of %s, got %s", id, copyOf, have)
+		}
+	}
+	for _, id := range []string{
+		report.MakeEndpointNodeID("host1", "200", "5.6.7.8", "80"),
+		report.MakeEndpointNodeID("host1", "100", "10.0.0.3", "9090"),
+		report.MakeEndpointNodeID("host1", "200", "10.0.0.3", "9090"),
+	} {
+		if _, ok := rpt.Endpoint.Nodes[id]; ok {
+			t.Errorf("unexpected NAT copy %s from another namespace", id)
+		}
+	}
+	if len(rpt.Endpoint.Nodes) != 4 {
+		t.Errorf("expected 4 endpoints, got %v", rpt.Endpoint.Nodes)
+	}
+
+	// Namespaces no longer in the report stop being tracked.
+	mapper.applyNAT(report.MakeReport(), "host1")
+	if len(mapper.namespaces) != 0 {
+		t.Errorf("expected no tracked namespaces, got %v", mapper.namespaces)
+	}
+}
+
+func TestNatPerFamily(t *testing.T) {
+	var (
+		v4 = &mockFlowWalker{flows: []flow{
+			natFlow(1, "2.3.4.5", 22222, "1.2.3.4", 80, "10.0.47.1", 80),
+		}}
+		v6 = &mockFlowWalker{flows: []flow{
+			natFlow(1, "2001:db8::9", 22222, "2001:db8::1", 80, "fd00::5", 80),
+			// NAT64, from a v6 client to the v4 server.
+			natFlow(2, "2001:db8::9", 33333, "64:ff9b::a00:2f01", 80, "10.0.47.1", 80),
+		}}
+		server4ID = report.MakeEndpointNodeID("host1", "", "10.0.47.1", "80")
+		server6ID = report.MakeEndpointNodeID("host1", "", "fd00::5", "80")
+		rpt       = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNodeWith(server4ID, map[string]string{Addr: "10.0.47.1", Port: "80"}))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(server6ID, map[string]string{Addr: "fd00::5", Port: "80"}))
+
+	makeNATMapper(multiFlowWalker{v4, v6}).applyNAT(rpt, "host1")
+
+	for id, copyOf := range map[string]string{
+		report.MakeEndpointNodeID("host1", "", "1.2.3.4", "80"):     server4ID,
+		report.MakeEndpointNodeID("host1", "", "2001:db8::1", "80"): server6ID,
+	} {
+		node, ok := rpt.Endpoint.Nodes[id]
+		if !ok {
+			t.Errorf("expected NAT copy %s", id)
+			continue
+		}
+		if have, _ := node.Latest.Lookup("copy_of"); have != copyOf {
+			t.Errorf("expected %s to be a copy of %s, got %s", id, copyOf, have)
+		}
+	}
+	nat64ID := report.MakeEndpointNodeID("host1", "", "64:ff9b::a00:2f01", "80")
+	if _, ok := rpt.Endpoint.Nodes[nat64ID]; ok {
+		t.Errorf("unexpected NAT copy %s across address families", nat64ID)
+	}
+	if len(rpt.Endpoint.Nodes) != 4 {
+		t.Errorf("expected 4 endpoints, got %v", rpt.Endpoint.Nodes)
+	}
+}
+
+func TestNatMergesAliasEdges(t *testing.T) {
+	mtime.NowForce(mtime.Now())
+	defer mtime.NowReset()
+
+	// A client connects to 1.2.3.4:80, which DNAT sends to a container on
+	// 10.0.47.1:80. The connection was seen both before and after it was
+	// rewritten, as edges to each address.
+	var (
+		clientID  = report.MakeEndpointNodeID("host1", "", "2.3.4.5", "22222")
+		serverID  = report.MakeEndpointNodeID("host1", "", "10.0.47.1", "80")
+		aliasID   = report.MakeEndpointNodeID("host1", "", "1.2.3.4", "80")
+		otherID   = report.MakeEndpointNodeID("host1", "", "10.0.47.9", "80")
+		ct        = &mockFlowWalker{flows: []flow{natFlow(1, "2.3.4.5", 22222, "1.2.3.4", 80, "10.0.47.1", 80)}}
+		rpt       = report.MakeReport()
+		u64       = func(v uint64) *uint64 { return &v }
+		preNAT    = report.EdgeMetadata{EgressByteCount: u64(100), WithTCP: true, TCPConnections: u64(1)}
+		postNAT   = report.EdgeMetadata{EgressByteCount: u64(50), WithTCP: true, TCPConnections: u64(1)}
+		unrelated = report.EdgeMetadata{EgressByteCount: u64(7)}
+	)
+	rpt.Endpoint.AddNode(report.MakeNode(clientID).
+		WithEdge(aliasID, preNAT).
+		WithEdge(serverID, postNAT).
+		WithEdge(otherID, unrelated))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(serverID, map[string]string{Addr: "10.0.47.1", Port: "80"}))
+	rpt.Endpoint.AddNode(report.MakeNode(otherID))
+
+	makeNATMapper(ct).applyNAT(rpt, "host1")
+
+	client := rpt.Endpoint.Nodes[clientID]
+	if want := report.MakeIDList(serverID, otherID); !reflect.DeepEqual(want, client.Adjacency) {
+		t.Errorf("adjacency: %s", test.Diff(want, client.Adjacency))
+	}
+	if _, ok := client.Edges.Lookup(aliasID); ok {
+		t.Errorf("expected the edge to the alias to be merged away, got %v", client.Edges)
+	}
+	edge, ok := client.Edges.Lookup(serverID)
+	if !ok {
+		t.Fatalf("missing the edge to the server: %v", client.Edges)
+	}
+	if *edge.EgressByteCount != 100 {
+		t.Errorf("expected the bytes seen either side of NAT to be counted once, got %d", *edge.EgressByteCount)
+	}
+	if *edge.TCPConnections != 1 {
+		t.Errorf("expected the connection to be counted once, got %d", *edge.TCPConnections)
+	}
+	if md, _ := client.Edges.Lookup(otherID); *md.EgressByteCount != 7 {
+		t.Errorf("expected other edges to be left alone, got %v", md)
+	}
+
+	// The alias itself is still reported, for connections from elsewhere.
+	if _, ok := rpt.Endpoint.Nodes[aliasID]; !ok {
+		t.Errorf("expected NAT copy %s", aliasID)
+	}
+}
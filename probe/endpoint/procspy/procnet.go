@@ -35,7 +35,7 @@ again:
 	b := p.b
 
 	var (
-		sl, local, remote, state, inode []byte
+		sl, local, remote, state, queues, inode []byte
 	)
 
 	sl, b = nextField(b) // 'sl' column
@@ -54,16 +54,17 @@ again:
 		p.b = nextLine(b)
 		goto again
 	}
-	_, b = nextField(b) // 'tx_queue' column
-	_, b = nextField(b) // 'rx_queue' column
-	_, b = nextField(b) // 'tr' column
-	_, b = nextField(b) // 'uid' column
-	_, b = nextField(b) // 'timeout' column
+	queues, b = nextField(b) // 'tx_queue:rx_queue' column
+	_, b = nextField(b)      // 'tr:tm->when' column
+	_, b = nextField(b)      // 'retrnsmt' column
+	_, b = nextField(b)      // 'uid' column
+	_, b = nextField(b)      // 'timeout' column
 	inode, b = nextField(b)
 
 	p.c.LocalAddress, p.c.LocalPort = scanAddressNA(local, &p.bytesLocal)
 	p.c.RemoteAddress, p.c.RemotePort = scanAddressNA(remote, &p.bytesRemote)
 	p.c.inode = parseDec(inode)
+	p.c.SocketStats = scanSocketStats(queues, b)
 	p.b = nextLine(b)
 	if _, alreadySeen := p.seen[p.c.inode]; alreadySeen {
 		goto again
@@ -72,6 +73,32 @@ again:
 	return &p.c
 }
 
+// scanSocketStats parses the 'tx_queue:rx_queue' column, and the congestion
+// window from the rest of the line, which only TCP sockets have: after the
+// inode come the refcount, socket pointer, rto, ato, quick ack, cwnd and
+// ssthresh columns.
+func scanSocketStats(queues, rest []byte) SocketStats {
+	var stats SocketStats
+	if col := bytes.IndexByte(queues, ':'); col != -1 {
+		stats.HasQueues = true
+		stats.SendQueue = uint64(parseHex(queues[:col]))
+		stats.ReceiveQueue = uint64(parseHex(queues[col+1:]))
+	}
+	if i := bytes.IndexByte(rest, '\n'); i != -1 {
+		rest = rest[:i]
+	}
+	var cwnd []byte
+	for i := 0; i < 6; i++ {
+		cwnd, rest = nextField(rest)
+	}
+	// The cwnd column is always followed by ssthresh, so if it was found,
+	// there's more of the line left.
+	if rest != nil {
+		stats.CongestionWindow = parseDec(cwnd)
+	}
+	return stats
+}
+
 // scanAddressNA parses 'A12CF62E:00AA' to the address/port. Handles IPv4 and
 // IPv6 addresses. The address is a big endian 32 bit ints, hex encoded. We
 // just decode the hex and flip the bytes in every group of 4.
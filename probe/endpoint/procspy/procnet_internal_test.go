@@ -21,6 +21,7 @@ func TestProcNet(t *testing.T) {
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
 			RemotePort:    0x0,
 			inode:         5107,
+			SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 		},
 		{
 			LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
@@ -28,6 +29,7 @@ func TestProcNet(t *testing.T) {
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
 			RemotePort:    0x0,
 			inode:         5084,
+			SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 		},
 		{
 			LocalAddress:  net.IP([]byte{0x7f, 0x0, 0x0, 0x01}),
@@ -35,6 +37,7 @@ func TestProcNet(t *testing.T) {
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
 			RemotePort:    0x0,
 			inode:         10550,
+			SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 		},
 		{
 			LocalAddress:  net.IP([]byte{0x2e, 0xf6, 0x2c, 0xa1}),
@@ -42,6 +45,7 @@ func TestProcNet(t *testing.T) {
 			RemoteAddress: net.IP([]byte{0xc0, 0x1e, 0xfc, 0x57}),
 			RemotePort:    0x01bb,
 			inode:         639474,
+			SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 		},
 	}
 	for i := 0; i < 4; i++ {
@@ -73,7 +77,8 @@ func TestTransport6(t *testing.T) {
 			RemoteAddress: net.IP(make([]byte, 16)),
 			RemotePort:    0x0,
 			// uid:           0,
-			inode: 23661201,
+			inode:       23661201,
+			SocketStats: SocketStats{HasQueues: true, CongestionWindow: 10},
 		},
 		{
 			// state: 1,
@@ -92,7 +97,8 @@ func TestTransport6(t *testing.T) {
 			}),
 			RemotePort: 0x01bb,
 			// uid:        1000,
-			inode: 36856710,
+			inode:       36856710,
+			SocketStats: SocketStats{HasQueues: true, CongestionWindow: 8},
 		},
 	}
 
@@ -149,6 +155,7 @@ func TestProcNetFiltersDuplicates(t *testing.T) {
 		RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
 		RemotePort:    0x0,
 		inode:         5107,
+		SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 	}
 	have := p.Next()
 	want := expected
@@ -0,0 +1,109 @@
+package procspy
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/probe/process"
+)
+
+// procNetFiles are read by the procNetScanner, with the transport of the
+// connections in each.
+var procNetFiles = []struct {
+	name, transport string
+}{
+	{"tcp", "tcp"},
+	{"tcp6", "tcp"},
+	{"udp", "udp"},
+	{"udp6", "udp"},
+}
+
+type procNetScanner struct {
+	procRoot string
+}
+
+// NewProcNetScanner makes a ConnectionScanner which reads the connections
+// straight from procRoot/net/{tcp,tcp6,udp,udp6}, and finds their processes
+// by looking through procRoot/PID/fd. Unlike the usual scanner it doesn't
+// walk the network namespaces of other processes, so it only sees the
+// connections in its own, but it needs nothing else. It is meant as a
+// fallback for when the usual scanner fails.
+func NewProcNetScanner(procRoot string) ConnectionScanner {
+	return procNetScanner{procRoot: procRoot}
+}
+
+// Connections implements ConnectionScanner. Of UDP sockets, only connected
+// ones are listed.
+func (s procNetScanner) Connections(processes bool) (ConnIter, error) {
+	var (
+		conns []Connection
+		read  bool
+	)
+	for _, file := range procNetFiles {
+		buf, err := fs.ReadFile(filepath.Join(s.procRoot, "net", file.name))
+		if err != nil {
+			continue
+		}
+		read = true
+		pn := NewProcNet(buf)
+		for c := pn.Next(); c != nil; c = pn.Next() {
+			conn := *c
+			// ProcNet reuses its address buffers.
+			conn.LocalAddress = append(net.IP(nil), c.LocalAddress...)
+			conn.RemoteAddress = append(net.IP(nil), c.RemoteAddress...)
+			conn.Transport = file.transport
+			conns = append(conns, conn)
+		}
+	}
+	if !read {
+		return nil, fmt.Errorf("cannot read connections from %s", filepath.Join(s.procRoot, "net"))
+	}
+
+	var err error
+	if processes {
+		var sockets map[uint64]*Proc
+		if sockets, err = s.sockets(); err != nil {
+			err = ProcessLookupError{err}
+		}
+		for i, conn := range conns {
+			if proc, ok := sockets[conn.inode]; ok {
+				conns[i].Proc = *proc
+			}
+		}
+	}
+	iter, _ := FixedScanner(conns).Connections(processes)
+	return iter, err
+}
+
+// sockets maps the inode of every socket open by a process to the process.
+func (s procNetScanner) sockets() (map[uint64]*Proc, error) {
+	var (
+		sockets = map[uint64]*Proc{}
+		statT   syscall.Stat_t
+	)
+	err := process.NewWalker(s.procRoot).Walk(func(p, _ process.Process) {
+		fdBase := filepath.Join(s.procRoot, strconv.Itoa(p.PID), "fd")
+		fds, err := fs.ReadDirNames(fdBase)
+		if err != nil {
+			// Process is gone by now, or we don't have access.
+			return
+		}
+		proc := &Proc{PID: uint(p.PID), Name: p.Name}
+		for _, fd := range fds {
+			if err := fs.Stat(filepath.Join(fdBase, fd), &statT); err != nil {
+				continue
+			}
+			if statT.Mode&syscall.S_IFMT == syscall.S_IFSOCK {
+				sockets[statT.Ino] = proc
+			}
+		}
+	})
+	return sockets, err
+}
+
+// Stop implements ConnectionScanner (dummy since there is no background work)
+func (procNetScanner) Stop() {}
@@ -0,0 +1,105 @@
+package procspy
+
+import (
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+)
+
+const (
+	procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+	procNetTCP = procNetHeader +
+		// listening, so skipped
+		"   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 100 1 ffff8800a6aaf040 100 0 0 10 0\n" +
+		// established, 10.0.0.1:54321 -> 10.0.0.2:80
+		"   1: 0100000A:D431 0200000A:0050 01 00000010:00000020 00:00000000 00000000     0        0 101 1 ffff8800a6aaf740 100 0 0 10 0\n" +
+		// close wait, 10.0.0.1:54322 -> 10.0.0.3:443
+		"   2: 0100000A:D432 0300000A:01BB 08 00000000:00000000 00:00000000 00000000     0        0 102 1 ffff8800a729b780 100 0 0 10 0\n"
+
+	procNetTCP6 = procNetHeader +
+		// established, [2001:db8::1]:54323 -> [2001:db8::2]:8080
+		"   0: B80D0120000000000000000001000000:D433 B80D0120000000000000000002000000:1F90 01 00000000:00000000 00:00000000 00000000     0        0 103 1 ffff8800a6aaf040 100 0 0 10 0\n"
+
+	procNetUDP = procNetHeader +
+		// unconnected, so skipped
+		"   0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 104 2 ffff8800a6aaf040 0\n" +
+		// connected, 10.0.0.1:54324 -> 10.0.0.4:53
+		"   1: 0100000A:D434 0400000A:0035 01 00000000:00000000 00:00000000 00000000     0        0 105 2 ffff8800a6aaf040 0\n"
+)
+
+func procNetFS() fs.Entry {
+	socket := func(fd string, inode uint64) fs.Entry {
+		return fs.File{FName: fd, FStat: syscall.Stat_t{Ino: inode, Mode: syscall.S_IFSOCK}}
+	}
+	return fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("net",
+				fs.File{FName: "tcp", FContents: procNetTCP},
+				fs.File{FName: "tcp6", FContents: procNetTCP6},
+				fs.File{FName: "udp", FContents: procNetUDP},
+			),
+			fs.Dir("42",
+				fs.Dir("fd",
+					socket("3", 101),
+					socket("4", 103),
+					socket("5", 105),
+					fs.File{FName: "6", FStat: syscall.Stat_t{Ino: 102}}, // not a socket
+				),
+				fs.File{FName: "cmdline", FContents: "curl"},
+				fs.File{FName: "stat", FContents: "42 na R 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0"},
+				fs.File{FName: "limits", FContents: ""},
+			),
+		),
+	)
+}
+
+func TestProcNetScanner(t *testing.T) {
+	fs_hook.Mock(procNetFS())
+	defer fs_hook.Restore()
+
+	curl := Proc{PID: 42, Name: "curl"}
+	want := []Connection{
+		{Transport: "tcp", LocalAddress: net.ParseIP("10.0.0.1"), LocalPort: 54321, RemoteAddress: net.ParseIP("10.0.0.2"), RemotePort: 80, inode: 101, Proc: curl, SocketStats: SocketStats{HasQueues: true, SendQueue: 16, ReceiveQueue: 32, CongestionWindow: 10}},
+		{Transport: "tcp", LocalAddress: net.ParseIP("10.0.0.1"), LocalPort: 54322, RemoteAddress: net.ParseIP("10.0.0.3"), RemotePort: 443, inode: 102, SocketStats: SocketStats{HasQueues: true, CongestionWindow: 10}},
+		{Transport: "tcp", LocalAddress: net.ParseIP("2001:db8::1"), LocalPort: 54323, RemoteAddress: net.ParseIP("2001:db8::2"), RemotePort: 8080, inode: 103, Proc: curl, SocketStats: SocketStats{HasQueues: true, CongestionWindow: 10}},
+		{Transport: "udp", LocalAddress: net.ParseIP("10.0.0.1"), LocalPort: 54324, RemoteAddress: net.ParseIP("10.0.0.4"), RemotePort: 53, inode: 105, Proc: curl, SocketStats: SocketStats{HasQueues: true}},
+	}
+
+	for _, processes := range []bool{true, false} {
+		iter, err := NewProcNetScanner("/proc").Connections(processes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have []Connection
+		for c := iter.Next(); c != nil; c = iter.Next() {
+			// Compare addresses in their canonical form.
+			c.LocalAddress, c.RemoteAddress = net.ParseIP(c.LocalAddress.String()), net.ParseIP(c.RemoteAddress.String())
+			have = append(have, *c)
+		}
+		expected := want
+		if !processes {
+			expected = make([]Connection, len(want))
+			for i, c := range want {
+				c.Proc = Proc{}
+				expected[i] = c
+			}
+		}
+		if !reflect.DeepEqual(expected, have) {
+			t.Errorf("processes=%v: want %+v, have %+v", processes, expected, have)
+		}
+	}
+}
+
+func TestProcNetScannerUnavailable(t *testing.T) {
+	fs_hook.Mock(fs.Dir("", fs.Dir("proc")))
+	defer fs_hook.Restore()
+
+	if _, err := NewProcNetScanner("/proc").Connections(false); err == nil {
+		t.Error("expected an error without /proc/net")
+	}
+}
@@ -24,6 +24,18 @@ type Connection struct {
 	RemotePort    uint16
 	inode         uint64
 	Proc
+	SocketStats
+}
+
+// SocketStats are what is known of the state of a connection's socket, where
+// the scanner can tell: only when it reads /proc/net/tcp{,6}.
+type SocketStats struct {
+	// HasQueues is set if SendQueue and ReceiveQueue are known: the bytes
+	// not yet acknowledged by the peer, and not yet read by the process.
+	HasQueues               bool
+	SendQueue, ReceiveQueue uint64
+	// CongestionWindow is in segments, and zero if unknown.
+	CongestionWindow uint64
 }
 
 // Proc is a single process with PID and process name.
@@ -38,13 +50,25 @@ type ConnIter interface {
 	Next() *Connection
 }
 
+// ProcessLookupError is returned by Connections, together with the
+// connections, when they could be listed but the processes owning them could
+// not be looked up. The connections then have no Proc filled in.
+type ProcessLookupError struct {
+	Err error
+}
+
+func (e ProcessLookupError) Error() string {
+	return "process info unavailable: " + e.Err.Error()
+}
+
 // ConnectionScanner scans the system for established (TCP) connections
 type ConnectionScanner interface {
 	// Connections returns all established (TCP) connections.  If processes is
 	// false we'll just list all TCP connections, and there is no need to be root.
 	// If processes is true it'll additionally try to lookup the process owning the
 	// connection, filling in the Proc field. You will need to run this as root to
-	// find all processes.
+	// find all processes. If that lookup fails, the connections are returned
+	// anyway, along with a ProcessLookupError.
 	Connections(processes bool) (ConnIter, error)
 	// Stops the scanning
 	Stop()
@@ -42,21 +42,11 @@ func (s *darwinScanner) Connections(processes bool) (ConnIter, error) {
 	}
 	connections := parseDarwinNetstat(string(out))
 
+	var partialErr error
 	if processes {
-		out, err := exec.Command(
-			lsofBinary,
-			"-i",       // only Internet files
-			"-n", "-P", // no number resolving
-			"-w",             // no warnings
-			"-F", lsofFields, // \n based output of only the fields we want.
-		).CombinedOutput()
+		procs, err := lsofProcesses()
 		if err != nil {
-			return nil, err
-		}
-
-		procs, err := parseLSOF(string(out))
-		if err != nil {
-			return nil, err
+			partialErr = ProcessLookupError{err}
 		}
 		for local, proc := range procs {
 			for i, c := range connections {
@@ -72,7 +62,21 @@ func (s *darwinScanner) Connections(processes bool) (ConnIter, error) {
 	}
 
 	f := fixedConnIter(connections)
-	return &f, nil
+	return &f, partialErr
+}
+
+func lsofProcesses() (map[string]Proc, error) {
+	out, err := exec.Command(
+		lsofBinary,
+		"-i",       // only Internet files
+		"-n", "-P", // no number resolving
+		"-w",             // no warnings
+		"-F", lsofFields, // \n based output of only the fields we want.
+	).CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	return parseLSOF(string(out))
 }
 
 // Nothing to stop since there's nothing running in the background
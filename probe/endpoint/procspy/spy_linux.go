@@ -53,11 +53,16 @@ func (s *linuxScanner) Connections(processes bool) (ConnIter, error) {
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
 
-	var procs map[uint64]*Proc
+	var (
+		procs      map[uint64]*Proc
+		partialErr error
+	)
 	if processes {
 		var err error
 		if procs, err = s.r.getWalkedProcPid(buf); err != nil {
-			return nil, err
+			// Carry on with the connections from /proc/net
+			buf.Reset()
+			procs, partialErr = nil, ProcessLookupError{err}
 		}
 	}
 
@@ -70,7 +75,7 @@ func (s *linuxScanner) Connections(processes bool) (ConnIter, error) {
 		pn:    NewProcNet(buf.Bytes()),
 		buf:   buf,
 		procs: procs,
-	}, nil
+	}, partialErr
 }
 
 func (s *linuxScanner) Stop() {
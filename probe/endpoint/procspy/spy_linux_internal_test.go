@@ -31,6 +31,7 @@ func TestLinuxConnections(t *testing.T) {
 		RemoteAddress: net.ParseIP("0.0.0.0").To4(),
 		RemotePort:    0,
 		inode:         5107,
+		SocketStats:   SocketStats{HasQueues: true, CongestionWindow: 10},
 		Proc: Proc{
 			PID:  1,
 			Name: "foo",
@@ -1,25 +1,99 @@
 package endpoint
 
 import (
+	"sync"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/scope/common/logging"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
-// Node metadata keys.
+// KeyNamespace is the namespace of the keys the endpoint reporter writes
+// (see report.NamespacedKey).
+const KeyNamespace = "endpoint"
+
+// The names within KeyNamespace of the keys which are set and looked up on
+// nodes directly, with report.Node's Set and Get.
 const (
-	Addr            = "addr" // typically IPv4
-	Port            = "port"
-	Conntracked     = "conntracked"
-	EBPF            = "eBPF"
-	Procspied       = "procspied"
-	ReverseDNSNames = "reverse_dns_names"
-	SnoopedDNSNames = "snooped_dns_names"
+	serviceKey          = "service"
+	networkNamespaceKey = "network_namespace"
 )
 
+// Node metadata keys, in the KeyNamespace namespace.
+var (
+	Addr            = report.NamespacedKey(KeyNamespace, "addr") // typically IPv4
+	Port            = report.NamespacedKey(KeyNamespace, "port")
+	Conntracked     = report.NamespacedKey(KeyNamespace, "conntracked")
+	EBPF            = report.NamespacedKey(KeyNamespace, "eBPF")
+	Procspied       = report.NamespacedKey(KeyNamespace, "procspied")
+	ReverseDNSNames = report.NamespacedKey(KeyNamespace, "reverse_dns_names")
+	SnoopedDNSNames = report.NamespacedKey(KeyNamespace, "snooped_dns_names")
+
+	// Service is the name of the service usually found on an endpoint's
+	// port, if it is well known and the endpoint was connected to.
+	Service = report.NamespacedKey(KeyNamespace, serviceKey)
+
+	// UID and User identify the owner of the process behind an endpoint,
+	// when known.
+	UID  = report.NamespacedKey(KeyNamespace, "uid")
+	User = report.NamespacedKey(KeyNamespace, "user")
+
+	// StartTime is when the process behind an endpoint started, which tells
+	// a restarted process apart from the one which had its PID before.
+	StartTime = report.NamespacedKey(KeyNamespace, "start_time")
+
+	// SendQueue and ReceiveQueue are the bytes queued on the socket of an
+	// endpoint, and CongestionWindow its TCP congestion window in segments,
+	// when known.
+	SendQueue        = report.NamespacedKey(KeyNamespace, "send_queue")
+	ReceiveQueue     = report.NamespacedKey(KeyNamespace, "receive_queue")
+	CongestionWindow = report.NamespacedKey(KeyNamespace, "congestion_window")
+
+	// NAT64Addr is the IPv4 address embedded in an endpoint's address, if
+	// that is a NAT64 one (in 64:ff9b::/96); the endpoint is really that
+	// IPv4 address.
+	NAT64Addr = report.NamespacedKey(KeyNamespace, "nat64_addr")
+
+	// NetworkNamespace is the ID of the network namespace an endpoint was
+	// seen from, when known.
+	NetworkNamespace = report.NamespacedKey(KeyNamespace, networkNamespaceKey)
+
+	// SystemdUnit is the systemd service the process behind an endpoint
+	// runs in, when known, e.g. "nginx.service".
+	SystemdUnit = report.NamespacedKey(KeyNamespace, "systemd_unit")
+
+	// EnvPrefix is prepended to the names of the environment variables
+	// captured from the process owning an endpoint.
+	EnvPrefix = report.NamespacedKey(KeyNamespace, "env_")
+
+	// ByteCounts is set on the host node when using conntrack, to
+	// ByteCountsAvailable if conntrack accounting is enabled, so connections
+	// can have byte counts, or ByteCountsUnavailable if it isn't.
+	ByteCounts = report.NamespacedKey(KeyNamespace, "byte_counts")
+
+	// Warning is set on the host node when the connections could only be
+	// partly reported, e.g. without the processes owning them.
+	Warning = report.NamespacedKey(KeyNamespace, "warning")
+)
+
+// Values of ByteCounts.
+const (
+	ByteCountsAvailable   = "available"
+	ByteCountsUnavailable = "unavailable"
+)
+
+// HostMetadataTemplates are the templates for the metadata the endpoint
+// reporter adds to host nodes.
+var HostMetadataTemplates = report.MetadataTemplates{
+	Warning:    {ID: Warning, Label: "Connections", From: report.FromLatest, Priority: 20},
+	ByteCounts: {ID: ByteCounts, Label: "Byte counts", From: report.FromLatest, Priority: 21},
+}
+
 // ReporterConfig are the config options for the endpoint reporter.
 type ReporterConfig struct {
 	HostID       string
@@ -33,13 +107,46 @@ type ReporterConfig struct {
 	ProcessCache *process.CachingWalker
 	Scanner      procspy.ConnectionScanner
 	DNSSnooper   *DNSSnooper
+
+	// NamespacedNAT also tracks NAT in the network namespaces of
+	// containers, running conntrack in each of them, so rewrites done inside
+	// a container apply only to its own endpoints.
+	NamespacedNAT bool
+
+	// EnvVars lists the environment variables to capture from the process
+	// owning each endpoint, if known. Nothing else is read from the
+	// environment.
+	EnvVars []string
+
+	// SpyTimeout bounds how long listing the connections from /proc may
+	// take. If it runs out, the connections listed so far are reported. Zero
+	// means no limit.
+	SpyTimeout time.Duration
+
+	// ExcludeLoopback leaves out connections between two loopback
+	// addresses. Connections with a loopback address on one side only are
+	// still reported.
+	ExcludeLoopback bool
+
+	// ConnectionSamples adds each connection's ports, and state where
+	// known, to its edge, so that the connections behind an aggregated edge
+	// can be looked at individually.
+	ConnectionSamples bool
 }
 
-// Reporter generates Reports containing the Endpoint topology.
+// Reporter generates Reports containing the Endpoint topology. It is safe to
+// call Report concurrently; reports are generated one at a time.
 type Reporter struct {
-	conf              ReporterConfig
+	conf ReporterConfig
+
+	// mtx guards the tracker state (e.g. first seen times) carried over
+	// from one report to the next.
+	mtx               sync.Mutex
 	connectionTracker connectionTracker
 	natMapper         natMapper
+
+	// accounting is nil unless using conntrack.
+	accounting *conntrackAccounting
 }
 
 // SpyDuration is an exported prometheus metric
@@ -54,12 +161,35 @@ var SpyDuration = prometheus.NewSummaryVec(
 	[]string{},
 )
 
+func init() {
+	prometheus.MustRegister(SpyDuration)
+}
+
+// limitedLog is for errors which would otherwise be logged every report,
+// e.g. conntrack or parts of procfs being unavailable.
+var limitedLog = logging.NewRateLimited(time.Minute)
+
 // NewReporter creates a new Reporter that invokes procspy.Connections to
 // generate a report.Report that contains every discovered (spied) connection
 // on the host machine, at the granularity of host and port. That information
 // is stored in the Endpoint topology. It optionally enriches that topology
 // with process (PID) information.
 func NewReporter(conf ReporterConfig) *Reporter {
+	natMapper := makeNATMapper(newPerFamilyConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, "--any-nat"))
+	if conf.UseConntrack && conf.NamespacedNAT {
+		if hostNamespace, err := hostNetworkNamespace(conf.ProcRoot); err != nil {
+			log.Warnf("Not tracking NAT per network namespace: %v", err)
+		} else {
+			natMapper = makeNamespacedNATMapper(natMapper.flowWalker, conf.ProcRoot, hostNamespace, func(netns string) flowWalker {
+				return newPerFamilyNamespacedConntrackFlowWalker(netns, conf.BufferSize, "--any-nat")
+			})
+		}
+	}
+	var accounting *conntrackAccounting
+	if conf.UseConntrack {
+		accounting = newConntrackAccounting(conf.ProcRoot)
+		accounting.Enabled() // so any warning is logged at startup
+	}
 	return &Reporter{
 		conf: conf,
 		connectionTracker: newConnectionTracker(connectionTrackerConfig{
@@ -74,16 +204,24 @@ func NewReporter(conf ReporterConfig) *Reporter {
 			ProcessCache: conf.ProcessCache,
 			Scanner:      conf.Scanner,
 			DNSSnooper:   conf.DNSSnooper,
+			EnvVars:      conf.EnvVars,
+			SpyTimeout:   conf.SpyTimeout,
+
+			ExcludeLoopback:   conf.ExcludeLoopback,
+			ConnectionSamples: conf.ConnectionSamples,
 		}),
-		natMapper: makeNATMapper(newConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, "--any-nat")),
+		natMapper:  natMapper,
+		accounting: accounting,
 	}
 }
 
 // Name of this reporter, for metrics gathering
-func (Reporter) Name() string { return "Endpoint" }
+func (*Reporter) Name() string { return "Endpoint" }
 
 // Stop stop stop
 func (r *Reporter) Stop() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 	r.connectionTracker.Stop()
 	r.natMapper.stop()
 	if r.conf.Scanner != nil {
@@ -91,15 +229,34 @@ func (r *Reporter) Stop() {
 	}
 }
 
-// Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+// Report implements Reporter. Problems which still leave some connections to
+// report are recorded as a Warning on the host node; an error is only
+// returned if nothing could be reported at all, or if ctx is done.
+func (r *Reporter) Report(ctx context.Context) (report.Report, error) {
 	defer func(begin time.Time) {
 		SpyDuration.WithLabelValues().Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 	rpt := report.MakeReport()
 
-	r.connectionTracker.ReportConnections(&rpt)
+	err := r.connectionTracker.ReportConnections(ctx, &rpt)
+	if err != nil && (len(rpt.Endpoint.Nodes) == 0 || err == ctx.Err()) {
+		limitedLog.Flush()
+		return report.MakeReport(), err
+	}
 	r.natMapper.applyNAT(rpt, r.conf.HostID)
+	if r.accounting != nil {
+		byteCounts := ByteCountsUnavailable
+		if r.accounting.Enabled() {
+			byteCounts = ByteCountsAvailable
+		}
+		rpt.Host = rpt.Host.WithMetadataTemplates(HostMetadataTemplates)
+		rpt.Host = rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID(r.conf.HostID), map[string]string{
+			ByteCounts: byteCounts,
+		}))
+	}
+	limitedLog.Flush()
 	return rpt, nil
 }
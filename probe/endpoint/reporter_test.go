@@ -1,13 +1,18 @@
 package endpoint_test
 
 import (
+	"errors"
 	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -77,7 +82,7 @@ func TestSpyNoProcesses(t *testing.T) {
 		BufferSize: bufferSize,
 		Scanner:    scanner,
 	})
-	r, _ := reporter.Report()
+	r, _ := reporter.Report(context.Background())
 	//buf, _ := json.MarshalIndent(r, "", "    ")
 	//t.Logf("\n%s\n", buf)
 
@@ -101,7 +106,7 @@ func TestSpyWithProcesses(t *testing.T) {
 		BufferSize: bufferSize,
 		Scanner:    scanner,
 	})
-	r, _ := reporter.Report()
+	r, _ := reporter.Report(context.Background())
 	// buf, _ := json.MarshalIndent(r, "", "    ") ; t.Logf("\n%s\n", buf)
 
 	var (
@@ -126,3 +131,251 @@ func TestSpyWithProcesses(t *testing.T) {
 		}
 	}
 }
+
+func TestReporterConcurrentReport(t *testing.T) {
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     "hostid",
+		HostName:   "hostname",
+		SpyProcs:   true,
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    procspy.FixedScanner(fixConnectionsWithProcesses),
+	})
+	defer reporter.Stop()
+
+	// Run with -race to check that concurrent reports don't share unguarded
+	// state.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				r, err := reporter.Report(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want, have := 2, len(r.Endpoint.Nodes); want != have {
+					t.Errorf("want %d nodes, have %d", want, have)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// partialScanner returns its connections along with err.
+type partialScanner struct {
+	procspy.FixedScanner
+	err error
+}
+
+func (s partialScanner) Connections(processes bool) (procspy.ConnIter, error) {
+	iter, _ := s.FixedScanner.Connections(processes)
+	return iter, s.err
+}
+
+func TestSpyProcessLookupFails(t *testing.T) {
+	const hostID = "hostid"
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     hostID,
+		HostName:   "hostname",
+		SpyProcs:   true,
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner: partialScanner{
+			FixedScanner: procspy.FixedScanner(fixConnections),
+			err:          procspy.ProcessLookupError{Err: errors.New("permission denied")},
+		},
+	})
+	defer reporter.Stop()
+
+	r, err := reporter.Report(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want, have := 3, len(r.Endpoint.Nodes); want != have {
+		t.Fatalf("want %d nodes, have %d", want, have)
+	}
+	warning, ok := r.Host.Nodes[report.MakeHostNodeID(hostID)].Latest.Lookup(endpoint.Warning)
+	if !ok || warning != "process info unavailable: permission denied" {
+		t.Errorf("expected a warning on the host node, got %q", warning)
+	}
+	if _, ok := r.Host.MetadataTemplates[endpoint.Warning]; !ok {
+		t.Errorf("expected a metadata template for the warning")
+	}
+}
+
+func TestSpyScanFails(t *testing.T) {
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     "hostid",
+		HostName:   "hostname",
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    partialScanner{err: errors.New("no /proc")},
+	})
+	defer reporter.Stop()
+
+	if _, err := reporter.Report(context.Background()); err == nil {
+		t.Errorf("expected an error when no connections could be listed")
+	}
+}
+
+// slowScanner lists its first connection straight away, and the rest only
+// once release is closed. It counts its scans in scans, if set.
+type slowScanner struct {
+	conns   []procspy.Connection
+	release chan struct{}
+	scans   *int32
+}
+
+func (s slowScanner) Connections(_ bool) (procspy.ConnIter, error) {
+	if s.scans != nil {
+		atomic.AddInt32(s.scans, 1)
+	}
+	return &slowConnIter{conns: s.conns, release: s.release}, nil
+}
+
+func (s slowScanner) Stop() {}
+
+type slowConnIter struct {
+	conns   []procspy.Connection
+	release chan struct{}
+	listed  int
+}
+
+func (i *slowConnIter) Next() *procspy.Connection {
+	if i.listed == len(i.conns) {
+		return nil
+	}
+	if i.listed > 0 {
+		<-i.release
+	}
+	i.listed++
+	return &i.conns[i.listed-1]
+}
+
+func TestSpyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	const timeout = 50 * time.Millisecond
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     "hostid",
+		HostName:   "hostname",
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		SpyTimeout: timeout,
+		Scanner:    slowScanner{conns: fixConnections, release: release},
+	})
+
+	begin := time.Now()
+	r, err := reporter.Report(context.Background())
+	if took := time.Since(begin); took > 10*timeout {
+		t.Errorf("Report took %v, with a timeout of %v", took, timeout)
+	}
+	if err != nil {
+		t.Fatalf("expected the connections listed before the timeout, got %v", err)
+	}
+	// Only the first connection was listed in time.
+	if want, have := 2, len(r.Endpoint.Nodes); want != have {
+		t.Errorf("want %d nodes, have %d", want, have)
+	}
+	if _, ok := r.Host.Nodes[report.MakeHostNodeID("hostid")].Latest.Lookup(endpoint.Warning); !ok {
+		t.Errorf("expected a warning about the timeout")
+	}
+}
+
+func TestSpyTimeoutSingleScan(t *testing.T) {
+	release := make(chan struct{})
+	var scans int32
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     "hostid",
+		HostName:   "hostname",
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		SpyTimeout: 10 * time.Millisecond,
+		Scanner:    slowScanner{conns: fixConnections, release: release, scans: &scans},
+	})
+
+	// Reports while a scan is stuck wait for it, rather than piling up more.
+	for i := 0; i < 3; i++ {
+		if _, err := reporter.Report(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := atomic.LoadInt32(&scans); have != 1 {
+		t.Errorf("expected a single scan in flight, got %d", have)
+	}
+
+	// Once it has finished, the next report starts a new one.
+	close(release)
+	r, err := reporter.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := atomic.LoadInt32(&scans); have > 2 {
+		t.Errorf("expected at most one more scan, got %d", have)
+	}
+	if len(r.Endpoint.Nodes) == 0 {
+		t.Errorf("expected the connections to be reported")
+	}
+}
+
+func TestReportCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     "hostid",
+		HostName:   "hostname",
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    slowScanner{conns: fixConnections, release: release},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	begin := time.Now()
+	_, err := reporter.Report(ctx)
+	if took := time.Since(begin); took > time.Second {
+		t.Errorf("Report took %v after being cancelled", took)
+	}
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestKeysAreNamespaced(t *testing.T) {
+	for _, key := range []string{
+		endpoint.Addr, endpoint.Port, endpoint.Conntracked, endpoint.EBPF, endpoint.Procspied,
+		endpoint.ReverseDNSNames, endpoint.SnoopedDNSNames, endpoint.Service, endpoint.UID,
+		endpoint.User, endpoint.StartTime, endpoint.SendQueue, endpoint.ReceiveQueue,
+		endpoint.CongestionWindow, endpoint.NAT64Addr, endpoint.NetworkNamespace, endpoint.SystemdUnit,
+		endpoint.EnvPrefix, endpoint.ByteCounts, endpoint.Warning,
+	} {
+		if namespace, _, ok := report.ParseNamespacedKey(key); !ok || namespace != endpoint.KeyNamespace {
+			t.Errorf("%q is not in the %q namespace", key, endpoint.KeyNamespace)
+		}
+	}
+}
+
+func TestSensitiveKeysAreAnonymized(t *testing.T) {
+	const secret = "hunter2"
+	id := report.MakeEndpointNodeID("host", "", "10.0.0.1", "80")
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith(id, map[string]string{
+		endpoint.User:                      secret,
+		endpoint.UID:                       secret,
+		endpoint.EnvPrefix + "DB_PASSWORD": secret,
+	}))
+	for _, n := range report.Anonymize(rpt, "salt").Endpoint.Nodes {
+		n.Latest.ForEach(func(key string, _ time.Time, value string) {
+			if value == secret {
+				t.Errorf("expected %s to be anonymized", key)
+			}
+		})
+	}
+}
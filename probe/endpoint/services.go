@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// ServicesFile lists the services known to the probe by port, in addition
+// to wellKnownServices.
+const ServicesFile = "/etc/services"
+
+// wellKnownServices names the ports most often seen, for when there is no
+// services file, e.g. in a minimal container image. The names are those
+// used in the IANA registry, like the services file.
+var wellKnownServices = serviceNames{
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "domain",
+	80:    "http",
+	110:   "pop3",
+	143:   "imap",
+	389:   "ldap",
+	443:   "https",
+	587:   "submission",
+	636:   "ldaps",
+	993:   "imaps",
+	995:   "pop3s",
+	1433:  "ms-sql-s",
+	2049:  "nfs",
+	2181:  "zookeeper",
+	2379:  "etcd-client",
+	3306:  "mysql",
+	5432:  "postgresql",
+	5672:  "amqp",
+	6379:  "redis",
+	9092:  "kafka",
+	11211: "memcache",
+	27017: "mongodb",
+}
+
+// serviceNames maps TCP ports to the names of the services using them.
+type serviceNames map[uint16]string
+
+// loadServiceNames returns the well-known services, updated with the TCP
+// services listed in the services file at path, if it can be read.
+func loadServiceNames(path string) serviceNames {
+	result := serviceNames{}
+	for port, name := range wellKnownServices {
+		result[port] = name
+	}
+	buf, err := fs.ReadFile(path)
+	if err != nil {
+		return result
+	}
+	for port, name := range parseServices(bytes.NewReader(buf)) {
+		result[port] = name
+	}
+	return result
+}
+
+// parseServices reads the TCP services from a services(5) file, keeping
+// the first name listed for each port.
+func parseServices(r io.Reader) serviceNames {
+	result := serviceNames{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		parts := strings.SplitN(fields[1], "/", 2)
+		if len(parts) != 2 || parts[1] != "tcp" {
+			continue
+		}
+		port, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			continue
+		}
+		if _, ok := result[uint16(port)]; !ok {
+			result[uint16(port)] = fields[0]
+		}
+	}
+	return result
+}
+
+func (s serviceNames) lookup(port uint16) (string, bool) {
+	name, ok := s[port]
+	return name, ok
+}
@@ -0,0 +1,51 @@
+package endpoint
+
+import (
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+)
+
+const servicesFixture = `# Network services, Internet style
+http		80/tcp		www		# WorldWideWeb HTTP
+postgresql	5432/tcp	postgres	# PostgreSQL Database
+postgresql	5432/udp	postgres
+custom		7000/tcp
+custom-udp	7001/udp
+alsocustom	7000/tcp
+`
+
+func TestServiceNames(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("etc", fs.File{FName: "services", FContents: servicesFixture}),
+	))
+	defer fs_hook.Restore()
+
+	for _, services := range []serviceNames{
+		loadServiceNames("/etc/services"),
+		loadServiceNames("/nonexistent"),
+	} {
+		for port, want := range map[uint16]string{
+			22:   "ssh",
+			80:   "http",
+			443:  "https",
+			5432: "postgresql",
+		} {
+			if have, ok := services.lookup(port); !ok || have != want {
+				t.Errorf("port %d: want %q, have %q", port, want, have)
+			}
+		}
+		if have, ok := services.lookup(54321); ok {
+			t.Errorf("expected no service for port 54321, got %q", have)
+		}
+	}
+
+	services := loadServiceNames("/etc/services")
+	if have, _ := services.lookup(7000); have != "custom" {
+		t.Errorf("expected the first name listed in the services file, got %q", have)
+	}
+	if have, ok := services.lookup(7001); ok {
+		t.Errorf("expected UDP-only services to be ignored, got %q", have)
+	}
+}
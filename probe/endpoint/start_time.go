@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of the start times in
+// /proc/<pid>/stat. It is 100 on every architecture Linux supports, bar
+// some long-gone Alphas.
+const clockTicksPerSecond = 100
+
+// readBootTime reads when the host booted, from the btime line of
+// /proc/stat.
+func readBootTime(procRoot string) (time.Time, error) {
+	buf, err := fs.ReadFile(path.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			seconds, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in %s", path.Join(procRoot, "stat"))
+}
+
+// readStartTicks reads when process pid started, in clock ticks since boot,
+// from field 22 of /proc/<pid>/stat.
+func readStartTicks(procRoot string, pid int) (uint64, error) {
+	buf, err := fs.ReadFile(path.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The command (field 2) is in parentheses and may itself contain spaces
+	// and parentheses, so count the fields from after the last one.
+	i := bytes.LastIndexByte(buf, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(buf[i+1:]))
+	const startTimeField = 22 - 3
+	if len(fields) <= startTimeField {
+		return 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	return strconv.ParseUint(fields[startTimeField], 10, 64)
+}
+
+// processStartTime adds when process pid started to nodeInfo, as StartTime,
+// given when the host booted. Start times are read at most once per report,
+// using cache; nothing is added if the boot time or the start time is
+// unknown.
+func (t *connectionTracker) processStartTime(pid int, bootTime time.Time, cache map[int]string, nodeInfo map[string]string) {
+	if bootTime.IsZero() {
+		return
+	}
+	startTime, ok := cache[pid]
+	if !ok {
+		ticks, err := readStartTicks(t.conf.ProcRoot, pid)
+		if err == nil {
+			offset := time.Duration(ticks) * time.Second / clockTicksPerSecond
+			startTime = bootTime.Add(offset).UTC().Format(time.RFC3339Nano)
+		}
+		logProcReadError("start time", err)
+		cache[pid] = startTime
+	}
+	if startTime != "" {
+		nodeInfo[StartTime] = startTime
+	}
+}
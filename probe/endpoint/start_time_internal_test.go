@@ -0,0 +1,54 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+)
+
+func TestProcessStartTime(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.File{FName: "stat", FContents: "cpu  1 2 3 4\nbtime 1500000000\nprocesses 42\n"},
+			fs.Dir("1", fs.File{FName: "stat", FContents: "1 (init) S 0 1 1 0 -1 4194560 1 2 3 4 5 6 7 8 20 0 1 0 12345 100 200"}),
+			fs.Dir("2", fs.File{FName: "stat", FContents: "2 (a) (b c) S 1 2 2 0 -1 4194560 1 2 3 4 5 6 7 8 20 0 1 0 250 100 200"}),
+			fs.Dir("3", fs.File{FName: "stat", FContents: "3 (truncated) S 1"}),
+		),
+	))
+	defer fs_hook.Restore()
+
+	bootTime, err := readBootTime("/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1500000000, 0); !bootTime.Equal(want) {
+		t.Fatalf("want boot time %v, have %v", want, bootTime)
+	}
+
+	tracker := connectionTracker{conf: connectionTrackerConfig{ProcRoot: "/proc"}}
+	cache := map[int]string{}
+	for _, tc := range []struct {
+		pid  int
+		want string
+	}{
+		{1, "2017-07-14T02:42:03.45Z"},
+		{2, "2017-07-14T02:40:02.5Z"}, // command with spaces and parentheses
+		{3, ""},                       // unparseable stat
+		{4, ""},                       // process has gone
+	} {
+		nodeInfo := map[string]string{}
+		tracker.processStartTime(tc.pid, bootTime, cache, nodeInfo)
+		if have, ok := nodeInfo[StartTime]; have != tc.want || ok != (tc.want != "") {
+			t.Errorf("pid %d: want start time %q, have %v", tc.pid, tc.want, nodeInfo)
+		}
+	}
+
+	// Without a boot time, start times can't be worked out.
+	nodeInfo := map[string]string{}
+	tracker.processStartTime(1, time.Time{}, map[int]string{}, nodeInfo)
+	if _, ok := nodeInfo[StartTime]; ok {
+		t.Errorf("want no start time without a boot time, have %v", nodeInfo)
+	}
+}
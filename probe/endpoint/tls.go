@@ -0,0 +1,24 @@
+package endpoint
+
+// tlsPorts are the ports whose services speak TLS from the start of the
+// connection, rather than upgrading to it (e.g. with STARTTLS), so that a
+// connection to one can be taken to be encrypted.
+var tlsPorts = map[uint16]struct{}{
+	443:  {}, // https
+	465:  {}, // smtps
+	636:  {}, // ldaps
+	853:  {}, // domain-s
+	990:  {}, // ftps
+	993:  {}, // imaps
+	995:  {}, // pop3s
+	2376: {}, // docker-s
+	5061: {}, // sips
+	6443: {}, // kubernetes API
+	8443: {}, // https-alt
+}
+
+// isTLSPort tells whether connections to port are usually encrypted.
+func isTLSPort(port uint16) bool {
+	_, ok := tlsPorts[port]
+	return ok
+}
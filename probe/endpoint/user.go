@@ -0,0 +1,72 @@
+package endpoint
+
+import (
+	"os/user"
+	"path"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// userCache resolves uids to user names, remembering each answer (including
+// failures) for the life of the probe, as users come and go rarely.
+type userCache struct {
+	mtx    sync.Mutex
+	lookup func(uid string) (string, error)
+	names  map[string]string
+}
+
+func newUserCache(lookup func(uid string) (string, error)) *userCache {
+	return &userCache{lookup: lookup, names: map[string]string{}}
+}
+
+// lookupUsername resolves uid using the probe's own user database, which is
+// the host's unless the probe is containerised without it mounted.
+func lookupUsername(uid string) (string, error) {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+func (c *userCache) get(uid string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	name, ok := c.names[uid]
+	if !ok {
+		name, _ = c.lookup(uid)
+		c.names[uid] = name
+	}
+	return name, name != ""
+}
+
+// processUser adds the uid of process pid, and the user name it resolves to
+// if any, to nodeInfo. The uid is that of the owner of the process' procfs
+// entries, which can be seen even when the rest of a process owned by
+// another user can't be read. Uids are read at most once per report, using
+// cache.
+func (t *connectionTracker) processUser(pid int, cache map[int]string, nodeInfo map[string]string) {
+	uid, ok := cache[pid]
+	if !ok {
+		var stat syscall.Stat_t
+		err := fs.Stat(path.Join(t.conf.ProcRoot, strconv.Itoa(pid), "stat"), &stat)
+		if err == nil {
+			uid = strconv.FormatUint(uint64(stat.Uid), 10)
+		}
+		logProcReadError("owner", err)
+		cache[pid] = uid
+	}
+	if uid == "" {
+		return
+	}
+	nodeInfo[UID] = uid
+	if name, ok := t.users.get(uid); ok {
+		nodeInfo[User] = name
+	}
+}
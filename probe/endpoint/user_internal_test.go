@@ -0,0 +1,63 @@
+package endpoint
+
+import (
+	"fmt"
+	"reflect"
+	"syscall"
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+)
+
+func TestProcessUser(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("1", fs.File{FName: "stat", FStat: syscall.Stat_t{Uid: 0}}),
+			fs.Dir("2", fs.File{FName: "stat", FStat: syscall.Stat_t{Uid: 1000}}),
+			fs.Dir("3", fs.File{FName: "stat", FStat: syscall.Stat_t{Uid: 1001}}),
+		),
+	))
+	defer fs_hook.Restore()
+
+	lookups := map[string]int{}
+	tracker := connectionTracker{
+		conf: connectionTrackerConfig{ProcRoot: "/proc"},
+		users: newUserCache(func(uid string) (string, error) {
+			lookups[uid]++
+			switch uid {
+			case "0":
+				return "root", nil
+			case "1000":
+				return "alice", nil
+			}
+			return "", fmt.Errorf("unknown user %s", uid)
+		}),
+	}
+
+	cache := map[int]string{}
+	for _, tc := range []struct {
+		pid       int
+		uid, user string
+	}{
+		{1, "0", "root"},
+		{2, "1000", "alice"},
+		{2, "1000", "alice"},
+		{3, "1001", ""}, // uid is still recorded when it can't be resolved
+		{4, "", ""},     // process has gone
+	} {
+		nodeInfo := map[string]string{}
+		tracker.processUser(tc.pid, cache, nodeInfo)
+		if nodeInfo[UID] != tc.uid || nodeInfo[User] != tc.user {
+			t.Errorf("pid %d: want uid %q user %q, have %v", tc.pid, tc.uid, tc.user, nodeInfo)
+		}
+		if _, ok := nodeInfo[User]; ok != (tc.user != "") {
+			t.Errorf("pid %d: unexpected user in %v", tc.pid, nodeInfo)
+		}
+	}
+
+	// Each uid is only looked up once, whether or not it resolved.
+	if want := map[string]int{"0": 1, "1000": 1, "1001": 1}; !reflect.DeepEqual(want, lookups) {
+		t.Errorf("want lookups %v, have %v", want, lookups)
+	}
+}
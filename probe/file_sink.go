@@ -0,0 +1,37 @@
+package probe
+
+import (
+	"os"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// FileSink is a Sink which appends reports to a file as newline-delimited
+// JSON, suitable for replaying into the app later.
+type FileSink struct {
+	f *os.File
+	w *report.StreamWriter
+}
+
+// NewFileSink opens (or creates) the file at path for appending.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, w: report.NewStreamWriter(f)}, nil
+}
+
+// Name implements Sink.
+func (s *FileSink) Name() string { return "file" }
+
+// Write implements Sink, stamping the report with the current time.
+func (s *FileSink) Write(rpt report.Report) error {
+	return s.w.Write(mtime.Now(), rpt)
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,181 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CloudInfo is what is known of the cloud instance a host runs on.
+type CloudInfo struct {
+	Provider     string
+	Region       string
+	Zone         string
+	InstanceType string
+}
+
+// cloudMetadataTimeout bounds each request to a metadata service. They are
+// link-local, so they answer quickly or not at all.
+const cloudMetadataTimeout = time.Second
+
+// metadataGetter makes a request to a metadata service, by path, and returns
+// the body of the response.
+type metadataGetter func(method, path string, header http.Header) (string, error)
+
+type cloudProvider struct {
+	name    string
+	baseURL string
+	detect  func(metadataGetter) (CloudInfo, error)
+}
+
+var cloudProviders = []cloudProvider{
+	{"aws", "http://169.254.169.254", detectAWS},
+	{"gcp", "http://metadata.google.internal", detectGCP},
+	{"azure", "http://169.254.169.254", detectAzure},
+}
+
+// CloudMetadata finds out which cloud instance the host is, from the metadata
+// service of the cloud it runs on. The metadata services are tried once, in
+// the background, as the answer doesn't change.
+type CloudMetadata struct {
+	mtx       sync.Mutex
+	info      CloudInfo
+	found     bool
+	client    *http.Client
+	providers []cloudProvider
+}
+
+// NewCloudMetadata makes a CloudMetadata, and starts looking for a metadata
+// service.
+func NewCloudMetadata() *CloudMetadata {
+	c := newCloudMetadata(cloudProviders)
+	go c.detect()
+	return c
+}
+
+func newCloudMetadata(providers []cloudProvider) *CloudMetadata {
+	return &CloudMetadata{
+		client:    &http.Client{Timeout: cloudMetadataTimeout},
+		providers: providers,
+	}
+}
+
+// Info returns the host's cloud instance, if it has been found.
+func (c *CloudMetadata) Info() (CloudInfo, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.info, c.found
+}
+
+func (c *CloudMetadata) detect() {
+	for _, provider := range c.providers {
+		info, err := provider.detect(c.getter(provider.baseURL))
+		if err != nil {
+			continue
+		}
+		info.Provider = provider.name
+		log.Infof("Running on %s, in %s", info.Provider, info.Zone)
+		c.mtx.Lock()
+		c.info, c.found = info, true
+		c.mtx.Unlock()
+		return
+	}
+	log.Info("No cloud metadata service found")
+}
+
+func (c *CloudMetadata) getter(baseURL string) metadataGetter {
+	return func(method, path string, header http.Header) (string, error) {
+		req, err := http.NewRequest(method, baseURL+path, nil)
+		if err != nil {
+			return "", err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		return strings.TrimSpace(string(body)), err
+	}
+}
+
+// detectAWS asks the EC2 instance metadata service, with a session token if
+// it hands one out (IMDSv2), or without.
+func detectAWS(get metadataGetter) (CloudInfo, error) {
+	header := http.Header{}
+	if token, err := get("PUT", "/latest/api/token", http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"60"}}); err == nil {
+		header.Set("X-Aws-Ec2-Metadata-Token", token)
+	}
+	// The zone can't be relied on to be the region with a letter on the end,
+	// e.g. Local Zones like us-west-2-lax-1a, so take both from the instance
+	// identity document.
+	body, err := get("GET", "/latest/dynamic/instance-identity/document", header)
+	if err != nil {
+		return CloudInfo{}, err
+	}
+	var document struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceType     string `json:"instanceType"`
+	}
+	if err := json.Unmarshal([]byte(body), &document); err != nil {
+		return CloudInfo{}, err
+	} else if document.AvailabilityZone == "" {
+		return CloudInfo{}, fmt.Errorf("no availability zone")
+	}
+	return CloudInfo{Region: document.Region, Zone: document.AvailabilityZone, InstanceType: document.InstanceType}, nil
+}
+
+// detectGCP asks the GCE metadata server, which answers with resource paths,
+// e.g. projects/123/zones/us-central1-a.
+func detectGCP(get metadataGetter) (CloudInfo, error) {
+	header := http.Header{"Metadata-Flavor": {"Google"}}
+	zone, err := get("GET", "/computeMetadata/v1/instance/zone", header)
+	if err != nil {
+		return CloudInfo{}, err
+	}
+	machineType, err := get("GET", "/computeMetadata/v1/instance/machine-type", header)
+	if err != nil {
+		return CloudInfo{}, err
+	}
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i != -1 {
+		region = zone[:i]
+	}
+	return CloudInfo{
+		Region:       region,
+		Zone:         zone,
+		InstanceType: machineType[strings.LastIndex(machineType, "/")+1:],
+	}, nil
+}
+
+// detectAzure asks the Azure instance metadata service. Zone is empty unless
+// the VM was placed in an availability zone.
+func detectAzure(get metadataGetter) (CloudInfo, error) {
+	body, err := get("GET", "/metadata/instance/compute?api-version=2017-12-01", http.Header{"Metadata": {"true"}})
+	if err != nil {
+		return CloudInfo{}, err
+	}
+	var compute struct {
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMSize   string `json:"vmSize"`
+	}
+	if err := json.Unmarshal([]byte(body), &compute); err != nil {
+		return CloudInfo{}, err
+	}
+	return CloudInfo{Region: compute.Location, Zone: compute.Zone, InstanceType: compute.VMSize}, nil
+}
@@ -0,0 +1,54 @@
+package host
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCloudMetadataAWS(t *testing.T) {
+	const token = "sometoken"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" && r.URL.Path == "/latest/api/token" {
+			w.Write([]byte(token))
+			return
+		}
+		if r.Header.Get("X-Aws-Ec2-Metadata-Token") != token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/dynamic/instance-identity/document":
+			w.Write([]byte(`{"region": "us-west-2", "availabilityZone": "us-west-2-lax-1a", "instanceType": "m5.large"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	c := newCloudMetadata([]cloudProvider{
+		{"gcp", unreachable.URL, detectGCP},
+		{"azure", server.URL, detectAzure}, // not found
+		{"aws", server.URL, detectAWS},
+	})
+	if _, ok := c.Info(); ok {
+		t.Fatal("expected no info before detection")
+	}
+	c.detect()
+	info, ok := c.Info()
+	if !ok {
+		t.Fatal("expected the AWS metadata service to be found")
+	}
+	if want := (CloudInfo{Provider: "aws", Region: "us-west-2", Zone: "us-west-2-lax-1a", InstanceType: "m5.large"}); info != want {
+		t.Errorf("want %+v, have %+v", want, info)
+	}
+
+	// Without any metadata service, there's nothing to tag hosts with.
+	c = newCloudMetadata([]cloudProvider{{"aws", unreachable.URL, detectAWS}})
+	c.detect()
+	if info, ok := c.Info(); ok {
+		t.Errorf("expected no info without a metadata service, got %+v", info)
+	}
+}
@@ -0,0 +1,62 @@
+package host
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/common/hostname"
+)
+
+// Strategies for deriving the host ID, for HostID.
+const (
+	HostIDHostname  = "hostname"
+	HostIDMachineID = "machine-id"
+)
+
+var (
+	// GetHostname is swappable for mocking in tests.
+	GetHostname = hostname.Get
+
+	// MachineIDFiles are tried in order by the machine-id strategy. They are
+	// the host's, so are read through the root of its init process, under
+	// the probe's proc root: the probe usually runs in a container, where
+	// they'd be the container's own.
+	MachineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+)
+
+// HostID works out the ID this probe reports its host under. The override
+// wins if given. Otherwise the machine-id strategy uses the systemd machine
+// ID, which stays the same across reboots and hostname changes, falling back
+// to the hostname if there isn't one; the hostname strategy always uses the
+// hostname.
+func HostID(strategy, override, procRoot string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	switch strategy {
+	case HostIDHostname:
+		return GetHostname(), nil
+	case HostIDMachineID:
+		if id, ok := machineID(procRoot); ok {
+			return id, nil
+		}
+		return GetHostname(), nil
+	default:
+		return "", fmt.Errorf("unknown host ID strategy %q", strategy)
+	}
+}
+
+func machineID(procRoot string) (string, bool) {
+	for _, file := range MachineIDFiles {
+		buf, err := fs.ReadFile(path.Join(procRoot, "1", "root", file))
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(buf)); id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
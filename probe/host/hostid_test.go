@@ -0,0 +1,65 @@
+package host_test
+
+import (
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+
+	"github.com/weaveworks/scope/probe/host"
+)
+
+func TestHostID(t *testing.T) {
+	oldHostname := host.GetHostname
+	defer func() { host.GetHostname = oldHostname }()
+	host.GetHostname = func() string { return "myhost" }
+
+	// The host's files are under the root of its init process.
+	hostRoot := func(entries ...fs.Entry) fs.Entry {
+		return fs.Dir("", fs.Dir("hostproc", fs.Dir("1", fs.Dir("root", entries...))))
+	}
+	var (
+		etcMachineID = hostRoot(
+			fs.Dir("etc", fs.File{FName: "machine-id", FContents: "abc123\n"}),
+		)
+		dbusMachineID = hostRoot(
+			fs.Dir("var", fs.Dir("lib", fs.Dir("dbus", fs.File{FName: "machine-id", FContents: "def456\n"}))),
+		)
+		emptyMachineID = hostRoot(
+			fs.Dir("etc", fs.File{FName: "machine-id", FContents: "\n"}),
+		)
+		noMachineID = hostRoot(fs.Dir("etc"))
+		// The probe's own, which isn't the host's.
+		containerMachineID = fs.Dir("",
+			fs.Dir("etc", fs.File{FName: "machine-id", FContents: "container\n"}),
+			fs.Dir("hostproc", fs.Dir("1", fs.Dir("root", fs.Dir("etc")))),
+		)
+	)
+	for _, tc := range []struct {
+		name               string
+		fs                 fs.Entry
+		strategy, override string
+		want               string
+	}{
+		{"hostname", etcMachineID, host.HostIDHostname, "", "myhost"},
+		{"machine-id", etcMachineID, host.HostIDMachineID, "", "abc123"},
+		{"dbus machine-id", dbusMachineID, host.HostIDMachineID, "", "def456"},
+		{"empty machine-id", emptyMachineID, host.HostIDMachineID, "", "myhost"},
+		{"no machine-id", noMachineID, host.HostIDMachineID, "", "myhost"},
+		{"container machine-id", containerMachineID, host.HostIDMachineID, "", "myhost"},
+		{"override", etcMachineID, host.HostIDMachineID, "given", "given"},
+	} {
+		fs_hook.Mock(tc.fs)
+		have, err := host.HostID(tc.strategy, tc.override, "/hostproc")
+		fs_hook.Restore()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if have != tc.want {
+			t.Errorf("%s: want %q, have %q", tc.name, tc.want, have)
+		}
+	}
+
+	if _, err := host.HostID("cloud", "", "/hostproc"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"net"
 	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 // Keys for use in Node.Latest.
@@ -24,6 +27,21 @@ const (
 	CPUUsage      = "host_cpu_usage_percent"
 	MemoryUsage   = "host_mem_usage_bytes"
 	ScopeVersion  = "host_scope_version"
+	TimeZone      = "host_timezone"
+
+	CloudProvider     = "host_cloud_provider"
+	CloudRegion       = "host_cloud_region"
+	CloudZone         = "host_cloud_zone"
+	CloudInstanceType = "host_cloud_instance_type"
+
+	// Orchestrator is the container orchestration platform the host is
+	// part of, if known, e.g. "kubernetes".
+	Orchestrator = "orchestrator"
+
+	NetworkInterfacesTablePrefix = "host_network_interfaces_"
+	NetworkInterface             = "host_network_interface"
+	NetworkRxBytes               = "host_network_rx_bytes"
+	NetworkTxBytes               = "host_network_tx_bytes"
 )
 
 // Exposed for testing.
@@ -32,6 +50,7 @@ const (
 	ProcLoad    = "/proc/loadavg"
 	ProcStat    = "/proc/stat"
 	ProcMemInfo = "/proc/meminfo"
+	ProcNetDev  = "/proc/net/dev"
 )
 
 // Exposed for testing.
@@ -43,6 +62,13 @@ var (
 		OS:            {ID: OS, Label: "OS", From: report.FromLatest, Priority: 12},
 		LocalNetworks: {ID: LocalNetworks, Label: "Local Networks", From: report.FromSets, Priority: 13},
 		ScopeVersion:  {ID: ScopeVersion, Label: "Scope Version", From: report.FromLatest, Priority: 14},
+
+		CloudProvider:     {ID: CloudProvider, Label: "Cloud Provider", From: report.FromLatest, Priority: 15},
+		CloudRegion:       {ID: CloudRegion, Label: "Region", From: report.FromLatest, Priority: 16},
+		CloudZone:         {ID: CloudZone, Label: "Zone", From: report.FromLatest, Priority: 17},
+		CloudInstanceType: {ID: CloudInstanceType, Label: "Instance Type", From: report.FromLatest, Priority: 18},
+		TimeZone:          {ID: TimeZone, Label: "Time Zone", From: report.FromLatest, Priority: 19},
+		Orchestrator:      {ID: Orchestrator, Label: "Orchestrator", From: report.FromLatest, Priority: 20},
 	}
 
 	MetricTemplates = report.MetricTemplates{
@@ -50,6 +76,20 @@ var (
 		MemoryUsage: {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		Load1:       {ID: Load1, Label: "Load (1m)", Format: report.DefaultFormat, Group: "load", Priority: 11},
 	}
+
+	TableTemplates = report.TableTemplates{
+		NetworkInterfacesTablePrefix: {
+			ID:     NetworkInterfacesTablePrefix,
+			Label:  "Network Interfaces",
+			Type:   report.MulticolumnTableType,
+			Prefix: NetworkInterfacesTablePrefix,
+			Columns: []report.Column{
+				{ID: NetworkInterface, Label: "Interface"},
+				{ID: NetworkRxBytes, Label: "Received (bytes)"},
+				{ID: NetworkTxBytes, Label: "Sent (bytes)"},
+			},
+		},
+	}
 )
 
 // Reporter generates Reports containing the host topology.
@@ -63,11 +103,15 @@ type Reporter struct {
 	hostShellCmd    []string
 	handlerRegistry *controls.HandlerRegistry
 	pipeIDToTTY     map[string]uintptr
+	includeLoopback bool
+	cloud           *CloudMetadata
 }
 
 // NewReporter returns a Reporter which produces a report containing host
-// topology for this host.
-func NewReporter(hostID, hostName, probeID, version string, pipes controls.PipeClient, handlerRegistry *controls.HandlerRegistry) *Reporter {
+// topology for this host. Loopback interfaces are left out of the network
+// interface table unless includeLoopback is set. Hosts are tagged with the
+// cloud instance they are, if cloud is not nil.
+func NewReporter(hostID, hostName, probeID, version string, pipes controls.PipeClient, handlerRegistry *controls.HandlerRegistry, includeLoopback bool, cloud *CloudMetadata) *Reporter {
 	r := &Reporter{
 		hostID:          hostID,
 		hostName:        hostName,
@@ -77,6 +121,8 @@ func NewReporter(hostID, hostName, probeID, version string, pipes controls.PipeC
 		hostShellCmd:    getHostShellCmd(),
 		handlerRegistry: handlerRegistry,
 		pipeIDToTTY:     map[string]uintptr{},
+		includeLoopback: includeLoopback,
+		cloud:           cloud,
 	}
 	r.registerControls()
 	return r
@@ -101,8 +147,46 @@ var GetLocalNetworks = func() ([]*net.IPNet, error) {
 	return localNets, nil
 }
 
+// InterfaceStats are the traffic totals of a network interface since it
+// came up.
+type InterfaceStats struct {
+	RxBytes, TxBytes uint64
+}
+
+// isLoopback reports whether the named interface is the loopback interface
+// ("lo" on Linux, "lo0" on Darwin).
+func isLoopback(name string) bool {
+	return name == "lo" || name == "lo0"
+}
+
+// interfaceRows turns network stats into rows for the network interfaces
+// table, sorted by interface name. Only the interfaces present in stats get
+// a row, so interfaces which have disappeared since the last report are
+// dropped.
+func (r *Reporter) interfaceRows(stats map[string]InterfaceStats) []report.Row {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		if r.includeLoopback || !isLoopback(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	rows := make([]report.Row, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, report.Row{
+			ID: name,
+			Entries: map[string]string{
+				NetworkInterface: name,
+				NetworkRxBytes:   strconv.FormatUint(stats[name].RxBytes, 10),
+				NetworkTxBytes:   strconv.FormatUint(stats[name].TxBytes, 10),
+			},
+		})
+	}
+	return rows
+}
+
 // Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	var (
 		rep        = report.MakeReport()
 		localCIDRs []string
@@ -129,6 +213,7 @@ func (r *Reporter) Report() (report.Report, error) {
 
 	rep.Host = rep.Host.WithMetadataTemplates(MetadataTemplates)
 	rep.Host = rep.Host.WithMetricTemplates(MetricTemplates)
+	rep.Host = rep.Host.WithTableTemplates(TableTemplates)
 
 	now := mtime.Now()
 	metrics := GetLoad(now)
@@ -137,22 +222,41 @@ func (r *Reporter) Report() (report.Report, error) {
 	memoryUsage, max := GetMemoryUsageBytes()
 	metrics[MemoryUsage] = report.MakeSingletonMetric(now, memoryUsage).WithMax(max)
 
-	rep.Host.AddNode(
-		report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
-			report.ControlProbeID: r.probeID,
-			Timestamp:             mtime.Now().UTC().Format(time.RFC3339Nano),
-			HostName:              r.hostName,
-			OS:                    runtime.GOOS,
-			KernelVersion:         kernel,
-			Uptime:                uptime.String(),
-			ScopeVersion:          r.version,
-		}).
-			WithSets(report.EmptySets.
-				Add(LocalNetworks, report.MakeStringSet(localCIDRs...)),
-			).
-			WithMetrics(metrics).
-			WithLatestActiveControls(ExecHost),
-	)
+	node := report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
+		report.ControlProbeID: r.probeID,
+		Timestamp:             mtime.Now().UTC().Format(time.RFC3339Nano),
+		HostName:              r.hostName,
+		OS:                    runtime.GOOS,
+		KernelVersion:         kernel,
+		Uptime:                uptime.String(),
+		ScopeVersion:          r.version,
+		TimeZone:              now.Format("MST -07:00"),
+	}).
+		WithSets(report.EmptySets.
+			Add(LocalNetworks, report.MakeStringSet(localCIDRs...)),
+		).
+		WithMetrics(metrics).
+		WithLatestActiveControls(ExecHost)
+	if r.cloud != nil {
+		if info, ok := r.cloud.Info(); ok {
+			latests := map[string]string{}
+			for key, value := range map[string]string{
+				CloudProvider:     info.Provider,
+				CloudRegion:       info.Region,
+				CloudZone:         info.Zone,
+				CloudInstanceType: info.InstanceType,
+			} {
+				if value != "" {
+					latests[key] = value
+				}
+			}
+			node = node.WithLatests(latests)
+		}
+	}
+	if stats, err := GetNetworkStats(); err == nil {
+		node = node.AddPrefixMulticolumnTable(NetworkInterfacesTablePrefix, r.interfaceRows(stats))
+	}
+	rep.Host.AddNode(node)
 
 	rep.Host.Controls.AddControl(report.Control{
 		ID:    ExecHost,
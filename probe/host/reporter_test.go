@@ -10,6 +10,7 @@ import (
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 func TestReporter(t *testing.T) {
@@ -40,6 +41,7 @@ func TestReporter(t *testing.T) {
 		oldGetCPUUsagePercent         = host.GetCPUUsagePercent
 		oldGetMemoryUsageBytes        = host.GetMemoryUsageBytes
 		oldGetLocalNetworks           = host.GetLocalNetworks
+		oldGetNetworkStats            = host.GetNetworkStats
 	)
 	defer func() {
 		host.GetKernelReleaseAndVersion = oldGetKernelReleaseAndVersion
@@ -48,6 +50,7 @@ func TestReporter(t *testing.T) {
 		host.GetCPUUsagePercent = oldGetCPUUsagePercent
 		host.GetMemoryUsageBytes = oldGetMemoryUsageBytes
 		host.GetLocalNetworks = oldGetLocalNetworks
+		host.GetNetworkStats = oldGetNetworkStats
 	}()
 	host.GetKernelReleaseAndVersion = func() (string, string, error) { return release, version, nil }
 	host.GetLoad = func(time.Time) report.Metrics { return metrics }
@@ -55,9 +58,15 @@ func TestReporter(t *testing.T) {
 	host.GetCPUUsagePercent = func() (float64, float64) { return 30.0, 100.0 }
 	host.GetMemoryUsageBytes = func() (float64, float64) { return 60.0, 100.0 }
 	host.GetLocalNetworks = func() ([]*net.IPNet, error) { return []*net.IPNet{ipnet}, nil }
+	host.GetNetworkStats = func() (map[string]host.InterfaceStats, error) {
+		return map[string]host.InterfaceStats{
+			"lo":   {RxBytes: 10, TxBytes: 10},
+			"eth0": {RxBytes: 1234, TxBytes: 5678},
+		}, nil
+	}
 
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, err := host.NewReporter(hostID, hostname, "", "", nil, hr).Report()
+	rpt, err := host.NewReporter(hostID, hostname, "", "", nil, hr, false, nil).Report(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -77,6 +86,7 @@ func TestReporter(t *testing.T) {
 		{host.OS, runtime.GOOS},
 		{host.Uptime, uptime},
 		{host.KernelVersion, kernel},
+		{host.TimeZone, timestamp.Format("MST -07:00")},
 	} {
 		if have, ok := node.Latest.Lookup(tuple.key); !ok || have != tuple.want {
 			t.Errorf("Expected %s %q, got %q", tuple.key, tuple.want, have)
@@ -99,4 +109,19 @@ func TestReporter(t *testing.T) {
 			t.Errorf("Expected %s metric sample %f, got %f", key, wantSample, sample.Value)
 		}
 	}
+
+	// Should have a network interface table, without loopback
+	for _, tuple := range []struct {
+		key, want string
+	}{
+		{host.NetworkInterfacesTablePrefix + "eth0" + report.TableEntryKeySeparator + host.NetworkRxBytes, "1234"},
+		{host.NetworkInterfacesTablePrefix + "eth0" + report.TableEntryKeySeparator + host.NetworkTxBytes, "5678"},
+	} {
+		if have, ok := node.Latest.Lookup(tuple.key); !ok || have != tuple.want {
+			t.Errorf("Expected %s %q, got %q", tuple.key, tuple.want, have)
+		}
+	}
+	if _, ok := node.Latest.Lookup(host.NetworkInterfacesTablePrefix + "lo" + report.TableEntryKeySeparator + host.NetworkRxBytes); ok {
+		t.Errorf("Expected loopback interface to be skipped")
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/weaveworks/scope/report"
@@ -84,3 +85,38 @@ var GetCPUUsagePercent = func() (float64, float64) {
 var GetMemoryUsageBytes = func() (float64, float64) {
 	return 0.0, 0.0
 }
+
+// GetNetworkStats returns the received and transmitted bytes of each network
+// interface, keyed by interface name.
+var GetNetworkStats = func() (map[string]InterfaceStats, error) {
+	out, err := exec.Command("netstat", "-ib").CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	return parseNetstat(out)
+}
+
+// parseNetstat parses the output of `netstat -ib`. Interfaces are listed once
+// per address; only the link-level line (with a <Link#n> network) is used.
+// The address column is empty for some interfaces, so the byte counters are
+// found by counting back from the end of the line.
+func parseNetstat(out []byte) (map[string]InterfaceStats, error) {
+	stats := map[string]InterfaceStats{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || !strings.HasPrefix(fields[2], "<Link#") {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], "*") // down interfaces are starred
+		rx, err := strconv.ParseUint(fields[len(fields)-5], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := strconv.ParseUint(fields[len(fields)-2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		stats[name] = InterfaceStats{RxBytes: rx, TxBytes: tx}
+	}
+	return stats, nil
+}
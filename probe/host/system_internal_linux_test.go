@@ -0,0 +1,35 @@
+package host
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const procNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1969712   20466    0    0    0     0          0         0  1969712   20466    0    0    0     0       0          0
+  eth0: 873624521  693042    0    0    0     0          0         0 52399745  412364    0    0    0     0       0          0
+docker0:       0       0    0    0    0     0          0         0     4521      37    0    0    0     0       0          0
+veth1a2b3c:18446744073709551615 12 0 0 0 0 0 0 42 1 0 0 0 0 0 0
+`
+
+func TestParseNetDev(t *testing.T) {
+	have, err := parseNetDev(strings.NewReader(procNetDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]InterfaceStats{
+		"lo":         {RxBytes: 1969712, TxBytes: 1969712},
+		"eth0":       {RxBytes: 873624521, TxBytes: 52399745},
+		"docker0":    {RxBytes: 0, TxBytes: 4521},
+		"veth1a2b3c": {RxBytes: 18446744073709551615, TxBytes: 42},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	if _, err := parseNetDev(strings.NewReader("  eth0: 1 2 3\n")); err == nil {
+		t.Error("expected an error for a truncated line")
+	}
+}
@@ -1,8 +1,11 @@
 package host
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"strconv"
 	"strings"
 	"syscall"
@@ -107,3 +110,45 @@ var GetMemoryUsageBytes = func() (float64, float64) {
 	used := meminfo.MemTotal - meminfo.MemFree - meminfo.Buffers - meminfo.Cached
 	return float64(used * kb), float64(meminfo.MemTotal * kb)
 }
+
+// GetNetworkStats returns the received and transmitted bytes of each network
+// interface, keyed by interface name.
+var GetNetworkStats = func() (map[string]InterfaceStats, error) {
+	f, err := os.Open(ProcNetDev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+// parseNetDev parses the contents of /proc/net/dev. After two header lines,
+// each line is an interface name followed by a colon, 8 receive counters
+// (bytes first) and 8 transmit counters (bytes first).
+func parseNetDev(r io.Reader) (map[string]InterfaceStats, error) {
+	var (
+		stats   = map[string]InterfaceStats{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		colon := strings.Index(scanner.Text(), ":")
+		if colon < 0 {
+			continue // header
+		}
+		name := strings.TrimSpace(scanner.Text()[:colon])
+		fields := strings.Fields(scanner.Text()[colon+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("invalid format for interface %s: %q", name, scanner.Text())
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		stats[name] = InterfaceStats{RxBytes: rx, TxBytes: tx}
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package probe
+
+import (
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// KeyFilter is a Tagger which removes metadata from the nodes of every
+// topology, so that it never leaves the host: the keys on the deny list,
+// and, if there is an allow list, every key not on it. Keys are matched
+// exactly, or by prefix if they end in "*", e.g. "docker_env_*". It covers
+// the latest values and sets of the nodes, not their metrics or counters.
+//
+// Scope needs some keys, such as the host node ID and PID, to join the
+// topologies up, so an allow list must include those to be useful.
+//
+// It should be the last Tagger added, so it sees what the others added.
+type KeyFilter struct {
+	allow, deny keyPatterns
+}
+
+// NewKeyFilter makes a KeyFilter from lists of keys to allow and deny. An
+// empty allow list allows every key not denied.
+func NewKeyFilter(allow, deny []string) *KeyFilter {
+	return &KeyFilter{allow: makeKeyPatterns(allow), deny: makeKeyPatterns(deny)}
+}
+
+// Name implements Tagger
+func (*KeyFilter) Name() string { return "KeyFilter" }
+
+// Tag implements Tagger
+func (f *KeyFilter) Tag(r report.Report) (report.Report, error) {
+	r.WalkTopologies(func(t *report.Topology) {
+		nodes := make(report.Nodes, len(t.Nodes))
+		for id, node := range t.Nodes {
+			nodes[id] = f.filter(node)
+		}
+		t.Nodes = nodes
+	})
+	return r, nil
+}
+
+func (f *KeyFilter) keep(key string) bool {
+	if f.deny.match(key) {
+		return false
+	}
+	return len(f.allow) == 0 || f.allow.match(key)
+}
+
+func (f *KeyFilter) filter(n report.Node) report.Node {
+	n.Latest.ForEach(func(key string, _ time.Time, _ string) {
+		if !f.keep(key) {
+			n.Latest = n.Latest.Delete(key)
+		}
+	})
+	for _, key := range n.Sets.Keys() {
+		if !f.keep(key) {
+			n.Sets = n.Sets.Delete(key)
+		}
+	}
+	return n
+}
+
+// keyPatterns are keys, or key prefixes ending in "*".
+type keyPatterns []string
+
+func makeKeyPatterns(keys []string) keyPatterns {
+	var result keyPatterns
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func (ps keyPatterns) match(key string) bool {
+	for _, p := range ps {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func keyFilterInput() report.Report {
+	rpt := report.MakeReport()
+	rpt.Process.AddNode(report.MakeNodeWith("host1;10", map[string]string{
+		report.HostNodeID:   report.MakeHostNodeID("host1"),
+		"pid":               "10",
+		"name":              "nginx",
+		"cmdline":           "nginx -p secret",
+		"endpoint:env_USER": "root",
+		"endpoint:env_HOME": "/root",
+	}).WithSet("endpoint:snooped_dns_names", report.MakeStringSet("internal.example.com")))
+	rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID("host1"), map[string]string{
+		"host_name": "host1",
+		"cmdline":   "unusual, but denied all the same",
+	}))
+	return rpt
+}
+
+func TestKeyFilter(t *testing.T) {
+	for name, c := range map[string]struct {
+		allow, deny []string
+		process     []string
+		host        []string
+		sets        bool
+	}{
+		"deny": {
+			deny:    []string{"cmdline", "endpoint:env_*"},
+			process: []string{report.HostNodeID, "pid", "name"},
+			host:    []string{"host_name"},
+			sets:    true,
+		},
+		"allow": {
+			allow:   []string{report.HostNodeID, "pid", "name"},
+			process: []string{report.HostNodeID, "pid", "name"},
+			host:    []string{},
+		},
+		"allow and deny": {
+			allow:   []string{report.HostNodeID, "pid", "name", "endpoint:*"},
+			deny:    []string{"name", "endpoint:env_*"},
+			process: []string{report.HostNodeID, "pid"},
+			host:    []string{},
+			sets:    true,
+		},
+	} {
+		have, err := NewKeyFilter(c.allow, c.deny).Tag(keyFilterInput())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, want := range map[string][]string{
+			"host1;10":                     c.process,
+			report.MakeHostNodeID("host1"): c.host,
+		} {
+			node, ok := have.Process.Nodes[id]
+			if !ok {
+				node = have.Host.Nodes[id]
+			}
+			keys := map[string]bool{}
+			node.Latest.ForEach(func(key string, _ time.Time, _ string) {
+				keys[key] = true
+			})
+			if len(keys) != len(want) {
+				t.Errorf("%s: %s: want keys %v, have %v", name, id, want, keys)
+			}
+			for _, key := range want {
+				if !keys[key] {
+					t.Errorf("%s: %s: missing %s", name, id, key)
+				}
+			}
+		}
+		_, hasSet := have.Process.Nodes["host1;10"].Sets.Lookup("endpoint:snooped_dns_names")
+		if hasSet != c.sets {
+			t.Errorf("%s: want the DNS names set %v, have %v", name, c.sets, hasSet)
+		}
+	}
+}
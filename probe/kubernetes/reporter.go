@@ -13,6 +13,7 @@ import (
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 // These constants are keys used in node metadata
@@ -194,7 +195,7 @@ func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
 }
 
 // Report generates a Report containing Container and ContainerImage topologies
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	result := report.MakeReport()
 	serviceTopology, services, err := r.serviceTopology()
 	if err != nil {
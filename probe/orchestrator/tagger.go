@@ -0,0 +1,155 @@
+// Package orchestrator detects the container orchestration platform a host
+// is part of, so the app can tell which views are relevant to it.
+package orchestrator
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// The platforms detected, as the values of host.Orchestrator.
+const (
+	Kubernetes = "kubernetes"
+	ECS        = "ecs"
+	Swarm      = "swarm"
+)
+
+// indicators are the signs of each platform: environment variables set in
+// the probe's own container, processes running on the host, and labels the
+// platform puts on the containers it runs.
+var indicators = []struct {
+	platform  string
+	envVars   []string
+	processes []string
+	labels    []string
+}{
+	{
+		platform:  Kubernetes,
+		envVars:   []string{"KUBERNETES_SERVICE_HOST"},
+		processes: []string{"kubelet"},
+		labels:    []string{"io.kubernetes.pod.uid"},
+	},
+	{
+		platform: ECS,
+		envVars:  []string{"ECS_CONTAINER_METADATA_URI", "ECS_CONTAINER_METADATA_URI_V4"},
+		labels:   []string{"com.amazonaws.ecs.task-arn"},
+	},
+	{
+		platform: Swarm,
+		labels:   []string{"com.docker.swarm.service.id"},
+	},
+}
+
+// Tagger is a Tagger which sets host.Orchestrator on the host node to the
+// platform the host is part of. Until any platform's signs are seen, every
+// report is looked at; after that, the answer is kept. If more than one
+// platform's signs are seen at once, the host isn't tagged at all.
+type Tagger struct {
+	hostNodeID string
+	getenv     func(string) string
+
+	mtx      sync.Mutex
+	detected bool
+	platform string
+}
+
+// NewTagger makes a Tagger for the host with the given ID.
+func NewTagger(hostID string) *Tagger {
+	return newTagger(hostID, os.Getenv)
+}
+
+func newTagger(hostID string, getenv func(string) string) *Tagger {
+	return &Tagger{hostNodeID: report.MakeHostNodeID(hostID), getenv: getenv}
+}
+
+// Name implements Tagger
+func (*Tagger) Name() string { return "Orchestrator" }
+
+// Tag implements Tagger
+func (t *Tagger) Tag(r report.Report) (report.Report, error) {
+	t.mtx.Lock()
+	if !t.detected {
+		t.detect(r)
+	}
+	platform := t.platform
+	t.mtx.Unlock()
+
+	if platform != "" {
+		r.Host.AddNode(report.MakeNodeWith(t.hostNodeID, map[string]string{host.Orchestrator: platform}))
+	}
+	return r, nil
+}
+
+func (t *Tagger) detect(r report.Report) {
+	var (
+		processes = processNames(r.Process)
+		found     []string
+	)
+	for _, i := range indicators {
+		if t.anyEnvVar(i.envVars) || anyOf(processes, i.processes) || anyLabel(r.Container, i.labels) {
+			found = append(found, i.platform)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return
+	case 1:
+		log.Infof("Running on %s", found[0])
+		t.platform = found[0]
+	default:
+		sort.Strings(found)
+		log.Warnf("Found signs of several orchestrators (%s), not reporting any", strings.Join(found, ", "))
+	}
+	t.detected = true
+}
+
+func (t *Tagger) anyEnvVar(names []string) bool {
+	for _, name := range names {
+		if t.getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// processNames lists the names of the processes, without the path they were
+// run by, e.g. "kubelet" for "/usr/bin/kubelet".
+func processNames(processes report.Topology) map[string]struct{} {
+	names := map[string]struct{}{}
+	for _, n := range processes.Nodes {
+		if name, ok := n.Latest.Lookup(process.Name); ok {
+			names[path.Base(name)] = struct{}{}
+		}
+	}
+	return names
+}
+
+func anyOf(values map[string]struct{}, wanted []string) bool {
+	for _, w := range wanted {
+		if _, ok := values[w]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func anyLabel(containers report.Topology, labels []string) bool {
+	for _, n := range containers.Nodes {
+		for _, label := range labels {
+			if _, ok := n.Latest.Lookup(docker.LabelPrefix + label); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
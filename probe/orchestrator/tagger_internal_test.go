@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+func noEnv(string) string { return "" }
+
+func orchestrator(t *testing.T, tagger *Tagger, r report.Report) (string, bool) {
+	r, err := tagger.Tag(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Host.Nodes[report.MakeHostNodeID("host1")].Latest.Lookup(host.Orchestrator)
+}
+
+func TestTaggerKubernetes(t *testing.T) {
+	kubelet := report.MakeReport()
+	kubelet.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", "42"), map[string]string{
+		process.PID:  "42",
+		process.Name: "/usr/bin/kubelet",
+	}))
+	pod := report.MakeReport()
+	pod.Container.AddNode(report.MakeNodeWith(report.MakeContainerNodeID("abc"), map[string]string{
+		docker.LabelPrefix + "io.kubernetes.pod.uid": "1234",
+	}))
+
+	for name, c := range map[string]struct {
+		getenv func(string) string
+		r      report.Report
+	}{
+		"kubelet process": {noEnv, kubelet},
+		"pod container":   {noEnv, pod},
+		"service env var": {func(key string) string {
+			if key == "KUBERNETES_SERVICE_HOST" {
+				return "10.96.0.1"
+			}
+			return ""
+		}, report.MakeReport()},
+	} {
+		if have, ok := orchestrator(t, newTagger("host1", c.getenv), c.r); !ok || have != Kubernetes {
+			t.Errorf("%s: expected %q, got %q", name, Kubernetes, have)
+		}
+	}
+}
+
+func TestTaggerCached(t *testing.T) {
+	tagger := newTagger("host1", noEnv)
+	if have, ok := orchestrator(t, tagger, report.MakeReport()); ok {
+		t.Errorf("expected no orchestrator without any signs of one, got %q", have)
+	}
+
+	swarm := report.MakeReport()
+	swarm.Container.AddNode(report.MakeNodeWith(report.MakeContainerNodeID("abc"), map[string]string{
+		docker.LabelPrefix + "com.docker.swarm.service.id": "xyz",
+	}))
+	if have, _ := orchestrator(t, tagger, swarm); have != Swarm {
+		t.Errorf("expected %q, got %q", Swarm, have)
+	}
+	// Once found, it's kept, even without the signs.
+	if have, _ := orchestrator(t, tagger, report.MakeReport()); have != Swarm {
+		t.Errorf("expected %q to be kept, got %q", Swarm, have)
+	}
+}
+
+func TestTaggerAmbiguous(t *testing.T) {
+	r := report.MakeReport()
+	r.Container.AddNode(report.MakeNodeWith(report.MakeContainerNodeID("abc"), map[string]string{
+		docker.LabelPrefix + "io.kubernetes.pod.uid":      "1234",
+		docker.LabelPrefix + "com.amazonaws.ecs.task-arn": "arn:aws:ecs:task",
+	}))
+	if have, ok := orchestrator(t, newTagger("host1", noEnv), r); ok {
+		t.Errorf("expected no orchestrator with signs of several, got %q", have)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 
 	docker_client "github.com/fsouza/go-dockerclient"
 )
@@ -376,7 +377,7 @@ func (w *Weave) Tag(r report.Report) (report.Report, error) {
 }
 
 // Report implements Reporter.
-func (w *Weave) Report() (report.Report, error) {
+func (w *Weave) Report(context.Context) (report.Report, error) {
 	w.mtx.RLock()
 	defer w.mtx.RUnlock()
 
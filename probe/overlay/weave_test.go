@@ -14,6 +14,7 @@ import (
 	"github.com/weaveworks/scope/test/weave"
 
 	docker_client "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -77,7 +78,7 @@ func runTest(t *testing.T, f func(*overlay.Weave)) {
 
 	// Wait until the reporter reports some nodes
 	test.Poll(t, 300*time.Millisecond, 1, func() interface{} {
-		have, _ := w.Report()
+		have, _ := w.Report(context.Background())
 		return len(have.Overlay.Nodes)
 	})
 
@@ -129,7 +130,7 @@ func TestContainerTopologyTagging(t *testing.T) {
 func TestOverlayTopology(t *testing.T) {
 	test := func(w *overlay.Weave) {
 		// Overlay node should include peer name and nickname
-		have, err := w.Report()
+		have, err := w.Report(context.Background())
 		if err != nil {
 			t.Fatal(err)
 		}
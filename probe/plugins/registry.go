@@ -211,11 +211,14 @@ func (r *Registry) Implementers(iface string, f func(p *Plugin)) {
 func (r *Registry) Name() string { return "plugins" }
 
 // Report implements the Reporter interface
-func (r *Registry) Report() (report.Report, error) {
+func (r *Registry) Report(ctx context.Context) (report.Report, error) {
 	rpt := report.MakeReport()
 	// All plugins are assumed to (and must) implement reporter
 	r.forEach(&r.lock, func(plugin *Plugin) {
-		pluginReport, err := plugin.Report()
+		if ctx.Err() != nil {
+			return
+		}
+		pluginReport, err := plugin.Report(ctx)
 		if err != nil {
 			log.Errorf("plugins: %s: /report error: %v", plugin.socket, err)
 		}
@@ -224,6 +227,9 @@ func (r *Registry) Report() (report.Report, error) {
 		}
 		rpt = rpt.Merge(pluginReport)
 	})
+	if err := ctx.Err(); err != nil {
+		return report.MakeReport(), err
+	}
 	return rpt, nil
 }
 
@@ -406,7 +412,7 @@ func NewPlugin(ctx context.Context, socket string, client *http.Client, expected
 }
 
 // Report gets the latest report from the plugin
-func (p *Plugin) Report() (result report.Report, err error) {
+func (p *Plugin) Report(ctx context.Context) (result report.Report, err error) {
 	result = report.MakeReport()
 	defer func() {
 		p.setStatus(err)
@@ -417,7 +423,7 @@ func (p *Plugin) Report() (result report.Report, err error) {
 		}
 	}()
 
-	if err := p.get("/report", p.handshakeMetadata, &result); err != nil {
+	if err := p.get(ctx, "/report", p.handshakeMetadata, &result); err != nil {
 		return result, err
 	}
 	if result.Plugins.Size() != 1 {
@@ -479,10 +485,18 @@ func (p *Plugin) setStatus(err error) {
 	}
 }
 
-func (p *Plugin) get(path string, params url.Values, result interface{}) error {
-	// Context here lets us either timeout req. or cancel it in Plugin.Close
-	ctx, cancel := context.WithTimeout(p.context, pluginTimeout)
+func (p *Plugin) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	// Context here lets us either timeout req., or cancel it in Plugin.Close
+	// or through the caller's ctx
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
 	defer cancel()
+	go func() {
+		select {
+		case <-p.context.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 	resp, err := ctxhttp.Get(ctx, p.client, fmt.Sprintf("http://plugin%s?%s", path, params.Encode()))
 	if err != nil {
 		return err
@@ -25,6 +25,7 @@ import (
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/reflect"
+	"golang.org/x/net/context"
 )
 
 func testRegistry(t *testing.T, apiVersion string) *Registry {
@@ -251,7 +252,7 @@ func TestRegistryLoadsExistingPlugins(t *testing.T) {
 	r := testRegistry(t, "1")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"testPlugin"})
 }
 
@@ -277,7 +278,7 @@ func TestRegistryLoadsExistingPluginsEvenWhenOneFails(t *testing.T) {
 	r := testRegistry(t, "1")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPlugins(t, r.ForEach, []xfer.PluginSpec{
 		{
 			ID:     "aFailure",
@@ -301,7 +302,7 @@ func TestRegistryDiscoversNewPlugins(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{})
 
 	// Add the new plugin
@@ -315,7 +316,7 @@ func TestRegistryDiscoversNewPlugins(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"testPlugin"})
 }
 
@@ -331,7 +332,7 @@ func TestRegistryRemovesPlugins(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"testPlugin"})
 
 	// Remove the plugin
@@ -359,13 +360,13 @@ func TestRegistryUpdatesPluginsWhenTheyChange(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"testPlugin"})
 
 	// Update the plugin. Just change what the handler will respond with.
 	resp = `{"Plugins":[{"id":"testPlugin","label":"updatedPlugin","interfaces":["reporter"]}]}`
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPlugins(t, r.ForEach, []xfer.PluginSpec{
 		{
 			ID:         "testPlugin",
@@ -395,7 +396,7 @@ func TestRegistryReturnsPluginsByInterface(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"plugin1", "plugin2"})
 	checkLoadedPluginIDs(t, func(fn func(*Plugin)) { r.Implementers("reporter", fn) }, []string{"plugin1"})
 	checkLoadedPluginIDs(t, func(fn func(*Plugin)) { r.Implementers("other", fn) }, []string{"plugin2"})
@@ -420,7 +421,7 @@ func TestRegistryHandlesConflictingPlugins(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	// Should just have the second one (we just log conflicts)
 	checkLoadedPluginIDs(t, r.ForEach, []string{"plugin1"})
 	checkLoadedPluginIDs(t, func(fn func(*Plugin)) { r.Implementers("other", fn) }, []string{"plugin1"})
@@ -475,7 +476,7 @@ func TestRegistryRejectsErroneousPluginResponses(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPlugins(t, r.ForEach, []xfer.PluginSpec{
 		{
 			ID:     "changedID",
@@ -554,7 +555,7 @@ func TestRegistryRejectsPluginResponsesWhichAreTooLarge(t *testing.T) {
 	r := testRegistry(t, "")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPlugins(t, r.ForEach, []xfer.PluginSpec{
 		{ID: "foo", Label: "foo", Status: `error: response must be shorter than 50MB`},
 	})
@@ -604,7 +605,7 @@ func TestRegistryChecksForValidPluginIDs(t *testing.T) {
 	r := testRegistry(t, "1")
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	checkLoadedPluginIDs(t, r.ForEach, []string{"P-L-U-G-I-N", "another-testPlugin", "testPlugin"})
 }
 
@@ -742,7 +743,7 @@ func TestRegistryRewritesControlReports(t *testing.T) {
 	r := testRegistry(t, "1")
 	defer r.Close()
 
-	rpt, err := r.Report()
+	rpt, err := r.Report(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -787,7 +788,7 @@ func TestRegistryRegistersHandlers(t *testing.T) {
 	}
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	expectedLen := 3
 	if len(testBackend.handlers) != expectedLen {
 		t.Fatalf("Expected %d registered handler, got %d", expectedLen, len(testBackend.handlers))
@@ -837,7 +838,7 @@ func TestRegistryHandlersCallPlugins(t *testing.T) {
 	}
 	defer r.Close()
 
-	r.Report()
+	r.Report(context.Background())
 	fakeID := fakeControlID("testPlugin", controlID(1))
 	req := xfer.Request{NodeID: "node1", Control: fakeID}
 	res := handlerRegistry.HandleControlRequest(req)
@@ -6,6 +6,8 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/armon/go-metrics"
+	"github.com/weaveworks/common/mtime"
+	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/report"
@@ -13,16 +15,21 @@ import (
 
 const (
 	reportBufferSize = 16
+
+	// How long Stop waits for the final report to be generated and published.
+	stopTimeout = 5 * time.Second
 )
 
 // Probe sits there, generating and publishing reports.
 type Probe struct {
 	spyInterval, publishInterval time.Duration
 	publisher                    *appclient.ReportPublisher
+	hostID, version              string
 
 	tickers   []Ticker
 	reporters []Reporter
 	taggers   []Tagger
+	sinks     []Sink
 
 	quit chan struct{}
 	done sync.WaitGroup
@@ -37,24 +44,32 @@ type Tagger interface {
 	Tag(r report.Report) (report.Report, error)
 }
 
-// Reporter generates Reports.
+// Reporter generates Reports. Report should give up, returning ctx.Err(),
+// once ctx is done.
 type Reporter interface {
 	Name() string
-	Report() (report.Report, error)
+	Report(ctx context.Context) (report.Report, error)
+}
+
+// Sink receives a copy of every report the probe publishes, e.g. to record
+// them locally.
+type Sink interface {
+	Name() string
+	Write(r report.Report) error
 }
 
 // ReporterFunc uses a function to implement a Reporter
-func ReporterFunc(name string, f func() (report.Report, error)) Reporter {
+func ReporterFunc(name string, f func(context.Context) (report.Report, error)) Reporter {
 	return reporterFunc{name, f}
 }
 
 type reporterFunc struct {
 	name string
-	f    func() (report.Report, error)
+	f    func(context.Context) (report.Report, error)
 }
 
-func (r reporterFunc) Name() string                   { return r.name }
-func (r reporterFunc) Report() (report.Report, error) { return r.f() }
+func (r reporterFunc) Name() string                                      { return r.name }
+func (r reporterFunc) Report(ctx context.Context) (report.Report, error) { return r.f(ctx) }
 
 // Ticker is something which will be invoked every spyDuration.
 // It's useful for things that should be updated on that interval.
@@ -68,12 +83,15 @@ type Ticker interface {
 func New(
 	spyInterval, publishInterval time.Duration,
 	publisher appclient.Publisher,
+	hostID, version string,
 	noControls bool,
 ) *Probe {
 	result := &Probe{
 		spyInterval:     spyInterval,
 		publishInterval: publishInterval,
 		publisher:       appclient.NewReportPublisher(publisher, noControls),
+		hostID:          hostID,
+		version:         version,
 		quit:            make(chan struct{}),
 		spiedReports:    make(chan report.Report, reportBufferSize),
 		shortcutReports: make(chan report.Report, reportBufferSize),
@@ -96,6 +114,11 @@ func (p *Probe) AddTicker(ts ...Ticker) {
 	p.tickers = append(p.tickers, ts...)
 }
 
+// AddSink adds a new Sink to the Probe
+func (p *Probe) AddSink(ss ...Sink) {
+	p.sinks = append(p.sinks, ss...)
+}
+
 // Start starts the probe
 func (p *Probe) Start() {
 	p.done.Add(2)
@@ -103,10 +126,26 @@ func (p *Probe) Start() {
 	go p.publishLoop()
 }
 
-// Stop stops the probe
+// Stop stops the probe. Before returning, it publishes a final report, along
+// with any reports still queued, so the app gets to see the latest state.
 func (p *Probe) Stop() {
 	close(p.quit)
 	p.done.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		rpt := p.tag(p.report(ctx))
+		rpt = drain(rpt, p.spiedReports)
+		p.drainAndPublish(rpt, p.shortcutReports)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(stopTimeout):
+		log.Warnf("Timed out publishing final report after %v", stopTimeout)
+	}
 }
 
 // Publish will queue a report for immediate publication,
@@ -120,12 +159,23 @@ func (p *Probe) spyLoop() {
 	defer p.done.Done()
 	spyTick := time.Tick(p.spyInterval)
 
+	// Cancel any report in progress when the probe is stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	for {
 		select {
 		case <-spyTick:
 			t := time.Now()
 			p.tick()
-			rpt := p.report()
+			rpt := p.report(ctx)
 			rpt = p.tag(rpt)
 			p.spiedReports <- rpt
 			metrics.MeasureSince([]string{"Report Generaton"}, t)
@@ -146,19 +196,24 @@ func (p *Probe) tick() {
 	}
 }
 
-func (p *Probe) report() report.Report {
+// report merges the reports of all the reporters. If ctx is done before they
+// have all finished, the reports so far are returned without waiting for the
+// rest.
+func (p *Probe) report(ctx context.Context) report.Report {
 	reports := make(chan report.Report, len(p.reporters))
 	for _, rep := range p.reporters {
 		go func(rep Reporter) {
 			t := time.Now()
 			timer := time.AfterFunc(p.spyInterval, func() { log.Warningf("%v reporter took longer than %v", rep.Name(), p.spyInterval) })
-			newReport, err := rep.Report()
+			newReport, err := rep.Report(ctx)
 			if !timer.Stop() {
 				log.Warningf("%v reporter took %v (longer than %v)", rep.Name(), time.Now().Sub(t), p.spyInterval)
 			}
 			metrics.MeasureSince([]string{rep.Name(), "reporter"}, t)
 			if err != nil {
-				log.Errorf("error generating report: %v", err)
+				if ctx.Err() == nil {
+					log.Errorf("error generating report: %v", err)
+				}
 				newReport = report.MakeReport() // empty is OK to merge
 			}
 			reports <- newReport
@@ -167,7 +222,13 @@ func (p *Probe) report() report.Report {
 
 	result := report.MakeReport()
 	for i := 0; i < cap(reports); i++ {
-		result = result.Merge(<-reports)
+		select {
+		case rpt := <-reports:
+			result = result.Merge(rpt)
+		case <-ctx.Done():
+			log.Warnf("Gave up waiting for %d reporters: %v", cap(reports)-i, ctx.Err())
+			return result
+		}
 	}
 	return result
 }
@@ -189,20 +250,31 @@ func (p *Probe) tag(r report.Report) report.Report {
 	return r
 }
 
-func (p *Probe) drainAndPublish(rpt report.Report, rs chan report.Report) {
-ForLoop:
+// drain merges all the reports currently queued on rs into rpt.
+func drain(rpt report.Report, rs chan report.Report) report.Report {
 	for {
 		select {
 		case r := <-rs:
 			rpt = rpt.Merge(r)
 		default:
-			break ForLoop
+			return rpt
 		}
 	}
+}
 
+func (p *Probe) drainAndPublish(rpt report.Report, rs chan report.Report) {
+	rpt = drain(rpt, rs)
+	rpt.Timestamp = mtime.Now()
+	rpt.HostID = p.hostID
+	rpt.ProbeVersion, rpt.SchemaVersion = p.version, report.SchemaVersion
 	if err := p.publisher.Publish(rpt.BackwardCompatible()); err != nil {
 		log.Infof("publish: %v", err)
 	}
+	for _, sink := range p.sinks {
+		if err := sink.Write(rpt); err != nil {
+			log.Errorf("error writing report to %v sink: %v", sink.Name(), err)
+		}
+	}
 }
 
 func (p *Probe) publishLoop() {
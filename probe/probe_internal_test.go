@@ -1,16 +1,20 @@
 package probe
 
 import (
+	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"testing"
 	"time"
 
 	"github.com/ugorji/go/codec"
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 	"github.com/weaveworks/scope/test/reflect"
+	"golang.org/x/net/context"
 )
 
 func TestApply(t *testing.T) {
@@ -19,7 +23,7 @@ func TestApply(t *testing.T) {
 		endpointNode   = report.MakeNodeWith(endpointNodeID, map[string]string{"5": "6"})
 	)
 
-	p := New(0, 0, nil, false)
+	p := New(0, 0, nil, "", "", false)
 	p.AddTagger(NewTopologyTagger())
 
 	r := report.MakeReport()
@@ -43,12 +47,68 @@ type mockReporter struct {
 	r report.Report
 }
 
-func (m mockReporter) Report() (report.Report, error) {
+func (m mockReporter) Report(context.Context) (report.Report, error) {
 	return m.r.Copy(), nil
 }
 
 func (mockReporter) Name() string { return "Mock" }
 
+func TestReportFailingReporter(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith("a", map[string]string{"b": "c"}))
+
+	p := New(0, 0, nil, "", "", false)
+	p.AddReporter(
+		ReporterFunc("Failing", func(context.Context) (report.Report, error) {
+			return report.MakeReport(), fmt.Errorf("failed")
+		}),
+		mockReporter{rpt},
+	)
+
+	have := p.report(context.Background())
+	if _, ok := have.Endpoint.Nodes["a"]; !ok {
+		t.Errorf("report is missing the working reporter's node: %v", have)
+	}
+}
+
+func TestReportCancelled(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		errs    = make(chan error, 1)
+	)
+	defer close(release)
+
+	p := New(0, 0, nil, "", "", false)
+	p.AddReporter(
+		ReporterFunc("Cancellable", func(ctx context.Context) (report.Report, error) {
+			<-ctx.Done()
+			errs <- ctx.Err()
+			return report.MakeReport(), ctx.Err()
+		}),
+		// Reporters which ignore ctx aren't waited for either.
+		ReporterFunc("Stuck", func(context.Context) (report.Report, error) {
+			<-release
+			return report.MakeReport(), nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan struct{})
+	go func() {
+		p.report(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("report didn't return after being cancelled")
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
 type mockPublisher struct {
 	have chan report.Report
 }
@@ -75,6 +135,9 @@ func TestProbe(t *testing.T) {
 	defer mtime.NowReset()
 
 	want := report.MakeReport()
+	want.Timestamp = now.Round(0) // no monotonic clock reading once decoded
+	want.HostID = "hostid"
+	want.ProbeVersion, want.SchemaVersion = "1.2.3", report.SchemaVersion
 	node := report.MakeNodeWith("a", map[string]string{"b": "c"})
 
 	// marshalling->unmarshaling is not idempotent due to `json:"omitempty"`
@@ -97,7 +160,7 @@ func TestProbe(t *testing.T) {
 
 	pub := mockPublisher{make(chan report.Report, 10)}
 
-	p := New(10*time.Millisecond, 100*time.Millisecond, pub, false)
+	p := New(10*time.Millisecond, 100*time.Millisecond, pub, "hostid", "1.2.3", false)
 	p.AddReporter(mockReporter{want})
 	p.Start()
 	defer p.Stop()
@@ -106,3 +169,104 @@ func TestProbe(t *testing.T) {
 		return <-pub.have
 	})
 }
+
+func TestProbeStopPublishesFinalReport(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith("a", map[string]string{"b": "c"}))
+
+	pub := mockPublisher{make(chan report.Report, 10)}
+
+	// Long intervals, so only the final report on Stop gets published
+	p := New(time.Hour, time.Hour, pub, "", "", false)
+	p.AddReporter(mockReporter{rpt})
+	p.Start()
+	p.Stop()
+
+	select {
+	case have := <-pub.have:
+		if _, ok := have.Endpoint.Nodes["a"]; !ok {
+			t.Errorf("final report is missing node: %v", have)
+		}
+	default:
+		t.Fatal("no report published on Stop")
+	}
+}
+
+// teePublisher keeps a copy of what it publishes.
+type teePublisher struct {
+	sent *bytes.Buffer
+	next appclient.Publisher
+}
+
+func (p teePublisher) Publish(r io.Reader) error {
+	return p.next.Publish(io.TeeReader(r, p.sent))
+}
+
+func (p teePublisher) Stop() { p.next.Stop() }
+
+func TestProbeDryRun(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith("a", map[string]string{"b": "c"}))
+
+	// Long intervals, so only the final report on Stop gets printed
+	out, sent := &bytes.Buffer{}, &bytes.Buffer{}
+	p := New(time.Hour, time.Hour, teePublisher{sent, appclient.NewDryRunPublisher(out)}, "hostid", "1.2.3", false)
+	p.AddReporter(mockReporter{rpt})
+	p.Start()
+	p.Stop()
+
+	// The output is what an app would have been sent, as indented JSON.
+	published, err := report.MakeFromBinary(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	if err := codec.NewEncoder(want, &codec.JsonHandle{Indent: 2}).Encode(published); err != nil {
+		t.Fatal(err)
+	}
+	want.WriteString("\n")
+	if want.String() != out.String() {
+		t.Fatalf("want:\n%s\nhave:\n%s", want, out)
+	}
+
+	var have report.Report
+	if err := codec.NewDecoderBytes(out.Bytes(), &codec.JsonHandle{}).Decode(&have); err != nil {
+		t.Fatalf("printed report isn't JSON: %v", err)
+	}
+	if b, _ := have.Endpoint.Nodes["a"].Latest.Lookup("b"); b != "c" || have.HostID != "hostid" {
+		t.Errorf("printed report doesn't match: %v", have)
+	}
+}
+
+func TestProbeTopologyFilter(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith("host1;1.2.3.4;80", map[string]string{"addr": "1.2.3.4"}))
+	rpt.Process.AddNode(report.MakeNodeWith("host1;10", map[string]string{"pid": "10"}))
+	rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID("host1"), map[string]string{"host_name": "host1"}))
+
+	filter, err := NewTopologyFilter([]string{report.Host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(10*time.Second, 100*time.Millisecond, nil, "hostid", "1.2.3", false)
+	p.AddReporter(mockReporter{rpt})
+	p.AddTagger(filter)
+	have := p.tag(p.report(context.Background()))
+
+	if len(have.Endpoint.Nodes) != 0 || len(have.Process.Nodes) != 0 {
+		t.Errorf("expected only the host topology, got %v and %v", have.Endpoint.Nodes, have.Process.Nodes)
+	}
+	if _, ok := have.Host.Nodes[report.MakeHostNodeID("host1")]; !ok {
+		t.Errorf("expected the host to be kept, got %v", have.Host.Nodes)
+	}
+	if err := have.Validate(); err != nil {
+		t.Errorf("expected a valid report: %v", err)
+	}
+	if len(rpt.Endpoint.Nodes) != 1 {
+		t.Errorf("expected the reporter's report to be left alone")
+	}
+
+	if _, err := NewTopologyFilter([]string{"containers"}); err == nil {
+		t.Errorf("expected an unknown topology to be rejected")
+	}
+}
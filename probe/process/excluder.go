@@ -0,0 +1,158 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Excluder drops processes matching any of a list of patterns from reports,
+// along with their endpoints and the edges to those endpoints.
+type Excluder struct {
+	patterns []*regexp.Regexp
+	pids     map[string]struct{}
+}
+
+// NewExcluder makes an Excluder. Patterns are shell globs, as for
+// path.Match, except that * matches across '/' too, so "*java*" matches
+// "/usr/bin/java -jar app.jar". They are matched against the name and the
+// command line of each process. If excludeSelf is true, the probe's own
+// process is dropped too.
+func NewExcluder(patterns []string, excludeSelf bool) (Excluder, error) {
+	e := Excluder{pids: map[string]struct{}{}}
+	for _, pattern := range patterns {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return Excluder{}, err
+		}
+		e.patterns = append(e.patterns, re)
+	}
+	if excludeSelf {
+		e.pids[strconv.Itoa(os.Getpid())] = struct{}{}
+	}
+	return e, nil
+}
+
+// Name of this tagger, for metrics gathering
+func (Excluder) Name() string { return "ProcessExcluder" }
+
+// Tag implements Tagger.
+func (e Excluder) Tag(r report.Report) (report.Report, error) {
+	if len(e.patterns) == 0 && len(e.pids) == 0 {
+		return r, nil
+	}
+
+	pids := map[string]struct{}{}
+	for pid := range e.pids {
+		pids[pid] = struct{}{}
+	}
+	processes := make(report.Nodes, len(r.Process.Nodes))
+	for id, n := range r.Process.Nodes {
+		pid, _ := n.Latest.Lookup(PID)
+		if _, ok := pids[pid]; ok || e.matches(n) {
+			pids[pid] = struct{}{}
+			continue
+		}
+		processes[id] = n
+	}
+	r.Process.Nodes = processes
+
+	var (
+		endpoints = make(report.Nodes, len(r.Endpoint.Nodes))
+		dropped   = map[string]struct{}{}
+	)
+	for id, n := range r.Endpoint.Nodes {
+		if pid, ok := n.Latest.Lookup(PID); ok {
+			if _, ok := pids[pid]; ok {
+				dropped[id] = struct{}{}
+				continue
+			}
+		}
+		endpoints[id] = n
+	}
+	if len(dropped) > 0 {
+		for id, n := range endpoints {
+			endpoints[id] = pruneAdjacency(n, dropped)
+		}
+	}
+	r.Endpoint.Nodes = endpoints
+	return r, nil
+}
+
+func (e Excluder) matches(n report.Node) bool {
+	name, _ := n.Latest.Lookup(Name)
+	cmdline, _ := n.Latest.Lookup(Cmdline)
+	for _, pattern := range e.patterns {
+		for _, s := range []string{name, cmdline} {
+			if s != "" && pattern.MatchString(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globRegexp compiles a glob into an anchored regexp: * matches any run of
+// characters, ? any single one, [...] a character class, negated by [!...],
+// and \ escapes the character after it.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	buf.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, fmt.Errorf("bad pattern %q: trailing backslash", pattern)
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("bad pattern %q: unterminated character class", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			buf.WriteString("[")
+			// Globs negate classes with a leading '!', regexps with '^'.
+			if strings.HasPrefix(class, "!") {
+				buf.WriteString("^")
+				class = class[1:]
+			}
+			buf.WriteString(class)
+			buf.WriteString("]")
+			i += end + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
+
+// pruneAdjacency removes the adjacencies and edges to dropped nodes.
+func pruneAdjacency(n report.Node, dropped map[string]struct{}) report.Node {
+	var (
+		adjacency = report.MakeIDList()
+		edges     = report.EmptyEdgeMetadatas
+	)
+	for _, dst := range n.Adjacency {
+		if _, ok := dropped[dst]; !ok {
+			adjacency = adjacency.Add(dst)
+		}
+	}
+	n.Edges.ForEach(func(dst string, md report.EdgeMetadata) {
+		if _, ok := dropped[dst]; !ok {
+			edges = edges.Add(dst, md)
+		}
+	})
+	n.Adjacency, n.Edges = adjacency, edges
+	return n
+}
@@ -0,0 +1,130 @@
+package process_test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestExcluder(t *testing.T) {
+	var (
+		self     = strconv.Itoa(os.Getpid())
+		endpoint = func(port, pid string) report.Node {
+			n := report.MakeNode(report.MakeEndpointNodeID("host1", "", "10.0.0.1", port))
+			if pid != "" {
+				n = n.WithLatests(map[string]string{process.PID: pid})
+			}
+			return n
+		}
+		exporter = endpoint("9100", "42")
+		server   = endpoint("80", "1")
+		remote   = endpoint("54321", "")
+		probe    = endpoint("4040", self)
+	)
+	rpt := report.MakeReport()
+	for pid, name := range map[string]string{"1": "apache", "42": "node-exporter", self: "scope"} {
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{
+			process.PID:  pid,
+			process.Name: name,
+		}))
+	}
+	rpt.Endpoint.AddNode(exporter.WithEdge(server.ID, report.EdgeMetadata{}))
+	rpt.Endpoint.AddNode(server.WithEdge(exporter.ID, report.EdgeMetadata{}))
+	rpt.Endpoint.AddNode(remote.WithEdge(exporter.ID, report.EdgeMetadata{}).WithEdge(server.ID, report.EdgeMetadata{}))
+	rpt.Endpoint.AddNode(probe.WithEdge(server.ID, report.EdgeMetadata{}))
+
+	excluder, err := process.NewExcluder([]string{"node-*"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := excluder.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(have.Process.Nodes) != 1 {
+		t.Errorf("expected only apache to be left, got %v", have.Process.Nodes)
+	}
+	if _, ok := have.Process.Nodes[report.MakeProcessNodeID("host1", "1")]; !ok {
+		t.Errorf("expected apache to be kept, got %v", have.Process.Nodes)
+	}
+	for _, id := range []string{exporter.ID, probe.ID} {
+		if _, ok := have.Endpoint.Nodes[id]; ok {
+			t.Errorf("expected endpoint %s to be dropped", id)
+		}
+	}
+	for _, id := range []string{server.ID, remote.ID} {
+		n, ok := have.Endpoint.Nodes[id]
+		if !ok {
+			t.Fatalf("expected endpoint %s to be kept", id)
+		}
+		if n.Adjacency.Contains(exporter.ID) {
+			t.Errorf("expected the adjacency %s -> %s to be pruned", id, exporter.ID)
+		}
+		if _, ok := n.Edges.Lookup(exporter.ID); ok {
+			t.Errorf("expected the edge %s -> %s to be pruned", id, exporter.ID)
+		}
+	}
+	if !have.Endpoint.Nodes[remote.ID].Adjacency.Contains(server.ID) {
+		t.Errorf("expected the adjacency %s -> %s to be kept", remote.ID, server.ID)
+	}
+
+	if _, err := process.NewExcluder([]string{"["}, false); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+}
+
+func TestExcluderFullPaths(t *testing.T) {
+	rpt := report.MakeReport()
+	for pid, cmdline := range map[string]string{
+		"1": "/usr/bin/java -jar /opt/app/app.jar",
+		"2": "/usr/sbin/sshd -D",
+		"3": "/usr/local/bin/exporter --web.listen-address=:9100",
+	} {
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{
+			process.PID:     pid,
+			process.Name:    cmdline[:strings.Index(cmdline, " ")],
+			process.Cmdline: cmdline,
+		}))
+	}
+
+	// * matches across '/', so patterns match full paths.
+	excluder, err := process.NewExcluder([]string{"*java -jar *", "*/exporter"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := excluder.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Process.Nodes[report.MakeProcessNodeID("host1", "2")]; !ok || len(have.Process.Nodes) != 1 {
+		t.Errorf("expected only sshd to be left, got %v", have.Process.Nodes)
+	}
+}
+
+func TestExcluderNegatedClass(t *testing.T) {
+	rpt := report.MakeReport()
+	for pid, name := range map[string]string{"1": "worker-1", "2": "worker-a", "3": "worker-!"} {
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{
+			process.PID:  pid,
+			process.Name: name,
+		}))
+	}
+
+	// [!0-9] matches anything but a digit, and not a literal '!'.
+	excluder, err := process.NewExcluder([]string{"worker-[!0-9]"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := excluder.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Process.Nodes[report.MakeProcessNodeID("host1", "1")]; !ok || len(have.Process.Nodes) != 1 {
+		t.Errorf("expected only worker-1 to be left, got %v", have.Process.Nodes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/report"
+	"golang.org/x/net/context"
 )
 
 // We use these keys in node metadata
@@ -61,9 +62,9 @@ func NewReporter(walker Walker, scope string, jiffies Jiffies, noCommandLineArgu
 func (Reporter) Name() string { return "Process" }
 
 // Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(ctx context.Context) (report.Report, error) {
 	result := report.MakeReport()
-	processes, err := r.processTopology()
+	processes, err := r.processTopology(ctx)
 	if err != nil {
 		return result, err
 	}
@@ -71,7 +72,7 @@ func (r *Reporter) Report() (report.Report, error) {
 	return result, nil
 }
 
-func (r *Reporter) processTopology() (report.Topology, error) {
+func (r *Reporter) processTopology(ctx context.Context) (report.Topology, error) {
 	t := report.MakeTopology().
 		WithMetadataTemplates(MetadataTemplates).
 		WithMetricTemplates(MetricTemplates)
@@ -82,6 +83,10 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 	}
 
 	err = r.walker.Walk(func(p, prev Process) {
+		// The walk can't be interrupted, but we can skip the work.
+		if ctx.Err() != nil {
+			return
+		}
 		pidstr := strconv.Itoa(p.PID)
 		nodeID := report.MakeProcessNodeID(r.scope, pidstr)
 		node := report.MakeNode(nodeID)
@@ -117,6 +122,9 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 
 		t.AddNode(node)
 	})
+	if err == nil {
+		err = ctx.Err()
+	}
 
 	return t, err
 }
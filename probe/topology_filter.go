@@ -0,0 +1,47 @@
+package probe
+
+import (
+	"fmt"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// TopologyFilter is a Tagger which empties the topologies of a report other
+// than those enabled, so their nodes are never sent. The topologies are
+// still there, so the report is valid; taggers adding nodes to a disabled
+// topology should come before it.
+type TopologyFilter struct {
+	enabled map[string]bool
+}
+
+// NewTopologyFilter makes a TopologyFilter enabling the topologies with the
+// given names (e.g. report.Container), or an error for unknown names.
+func NewTopologyFilter(topologies []string) (*TopologyFilter, error) {
+	f := &TopologyFilter{enabled: map[string]bool{}}
+	for _, name := range topologies {
+		if _, ok := report.MakeReport().Topology(name); !ok {
+			return nil, fmt.Errorf("unknown topology %q", name)
+		}
+		f.enabled[name] = true
+	}
+	return f, nil
+}
+
+// Enabled says whether the topology with the given name is enabled, so that
+// reporters for disabled ones needn't run at all.
+func (f *TopologyFilter) Enabled(name string) bool {
+	return f.enabled[name]
+}
+
+// Name implements Tagger
+func (*TopologyFilter) Name() string { return "TopologyFilter" }
+
+// Tag implements Tagger
+func (f *TopologyFilter) Tag(r report.Report) (report.Report, error) {
+	r.WalkNamedTopologies(func(name string, t *report.Topology) {
+		if !f.enabled[name] && len(t.Nodes) > 0 {
+			t.Nodes = report.Nodes{}
+		}
+	})
+	return r, nil
+}
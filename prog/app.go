@@ -6,6 +6,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -50,12 +51,12 @@ func init() {
 }
 
 // Router creates the mux for all the various app components.
-func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, externalUI bool) http.Handler {
+func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, externalUI bool, cors app.CORSConfig, auth app.AuthConfig) http.Handler {
 	router := mux.NewRouter().SkipClean(true)
 
 	// We pull in the http.DefaultServeMux to get the pprof routes
 	router.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
-	router.Path("/metrics").Handler(prometheus.Handler())
+	app.RegisterMetricsRoute(router)
 
 	app.RegisterReportPostHandler(collector, router)
 	app.RegisterControlRoutes(router, controlRouter)
@@ -72,7 +73,18 @@ func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter
 		RouteMatcher: router,
 		Duration:     requestDuration,
 	}
-	return instrument.Wrap(router)
+	return middleware.Merge(instrument, cors, auth).Wrap(router)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 }
 
 func awsConfigFromURL(url *url.URL) (*aws.Config, error) {
@@ -90,9 +102,16 @@ func awsConfigFromURL(url *url.URL) (*aws.Config, error) {
 	return config, nil
 }
 
-func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHostname, memcachedHostname string, memcachedTimeout time.Duration, memcachedService string, memcachedExpiration time.Duration, memcachedCompressionLevel int, window time.Duration, createTables bool) (app.Collector, error) {
+func collectorFactory(userIDer multitenant.UserIDer, collectorURL, storeURL, s3URL, natsHostname, memcachedHostname string, memcachedTimeout time.Duration, memcachedService string, memcachedExpiration time.Duration, memcachedCompressionLevel int, window time.Duration, createTables bool) (app.Collector, error) {
 	if collectorURL == "local" {
-		return app.NewCollector(window), nil
+		if storeURL == "" {
+			return app.NewCollector(window), nil
+		}
+		store, err := app.NewStore(storeURL)
+		if err != nil {
+			return nil, err
+		}
+		return app.NewCollectorWithStore(window, store)
 	}
 
 	parsed, err := url.Parse(collectorURL)
@@ -102,6 +121,9 @@ func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHo
 
 	switch parsed.Scheme {
 	case "file":
+		if filepath.Ext(parsed.Path) == ".ndjson" {
+			return app.NewStreamCollector(parsed.Path, window, parsed.Query().Get("pace") != "fast")
+		}
 		return app.NewFileCollector(parsed.Path, window)
 	case "dynamodb":
 		s3, err := url.Parse(s3URL)
@@ -237,7 +259,7 @@ func appMain(flags appFlags) {
 	}
 
 	collector, err := collectorFactory(
-		userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname, flags.memcachedHostname,
+		userIDer, flags.collectorURL, flags.storeURL, flags.s3URL, flags.natsHostname, flags.memcachedHostname,
 		flags.memcachedTimeout, flags.memcachedService, flags.memcachedExpiration, flags.memcachedCompressionLevel,
 		flags.window, flags.awsCreateTables)
 	if err != nil {
@@ -294,7 +316,12 @@ func appMain(flags appFlags) {
 		}
 	}
 
-	handler := router(collector, controlRouter, pipeRouter, flags.externalUI)
+	cors := app.CORSConfig{
+		AllowedOrigins: splitList(flags.corsAllowedOrigins),
+		AllowedMethods: splitList(flags.corsAllowedMethods),
+		AllowedHeaders: splitList(flags.corsAllowedHeaders),
+	}
+	handler := router(collector, controlRouter, pipeRouter, flags.externalUI, cors, flags.auth)
 	if flags.logHTTP {
 		handler = middleware.Log{
 			LogRequestHeaders: flags.logHTTPHeaders,
@@ -22,6 +22,7 @@ import (
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/weave/common"
 )
 
@@ -33,11 +34,15 @@ var (
 	probeTokenFlag         = "probe.token"
 	kubernetesPasswordFlag = "probe.kubernetes.password"
 	kubernetesTokenFlag    = "probe.kubernetes.token"
+	appAuthTokenFlag       = "app.auth.token"
+	appAuthPasswordFlag    = "app.auth.password"
 	sensitiveFlags         = []string{
 		serviceTokenFlag,
 		probeTokenFlag,
 		kubernetesPasswordFlag,
 		kubernetesTokenFlag,
+		appAuthTokenFlag,
+		appAuthPasswordFlag,
 	}
 	colonFinder         = regexp.MustCompile(`[^\\](:)`)
 	unescapeBackslashes = regexp.MustCompile(`\\(.)`)
@@ -85,6 +90,7 @@ type probeFlags struct {
 	token                  string
 	httpListen             string
 	publishInterval        time.Duration
+	publishBackoff         appclient.BackoffConfig
 	spyInterval            time.Duration
 	pluginsRoot            string
 	insecure               bool
@@ -95,15 +101,33 @@ type probeFlags struct {
 	noControls             bool
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
+	reportFile             string
+	dryRun                 bool
+	metadataAllow          string
+	metadataDeny           string
+	topologies             string
+	hostLoopbackStats      bool
+	hostCloudMetadata      bool
+	hostIDStrategy         string
+	hostID                 string
 
 	useConntrack        bool // Use conntrack for endpoint topo
 	conntrackBufferSize int  // Sie of kernel buffer for conntrack
+	conntrackNamespaces bool // Also track NAT in container network namespaces
 
-	spyProcs    bool // Associate endpoints with processes (must be root)
-	procEnabled bool // Produce process topology & process nodes in endpoint
-	useEbpfConn bool // Enable connection tracking with eBPF
+	spyProcs    bool          // Associate endpoints with processes (must be root)
+	spyTimeout  time.Duration // How long to wait for the connections from /proc
+	procEnabled bool          // Produce process topology & process nodes in endpoint
+	useEbpfConn bool          // Enable connection tracking with eBPF
 	procRoot    string
 
+	processExclude     string // Comma-separated patterns of processes to leave out
+	processExcludeSelf bool   // Leave out the probe's own process
+
+	endpointEnvVars           string // Comma-separated environment variables to capture
+	endpointIncludeLoopback   bool   // Report connections between loopback addresses
+	endpointConnectionSamples bool   // Sample the connections behind each edge
+
 	dockerEnabled  bool
 	dockerInterval time.Duration
 	dockerBridge   string
@@ -137,6 +161,7 @@ type appFlags struct {
 	dockerEndpoint string
 
 	collectorURL              string
+	storeURL                  string
 	s3URL                     string
 	controlRouterURL          string
 	pipeRouterURL             string
@@ -148,6 +173,10 @@ type appFlags struct {
 	memcachedCompressionLevel int
 	userIDHeader              string
 	externalUI                bool
+	auth                      app.AuthConfig
+	corsAllowedOrigins        string
+	corsAllowedMethods        string
+	corsAllowedHeaders        string
 
 	blockProfileRate int
 
@@ -181,6 +210,31 @@ func (c *containerLabelFiltersFlag) Set(flagValue string) error {
 	return nil
 }
 
+// labelTemplatesFlag collects label templates, each given as
+// topology=template, e.g. process={{.name}} ({{.pid}}). Templates are parsed
+// as the flags are, so invalid ones stop the app from starting.
+type labelTemplatesFlag struct {
+	minor     bool
+	templates []detailed.LabelTemplate
+}
+
+func (l *labelTemplatesFlag) String() string {
+	return fmt.Sprint(l.templates)
+}
+
+func (l *labelTemplatesFlag) Set(flagValue string) error {
+	parts := strings.SplitN(flagValue, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("label template isn't in the topology=template format")
+	}
+	template, err := detailed.ParseLabelTemplate(parts[0], l.minor, parts[1])
+	if err != nil {
+		return err
+	}
+	l.templates = append(l.templates, template)
+	return nil
+}
+
 func (c *containerLabelFiltersFlag) toAPITopologyOption(flagValue string, filterID string) (app.APITopologyOption, error) {
 	indexRanges := colonFinder.FindAllStringIndex(flagValue, -1)
 	if len(indexRanges) != 1 {
@@ -250,6 +304,8 @@ func main() {
 		dryRun                           bool
 		containerLabelFilterFlags        = containerLabelFiltersFlag{exclude: false, filterIDPrefix: "containerLabelFilterExclude"}
 		containerLabelFilterFlagsExclude = containerLabelFiltersFlag{exclude: true, filterIDPrefix: "containerLabelFilter"}
+		labelTemplateFlags               = labelTemplatesFlag{minor: false}
+		labelMinorTemplateFlags          = labelTemplatesFlag{minor: true}
 	)
 
 	// Flags that apply to both probe and app
@@ -272,11 +328,23 @@ func main() {
 	flag.StringVar(&flags.probe.token, probeTokenFlag, "", "Token to use to authenticate with cloud.weave.works")
 	flag.StringVar(&flags.probe.httpListen, "probe.http.listen", "", "listen address for HTTP profiling and instrumentation server")
 	flag.DurationVar(&flags.probe.publishInterval, "probe.publish.interval", 3*time.Second, "publish (output) interval")
+	flag.IntVar(&flags.probe.publishBackoff.Retries, "probe.publish.retries", appclient.DefaultPublishRetries, "how many times to retry publishing a report before dropping it")
+	flag.DurationVar(&flags.probe.publishBackoff.Initial, "probe.publish.initial-backoff", time.Second, "how long to wait before first retrying a failed publish")
+	flag.DurationVar(&flags.probe.publishBackoff.Max, "probe.publish.max-backoff", 10*time.Second, "longest to wait between retries of a failed publish")
 	flag.DurationVar(&flags.probe.spyInterval, "probe.spy.interval", time.Second, "spy (scan) interval")
 	flag.StringVar(&flags.probe.pluginsRoot, "probe.plugins.root", "/var/run/scope/plugins", "Root directory to search for plugins")
 	flag.BoolVar(&flags.probe.noControls, "probe.no-controls", false, "Disable controls (e.g. start/stop containers, terminals, logs ...)")
 	flag.BoolVar(&flags.probe.noCommandLineArguments, "probe.omit.cmd-args", false, "Disable collection of command-line arguments")
 	flag.BoolVar(&flags.probe.noEnvironmentVariables, "probe.omit.env-vars", false, "Disable collection of environment variables")
+	flag.StringVar(&flags.probe.hostIDStrategy, "probe.host.id-strategy", host.HostIDHostname, "how to derive the host ID: hostname, or machine-id to keep it stable across reboots and renames (falls back to the hostname)")
+	flag.StringVar(&flags.probe.hostID, "probe.host.id", "", "host ID to report, overriding probe.host.id-strategy")
+	flag.BoolVar(&flags.probe.hostLoopbackStats, "probe.host.loopback-stats", false, "Include loopback interfaces in the host's network interface table")
+	flag.BoolVar(&flags.probe.hostCloudMetadata, "probe.host.cloud-metadata", false, "Tag the host with its cloud provider, region, zone and instance type, from the AWS, GCP or Azure metadata service")
+	flag.StringVar(&flags.probe.reportFile, "probe.report-file", "", "Also append every published report to this file, as newline-delimited JSON")
+	flag.StringVar(&flags.probe.metadataAllow, "probe.metadata.allow", "", "Comma-separated list of node metadata keys to report, leaving out all others; a trailing * matches any key with that prefix (e.g. docker_*). Keys Scope needs, like host_node_id and pid, must be included")
+	flag.StringVar(&flags.probe.metadataDeny, "probe.metadata.deny", "", "Comma-separated list of node metadata keys (e.g. cmdline,docker_env_*) to leave out of reports")
+	flag.StringVar(&flags.probe.topologies, "probe.topologies", "", "Comma-separated list of the topologies to report (e.g. container,host), leaving the others empty and not collecting the endpoint or process topology unless listed; all if empty")
+	flag.BoolVar(&flags.probe.dryRun, "probe.dry-run", false, "Print each report to stdout as indented JSON, instead of publishing it to any app")
 
 	flag.BoolVar(&flags.probe.insecure, "probe.insecure", false, "(SSL) explicitly allow \"insecure\" SSL connections and transfers")
 	flag.StringVar(&flags.probe.resolver, "probe.resolver", "", "IP address & port of resolver to use.  Default is to use system resolver.")
@@ -286,10 +354,17 @@ func main() {
 	// Proc & endpoint
 	flag.BoolVar(&flags.probe.useConntrack, "probe.conntrack", true, "also use conntrack to track connections")
 	flag.IntVar(&flags.probe.conntrackBufferSize, "probe.conntrack.buffersize", 208*1024, "conntrack buffer size")
+	flag.BoolVar(&flags.probe.conntrackNamespaces, "probe.conntrack.namespaces", false, "also run conntrack in each container network namespace, to track NAT done inside containers")
 	flag.BoolVar(&flags.probe.spyProcs, "probe.proc.spy", true, "associate endpoints with processes (needs root)")
+	flag.DurationVar(&flags.probe.spyTimeout, "probe.proc.spy.timeout", 0, "report the connections listed so far if listing them from /proc takes longer than this (0 to wait indefinitely)")
 	flag.StringVar(&flags.probe.procRoot, "probe.proc.root", "/proc", "location of the proc filesystem")
 	flag.BoolVar(&flags.probe.procEnabled, "probe.processes", true, "produce process topology & include procspied connections")
+	flag.StringVar(&flags.probe.processExclude, "probe.processes.exclude", "", "Comma-separated list of patterns (e.g. node-exporter*) matching the names or command lines of processes to leave out, along with their connections")
+	flag.BoolVar(&flags.probe.processExcludeSelf, "probe.processes.exclude-self", true, "leave out the probe's own process and connections")
 	flag.BoolVar(&flags.probe.useEbpfConn, "probe.ebpf.connections", false, "enable connection tracking with eBPF")
+	flag.StringVar(&flags.probe.endpointEnvVars, "probe.endpoint.env-vars", "", "Comma-separated list of environment variables (e.g. SERVICE_NAME) to capture from the processes owning connections. No other variables are read.")
+	flag.BoolVar(&flags.probe.endpointIncludeLoopback, "probe.endpoint.include-loopback", true, "report connections between two loopback addresses")
+	flag.BoolVar(&flags.probe.endpointConnectionSamples, "probe.endpoint.connection-samples", false, "report the ports and state of a sample of the connections behind each edge")
 
 	// Docker
 	flag.BoolVar(&flags.probe.dockerEnabled, "probe.docker", false, "collect Docker-related attributes for processes")
@@ -338,8 +413,11 @@ func main() {
 	flag.StringVar(&flags.app.dockerEndpoint, "app.docker", app.DefaultDockerEndpoint, "Location of docker endpoint (to lookup container ID)")
 	flag.Var(&containerLabelFilterFlags, "app.container-label-filter", "Add container label-based view filter, specified as title:label. Multiple flags are accepted. Example: --app.container-label-filter='Database Containers:role=db'")
 	flag.Var(&containerLabelFilterFlagsExclude, "app.container-label-filter-exclude", "Add container label-based view filter that excludes containers with the given label, specified as title:label. Multiple flags are accepted. Example: --app.container-label-filter-exclude='Database Containers:role=db'")
+	flag.Var(&labelTemplateFlags, "app.label-template", "Format the labels of the nodes of a topology from their metadata, specified as topology=template. Multiple flags are accepted. Example: --app.label-template='process={{.name}} ({{.pid}})'. A node keeps its usual label if the template uses metadata it doesn't have")
+	flag.Var(&labelMinorTemplateFlags, "app.label-minor-template", "Like --app.label-template, for the minor labels of the nodes")
 
-	flag.StringVar(&flags.app.collectorURL, "app.collector", "local", "Collector to use (local, dynamodb, or file/directory)")
+	flag.StringVar(&flags.app.collectorURL, "app.collector", "local", "Collector to use (local, dynamodb, or file/directory). A *.ndjson file written by --probe.report-file is replayed at real-time pace, or as fast as possible with file:///path.ndjson?pace=fast")
+	flag.StringVar(&flags.app.storeURL, "app.collector.store", "", "Where the local collector also keeps every report for the window, as it was added (memory, or a directory as file:///path, which outlives the app and can be read back with --app.collector=file:///path). By default only downsampled reports are kept, in memory")
 	flag.StringVar(&flags.app.s3URL, "app.collector.s3", "local", "S3 URL to use (when collector is dynamodb)")
 	flag.StringVar(&flags.app.controlRouterURL, "app.control.router", "local", "Control router to use (local or sqs)")
 	flag.StringVar(&flags.app.pipeRouterURL, "app.pipe.router", "local", "Pipe router to use (local)")
@@ -351,6 +429,13 @@ func main() {
 	flag.IntVar(&flags.app.memcachedCompressionLevel, "app.memcached.compression", gzip.DefaultCompression, "How much to compress reports stored in memcached.")
 	flag.StringVar(&flags.app.userIDHeader, "app.userid.header", "", "HTTP header to use as userid")
 	flag.BoolVar(&flags.app.externalUI, "app.externalUI", false, "Point to externally hosted static UI assets")
+	flag.StringVar(&flags.app.auth.Token, appAuthTokenFlag, "", "If set, require this bearer token for /api requests. Probes authenticate with it via --probe.token")
+	flag.StringVar(&flags.app.auth.Username, "app.auth.username", "", "If set, require HTTP basic authentication with this username for /api requests")
+	flag.StringVar(&flags.app.auth.Password, appAuthPasswordFlag, "", "Password for HTTP basic authentication (see --app.auth.username)")
+	flag.BoolVar(&flags.app.auth.GateStatic, "app.auth.static", false, "Also require authentication for static UI assets")
+	flag.StringVar(&flags.app.corsAllowedOrigins, "app.cors.allowed-origins", "", "Comma-separated list of origins allowed to make cross-origin API requests ('*' for any). By default only same-origin requests are allowed")
+	flag.StringVar(&flags.app.corsAllowedMethods, "app.cors.allowed-methods", strings.Join(app.DefaultCORSMethods, ","), "Comma-separated list of methods allowed in cross-origin API requests")
+	flag.StringVar(&flags.app.corsAllowedHeaders, "app.cors.allowed-headers", strings.Join(app.DefaultCORSHeaders, ","), "Comma-separated list of headers allowed in cross-origin API requests")
 
 	flag.IntVar(&flags.app.blockProfileRate, "app.block.profile.rate", 0, "If more than 0, enable block profiling. The profiler aims to sample an average of one blocking event per rate nanoseconds spent blocked.")
 
@@ -362,6 +447,7 @@ func main() {
 	flag.Parse()
 
 	app.AddContainerFilters(append(containerLabelFilterFlags.apiTopologyOptions, containerLabelFilterFlagsExclude.apiTopologyOptions...)...)
+	detailed.SetLabelTemplates(append(labelTemplateFlags.templates, labelMinorTemplateFlags.templates...)...)
 
 	// Deal with common args
 	if debug {
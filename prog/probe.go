@@ -29,6 +29,7 @@ import (
 	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/orchestrator"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/plugins"
 	"github.com/weaveworks/scope/probe/process"
@@ -108,10 +109,18 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 	var (
 		probeID  = strconv.FormatInt(rand.Int63(), 16)
 		hostName = hostname.Get()
-		hostID   = hostName // TODO(pb): we should sanitize the hostname
 	)
+	hostID, err := host.HostID(flags.hostIDStrategy, flags.hostID, flags.procRoot)
+	if err != nil {
+		log.Fatalf("Failed to work out host ID: %v", err)
+	}
 	log.Infof("probe starting, version %s, ID %s", version, probeID)
 	checkNewScopeVersion(flags)
+	if flags.dryRun {
+		// Nothing is published, so there's no need to connect to any app.
+		log.Info("Dry run: printing reports instead of publishing them")
+		targets = nil
+	}
 
 	handlerRegistry := controls.NewDefaultHandlerRegistry()
 	clientFactory := func(hostname string, url url.URL) (appclient.AppClient, error) {
@@ -125,6 +134,8 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			ProbeVersion: version,
 			ProbeID:      probeID,
 			Insecure:     flags.insecure,
+
+			PublishBackoff: flags.publishBackoff,
 		}
 		return appclient.NewAppClient(
 			probeConfig, hostname, url,
@@ -149,9 +160,37 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 	}
 	defer resolver.Stop()
 
-	p := probe.New(flags.spyInterval, flags.publishInterval, clients, flags.noControls)
+	var publisher appclient.Publisher = clients
+	if flags.dryRun {
+		publisher = appclient.NewDryRunPublisher(os.Stdout)
+	}
+	p := probe.New(flags.spyInterval, flags.publishInterval, publisher, hostID, version, flags.noControls)
+
+	if flags.reportFile != "" {
+		sink, err := probe.NewFileSink(flags.reportFile)
+		if err != nil {
+			log.Fatalf("Failed to open report file: %v", err)
+			return
+		}
+		defer sink.Close()
+		p.AddSink(sink)
+	}
+
+	var topologyFilter *probe.TopologyFilter
+	if flags.topologies != "" {
+		if topologyFilter, err = probe.NewTopologyFilter(splitList(flags.topologies)); err != nil {
+			log.Fatalf("Invalid topologies: %v", err)
+		}
+	}
+	enabled := func(topology string) bool {
+		return topologyFilter == nil || topologyFilter.Enabled(topology)
+	}
 
-	hostReporter := host.NewReporter(hostID, hostName, probeID, version, clients, handlerRegistry)
+	var cloud *host.CloudMetadata
+	if flags.hostCloudMetadata {
+		cloud = host.NewCloudMetadata()
+	}
+	hostReporter := host.NewReporter(hostID, hostName, probeID, version, clients, handlerRegistry, flags.hostLoopbackStats, cloud)
 	defer hostReporter.Stop()
 	p.AddReporter(hostReporter)
 	p.AddTagger(probe.NewTopologyTagger(), host.NewTagger(hostID))
@@ -160,30 +199,45 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 	if flags.procEnabled {
 		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot))
 		p.AddTicker(processCache)
-		p.AddReporter(process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies, flags.noCommandLineArguments))
+		if enabled(report.Process) {
+			p.AddReporter(process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies, flags.noCommandLineArguments))
+		}
 	}
-
-	dnsSnooper, err := endpoint.NewDNSSnooper()
+	excluder, err := process.NewExcluder(splitList(flags.processExclude), flags.processExcludeSelf)
 	if err != nil {
-		log.Errorf("Failed to start DNS snooper: nodes for external services will be less accurate: %s", err)
-	} else {
-		defer dnsSnooper.Stop()
+		log.Fatalf("Failed to parse process exclusion patterns: %v", err)
 	}
+	p.AddTagger(excluder)
 
-	endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
-		HostID:       hostID,
-		HostName:     hostName,
-		SpyProcs:     flags.spyProcs,
-		UseConntrack: flags.useConntrack,
-		WalkProc:     flags.procEnabled,
-		UseEbpfConn:  flags.useEbpfConn,
-		ProcRoot:     flags.procRoot,
-		BufferSize:   flags.conntrackBufferSize,
-		ProcessCache: processCache,
-		DNSSnooper:   dnsSnooper,
-	})
-	defer endpointReporter.Stop()
-	p.AddReporter(endpointReporter)
+	if enabled(report.Endpoint) {
+		dnsSnooper, err := endpoint.NewDNSSnooper()
+		if err != nil {
+			log.Errorf("Failed to start DNS snooper: nodes for external services will be less accurate: %s", err)
+		} else {
+			defer dnsSnooper.Stop()
+		}
+
+		endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
+			HostID:        hostID,
+			HostName:      hostName,
+			SpyProcs:      flags.spyProcs,
+			SpyTimeout:    flags.spyTimeout,
+			UseConntrack:  flags.useConntrack,
+			NamespacedNAT: flags.conntrackNamespaces,
+			WalkProc:      flags.procEnabled,
+			UseEbpfConn:   flags.useEbpfConn,
+			ProcRoot:      flags.procRoot,
+			BufferSize:    flags.conntrackBufferSize,
+			ProcessCache:  processCache,
+			DNSSnooper:    dnsSnooper,
+			EnvVars:       splitList(flags.endpointEnvVars),
+
+			ExcludeLoopback:   !flags.endpointIncludeLoopback,
+			ConnectionSamples: flags.endpointConnectionSamples,
+		})
+		defer endpointReporter.Stop()
+		p.AddReporter(endpointReporter)
+	}
 
 	if flags.dockerEnabled {
 		// Don't add the bridge in Kubernetes since container IPs are global and
@@ -285,6 +339,17 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		p.AddReporter(pluginRegistry)
 	}
 
+	// After the process and container taggers, so it sees what they add.
+	p.AddTagger(orchestrator.NewTagger(hostID))
+
+	if topologyFilter != nil {
+		p.AddTagger(topologyFilter)
+	}
+	if flags.metadataAllow != "" || flags.metadataDeny != "" {
+		// Last, so that it applies to everything the other taggers add.
+		p.AddTagger(probe.NewKeyFilter(splitList(flags.metadataAllow), splitList(flags.metadataDeny)))
+	}
+
 	maybeExportProfileData(flags)
 
 	p.Start()
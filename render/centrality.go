@@ -0,0 +1,108 @@
+package render
+
+import (
+	"strconv"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// Centrality is the key added to Node.Latest by MakeCentralityDecorator. Its
+// value is how central the node is to the rendered graph, as a whole number;
+// the larger, the more central.
+const Centrality = "centrality"
+
+// The measures of centrality understood by MakeCentralityDecorator.
+const (
+	// DegreeCentrality counts the other nodes a node is connected to, either
+	// way, including pseudo nodes.
+	DegreeCentrality = "degree"
+	// BytesCentrality adds up the bytes sent, either way, over the edges to
+	// and from a node, totalled over the endpoint connections they were
+	// rendered from.
+	BytesCentrality = "bytes"
+)
+
+// MakeCentralityDecorator makes a decorator which marks every node with its
+// centrality, by the given measure. It returns false for unknown measures.
+func MakeCentralityDecorator(measure string) (Decorator, bool) {
+	var centrality func(report.Report, report.Nodes) map[string]uint64
+	switch measure {
+	case DegreeCentrality:
+		centrality = degreeCentrality
+	case BytesCentrality:
+		centrality = bytesCentrality
+	default:
+		return nil, false
+	}
+	return func(r Renderer) Renderer {
+		return centralityRenderer{Renderer: r, centrality: centrality}
+	}, true
+}
+
+type centralityRenderer struct {
+	Renderer
+	centrality func(report.Report, report.Nodes) map[string]uint64
+}
+
+// Render implements Renderer.
+func (c centralityRenderer) Render(rpt report.Report, dct Decorator) report.Nodes {
+	var (
+		input  = c.Renderer.Render(rpt, dct)
+		values = c.centrality(rpt, input)
+		output = make(report.Nodes, len(input))
+	)
+	for id, node := range input {
+		output[id] = node.WithLatest(Centrality, mtime.Now(), strconv.FormatUint(values[id], 10))
+	}
+	return output
+}
+
+// degreeCentrality counts the neighbours of each node, treating adjacencies
+// as undirected. Adjacencies to nodes not in nodes, and to the node itself,
+// are ignored.
+func degreeCentrality(_ report.Report, nodes report.Nodes) map[string]uint64 {
+	neighbours := map[string]map[string]struct{}{}
+	link := func(a, b string) {
+		if neighbours[a] == nil {
+			neighbours[a] = map[string]struct{}{}
+		}
+		neighbours[a][b] = struct{}{}
+	}
+	for id, node := range nodes {
+		for _, dst := range node.Adjacency {
+			if _, ok := nodes[dst]; !ok || dst == id {
+				continue
+			}
+			link(id, dst)
+			link(dst, id)
+		}
+	}
+	result := make(map[string]uint64, len(nodes))
+	for id := range nodes {
+		result[id] = uint64(len(neighbours[id]))
+	}
+	return result
+}
+
+// bytesCentrality adds up the bytes over the edges to and from each node. As
+// with MakeMinBytesDecorator, the rendered nodes don't carry edge metadata, so
+// the bytes are totalled from the report's endpoint edges, against the
+// rendered nodes holding either end of them. Edges within a node, and those
+// without byte counts, add nothing.
+func bytesCentrality(rpt report.Report, nodes report.Nodes) map[string]uint64 {
+	result := make(map[string]uint64, len(nodes))
+	WalkEndpointEdges(rpt, nodes, func(_, _ string, md report.EdgeMetadata, srcOwners, dstOwners []string) {
+		bytes, _ := edgeByteCount(md)
+		for _, a := range srcOwners {
+			for _, b := range dstOwners {
+				if a == b {
+					continue
+				}
+				result[a] += bytes
+				result[b] += bytes
+			}
+		}
+	})
+	return result
+}
@@ -0,0 +1,94 @@
+package render_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+// star renders each node from a single endpoint of the same name, with the
+// edges between the endpoints in the report.
+func star() (render.Renderer, report.Report) {
+	u64 := func(v uint64) *uint64 { return &v }
+	rpt := report.MakeReport()
+	// hub -> a, b, c, and the busy leaf c -> pseudo:internet.
+	rpt.Endpoint.AddNode(report.MakeNode("hub").
+		WithEdge("a", report.EdgeMetadata{EgressByteCount: u64(10)}).
+		WithEdge("b", report.EdgeMetadata{EgressByteCount: u64(10)}).
+		WithEdge("c", report.EdgeMetadata{EgressByteCount: u64(10)}))
+	rpt.Endpoint.AddNode(report.MakeNode("a"))
+	rpt.Endpoint.AddNode(report.MakeNode("b").WithAdjacent("hub"))
+	rpt.Endpoint.AddNode(report.MakeNode("c").WithEdge("pseudo:internet", report.EdgeMetadata{EgressByteCount: u64(1000)}))
+	rpt.Endpoint.AddNode(report.MakeNode("pseudo:internet"))
+
+	nodes := report.Nodes{}
+	for id, endpoint := range rpt.Endpoint.Nodes {
+		node := report.MakeNode(id).WithChildren(report.MakeNodeSet(endpoint.WithTopology(report.Endpoint)))
+		node.Adjacency = endpoint.Adjacency
+		nodes[id] = node
+	}
+	internet := nodes["pseudo:internet"]
+	nodes["pseudo:internet"] = internet.WithTopology(render.Pseudo)
+	return render.ConstantRenderer(nodes), rpt
+}
+
+func centralities(t *testing.T, measure string) map[string]uint64 {
+	renderer, rpt := star()
+	return renderCentralities(t, renderer, rpt, measure)
+}
+
+func renderCentralities(t *testing.T, renderer render.Renderer, rpt report.Report, measure string) map[string]uint64 {
+	dct, ok := render.MakeCentralityDecorator(measure)
+	if !ok {
+		t.Fatalf("unknown measure %s", measure)
+	}
+	result := map[string]uint64{}
+	for id, node := range render.ApplyDecorator(renderer).Render(rpt, dct) {
+		value, _ := node.Latest.Lookup(render.Centrality)
+		result[id], _ = strconv.ParseUint(value, 10, 64)
+	}
+	return result
+}
+
+func TestDegreeCentrality(t *testing.T) {
+	have := centralities(t, render.DegreeCentrality)
+	for id, want := range map[string]uint64{"hub": 3, "a": 1, "b": 1, "c": 2, "pseudo:internet": 1} {
+		if have[id] != want {
+			t.Errorf("%s: want %d, have %d", id, want, have[id])
+		}
+	}
+	for id, value := range have {
+		if id != "hub" && value >= have["hub"] {
+			t.Errorf("expected the hub to be the most central, but %s has %d", id, value)
+		}
+	}
+}
+
+func TestBytesCentrality(t *testing.T) {
+	have := centralities(t, render.BytesCentrality)
+	for id, want := range map[string]uint64{"hub": 30, "a": 10, "b": 10, "c": 1010, "pseudo:internet": 1000} {
+		if have[id] != want {
+			t.Errorf("%s: want %d, have %d", id, want, have[id])
+		}
+	}
+	if _, ok := render.MakeCentralityDecorator("pagerank"); ok {
+		t.Errorf("expected an unknown measure to be rejected")
+	}
+}
+
+func TestBytesCentralityProcesses(t *testing.T) {
+	// The bytes come from the endpoint edges behind the rendered processes.
+	have := renderCentralities(t, render.ProcessRenderer, fixture.Report, render.BytesCentrality)
+	for id, want := range map[string]uint64{
+		fixture.ClientProcess1NodeID: 100,
+		fixture.ClientProcess2NodeID: 200,
+		fixture.ServerProcessNodeID:  900,
+	} {
+		if have[id] != want {
+			t.Errorf("%s: want %d, have %d", id, want, have[id])
+		}
+	}
+}
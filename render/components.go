@@ -0,0 +1,67 @@
+package render
+
+import (
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// Component is the key added to Node.Latest by MakeComponentDecorator. Its
+// value identifies the connected component the node is in, by the lowest
+// node ID in it.
+const Component = "component"
+
+// MakeComponentDecorator makes a decorator which marks every node with the
+// connected component it is in, treating adjacencies as undirected. Nodes
+// without any connections are components of their own. If component is not
+// empty, only the nodes in that component are kept.
+func MakeComponentDecorator(component string) Decorator {
+	return func(r Renderer) Renderer {
+		return CustomRenderer{
+			Renderer: r,
+			RenderFunc: func(input report.Nodes) report.Nodes {
+				components := connectedComponents(input)
+				output := report.Nodes{}
+				for id, node := range input {
+					if component != "" && components[id] != component {
+						continue
+					}
+					output[id] = node.WithLatest(Component, mtime.Now(), components[id])
+				}
+				return output
+			},
+		}
+	}
+}
+
+// connectedComponents returns the component of each node, as the lowest ID
+// in it. Adjacencies to nodes not in nodes are ignored.
+func connectedComponents(nodes report.Nodes) map[string]string {
+	parent := make(map[string]string, len(nodes))
+	for id := range nodes {
+		parent[id] = id
+	}
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	for id, node := range nodes {
+		for _, dst := range node.Adjacency {
+			if _, ok := parent[dst]; !ok {
+				continue
+			}
+			a, b := find(id), find(dst)
+			if b < a {
+				a, b = b, a
+			}
+			parent[b] = a
+		}
+	}
+	result := make(map[string]string, len(nodes))
+	for id := range nodes {
+		result[id] = find(id)
+	}
+	return result
+}
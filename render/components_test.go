@@ -0,0 +1,46 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func twoClusters() render.Renderer {
+	return render.ConstantRenderer(report.Nodes{
+		// a -> b <- c, and d <-> e -> f
+		"a": report.MakeNode("a").WithAdjacent("b"),
+		"b": report.MakeNode("b"),
+		"c": report.MakeNode("c").WithAdjacent("b"),
+		"d": report.MakeNode("d").WithAdjacent("e"),
+		"e": report.MakeNode("e").WithAdjacent("d").WithAdjacent("f"),
+		"f": report.MakeNode("f"),
+		"g": report.MakeNode("g").WithAdjacent("missing"),
+	})
+}
+
+func TestComponentDecorator(t *testing.T) {
+	have := render.ApplyDecorator(twoClusters()).Render(report.MakeReport(), render.MakeComponentDecorator(""))
+	for id, want := range map[string]string{
+		"a": "a", "b": "a", "c": "a",
+		"d": "d", "e": "d", "f": "d",
+		"g": "g",
+	} {
+		if component, _ := have[id].Latest.Lookup(render.Component); component != want {
+			t.Errorf("%s: want component %q, have %q", id, want, component)
+		}
+	}
+}
+
+func TestComponentDecoratorFilters(t *testing.T) {
+	have := render.ApplyDecorator(twoClusters()).Render(report.MakeReport(), render.MakeComponentDecorator("d"))
+	if len(have) != 3 {
+		t.Fatalf("expected the 3 nodes of component d, got %v", have)
+	}
+	for _, id := range []string{"d", "e", "f"} {
+		if _, ok := have[id]; !ok {
+			t.Errorf("expected %s in %v", id, have)
+		}
+	}
+}
@@ -13,6 +13,8 @@ import (
 const (
 	UncontainedID    = "uncontained"
 	UncontainedMajor = "Uncontained"
+	UnlabelledID     = "unlabelled"
+	UnlabelledMajor  = "Unlabelled"
 
 	// Topology for IPs so we can differentiate them at the end
 	IP = "IP"
@@ -23,26 +25,19 @@ const (
 // NB We only want processes in container _or_ processes with network connections
 // but we need to be careful to ensure we only include each edge once, by only
 // including the ProcessRenderer once.
-var ContainerRenderer = MakeFilter(
-	func(n report.Node) bool {
-		// Drop deleted containers
-		state, ok := n.Latest.Lookup(docker.ContainerState)
-		return !ok || state != docker.StateDeleted
-	},
-	MakeReduce(
-		MakeMap(
-			MapProcess2Container,
-			ProcessRenderer,
-		),
+var ContainerRenderer = MakeReduce(
+	MakeMap(
+		MapProcess2Container,
+		ProcessRenderer,
+	),
 
-		// This mapper brings in short lived connections by joining with container IPs.
-		// We need to be careful to ensure we only include each edge once.  Edges brought in
-		// by the above renders will have a pid, so its enough to filter out any nodes with
-		// pids.
-		ShortLivedConnectionJoin(SelectContainer, MapContainer2IP),
+	// This mapper brings in short lived connections by joining with container IPs.
+	// We need to be careful to ensure we only include each edge once.  Edges brought in
+	// by the above renders will have a pid, so its enough to filter out any nodes with
+	// pids.
+	ShortLivedConnectionJoin(SelectContainer, MapContainer2IP),
 
-		SelectContainer,
-	),
+	SelectContainer,
 )
 
 const originalNodeID = "original_node_id"
@@ -208,18 +203,36 @@ var ContainerWithImageNameRenderer = containerWithImageNameRenderer{ContainerRen
 
 // ContainerImageRenderer is a Renderer which produces a renderable container
 // image graph by merging the container graph and the container image topology.
-var ContainerImageRenderer = FilterEmpty(report.Container,
-	MakeMap(
-		MapContainerImage2Name,
-		MakeReduce(
-			MakeMap(
-				MapContainer2ContainerImage,
-				ContainerWithImageNameRenderer,
+var ContainerImageRenderer = ContainerImageRendererBy(docker.ImageGroupByRepo)
+
+// ContainerImageRendererBy is like ContainerImageRenderer, but groups images
+// according to the given grouping.
+func ContainerImageRendererBy(grouping docker.ImageGrouping) Renderer {
+	return FilterEmpty(report.Container,
+		MakeMap(
+			MapContainerImage2NameBy(grouping),
+			MakeReduce(
+				MakeMap(
+					MapContainer2ContainerImage,
+					ContainerWithImageNameRenderer,
+				),
+				SelectContainerImage,
 			),
-			SelectContainerImage,
 		),
-	),
-)
+	)
+}
+
+// ContainerLabelRenderer is a Renderer which produces a renderable container
+// graph grouped by the value of the given Docker label, e.g.
+// com.docker.compose.service.
+func ContainerLabelRenderer(label string) Renderer {
+	return FilterEmpty(report.Container,
+		MakeMap(
+			MapContainer2Label(label),
+			ContainerWithImageNameRenderer,
+		),
+	)
+}
 
 // ContainerHostnameRenderer is a Renderer which produces a renderable container
 // by hostname graph..
@@ -358,25 +371,32 @@ func MapContainer2ContainerImage(n report.Node, _ report.Networks) report.Nodes
 }
 
 // MapContainerImage2Name ignores image versions
-func MapContainerImage2Name(n report.Node, _ report.Networks) report.Nodes {
-	// Propagate all pseudo nodes
-	if n.Topology == Pseudo {
-		return report.Nodes{n.ID: n}
-	}
+func MapContainerImage2Name(n report.Node, local report.Networks) report.Nodes {
+	return MapContainerImage2NameBy(docker.ImageGroupByRepo)(n, local)
+}
 
-	imageName, ok := n.Latest.Lookup(docker.ImageName)
-	if !ok {
-		return report.Nodes{}
-	}
+// MapContainerImage2NameBy maps container images to nodes named after the
+// parts of the image name significant for the given grouping.
+func MapContainerImage2NameBy(grouping docker.ImageGrouping) MapFunc {
+	return func(n report.Node, _ report.Networks) report.Nodes {
+		// Propagate all pseudo nodes
+		if n.Topology == Pseudo {
+			return report.Nodes{n.ID: n}
+		}
 
-	imageNameWithoutVersion := docker.ImageNameWithoutVersion(imageName)
-	n.ID = report.MakeContainerImageNodeID(imageNameWithoutVersion)
+		imageName, ok := n.Latest.Lookup(docker.ImageName)
+		if !ok {
+			return report.Nodes{}
+		}
 
-	if imageID, ok := report.ParseContainerImageNodeID(n.ID); ok {
-		n.Sets = n.Sets.Add(docker.ImageID, report.EmptyStringSet.Add(imageID))
-	}
+		n.ID = report.MakeContainerImageNodeID(docker.NormalizeImageName(imageName, grouping))
 
-	return report.Nodes{n.ID: n}
+		if imageID, ok := report.ParseContainerImageNodeID(n.ID); ok {
+			n.Sets = n.Sets.Add(docker.ImageID, report.EmptyStringSet.Add(imageID))
+		}
+
+		return report.Nodes{n.ID: n}
+	}
 }
 
 // MapContainer2Hostname maps container Nodes to 'hostname' renderabled nodes..
@@ -399,6 +419,32 @@ func MapContainer2Hostname(n report.Node, _ report.Networks) report.Nodes {
 	return report.Nodes{id: node}
 }
 
+// MapContainer2Label maps container Nodes to nodes for each value of the
+// given Docker label. Containers without the label are grouped into a single
+// "Unlabelled" pseudo node.
+func MapContainer2Label(label string) MapFunc {
+	key := docker.LabelPrefix + label
+	return func(n report.Node, _ report.Networks) report.Nodes {
+		// Propagate all pseudo nodes
+		if n.Topology == Pseudo {
+			return report.Nodes{n.ID: n}
+		}
+
+		value, timestamp, ok := n.Latest.LookupEntry(key)
+		if !ok || value == "" {
+			id := MakePseudoNodeID(UnlabelledID)
+			node := NewDerivedPseudoNode(id, n)
+			node.Counters = node.Counters.Add(n.Topology, 1)
+			return report.Nodes{id: node}
+		}
+
+		node := NewDerivedNode(value, n).WithTopology(MakeGroupNodeTopology(n.Topology, key))
+		node.Latest = node.Latest.Set(key, timestamp, value)
+		node.Counters = node.Counters.Add(n.Topology, 1)
+		return report.Nodes{value: node}
+	}
+}
+
 // MapToEmpty removes all the attributes, children, etc, of a node. Useful when
 // we just want to count the presence of nodes.
 func MapToEmpty(n report.Node, _ report.Networks) report.Nodes {
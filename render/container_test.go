@@ -7,6 +7,8 @@ import (
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/expected"
@@ -127,3 +129,102 @@ func TestContainerImageFilterRenderer(t *testing.T) {
 		t.Error(test.Diff(want, have))
 	}
 }
+
+func TestMapContainerImage2NameBy(t *testing.T) {
+	images := []report.Node{
+		report.MakeNodeWith(report.MakeContainerImageNodeID("1"), map[string]string{docker.ImageName: "redis:latest"}),
+		report.MakeNodeWith(report.MakeContainerImageNodeID("2"), map[string]string{docker.ImageName: "redis:3.2"}),
+		report.MakeNodeWith(report.MakeContainerImageNodeID("3"), map[string]string{docker.ImageName: "redis@sha256:abc"}),
+		report.MakeNodeWith(report.MakeContainerImageNodeID("4"), map[string]string{docker.ImageName: "redis:latest@sha256:abc"}),
+	}
+	for grouping, want := range map[docker.ImageGrouping][]string{
+		docker.ImageGroupByRepo:    {"redis", "redis", "redis", "redis"},
+		docker.ImageGroupByRepoTag: {"redis:latest", "redis:3.2", "redis", "redis:latest"},
+		docker.ImageGroupByDigest:  {"redis:latest", "redis:3.2", "redis@sha256:abc", "redis@sha256:abc"},
+	} {
+		mapper := render.MapContainerImage2NameBy(grouping)
+		for i, image := range images {
+			have := mapper(image, nil)
+			wantID := report.MakeContainerImageNodeID(want[i])
+			if _, ok := have[wantID]; !ok || len(have) != 1 {
+				t.Errorf("%s: expected %s to map to %s, got %v", grouping, image.ID, wantID, have)
+			}
+		}
+	}
+}
+
+func TestContainerRendererJoinsEndpointOnAnyNetwork(t *testing.T) {
+	var (
+		hostNodeID  = report.MakeHostNodeID("host1")
+		containerID = report.MakeContainerNodeID("multi")
+		local       = report.MakeEndpointNodeID("host1", "", "10.0.2.2", "43210")
+		remote      = report.MakeEndpointNodeID("host1", "", "8.8.8.8", "53")
+		rpt         = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNodeWith(local, map[string]string{
+		endpoint.Addr:        "10.0.2.2",
+		endpoint.Port:        "43210",
+		endpoint.Conntracked: "true",
+	}).WithAdjacent(remote).WithTopology(report.Endpoint))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(remote, map[string]string{
+		endpoint.Addr:        "8.8.8.8",
+		endpoint.Port:        "53",
+		endpoint.Conntracked: "true",
+	}).WithTopology(report.Endpoint))
+	rpt.Container.AddNode(report.MakeNodeWith(containerID, map[string]string{
+		docker.ContainerID: "multi",
+		report.HostNodeID:  hostNodeID,
+	}).WithSets(report.EmptySets.
+		Add(docker.ContainerNetworks, report.MakeStringSet("frontend", "backend")).
+		Add(docker.ContainerIPs, report.MakeStringSet("10.0.1.2", "10.0.2.2")).
+		Add(docker.ContainerIPsWithScopes, report.MakeStringSet(
+			report.MakeAddressNodeID("", "10.0.1.2"),
+			report.MakeAddressNodeID("", "10.0.2.2"),
+		)),
+	).WithTopology(report.Container))
+	rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, nil).
+		WithSets(report.EmptySets.Add(host.LocalNetworks, report.MakeStringSet("10.0.0.0/16"))).
+		WithTopology(report.Host))
+
+	have := utils.Prune(render.ContainerRenderer.Render(rpt, FilterNoop))
+	container, ok := have[containerID]
+	if !ok {
+		t.Fatalf("expected the container in %v", have)
+	}
+	if !container.Adjacency.Contains(render.OutgoingInternetID) {
+		t.Errorf("expected the backend network connection to be joined to the container, got %v", container.Adjacency)
+	}
+}
+
+func TestContainerLabelRenderer(t *testing.T) {
+	const composeService = "com.docker.compose.service"
+	input := fixture.Report.Copy()
+	input.Container.Nodes[fixture.ServerContainerNodeID] = input.Container.Nodes[fixture.ServerContainerNodeID].WithLatests(map[string]string{
+		docker.LabelPrefix + composeService: "web",
+	})
+
+	have := render.ContainerLabelRenderer(composeService).Render(input, FilterNoop)
+	unlabelledID := render.MakePseudoNodeID(render.UnlabelledID)
+	for id, wantContainer := range map[string]string{
+		"web":        fixture.ServerContainerNodeID,
+		unlabelledID: fixture.ClientContainerNodeID,
+	} {
+		node, ok := have[id]
+		if !ok {
+			t.Errorf("expected group %s in %v", id, have)
+			continue
+		}
+		var containers []string
+		node.Children.ForEach(func(child report.Node) {
+			if child.Topology == report.Container {
+				containers = append(containers, child.ID)
+			}
+		})
+		if len(containers) != 1 || containers[0] != wantContainer {
+			t.Errorf("%s: want container %s, have %v", id, wantContainer, containers)
+		}
+	}
+	if node := have["web"]; !node.Adjacency.Contains(unlabelledID) && !have[unlabelledID].Adjacency.Contains("web") {
+		t.Errorf("expected the client's connection to the server to join the groups, got %v", have)
+	}
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Delta is how a rendered topology changed from one render to the next.
+// Removed nodes are only listed by ID, sorted.
+type Delta struct {
+	Added   report.Nodes
+	Updated report.Nodes
+	Removed []string
+}
+
+// RenderDelta renders the report like r.Render, and also returns how the
+// result differs from previous, the result of an earlier render. A nil
+// previous counts as empty, so the first delta adds every node.
+func RenderDelta(r Renderer, rpt report.Report, dct Decorator, previous report.Nodes) (report.Nodes, Delta) {
+	current := r.Render(rpt, dct)
+	return current, Diff(previous, current)
+}
+
+// Diff gives the delta to get from the previous nodes to the current ones.
+func Diff(previous, current report.Nodes) Delta {
+	delta := Delta{
+		Added:   report.Nodes{},
+		Updated: report.Nodes{},
+		Removed: []string{},
+	}
+	for id, node := range current {
+		if old, ok := previous[id]; !ok {
+			delta.Added[id] = node
+		} else if !reflect.DeepEqual(old, node) {
+			delta.Updated[id] = node
+		}
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	sort.Strings(delta.Removed)
+	return delta
+}
@@ -0,0 +1,48 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestRenderDelta(t *testing.T) {
+	rpt := report.MakeReport()
+	first := render.ConstantRenderer(report.Nodes{
+		"a": report.MakeNode("a"),
+		"b": report.MakeNode("b"),
+		"c": report.MakeNode("c").WithAdjacent("a"),
+	})
+	second := render.ConstantRenderer(report.Nodes{
+		"a": report.MakeNode("a"),
+		"c": report.MakeNode("c").WithAdjacent("a").WithAdjacent("d"),
+		"d": report.MakeNode("d"),
+	})
+
+	// The first render adds everything.
+	previous, delta := render.RenderDelta(first, rpt, nil, nil)
+	if len(delta.Added) != 3 || len(delta.Updated) != 0 || len(delta.Removed) != 0 {
+		t.Errorf("expected every node to be added, got %v", delta)
+	}
+
+	current, delta := render.RenderDelta(second, rpt, nil, previous)
+	if want := second.Render(rpt, nil); !reflect.DeepEqual(want, current) {
+		t.Errorf("expected the nodes rendered as usual, got %v", current)
+	}
+	if _, ok := delta.Added["d"]; !ok || len(delta.Added) != 1 {
+		t.Errorf("expected d to be added, got %v", delta.Added)
+	}
+	if _, ok := delta.Updated["c"]; !ok || len(delta.Updated) != 1 {
+		t.Errorf("expected c to be updated, got %v", delta.Updated)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(want, delta.Removed) {
+		t.Errorf("expected %v to be removed, got %v", want, delta.Removed)
+	}
+
+	// Nothing changes between identical renders.
+	if _, delta := render.RenderDelta(second, rpt, nil, current); len(delta.Added)+len(delta.Updated)+len(delta.Removed) != 0 {
+		t.Errorf("expected an empty delta, got %v", delta)
+	}
+}
@@ -0,0 +1,88 @@
+package detailed
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// A LabelTemplate formats the labels of the nodes of a topology from their
+// latest values, in place of the usual labels, e.g. `{{.name}} ({{.pid}})`
+// for processes. Values with keys which aren't identifiers can be used with
+// index, e.g. `{{index . "io.kubernetes.pod.name"}}`, which gives an empty
+// string rather than failing if the node doesn't have the value.
+type LabelTemplate struct {
+	Topology string
+	Minor    bool // format LabelMinor rather than Label
+	template *template.Template
+}
+
+// ParseLabelTemplate parses a LabelTemplate for the given topology, e.g.
+// report.Process. Topologies no rendered nodes are in, such as
+// report.Endpoint or misspelt ones, are rejected, as the template would
+// never be used.
+func ParseLabelTemplate(topology string, minor bool, text string) (LabelTemplate, error) {
+	if _, ok := renderers[topology]; !ok && !strings.HasPrefix(topology, "group:") {
+		return LabelTemplate{}, fmt.Errorf("invalid label template: no nodes are rendered in topology %q", topology)
+	}
+	t, err := template.New(topology).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return LabelTemplate{}, fmt.Errorf("invalid label template for %s: %v", topology, err)
+	}
+	return LabelTemplate{Topology: topology, Minor: minor, template: t}, nil
+}
+
+type labelTemplateKey struct {
+	topology string
+	minor    bool
+}
+
+var labelTemplates = map[labelTemplateKey]LabelTemplate{}
+
+// SetLabelTemplates replaces the label templates used by MakeNodeSummary. A
+// later template for the same topology and label replaces an earlier one.
+// It isn't safe to call while summarizing nodes, so should be called at
+// start up.
+func SetLabelTemplates(templates ...LabelTemplate) {
+	labelTemplates = map[labelTemplateKey]LabelTemplate{}
+	for _, t := range templates {
+		labelTemplates[labelTemplateKey{t.Topology, t.Minor}] = t
+	}
+}
+
+// applyLabelTemplates formats the labels of a summary of n with the
+// templates for its topology. A label is left as it was if its template
+// uses a value n doesn't have.
+func applyLabelTemplates(summary NodeSummary, n report.Node) NodeSummary {
+	major, hasMajor := labelTemplates[labelTemplateKey{n.Topology, false}]
+	minor, hasMinor := labelTemplates[labelTemplateKey{n.Topology, true}]
+	if !hasMajor && !hasMinor {
+		return summary
+	}
+	values := map[string]string{}
+	n.Latest.ForEach(func(key string, _ time.Time, value string) {
+		values[key] = value
+	})
+	if label, ok := major.execute(values); hasMajor && ok {
+		summary.Label = label
+	}
+	if label, ok := minor.execute(values); hasMinor && ok {
+		summary.LabelMinor = label
+	}
+	return summary
+}
+
+func (t LabelTemplate) execute(values map[string]string) (string, bool) {
+	if t.template == nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, values); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
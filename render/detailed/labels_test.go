@@ -0,0 +1,74 @@
+package detailed_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestLabelTemplates(t *testing.T) {
+	var (
+		rpt    = report.MakeReport()
+		server = report.MakeNodeWith(report.MakeProcessNodeID("host1", "215"), map[string]string{
+			process.PID:  "215",
+			process.Name: "apache",
+		}).WithTopology(report.Process)
+		unnamed = report.MakeNodeWith(report.MakeProcessNodeID("host1", "216"), map[string]string{
+			process.PID:          "216",
+			docker.ContainerName: "web",
+		}).WithTopology(report.Process)
+	)
+	defaults := map[string]detailed.NodeSummary{}
+	for _, n := range []report.Node{server, unnamed} {
+		defaults[n.ID], _ = detailed.MakeNodeSummary(rpt, n)
+	}
+
+	major, err := detailed.ParseLabelTemplate(report.Process, false, "{{.name}} ({{.pid}})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	minor, err := detailed.ParseLabelTemplate(report.Process, true, "in {{.docker_container_name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detailed.SetLabelTemplates(major, minor)
+	defer detailed.SetLabelTemplates()
+
+	// Labels whose templates use values the node doesn't have are left as
+	// they were.
+	for _, c := range []struct {
+		node              report.Node
+		label, labelMinor string
+	}{
+		{server, "apache (215)", defaults[server.ID].LabelMinor},
+		{unnamed, defaults[unnamed.ID].Label, "in web"},
+	} {
+		have, ok := detailed.MakeNodeSummary(rpt, c.node)
+		if !ok {
+			t.Fatalf("%s: expected a summary", c.node.ID)
+		}
+		if have.Label != c.label || have.LabelMinor != c.labelMinor {
+			t.Errorf("%s: want labels %q, %q; have %q, %q", c.node.ID, c.label, c.labelMinor, have.Label, have.LabelMinor)
+		}
+	}
+}
+
+func TestParseLabelTemplateInvalid(t *testing.T) {
+	if _, err := detailed.ParseLabelTemplate(report.Process, false, "{{.name"); err == nil {
+		t.Error("expected an error for an unterminated action")
+	}
+}
+
+func TestParseLabelTemplateUnknownTopology(t *testing.T) {
+	for _, topology := range []string{report.Endpoint, "procesess", ""} {
+		if _, err := detailed.ParseLabelTemplate(topology, false, "{{.name}}"); err == nil {
+			t.Errorf("%q: expected an error for a topology without rendered nodes", topology)
+		}
+	}
+	if _, err := detailed.ParseLabelTemplate("group:process:name", false, "{{.name}}"); err != nil {
+		t.Errorf("expected group topologies to be allowed, got %v", err)
+	}
+}
@@ -2,8 +2,12 @@ package detailed
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/ugorji/go/codec"
+
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint"
@@ -45,19 +49,23 @@ type Column struct {
 
 // NodeSummary is summary information about a child for a Node.
 type NodeSummary struct {
-	ID         string               `json:"id"`
-	Label      string               `json:"label"`
-	LabelMinor string               `json:"labelMinor"`
-	Rank       string               `json:"rank"`
-	Shape      string               `json:"shape,omitempty"`
-	Stack      bool                 `json:"stack,omitempty"`
-	Linkable   bool                 `json:"linkable,omitempty"` // Whether this node can be linked-to
-	Pseudo     bool                 `json:"pseudo,omitempty"`
-	Metadata   []report.MetadataRow `json:"metadata,omitempty"`
-	Parents    []Parent             `json:"parents,omitempty"`
-	Metrics    []report.MetricRow   `json:"metrics,omitempty"`
-	Tables     []report.Table       `json:"tables,omitempty"`
-	Adjacency  report.IDList        `json:"adjacency,omitempty"`
+	ID          string               `json:"id"`
+	Label       string               `json:"label"`
+	LabelMinor  string               `json:"labelMinor"`
+	Rank        string               `json:"rank"`
+	Shape       string               `json:"shape,omitempty"`
+	Stack       bool                 `json:"stack,omitempty"`
+	Linkable    bool                 `json:"linkable,omitempty"` // Whether this node can be linked-to
+	Pseudo      bool                 `json:"pseudo,omitempty"`
+	Highlighted bool                 `json:"highlighted,omitempty"`
+	Structural  bool                 `json:"structural,omitempty"` // Whether this node's edges only show layout
+	RankValue   uint64               `json:"rankValue,omitempty"`  // How central the node is, if asked for with ?rank=
+	Component   string               `json:"component,omitempty"`
+	Metadata    []report.MetadataRow `json:"metadata,omitempty"`
+	Parents     []Parent             `json:"parents,omitempty"`
+	Metrics     []report.MetricRow   `json:"metrics,omitempty"`
+	Tables      []report.Table       `json:"tables,omitempty"`
+	Adjacency   report.IDList        `json:"adjacency,omitempty"`
 }
 
 var renderers = map[string]func(NodeSummary, report.Node) (NodeSummary, bool){
@@ -76,20 +84,31 @@ var renderers = map[string]func(NodeSummary, report.Node) (NodeSummary, bool){
 }
 
 var templates = map[string]struct{ Label, LabelMinor string }{
-	render.TheInternetID:      {render.InboundMajor, ""},
-	render.IncomingInternetID: {render.InboundMajor, render.InboundMinor},
-	render.OutgoingInternetID: {render.OutboundMajor, render.OutboundMinor},
+	render.TheInternetID:          {render.InboundMajor, ""},
+	render.IncomingInternetID:     {render.InboundMajor, render.InboundMinor},
+	render.OutgoingInternetID:     {render.OutboundMajor, render.OutboundMinor},
+	render.MulticastPseudoID:      {render.MulticastMajor, ""},
+	render.BroadcastPseudoID:      {render.BroadcastMajor, ""},
+	render.LinkLocalPseudoID:      {render.LinkLocalMajor, ""},
+	render.PrivateNetworkPseudoID: {render.PrivateNetworkMajor, ""},
 }
 
-// MakeNodeSummary summarizes a node, if possible.
+// MakeNodeSummary summarizes a node, if possible. Its labels are formatted
+// with the label templates for its topology, if there are any.
 func MakeNodeSummary(r report.Report, n report.Node) (NodeSummary, bool) {
-	if renderer, ok := renderers[n.Topology]; ok {
-		return renderer(baseNodeSummary(r, n), n)
+	var (
+		summary NodeSummary
+		ok      bool
+	)
+	if renderer, found := renderers[n.Topology]; found {
+		summary, ok = renderer(baseNodeSummary(r, n), n)
+	} else if strings.HasPrefix(n.Topology, "group:") {
+		summary, ok = groupNodeSummary(baseNodeSummary(r, n), r, n)
 	}
-	if strings.HasPrefix(n.Topology, "group:") {
-		return groupNodeSummary(baseNodeSummary(r, n), r, n)
+	if !ok {
+		return NodeSummary{}, false
 	}
-	return NodeSummary{}, false
+	return applyLabelTemplates(summary, n), true
 }
 
 // SummarizeMetrics returns a copy of the NodeSummary where the metrics are
@@ -105,15 +124,24 @@ func (n NodeSummary) SummarizeMetrics() NodeSummary {
 
 func baseNodeSummary(r report.Report, n report.Node) NodeSummary {
 	t, _ := r.Topology(n.Topology)
+	_, highlighted := n.Latest.Lookup(render.IsHighlighted)
+	_, structural := n.Latest.Lookup(render.IsStructural)
+	component, _ := n.Latest.Lookup(render.Component)
+	rank, _ := n.Latest.Lookup(render.Centrality)
+	rankValue, _ := strconv.ParseUint(rank, 10, 64)
 	return NodeSummary{
-		ID:        n.ID,
-		Shape:     t.GetShape(),
-		Linkable:  true,
-		Metadata:  NodeMetadata(r, n),
-		Metrics:   NodeMetrics(r, n),
-		Parents:   Parents(r, n),
-		Tables:    NodeTables(r, n),
-		Adjacency: n.Adjacency,
+		ID:          n.ID,
+		Shape:       t.GetShape(),
+		Linkable:    true,
+		Highlighted: highlighted,
+		Structural:  structural,
+		Component:   component,
+		RankValue:   rankValue,
+		Metadata:    NodeMetadata(r, n),
+		Metrics:     NodeMetrics(r, n),
+		Parents:     Parents(r, n),
+		Tables:      NodeTables(r, n),
+		Adjacency:   n.Adjacency,
 	}
 }
 
@@ -137,6 +165,16 @@ func pseudoNodeSummary(base NodeSummary, n report.Node) (NodeSummary, bool) {
 		return base, true
 	}
 
+	// try rendering it as collapsed pseudo nodes
+	if n.ID == render.OthersPseudoID {
+		count, _ := n.Counters.Lookup(render.CollapsedCount)
+		base.Label = render.OthersMajor
+		base.LabelMinor = fmt.Sprintf("%d nodes", count)
+		base.Shape = report.Cloud
+		base.Stack = true
+		return base, true
+	}
+
 	// try rendering it as an uncontained node
 	if strings.HasPrefix(n.ID, render.MakePseudoNodeID(render.UncontainedID)) {
 		base.Label = render.UncontainedMajor
@@ -146,6 +184,32 @@ func pseudoNodeSummary(base NodeSummary, n report.Node) (NodeSummary, bool) {
 		return base, true
 	}
 
+	// try rendering it as the containers without a label
+	if n.ID == render.MakePseudoNodeID(render.UnlabelledID) {
+		base.Label = render.UnlabelledMajor
+		base.LabelMinor = pluralize(n.Counters, report.Container, "container", "containers")
+		base.Shape = report.Square
+		base.Stack = true
+		return base, true
+	}
+
+	// try rendering it as the processes not in a systemd service
+	if n.ID == render.MakePseudoNodeID(render.NoSystemdUnitID) {
+		base.Label = render.NoSystemdUnitMajor
+		base.LabelMinor = pluralize(n.Counters, report.Process, "process", "processes")
+		base.Shape = report.Square
+		base.Stack = true
+		return base, true
+	}
+
+	// try rendering it as the host of containers whose host isn't known
+	if n.ID == render.MakePseudoNodeID(render.UnknownHostID) {
+		base.Label = render.UnknownHostMajor
+		base.LabelMinor = pluralize(n.Counters, report.Container, "container", "containers")
+		base.Shape = report.Circle
+		return base, true
+	}
+
 	// try rendering it as an unmanaged node
 	if strings.HasPrefix(n.ID, render.MakePseudoNodeID(render.UnmanagedID)) {
 		base.Label = render.UnmanagedMajor
@@ -155,7 +219,22 @@ func pseudoNodeSummary(base NodeSummary, n report.Node) (NodeSummary, bool) {
 		return base, true
 	}
 
-	// try rendering it as an endpoint
+	// try rendering it as a host, linked to its endpoints and processes
+	if strings.HasPrefix(n.ID, render.MakePseudoNodeID(render.HostLinkID)) {
+		base.Label = report.ExtractHostID(n)
+		base.LabelMinor = "host"
+		base.Shape = report.Circle
+		return base, true
+	}
+
+	// try rendering it as an endpoint, by the IPv4 address it stands for if
+	// reached through NAT64
+	if v4, ok := n.Latest.Lookup(endpoint.NAT64Addr); ok {
+		base.Label = v4
+		base.LabelMinor, _ = n.Latest.Lookup(endpoint.Addr)
+		base.Shape = report.Circle
+		return base, true
+	}
 	if addr, ok := n.Latest.Lookup(endpoint.Addr); ok {
 		base.Label = addr
 		base.Shape = report.Circle
@@ -284,7 +363,7 @@ func weaveNodeSummary(base NodeSummary, n report.Node) (NodeSummary, bool) {
 // groupNodeSummary renders the summary for a group node. n.Topology is
 // expected to be of the form: group:container:hostname
 func groupNodeSummary(base NodeSummary, r report.Report, n report.Node) (NodeSummary, bool) {
-	parts := strings.Split(n.Topology, ":")
+	parts := strings.SplitN(n.Topology, ":", 3)
 	if len(parts) != 3 {
 		return NodeSummary{}, false
 	}
@@ -324,6 +403,55 @@ func (s nodeSummariesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
 // NodeSummaries is a set of NodeSummaries indexed by ID.
 type NodeSummaries map[string]NodeSummary
 
+// Constants from https://github.com/ugorji/go/blob/master/codec/helper.go#L207
+const (
+	containerMapKey   = 2
+	containerMapValue = 3
+	containerMapEnd   = 4
+)
+
+// CodecEncodeSelf implements codec.Selfer. Summaries are written in ID
+// order, so the same topology always serializes to the same bytes.
+func (n NodeSummaries) CodecEncodeSelf(encoder *codec.Encoder) {
+	z, r := codec.GenHelperEncoder(encoder)
+	if n == nil {
+		r.EncodeNil()
+		return
+	}
+	ids := make([]string, 0, len(n))
+	for id := range n {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	r.EncodeMapStart(len(n))
+	for _, id := range ids {
+		z.EncSendContainerState(containerMapKey)
+		encoder.Encode(id)
+		z.EncSendContainerState(containerMapValue)
+		summary := n[id]
+		encoder.Encode(&summary)
+	}
+	z.EncSendContainerState(containerMapEnd)
+}
+
+// CodecDecodeSelf implements codec.Selfer.
+func (n *NodeSummaries) CodecDecodeSelf(decoder *codec.Decoder) {
+	var intermediate map[string]NodeSummary
+	decoder.Decode(&intermediate)
+	*n = intermediate
+}
+
+// MarshalJSON shouldn't be used, use CodecEncodeSelf instead
+func (NodeSummaries) MarshalJSON() ([]byte, error) {
+	panic("MarshalJSON shouldn't be used, use CodecEncodeSelf instead")
+}
+
+// UnmarshalJSON shouldn't be used, use CodecDecodeSelf instead
+func (*NodeSummaries) UnmarshalJSON(b []byte) error {
+	panic("UnmarshalJSON shouldn't be used, use CodecDecodeSelf instead")
+}
+
 // Summaries converts RenderableNodes into a set of NodeSummaries
 func Summaries(r report.Report, rns report.Nodes) NodeSummaries {
 
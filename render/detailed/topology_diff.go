@@ -2,6 +2,7 @@ package detailed
 
 import (
 	"reflect"
+	"sort"
 )
 
 // Diff is returned by TopoDiff. It represents the changes between two
@@ -12,7 +13,8 @@ type Diff struct {
 	Remove []string      `json:"remove"`
 }
 
-// TopoDiff gives you the diff to get from A to B.
+// TopoDiff gives you the diff to get from A to B. Each list is sorted by node
+// ID.
 func TopoDiff(a, b NodeSummaries) Diff {
 	diff := Diff{}
 
@@ -35,5 +37,9 @@ func TopoDiff(a, b NodeSummaries) Diff {
 		diff.Remove = append(diff.Remove, k)
 	}
 
+	sort.Sort(nodeSummariesByID(diff.Add))
+	sort.Sort(nodeSummariesByID(diff.Update))
+	sort.Strings(diff.Remove)
+
 	return diff
 }
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// MakeEdgeAgeDecorator makes a decorator which only keeps the edges first
+// seen within age of now (if newer is true), or longer ago than that (if
+// newer is false). Edges without a first seen time count as old. Nodes left
+// without any edges are removed.
+func MakeEdgeAgeDecorator(age time.Duration, newer bool) Decorator {
+	return func(r Renderer) Renderer {
+		return edgeAgeFilter{Renderer: r, age: age, newer: newer}
+	}
+}
+
+type edgeAgeFilter struct {
+	Renderer
+	age   time.Duration
+	newer bool
+}
+
+// Render implements Renderer. Edges are filtered in the report, before it is
+// rendered, as the rendered topologies don't carry edge metadata.
+func (f edgeAgeFilter) Render(rpt report.Report, dct Decorator) report.Nodes {
+	cutoff := mtime.Now().Add(-f.age)
+	pruned := rpt // shares nothing we modify, as each topology gets new Nodes
+	pruned.WalkTopologies(func(t *report.Topology) {
+		nodes := make(report.Nodes, len(t.Nodes))
+		for id, node := range t.Nodes {
+			adjacency := report.MakeIDList()
+			for _, dst := range node.Adjacency {
+				edge, _ := node.Edges.Lookup(dst)
+				isNew := !edge.FirstSeen.IsZero() && edge.FirstSeen.After(cutoff)
+				if isNew == f.newer {
+					adjacency = adjacency.Add(dst)
+				}
+			}
+			node.Adjacency = adjacency
+			nodes[id] = node
+		}
+		t.Nodes = nodes
+	})
+	// The pruned report must not be cached under the original report's ID,
+	// and is different on every call, so keep memoised renderers below us
+	// from caching it at all.
+	pruned.ID = rpt.ID + "-age"
+	if dct == nil {
+		dct = func(r Renderer) Renderer { return r }
+	}
+
+	var (
+		input     = f.Renderer.Render(pruned, dct)
+		output    = report.Nodes{}
+		connected = map[string]struct{}{}
+	)
+	for id, node := range input {
+		for _, dst := range node.Adjacency {
+			connected[id] = struct{}{}
+			connected[dst] = struct{}{}
+		}
+	}
+	for id, node := range input {
+		if _, ok := connected[id]; ok {
+			output[id] = node
+		}
+	}
+	return output
+}
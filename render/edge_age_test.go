@@ -0,0 +1,54 @@
+package render_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestEdgeAgeDecorator(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	edge := func(from, to string, age time.Duration) report.Node {
+		md := report.EdgeMetadata{}
+		if age >= 0 {
+			md.FirstSeen = now.Add(-age)
+		}
+		return report.MakeNode(from).WithAdjacent(to).WithEdge(to, md)
+	}
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(edge("new", "server", time.Minute))
+	rpt.Endpoint.AddNode(edge("old", "server", time.Hour))
+	rpt.Endpoint.AddNode(edge("unknown", "server", -1))
+	rpt.Endpoint.AddNode(report.MakeNode("server"))
+	rpt.Endpoint.AddNode(report.MakeNode("idle"))
+
+	for _, tc := range []struct {
+		newer bool
+		want  report.IDList
+	}{
+		{true, report.MakeIDList("new", "server")},
+		{false, report.MakeIDList("old", "unknown", "server")},
+	} {
+		decorator := render.MakeEdgeAgeDecorator(5*time.Minute, tc.newer)
+		have := report.MakeIDList()
+		for id := range render.ApplyDecorator(render.SelectEndpoint).Render(rpt, decorator) {
+			have = have.Add(id)
+		}
+		if !reflect.DeepEqual(tc.want, have) {
+			t.Errorf("newer=%v: %s", tc.newer, test.Diff(tc.want, have))
+		}
+	}
+
+	// The report itself must not be modified.
+	if !rpt.Endpoint.Nodes["old"].Adjacency.Contains("server") {
+		t.Error("filtering modified the report")
+	}
+}
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// WalkEndpointEdges calls f for each edge in the report's endpoint topology
+// with a rendered node holding either end of it. The rendered nodes don't
+// carry the endpoints' edges, so this is how traffic is totalled against
+// them. f is given the endpoints' IDs, the edge metadata, and the IDs of the
+// nodes holding each end, which can be empty.
+//
+// Nodes hold the endpoints they were rendered from, their endpoint children,
+// or themselves in the endpoint view. The returned map lists the nodes
+// holding each endpoint.
+func WalkEndpointEdges(rpt report.Report, nodes report.Nodes, f func(src, dst string, md report.EdgeMetadata, srcOwners, dstOwners []string)) map[string][]string {
+	owners := map[string][]string{}
+	for id, node := range nodes {
+		if node.Topology == report.Endpoint {
+			owners[id] = append(owners[id], id)
+		}
+		node.Children.ForEach(func(child report.Node) {
+			if child.Topology == report.Endpoint {
+				owners[child.ID] = append(owners[child.ID], id)
+			}
+		})
+	}
+	if len(owners) == 0 {
+		return owners
+	}
+	for src, node := range rpt.Endpoint.Nodes {
+		for _, dst := range node.Adjacency {
+			srcOwners, dstOwners := owners[src], owners[dst]
+			if len(srcOwners) == 0 && len(dstOwners) == 0 {
+				continue
+			}
+			md, _ := node.Edges.Lookup(dst)
+			f(src, dst, md, srcOwners, dstOwners)
+		}
+	}
+	return owners
+}
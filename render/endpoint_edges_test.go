@@ -0,0 +1,41 @@
+package render_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestWalkEndpointEdges(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNode("a1").
+		WithEdge("b1", report.EdgeMetadata{}).
+		WithEdge("x1", report.EdgeMetadata{})) // x1 isn't rendered
+	rpt.Endpoint.AddNode(report.MakeNode("x2").
+		WithEdge("x1", report.EdgeMetadata{})) // neither end is rendered
+
+	endpoint := func(id string) report.Node { return report.MakeNode(id).WithTopology(report.Endpoint) }
+	nodes := report.Nodes{
+		"a":  report.MakeNode("a").WithChildren(report.MakeNodeSet(endpoint("a1"))),
+		"b1": endpoint("b1"), // in the endpoint view, an endpoint holds itself
+	}
+
+	var walked []string
+	owners := render.WalkEndpointEdges(rpt, nodes, func(src, dst string, _ report.EdgeMetadata, srcOwners, dstOwners []string) {
+		walked = append(walked, src+">"+dst)
+		if want := []string{"a"}; !reflect.DeepEqual(want, srcOwners) {
+			t.Errorf("%s: %s", src, test.Diff(want, srcOwners))
+		}
+	})
+	sort.Strings(walked)
+	if want := []string{"a1>b1", "a1>x1"}; !reflect.DeepEqual(want, walked) {
+		t.Error(test.Diff(want, walked))
+	}
+	if want := map[string][]string{"a1": {"a"}, "b1": {"b1"}}; !reflect.DeepEqual(want, owners) {
+		t.Error(test.Diff(want, owners))
+	}
+}
@@ -0,0 +1,161 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	Low, High int
+}
+
+// DefaultEphemeralPorts is the range Linux picks the local ports of outbound
+// connections from, unless told otherwise by net.ipv4.ip_local_port_range.
+var DefaultEphemeralPorts = PortRange{32768, 60999}
+
+// Contains tells whether port is in the range.
+func (r PortRange) Contains(port int) bool {
+	return r.Low <= port && port <= r.High
+}
+
+// EphemeralPort is the port of the endpoints which the ephemeral ports of a
+// process are collapsed into. The process' PID follows it.
+const EphemeralPort = "ephemeral-"
+
+// CollapseEphemeralPorts renders endpoints with the given renderer, and
+// merges the endpoints of each process whose ports are within ports into a
+// single endpoint per process and address, as a client gets a new ephemeral
+// port for every connection it makes. Edges to and from the collapsed
+// endpoints are summed, and CollapsedCount records how many endpoints went
+// into each. Endpoints on other ports, such as those of servers, and
+// endpoints without a process are left as they are.
+func CollapseEphemeralPorts(ports PortRange, r Renderer) Renderer {
+	return CustomRenderer{
+		Renderer: r,
+		RenderFunc: func(input report.Nodes) report.Nodes {
+			return collapseEphemeralPorts(ports, input)
+		},
+	}
+}
+
+// MakeEphemeralPortsDecorator makes a decorator which renders from the
+// report with its endpoints' ephemeral ports collapsed, as by
+// CollapseEphemeralPorts, so the views built from endpoints, and the
+// decorators wrapped by this one, see a single client endpoint per process
+// and address.
+func MakeEphemeralPortsDecorator(ports PortRange) Decorator {
+	return func(r Renderer) Renderer {
+		return ephemeralPortsRenderer{Renderer: r, ports: ports}
+	}
+}
+
+type ephemeralPortsRenderer struct {
+	Renderer
+	ports PortRange
+}
+
+// Render implements Renderer.
+func (e ephemeralPortsRenderer) Render(rpt report.Report, dct Decorator) report.Nodes {
+	return e.Renderer.Render(e.collapse(rpt), dct)
+}
+
+// Stats implements Renderer.
+func (e ephemeralPortsRenderer) Stats(rpt report.Report, dct Decorator) Stats {
+	return e.Renderer.Stats(e.collapse(rpt), dct)
+}
+
+// collapse returns a copy of rpt with its endpoints collapsed. The copy gets
+// its own ID, so what is rendered from it isn't mistaken for what is
+// rendered from rpt by Memoise.
+func (e ephemeralPortsRenderer) collapse(rpt report.Report) report.Report {
+	rpt.ID = fmt.Sprintf("%s-ephemeral-%d-%d", rpt.ID, e.ports.Low, e.ports.High)
+	rpt.Endpoint.Nodes = collapseEphemeralPorts(e.ports, rpt.Endpoint.Nodes)
+	return rpt
+}
+
+func collapseEphemeralPorts(ports PortRange, input report.Nodes) report.Nodes {
+	renamed := map[string]string{}
+	for id, node := range input {
+		if newID, ok := ephemeralEndpointID(ports, id, node); ok {
+			renamed[id] = newID
+		}
+	}
+	if len(renamed) == 0 {
+		return input
+	}
+	rename := func(id string) string {
+		if newID, ok := renamed[id]; ok {
+			return newID
+		}
+		return id
+	}
+
+	var (
+		output = report.Nodes{}
+		counts = map[string]int{}
+		// Edges from collapsed endpoints are distinct connections,
+		// so they're flattened rather than merged.
+		edges = map[string]map[string]report.EdgeMetadata{}
+	)
+	for id, node := range input {
+		newID := rename(id)
+		nodeEdges, ok := edges[newID]
+		if !ok {
+			nodeEdges = map[string]report.EdgeMetadata{}
+			edges[newID] = nodeEdges
+		}
+		node.Edges.ForEach(func(dst string, md report.EdgeMetadata) {
+			dst = rename(dst)
+			if existing, ok := nodeEdges[dst]; ok {
+				md = existing.Flatten(md)
+			}
+			nodeEdges[dst] = md
+		})
+		adjacency := report.MakeIDList()
+		for _, adj := range node.Adjacency {
+			adjacency = adjacency.Add(rename(adj))
+		}
+		node.Adjacency, node.Edges = adjacency, report.EmptyEdgeMetadatas
+
+		if newID != id {
+			node.ID = newID
+			counts[newID]++
+		}
+		if existing, ok := output[newID]; ok {
+			node = existing.Merge(node)
+		}
+		output[newID] = node
+	}
+	for id, nodeEdges := range edges {
+		node := output[id]
+		for dst, md := range nodeEdges {
+			node.Edges = node.Edges.Add(dst, md)
+		}
+		if count, ok := counts[id]; ok {
+			node.Counters = node.Counters.Add(CollapsedCount, count)
+		}
+		output[id] = node
+	}
+	return output
+}
+
+// ephemeralEndpointID returns the ID of the endpoint the given one is
+// collapsed into, if it belongs to a process and its port is in ports.
+func ephemeralEndpointID(ports PortRange, id string, node report.Node) (string, bool) {
+	pid, ok := node.Latest.Lookup(process.PID)
+	if !ok {
+		return "", false
+	}
+	scope, addr, port, ok := report.ParseEndpointNodeID(id)
+	if !ok {
+		return "", false
+	}
+	if p, err := strconv.Atoi(port); err != nil || !ports.Contains(p) {
+		return "", false
+	}
+	return scope + report.ScopeDelim + addr + report.ScopeDelim + EphemeralPort + pid, true
+}
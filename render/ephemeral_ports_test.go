@@ -0,0 +1,76 @@
+package render_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCollapseEphemeralPorts(t *testing.T) {
+	var (
+		server    = report.MakeEndpointNodeID("", "", "10.0.0.2", "80")
+		collapsed = report.MakeEndpointNodeID("", "", "10.0.0.1", render.EphemeralPort+"10")
+		noProcess = report.MakeEndpointNodeID("", "", "10.0.0.1", "40100")
+		lowPort   = report.MakeEndpointNodeID("", "", "10.0.0.1", "1024")
+		bytes     = uint64(100)
+		conns     = uint64(1)
+		nodes     = report.Nodes{
+			server:    report.MakeNodeWith(server, map[string]string{process.PID: "20"}),
+			noProcess: report.MakeNode(noProcess).WithEdge(server, report.EdgeMetadata{}),
+			lowPort: report.MakeNodeWith(lowPort, map[string]string{process.PID: "10"}).
+				WithEdge(server, report.EdgeMetadata{}),
+		}
+	)
+	const clients = 50
+	for port := 40000; port < 40000+clients; port++ {
+		id := report.MakeEndpointNodeID("", "", "10.0.0.1", strconv.Itoa(port))
+		nodes[id] = report.MakeNodeWith(id, map[string]string{process.PID: "10"}).
+			WithEdge(server, report.EdgeMetadata{EgressByteCount: &bytes, TCPConnections: &conns})
+		// The server side knows of the connection too.
+		nodes[server] = nodes[server].WithEdge(id, report.EdgeMetadata{IngressByteCount: &bytes, TCPConnections: &conns})
+	}
+
+	have := render.CollapseEphemeralPorts(render.DefaultEphemeralPorts, render.ConstantRenderer(nodes)).
+		Render(report.MakeReport(), nil)
+
+	if want := 4; len(have) != want {
+		t.Fatalf("want %d nodes, have %d: %v", want, len(have), have)
+	}
+	for _, id := range []string{server, noProcess, lowPort} {
+		if _, ok := have[id]; !ok {
+			t.Errorf("expected %s to be kept", id)
+		}
+	}
+
+	client, ok := have[collapsed]
+	if !ok {
+		t.Fatalf("expected the client ports to be collapsed into %s, have %v", collapsed, have)
+	}
+	if count, _ := client.Counters.Lookup(render.CollapsedCount); count != clients {
+		t.Errorf("want %d endpoints collapsed, have %d", clients, count)
+	}
+	if pid, _ := client.Latest.Lookup(process.PID); pid != "10" {
+		t.Errorf("want the collapsed endpoint to keep its process, have %q", pid)
+	}
+	md, ok := client.Edges.Lookup(server)
+	if !ok || !client.Adjacency.Contains(server) {
+		t.Fatalf("expected an edge to the server, have %v", client)
+	}
+	if md.EgressByteCount == nil || *md.EgressByteCount != clients*bytes {
+		t.Errorf("want %d bytes to the server, have %v", clients*bytes, md.EgressByteCount)
+	}
+	if md.TCPConnections == nil || *md.TCPConnections != clients {
+		t.Errorf("want %d connections to the server, have %v", clients, md.TCPConnections)
+	}
+
+	back, ok := have[server].Edges.Lookup(collapsed)
+	if !ok || len(have[server].Adjacency) != 1 {
+		t.Fatalf("expected the server's edges to point at the collapsed endpoint, have %v", have[server])
+	}
+	if back.TCPConnections == nil || *back.TCPConnections != clients {
+		t.Errorf("want %d connections from the server, have %v", clients, back.TCPConnections)
+	}
+}
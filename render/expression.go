@@ -0,0 +1,281 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// ExpressionError is returned for an invalid filter expression. Pos is the
+// byte offset into the expression where the problem was found.
+type ExpressionError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("invalid expression at position %d: %s", e.Pos, e.Msg)
+}
+
+// Longest first, so that ">=" isn't read as ">".
+var comparisonOperators = []string{">=", "<=", "!=", "=", ">", "<", "~"}
+
+// ParseFilterExpression parses an expression like
+// `docker_image_name~redis AND (memory>1000000 OR pid=1)` into a FilterFunc.
+//
+// A comparison is a key, an operator and a value. Keys are looked up with
+// NodeValue. "=" and "!=" compare numerically if both sides are numbers, and
+// as strings otherwise; "~" matches values containing the given string; the
+// ordering operators (<, <=, >, >=) compare numerically, and never match
+// values which aren't numbers. Values containing spaces, parentheses or
+// operators can be double-quoted.
+//
+// Comparisons can be combined with AND and OR (in any case), AND binding
+// more tightly, and grouped with parentheses.
+func ParseFilterExpression(expr string) (FilterFunc, error) {
+	p := &expressionParser{expr: expr}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &ExpressionError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type expressionParser struct {
+	expr   string
+	tokens []token
+}
+
+// lex splits the expression into tokens. A quoted string is a single word.
+func (p *expressionParser) lex() error {
+	for i := 0; i < len(p.expr); {
+		switch c := p.expr[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{tokenClose, ")", i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(p.expr[i+1:], '"')
+			if end < 0 {
+				return &ExpressionError{Pos: i, Msg: "unterminated string"}
+			}
+			p.tokens = append(p.tokens, token{tokenWord, p.expr[i+1 : i+1+end], i})
+			i += end + 2
+		case isOperatorByte(c):
+			op := operatorAt(p.expr[i:])
+			if op == "" {
+				return &ExpressionError{Pos: i, Msg: fmt.Sprintf("unknown operator %q", c)}
+			}
+			p.tokens = append(p.tokens, token{tokenOperator, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(p.expr) && !isDelimiter(p.expr[i]) {
+				i++
+			}
+			p.tokens = append(p.tokens, token{tokenWord, p.expr[start:i], start})
+		}
+	}
+	return nil
+}
+
+func isOperatorByte(c byte) bool {
+	return strings.IndexByte("=!<>~", c) >= 0
+}
+
+func isDelimiter(c byte) bool {
+	return c == ' ' || c == '\t' || c == '(' || c == ')' || c == '"' || isOperatorByte(c)
+}
+
+func operatorAt(s string) string {
+	for _, op := range comparisonOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *expressionParser) peek() token {
+	if len(p.tokens) == 0 {
+		return token{kind: tokenEOF, pos: len(p.expr)}
+	}
+	return p.tokens[0]
+}
+
+func (p *expressionParser) next() token {
+	tok := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *expressionParser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseOr() (FilterFunc, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	fs := []FilterFunc{f}
+	for p.keyword("or") {
+		if f, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return AnyFilterFunc(fs...), nil
+}
+
+func (p *expressionParser) parseAnd() (FilterFunc, error) {
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	fs := []FilterFunc{f}
+	for p.keyword("and") {
+		if f, err = p.parsePrimary(); err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return ComposeFilterFuncs(fs...), nil
+}
+
+func (p *expressionParser) parsePrimary() (FilterFunc, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenOpen:
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, &ExpressionError{Pos: closing.pos, Msg: "expected )"}
+		}
+		return f, nil
+	case tokenWord:
+		if strings.EqualFold(tok.text, "and") || strings.EqualFold(tok.text, "or") {
+			return nil, &ExpressionError{Pos: tok.pos, Msg: "expected a comparison, got " + tok.text}
+		}
+		return p.parseComparison(tok.text)
+	case tokenEOF:
+		return nil, &ExpressionError{Pos: tok.pos, Msg: "expected a comparison"}
+	}
+	return nil, &ExpressionError{Pos: tok.pos, Msg: fmt.Sprintf("expected a key, got %q", tok.text)}
+}
+
+func (p *expressionParser) parseComparison(key string) (FilterFunc, error) {
+	op := p.next()
+	if op.kind != tokenOperator {
+		return nil, &ExpressionError{Pos: op.pos, Msg: "expected a comparison after " + key}
+	}
+	value := p.next()
+	if value.kind != tokenWord {
+		return nil, &ExpressionError{Pos: value.pos, Msg: "expected a value after " + op.text}
+	}
+	return func(n report.Node) bool {
+		have, ok := NodeValue(n, key)
+		return ok && compare(op.text, have, value.text)
+	}, nil
+}
+
+// NodeValue looks key up in the latest values, counters and metrics (the
+// most recent sample) of a node, in that order.
+func NodeValue(n report.Node, key string) (string, bool) {
+	if value, ok := n.Latest.Lookup(key); ok {
+		return value, true
+	}
+	if value, ok := n.Counters.Lookup(key); ok {
+		return strconv.Itoa(value), true
+	}
+	if metric, ok := n.Metrics[key]; ok {
+		if sample, ok := metric.LastSample(); ok {
+			return strconv.FormatFloat(sample.Value, 'f', -1, 64), true
+		}
+	}
+	return "", false
+}
+
+func compare(op, have, want string) bool {
+	switch op {
+	case "~":
+		return strings.Contains(have, want)
+	case "=":
+		return equal(have, want)
+	case "!=":
+		return !equal(have, want)
+	}
+	h, err := strconv.ParseFloat(have, 64)
+	if err != nil {
+		return false
+	}
+	w, err := strconv.ParseFloat(want, 64)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case ">":
+		return h > w
+	case ">=":
+		return h >= w
+	case "<":
+		return h < w
+	case "<=":
+		return h <= w
+	}
+	return false
+}
+
+// equal compares numbers numerically, so that "1.0" equals "1", and
+// anything else as strings.
+func equal(have, want string) bool {
+	h, err1 := strconv.ParseFloat(have, 64)
+	w, err2 := strconv.ParseFloat(want, 64)
+	if err1 == nil && err2 == nil {
+		return h == w
+	}
+	return have == want
+}
@@ -0,0 +1,74 @@
+package render_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	node := report.MakeNodeWith("a", map[string]string{
+		"image": "redis:3.2",
+		"pid":   "10",
+		"name":  "my app",
+	}).WithCounters(map[string]int{"processes": 3}).
+		WithMetrics(report.Metrics{"memory": report.MakeSingletonMetric(time.Now(), 2048)})
+
+	for expr, want := range map[string]bool{
+		// String and numeric fields
+		"image=redis:3.2": true,
+		"image=redis":     false,
+		"image~redis":     true,
+		"image!=redis":    true,
+		"pid=10.0":        true,
+		"pid>9":           true,
+		"pid<9":           false,
+		"image>1":         false,
+		`name="my app"`:   true,
+		"processes>=3":    true,
+		"memory>1000":     true,
+		"missing=1":       false,
+		"missing!=1":      false,
+		// AND binds more tightly than OR
+		"pid=1 OR pid=10 AND image~nginx":             false,
+		"pid=10 OR pid=1 AND image~nginx":             true,
+		"(pid=10 OR pid=1) AND image~nginx":           false,
+		"pid=1 or (pid=10 and image~redis)":           true,
+		"image~redis AND memory>1000 AND processes<5": true,
+		"((image~redis))":                             true,
+	} {
+		f, err := render.ParseFilterExpression(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if have := f(node); have != want {
+			t.Errorf("%s: want %v, have %v", expr, want, have)
+		}
+	}
+}
+
+func TestParseFilterExpressionErrors(t *testing.T) {
+	for expr, pos := range map[string]int{
+		"":                        0,
+		"memory":                  6,
+		">1000":                   0,
+		"memory>=":                8,
+		"memory>1000 AND":         15,
+		"(memory>1000":            12,
+		"memory>1000)":            11,
+		"memory>1000 pid=1":       12,
+		`name="unterminated`:      5,
+		"memory>1000 OR OR pid=1": 15,
+	} {
+		_, err := render.ParseFilterExpression(expr)
+		exprErr, ok := err.(*render.ExpressionError)
+		if !ok {
+			t.Errorf("%q: expected an ExpressionError, got %v", expr, err)
+		} else if exprErr.Pos != pos {
+			t.Errorf("%q: expected error at %d, got %v", expr, pos, exprErr)
+		}
+	}
+}
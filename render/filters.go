@@ -38,6 +38,28 @@ func (p PreciousNodeRenderer) Stats(rpt report.Report, dct Decorator) Stats {
 	return p.Renderer.Stats(rpt, dct)
 }
 
+// PreciousNodesRenderer ensures none of a set of nodes is filtered out by
+// decorators, like PreciousNodeRenderer, rendering once for all of them.
+type PreciousNodesRenderer struct {
+	PreciousNodeIDs []string
+	Renderer
+}
+
+// Render implements Renderer
+func (p PreciousNodesRenderer) Render(rpt report.Report, dct Decorator) report.Nodes {
+	undecoratedNodes := p.Renderer.Render(rpt, nil)
+	finalNodes := applyDecorator{ConstantRenderer(undecoratedNodes)}.Render(rpt, dct)
+	for _, id := range p.PreciousNodeIDs {
+		if _, ok := finalNodes[id]; ok {
+			continue
+		}
+		if preciousNode, ok := undecoratedNodes[id]; ok {
+			finalNodes[id] = preciousNode
+		}
+	}
+	return finalNodes
+}
+
 // CustomRenderer allow for mapping functions that received the entire topology
 // in one call - useful for functions that need to consider the entire graph.
 // We should minimise the use of this renderer type, as it is very inflexible.
@@ -0,0 +1,29 @@
+package render
+
+import (
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// IsHighlighted is the key added to Node.Latest by MakeHighlightDecorator.
+const IsHighlighted = "is_highlighted"
+
+// MakeHighlightDecorator makes a decorator which marks the nodes matching f
+// with the IsHighlighted key. Unlike a filter, it keeps the nodes which don't
+// match, unmarked.
+func MakeHighlightDecorator(f FilterFunc) Decorator {
+	return func(r Renderer) Renderer {
+		return CustomRenderer{
+			Renderer: r,
+			RenderFunc: func(input report.Nodes) report.Nodes {
+				output := input.Copy()
+				for id, node := range input {
+					if f(node) {
+						output[id] = node.WithLatest(IsHighlighted, mtime.Now(), "true")
+					}
+				}
+				return output
+			},
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package render_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func highlightInput() render.Renderer {
+	now := time.Now()
+	return render.ConstantRenderer(report.Nodes{
+		"redis": report.MakeNodeWith("redis", map[string]string{docker.ImageName: "redis:3.2"}).
+			WithMetrics(report.Metrics{docker.MemoryUsage: report.MakeSingletonMetric(now, 2000)}),
+		"nginx": report.MakeNodeWith("nginx", map[string]string{docker.ImageName: "nginx"}).
+			WithMetrics(report.Metrics{docker.MemoryUsage: report.MakeSingletonMetric(now, 500)}),
+		"unknown": report.MakeNode("unknown"),
+	})
+}
+
+func TestHighlightDecorator(t *testing.T) {
+	for expr, want := range map[string][]string{
+		docker.ImageName + "~redis":   {"redis"},
+		docker.MemoryUsage + ">1000":  {"redis"},
+		docker.MemoryUsage + "<=2000": {"redis", "nginx"},
+		docker.ImageName + "=nginx":   {"nginx"},
+		docker.ImageName + "!=nginx":  {"redis"},
+	} {
+		f, err := render.ParseFilterExpression(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		have := render.ApplyDecorator(highlightInput()).Render(report.MakeReport(), render.MakeHighlightDecorator(f))
+		if len(have) != 3 {
+			t.Errorf("%s: expected all nodes to be kept, got %v", expr, have)
+		}
+		wanted := map[string]bool{}
+		for _, id := range want {
+			wanted[id] = true
+		}
+		for id, node := range have {
+			if _, highlighted := node.Latest.Lookup(render.IsHighlighted); highlighted != wanted[id] {
+				t.Errorf("%s: expected %s highlighted=%v", expr, id, wanted[id])
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// HostLinkID is the first part of the IDs of the pseudo nodes added by
+// LinkHosts, the second being the host ID.
+const HostLinkID = "hostlink"
+
+// IsStructural is the key added to Node.Latest of the nodes whose edges show
+// how the graph is laid out, rather than any traffic, so the UI can style
+// them differently.
+const IsStructural = "is_structural"
+
+// LinkHosts is a Decorator which adds a pseudo node for each host, with an
+// edge to each of the host's endpoint and process nodes, to show which host
+// they are on. The host is taken from the scope of the node ID, or, for
+// endpoints on addresses which aren't scoped, from the host node ID the probe
+// tagged them with. The added edges have no metadata.
+func LinkHosts(r Renderer) Renderer {
+	return CustomRenderer{
+		Renderer:   r,
+		RenderFunc: linkHosts,
+	}
+}
+
+func linkHosts(input report.Nodes) report.Nodes {
+	output := report.Nodes{}
+	for id, node := range input {
+		output[id] = node
+		if node.Topology != report.Endpoint && node.Topology != report.Process {
+			continue
+		}
+		hostID, _, _ := report.ParseNodeID(id)
+		if hostID == "" {
+			hostID = report.ExtractHostID(node)
+		}
+		if hostID == "" {
+			continue
+		}
+		linkID := MakePseudoNodeID(HostLinkID, hostID)
+		link, ok := output[linkID]
+		if !ok {
+			link = report.MakeNode(linkID).WithTopology(Pseudo).WithLatests(map[string]string{
+				report.HostNodeID: report.MakeHostNodeID(hostID),
+				IsStructural:      "true",
+			})
+		}
+		output[linkID] = link.WithAdjacent(id)
+	}
+	return output
+}
@@ -0,0 +1,65 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestLinkHosts(t *testing.T) {
+	var (
+		endpoint = func(hostID, address, port string) report.Node {
+			return report.MakeNode(report.MakeEndpointNodeID(hostID, "", address, port)).
+				WithTopology(report.Endpoint).
+				WithLatests(map[string]string{report.HostNodeID: report.MakeHostNodeID(hostID)})
+		}
+		a1        = endpoint("hostA", "127.0.0.1", "80")
+		a2        = endpoint("hostA", "10.0.0.1", "8080").WithEdge(a1.ID, report.EdgeMetadata{})
+		b1        = endpoint("hostB", "127.0.0.1", "80")
+		container = report.MakeNode("container").WithTopology(report.Container)
+		input     = render.ConstantRenderer(report.Nodes{
+			a1.ID:        a1,
+			a2.ID:        a2,
+			b1.ID:        b1,
+			container.ID: container,
+		})
+	)
+
+	have := render.ApplyDecorator(input).Render(report.MakeReport(), render.LinkHosts)
+	if want := 6; len(have) != want {
+		t.Fatalf("want %d nodes, have %d: %v", want, len(have), have)
+	}
+	for hostID, endpoints := range map[string]report.IDList{
+		"hostA": report.MakeIDList(a1.ID, a2.ID),
+		"hostB": report.MakeIDList(b1.ID),
+	} {
+		link, ok := have[render.MakePseudoNodeID(render.HostLinkID, hostID)]
+		if !ok {
+			t.Errorf("missing a node for %s", hostID)
+			continue
+		}
+		if link.Topology != render.Pseudo {
+			t.Errorf("%s: expected a pseudo node, got %q", hostID, link.Topology)
+		}
+		if len(link.Adjacency) != len(endpoints) {
+			t.Errorf("%s: want edges to %v, have %v", hostID, endpoints, link.Adjacency)
+		}
+		for _, id := range endpoints {
+			if !link.Adjacency.Contains(id) {
+				t.Errorf("%s: missing an edge to %s", hostID, id)
+			}
+		}
+		if link.Edges.Size() != 0 {
+			t.Errorf("%s: expected edges without metadata, got %v", hostID, link.Edges)
+		}
+		if _, ok := link.Latest.Lookup(render.IsStructural); !ok {
+			t.Errorf("%s: expected the node to be marked structural", hostID)
+		}
+	}
+
+	// The nodes being linked are left as they were.
+	if !have[a2.ID].Adjacency.Contains(a1.ID) || len(have[a1.ID].Adjacency) != 0 {
+		t.Errorf("expected the endpoints' own edges to be unchanged, got %v", have)
+	}
+}
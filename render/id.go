@@ -48,9 +48,21 @@ func NewDerivedExternalNode(n report.Node, addr string, local report.Networks) (
 		}
 	}
 
-	// If the dstNodeAddr is not in a network local to this report, we emit an
-	// internet pseudoNode
-	if ip := net.ParseIP(addr); ip != nil && !local.Contains(ip) {
+	ip := net.ParseIP(addr)
+	// NAT64 addresses are classified as the IPv4 addresses they stand for.
+	if v4, ok := report.NAT64Embedded(ip); ok {
+		ip = v4
+	}
+
+	// Broadcast, multicast and link-local addresses are grouped by class,
+	// rather than getting a node each or joining the internet.
+	if id, ok := addressClassPseudoID(ip, local); ok {
+		return NewDerivedPseudoNode(id, n), true
+	}
+
+	// If the dstNodeAddr is not in a network local to this report, nor a
+	// private one, we emit an internet pseudoNode
+	if isInternetAddress(ip, local) {
 		// emit one internet node for incoming, one for outgoing
 		if len(n.Adjacency) > 0 {
 			return NewDerivedPseudoNode(IncomingInternetID, n), true
@@ -58,6 +70,12 @@ func NewDerivedExternalNode(n report.Node, addr string, local report.Networks) (
 		return NewDerivedPseudoNode(OutgoingInternetID, n), true
 	}
 
+	// Private addresses outside the local networks aren't on the internet,
+	// but no probe reports them either.
+	if isUnreportedPrivateAddress(ip, local) {
+		return NewDerivedPseudoNode(PrivateNetworkPseudoID, n), true
+	}
+
 	// The node is not external
 	return report.Node{}, false
 }
@@ -0,0 +1,101 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// MakeMinBytesDecorator makes a decorator which drops the edges between
+// rendered nodes over which fewer than minBytes went, either way, totalled
+// over the endpoint connections they were rendered from. Edges without byte
+// counts are kept, whatever the threshold. Nodes left without any edges are
+// removed; those which had none to start with are kept.
+func MakeMinBytesDecorator(minBytes uint64) Decorator {
+	return func(r Renderer) Renderer {
+		return minBytesFilter{Renderer: r, minBytes: minBytes}
+	}
+}
+
+type minBytesFilter struct {
+	Renderer
+	minBytes uint64
+}
+
+// pairTraffic is the bytes between a pair of rendered nodes. known is false
+// if none of the connections between them had byte counts.
+type pairTraffic struct {
+	bytes uint64
+	known bool
+}
+
+// Render implements Renderer. The rendered topologies don't carry edge
+// metadata, so the bytes are totalled from the report's endpoint edges (see
+// WalkEndpointEdges).
+func (f minBytesFilter) Render(rpt report.Report, dct Decorator) report.Nodes {
+	var (
+		input  = f.Renderer.Render(rpt, dct)
+		totals = map[[2]string]pairTraffic{}
+	)
+	WalkEndpointEdges(rpt, input, func(_, _ string, md report.EdgeMetadata, srcOwners, dstOwners []string) {
+		bytes, known := edgeByteCount(md)
+		for _, a := range srcOwners {
+			for _, b := range dstOwners {
+				key := nodePair(a, b)
+				t := totals[key]
+				t.bytes += bytes
+				t.known = t.known || known
+				totals[key] = t
+			}
+		}
+	})
+
+	var (
+		output    = make(report.Nodes, len(input))
+		hadEdges  = map[string]struct{}{}
+		connected = map[string]struct{}{}
+	)
+	for id, node := range input {
+		adjacency := report.MakeIDList()
+		for _, dst := range node.Adjacency {
+			hadEdges[id], hadEdges[dst] = struct{}{}, struct{}{}
+			if t, ok := totals[nodePair(id, dst)]; ok && t.known && t.bytes < f.minBytes {
+				node.Edges = removeEdge(node.Edges, dst)
+				continue
+			}
+			adjacency = adjacency.Add(dst)
+			connected[id], connected[dst] = struct{}{}, struct{}{}
+		}
+		node.Adjacency = adjacency
+		output[id] = node
+	}
+	for id := range output {
+		_, had := hadEdges[id]
+		if _, ok := connected[id]; had && !ok {
+			delete(output, id)
+		}
+	}
+	return output
+}
+
+// nodePair is the key for the edges between two nodes, either way round.
+func nodePair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// edgeByteCount returns the bytes sent both ways over an edge, and whether
+// it had any byte counts.
+func edgeByteCount(md report.EdgeMetadata) (uint64, bool) {
+	if md.EgressByteCount == nil && md.IngressByteCount == nil {
+		return 0, false
+	}
+	var total uint64
+	if md.EgressByteCount != nil {
+		total += *md.EgressByteCount
+	}
+	if md.IngressByteCount != nil {
+		total += *md.IngressByteCount
+	}
+	return total, true
+}
@@ -0,0 +1,65 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestMinBytesDecorator(t *testing.T) {
+	bytes := func(n uint64) report.EdgeMetadata { return report.EdgeMetadata{EgressByteCount: &n} }
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNode("a1").
+		WithEdge("b1", bytes(10)))
+	rpt.Endpoint.AddNode(report.MakeNode("a2").
+		WithEdge("b1", bytes(5)).              // a -> b totals 15 bytes
+		WithEdge("c1", bytes(1000)).           // a -> c is busy
+		WithEdge("d1", report.EdgeMetadata{})) // a -> d has no byte counts
+	for _, id := range []string{"b1", "c1", "d1"} {
+		rpt.Endpoint.AddNode(report.MakeNode(id))
+	}
+
+	endpoints := func(ids ...string) report.NodeSet {
+		var nodes []report.Node
+		for _, id := range ids {
+			nodes = append(nodes, report.MakeNode(id).WithTopology(report.Endpoint))
+		}
+		return report.MakeNodeSet(nodes...)
+	}
+	input := render.ConstantRenderer(report.Nodes{
+		"a": report.MakeNode("a").WithChildren(endpoints("a1", "a2")).
+			WithAdjacent("b").WithAdjacent("c").WithAdjacent("d"),
+		"b": report.MakeNode("b").WithChildren(endpoints("b1")),
+		"c": report.MakeNode("c").WithChildren(endpoints("c1")),
+		"d": report.MakeNode("d").WithChildren(endpoints("d1")),
+		"e": report.MakeNode("e"), // never had any edges
+	})
+
+	have := render.ApplyDecorator(input).Render(rpt, render.MakeMinBytesDecorator(100))
+	a, ok := have["a"]
+	if !ok {
+		t.Fatalf("expected a to be kept, got %v", have)
+	}
+	if a.Adjacency.Contains("b") {
+		t.Errorf("expected the quiet edge to b to be dropped, got %v", a.Adjacency)
+	}
+	for _, id := range []string{"c", "d"} {
+		if !a.Adjacency.Contains(id) {
+			t.Errorf("expected the edge to %s to be kept, got %v", id, a.Adjacency)
+		}
+	}
+	if _, ok := have["b"]; ok {
+		t.Errorf("expected b, left without edges, to be dropped")
+	}
+	for _, id := range []string{"c", "d", "e"} {
+		if _, ok := have[id]; !ok {
+			t.Errorf("expected %s to be kept", id)
+		}
+	}
+
+	// With a low enough threshold, everything stays.
+	if have := render.ApplyDecorator(input).Render(rpt, render.MakeMinBytesDecorator(15)); len(have) != 5 || !have["a"].Adjacency.Contains("b") {
+		t.Errorf("expected nothing to be dropped, got %v", have)
+	}
+}
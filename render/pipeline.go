@@ -0,0 +1,52 @@
+package render
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// A PipelineStage makes a Decorator from the value of a request parameter.
+// Make may return a nil Decorator, for values which don't ask for anything.
+type PipelineStage struct {
+	Param string
+	Make  func(value string) (Decorator, error)
+}
+
+// A Pipeline is the ordered list of stages a request's parameters are
+// turned into Decorators by. However the parameters are ordered in the
+// request, the Decorators are composed in the order of the Pipeline, each
+// decorating the renderer wrapped by those before it.
+type Pipeline []PipelineStage
+
+// Decorator composes the Decorators asked for by values, in order. It
+// returns nil if none are.
+func (p Pipeline) Decorator(values url.Values) (Decorator, error) {
+	var decorators []Decorator
+	for _, stage := range p {
+		value, ok := values[stage.Param]
+		if !ok {
+			continue
+		}
+		dct, err := stage.Make(value[0])
+		if err != nil {
+			return nil, err
+		}
+		if dct != nil {
+			decorators = append(decorators, dct)
+		}
+	}
+	if len(decorators) == 0 {
+		return nil, nil
+	}
+	return ComposeDecorators(decorators...), nil
+}
+
+// ParamError is returned by a PipelineStage for a value it doesn't
+// understand.
+type ParamError struct {
+	Param, Value string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid value %q for %s", e.Value, e.Param)
+}
@@ -0,0 +1,104 @@
+package render_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func expressionStage(param string, makeDecorator func(render.FilterFunc) render.Decorator) render.PipelineStage {
+	return render.PipelineStage{
+		Param: param,
+		Make: func(value string) (render.Decorator, error) {
+			f, err := render.ParseFilterExpression(value)
+			if err != nil {
+				return nil, err
+			}
+			return makeDecorator(f), nil
+		},
+	}
+}
+
+var testPipeline = render.Pipeline{
+	expressionStage("filter", render.MakeFilterDecorator),
+	expressionStage("highlight", render.MakeHighlightDecorator),
+	{
+		Param: "collapse",
+		Make: func(value string) (render.Decorator, error) {
+			if value != "pseudo" {
+				return nil, &render.ParamError{Param: "collapse", Value: value}
+			}
+			return func(r render.Renderer) render.Renderer { return render.CollapsePseudo(0, r) }, nil
+		},
+	},
+}
+
+func TestPipeline(t *testing.T) {
+	now := time.Now()
+	memory := func(bytes float64) report.Metrics {
+		return report.Metrics{docker.MemoryUsage: report.MakeSingletonMetric(now, bytes)}
+	}
+	var (
+		pseudoA = render.MakePseudoNodeID("a")
+		pseudoB = render.MakePseudoNodeID("b")
+		input   = render.ConstantRenderer(report.Nodes{
+			"redis": report.MakeNodeWith("redis", map[string]string{docker.ImageName: "redis"}).
+				WithMetrics(memory(2000)).WithAdjacent(pseudoA),
+			"nginx": report.MakeNodeWith("nginx", map[string]string{docker.ImageName: "nginx"}).
+				WithMetrics(memory(500)).WithAdjacent(pseudoB),
+			"idle":  report.MakeNode("idle").WithMetrics(memory(0)),
+			pseudoA: report.MakeNode(pseudoA).WithTopology(render.Pseudo),
+			pseudoB: report.MakeNode(pseudoB).WithTopology(render.Pseudo),
+		})
+	)
+
+	dct, err := testPipeline.Decorator(url.Values{
+		"collapse":  {"pseudo"},
+		"highlight": {docker.ImageName + "~redis"},
+		"filter":    {docker.MemoryUsage + ">0"},
+		"other":     {"ignored"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := render.ApplyDecorator(input).Render(report.MakeReport(), dct)
+
+	// The filter drops idle, the highlight marks redis, and the pseudo
+	// nodes both ends are connected to are collapsed into one.
+	if len(have) != 3 {
+		t.Fatalf("want redis, nginx and %s, have %v", render.OthersPseudoID, have)
+	}
+	for id, highlighted := range map[string]bool{"redis": true, "nginx": false} {
+		node, ok := have[id]
+		if !ok {
+			t.Fatalf("missing %s: %v", id, have)
+		}
+		if _, ok := node.Latest.Lookup(render.IsHighlighted); ok != highlighted {
+			t.Errorf("%s: want highlighted %v, have %v", id, highlighted, ok)
+		}
+		if !node.Adjacency.Contains(render.OthersPseudoID) || len(node.Adjacency) != 1 {
+			t.Errorf("%s: want an edge to %s only, have %v", id, render.OthersPseudoID, node.Adjacency)
+		}
+	}
+	if others := have[render.OthersPseudoID]; others.Topology != render.Pseudo {
+		t.Errorf("expected the others node, have %v", have)
+	}
+}
+
+func TestPipelineNothingAskedFor(t *testing.T) {
+	dct, err := testPipeline.Decorator(url.Values{"other": {"ignored"}})
+	if err != nil || dct != nil {
+		t.Errorf("want no decorator, have %v, %v", dct, err)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	_, err := testPipeline.Decorator(url.Values{"collapse": {"everything"}})
+	if _, ok := err.(*render.ParamError); !ok {
+		t.Errorf("want a ParamError, have %v", err)
+	}
+}
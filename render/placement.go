@@ -0,0 +1,53 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// Constants are used in the tests.
+const (
+	UnknownHostID    = "unknownhost"
+	UnknownHostMajor = "Unknown host"
+)
+
+// ContainerPlacementRenderer is a Renderer which produces a graph of hosts
+// and the containers running on them, with an edge from each host to each
+// of its containers and no others. Containers whose host isn't in the report
+// are attached to a single "unknown host" pseudo node.
+var ContainerPlacementRenderer = containerPlacementRenderer{ContainerWithImageNameRenderer}
+
+type containerPlacementRenderer struct {
+	Renderer
+}
+
+func (r containerPlacementRenderer) Render(rpt report.Report, dct Decorator) report.Nodes {
+	containers := r.Renderer.Render(rpt, dct)
+	hosts := SelectHost.Render(rpt, dct)
+
+	outputs := report.Nodes{}
+	for id, c := range containers {
+		if c.Topology != report.Container {
+			continue
+		}
+		c.Adjacency = report.EmptyIDList
+		c.Edges = report.EmptyEdgeMetadatas
+		outputs[id] = c
+
+		hostID := report.MakeHostNodeID(report.ExtractHostID(c))
+		host, ok := outputs[hostID]
+		if !ok {
+			if host, ok = hosts[hostID]; ok {
+				host.Adjacency = report.EmptyIDList
+				host.Edges = report.EmptyEdgeMetadatas
+			} else {
+				hostID = MakePseudoNodeID(UnknownHostID)
+				if host, ok = outputs[hostID]; !ok {
+					host = report.MakeNode(hostID).WithTopology(Pseudo)
+				}
+			}
+		}
+		host.Counters = host.Counters.Add(report.Container, 1)
+		outputs[hostID] = host.WithAdjacent(id)
+	}
+	return outputs
+}
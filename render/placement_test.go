@@ -0,0 +1,54 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestContainerPlacementRenderer(t *testing.T) {
+	var (
+		host1  = report.MakeHostNodeID("host1")
+		host2  = report.MakeHostNodeID("host2")
+		a      = report.MakeContainerNodeID("a")
+		b      = report.MakeContainerNodeID("b")
+		c      = report.MakeContainerNodeID("c")
+		orphan = report.MakeContainerNodeID("orphan")
+		rpt    = report.MakeReport()
+	)
+	for _, host := range []string{host1, host2} {
+		rpt.Host.AddNode(report.MakeNodeWith(host, map[string]string{report.HostNodeID: host}).WithTopology(report.Host))
+	}
+	for id, host := range map[string]string{a: host1, b: host1, c: host2, orphan: report.MakeHostNodeID("gone")} {
+		containerID, _ := report.ParseContainerNodeID(id)
+		rpt.Container.AddNode(report.MakeNodeWith(id, map[string]string{
+			docker.ContainerID: containerID,
+			report.HostNodeID:  host,
+		}).WithTopology(report.Container))
+	}
+	// Traffic between containers isn't shown.
+	rpt.Container.Nodes[a] = rpt.Container.Nodes[a].WithAdjacent(c)
+
+	have := render.ContainerPlacementRenderer.Render(rpt, FilterNoop)
+	unknownHost := render.MakePseudoNodeID(render.UnknownHostID)
+	want := map[string]report.IDList{
+		host1:       report.MakeIDList(a, b),
+		host2:       report.MakeIDList(c),
+		unknownHost: report.MakeIDList(orphan),
+		a:           report.EmptyIDList,
+		b:           report.EmptyIDList,
+		c:           report.EmptyIDList,
+		orphan:      report.EmptyIDList,
+	}
+	adjacencies := map[string]report.IDList{}
+	for id, node := range have {
+		adjacencies[id] = node.Adjacency
+	}
+	if !reflect.DeepEqual(want, adjacencies) {
+		t.Error(test.Diff(want, adjacencies))
+	}
+}
@@ -0,0 +1,151 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// PortRanges is a set of port ranges, such as "5432,6379,8000-9000".
+type PortRanges []PortRange
+
+// PortRangeError is returned for an invalid list of port ranges.
+type PortRangeError struct {
+	Value string
+	Msg   string
+}
+
+func (e *PortRangeError) Error() string {
+	return fmt.Sprintf("invalid port range %q: %s", e.Value, e.Msg)
+}
+
+// ParsePortRanges parses a comma-separated list of ports and inclusive
+// ranges of ports, e.g. "5432,6379,8000-9000".
+func ParsePortRanges(s string) (PortRanges, error) {
+	var ranges PortRanges
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+		low, high := value, value
+		if i := strings.Index(value, "-"); i != -1 {
+			low, high = value[:i], value[i+1:]
+		}
+		r := PortRange{}
+		var err error
+		if r.Low, err = parsePort(low); err != nil {
+			return nil, &PortRangeError{Value: value, Msg: err.Error()}
+		}
+		if r.High, err = parsePort(high); err != nil {
+			return nil, &PortRangeError{Value: value, Msg: err.Error()}
+		}
+		if r.Low > r.High {
+			return nil, &PortRangeError{Value: value, Msg: "range is backwards"}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("expected a port, got %q", s)
+	}
+	return int(port), nil
+}
+
+// Contains tells whether port is in any of the ranges.
+func (rs PortRanges) Contains(port int) bool {
+	for _, r := range rs {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// MakePortsDecorator makes a decorator which keeps only the rendered nodes
+// holding an endpoint on the given ports, or connected to one, and the edges
+// rendered from connections to or from those ports. Views which aren't
+// rendered from endpoints are left alone, and so are pseudo nodes, such as
+// the internet, which are kept whatever their ports; only their edges are
+// filtered.
+func MakePortsDecorator(ranges PortRanges) Decorator {
+	return func(r Renderer) Renderer {
+		return portsFilter{Renderer: r, ranges: ranges}
+	}
+}
+
+type portsFilter struct {
+	Renderer
+	ranges PortRanges
+}
+
+// Render implements Renderer. The rendered nodes don't carry the endpoints'
+// adjacencies, so the connections are looked up in the report's endpoint
+// topology (see WalkEndpointEdges).
+func (f portsFilter) Render(rpt report.Report, dct Decorator) report.Nodes {
+	var (
+		input = f.Renderer.Render(rpt, dct)
+		keep  = map[string]struct{}{}
+		edges = map[[2]string]struct{}{}
+	)
+	onPort := func(endpointID string) bool {
+		_, _, port, ok := report.ParseEndpointNodeID(endpointID)
+		if !ok {
+			return false
+		}
+		p, err := strconv.Atoi(port)
+		return err == nil && f.ranges.Contains(p)
+	}
+
+	// An edge is kept if any of the connections it was rendered from has an
+	// end on one of the ports, and a node if it is on one of the ports or
+	// still has an edge.
+	owners := WalkEndpointEdges(rpt, input, func(src, dst string, _ report.EdgeMetadata, srcOwners, dstOwners []string) {
+		if !onPort(src) && !onPort(dst) {
+			return
+		}
+		for _, a := range srcOwners {
+			for _, b := range dstOwners {
+				edges[[2]string{a, b}] = struct{}{}
+				keep[a], keep[b] = struct{}{}, struct{}{}
+			}
+		}
+	})
+	if len(owners) == 0 {
+		return input
+	}
+	for endpointID, ids := range owners {
+		if onPort(endpointID) {
+			for _, id := range ids {
+				keep[id] = struct{}{}
+			}
+		}
+	}
+	for id, node := range input {
+		if node.Topology == Pseudo {
+			keep[id] = struct{}{}
+		}
+	}
+
+	output := report.Nodes{}
+	for id := range keep {
+		node, ok := input[id]
+		if !ok {
+			continue
+		}
+		adjacency := report.MakeIDList()
+		for _, dst := range node.Adjacency {
+			if _, ok := edges[[2]string{id, dst}]; ok {
+				adjacency = adjacency.Add(dst)
+			} else {
+				node.Edges = removeEdge(node.Edges, dst)
+			}
+		}
+		node.Adjacency = adjacency
+		output[id] = node
+	}
+	return output
+}
@@ -0,0 +1,126 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	for input, want := range map[string]render.PortRanges{
+		"5432":                {{5432, 5432}},
+		"5432,6379,8000-9000": {{5432, 5432}, {6379, 6379}, {8000, 9000}},
+		" 80 , 443 ":          {{80, 80}, {443, 443}},
+	} {
+		have, err := render.ParsePortRanges(input)
+		if err != nil {
+			t.Errorf("%q: %v", input, err)
+		} else if !reflect.DeepEqual(want, have) {
+			t.Errorf("%q: want %v, have %v", input, want, have)
+		}
+	}
+
+	for _, input := range []string{"", "http", "80,", "8000-", "-80", "9000-8000", "65536", "1-2-3"} {
+		if _, err := render.ParsePortRanges(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		} else if _, ok := err.(*render.PortRangeError); !ok {
+			t.Errorf("%q: expected a PortRangeError, got %v", input, err)
+		}
+	}
+}
+
+func TestPortsDecorator(t *testing.T) {
+	var (
+		client   = report.MakeEndpointNodeID("host", "", "10.0.0.1", "54001")
+		postgres = report.MakeEndpointNodeID("host", "", "10.0.0.2", "5432")
+		web      = report.MakeEndpointNodeID("host", "", "10.0.0.2", "8080")
+		ssh      = report.MakeEndpointNodeID("host", "", "10.0.0.2", "22")
+		endpoint = func(id string) report.Node { return report.MakeNode(id).WithTopology(report.Endpoint) }
+		process  = func(id string, endpoints ...string) report.Node {
+			children := report.MakeNodeSet()
+			for _, e := range endpoints {
+				children = children.Add(endpoint(e))
+			}
+			return report.MakeNode(id).WithTopology(report.Process).WithChildren(children)
+		}
+	)
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(endpoint(client).WithAdjacent(postgres).WithAdjacent(web).WithAdjacent(ssh))
+	for _, id := range []string{postgres, web, ssh} {
+		rpt.Endpoint.AddNode(endpoint(id))
+	}
+	// The processes don't carry the endpoints' adjacencies, only their own.
+	input := render.ConstantRenderer(report.Nodes{
+		"psql":     process("psql", client).WithAdjacent("postgres").WithAdjacent("web").WithAdjacent("sshd"),
+		"postgres": process("postgres", postgres),
+		"web":      process("web", web),
+		"sshd":     process("sshd", ssh),
+		"idle":     process("idle"),
+	})
+
+	for ports, want := range map[string][]string{
+		"5432":           {"postgres"},
+		"8000-9000":      {"web"},
+		"5432,8000-9000": {"postgres", "web"},
+		"1000-2000":      {},
+	} {
+		ranges, err := render.ParsePortRanges(ports)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := render.ApplyDecorator(input).Render(rpt, render.MakePortsDecorator(ranges))
+		for _, id := range []string{"sshd", "idle"} {
+			if _, ok := have[id]; ok {
+				t.Errorf("%s: expected %s, not on the ports, to be dropped", ports, id)
+			}
+		}
+		if _, ok := have["psql"]; ok != (len(want) > 0) {
+			t.Errorf("%s: expected the client kept only if connected to a matching port, got %v", ports, have)
+		}
+		if adjacency := have["psql"].Adjacency; len(adjacency) != len(want) {
+			t.Errorf("%s: want edges to %v, have %v", ports, want, adjacency)
+		}
+		for _, id := range want {
+			if _, ok := have[id]; !ok {
+				t.Errorf("%s: expected %s to be kept", ports, id)
+			}
+			if !have["psql"].Adjacency.Contains(id) {
+				t.Errorf("%s: expected the edge to %s to be kept", ports, id)
+			}
+		}
+	}
+
+	// Pseudo nodes are kept whatever their ports, but only their edges over
+	// connections on the ports.
+	internet := report.MakeEndpointNodeID("host", "", "1.2.3.4", "443")
+	rpt.Endpoint.AddNode(endpoint(internet).WithAdjacent(postgres).WithAdjacent(web))
+	withInternet := render.ConstantRenderer(report.Nodes{
+		"postgres": process("postgres", postgres),
+		"web":      process("web", web),
+		render.TheInternetID: report.MakeNode(render.TheInternetID).WithTopology(render.Pseudo).
+			WithChildren(report.MakeNodeSet(endpoint(internet))).
+			WithAdjacent("postgres").WithAdjacent("web"),
+	})
+	for ports, want := range map[string]report.IDList{
+		"5432": report.MakeIDList("postgres"),
+		"22":   report.MakeIDList(),
+	} {
+		ranges, _ := render.ParsePortRanges(ports)
+		have := render.ApplyDecorator(withInternet).Render(rpt, render.MakePortsDecorator(ranges))
+		node, ok := have[render.TheInternetID]
+		if !ok {
+			t.Errorf("%s: expected the internet to be kept, got %v", ports, have)
+		} else if !reflect.DeepEqual(want, node.Adjacency) {
+			t.Errorf("%s: want the internet's edges to %v, have %v", ports, want, node.Adjacency)
+		}
+	}
+
+	// Views not rendered from endpoints are left alone.
+	hosts := render.ConstantRenderer(report.Nodes{"host": report.MakeNode("host").WithTopology(report.Host)})
+	ranges, _ := render.ParsePortRanges("5432")
+	if have := render.ApplyDecorator(hosts).Render(rpt, render.MakePortsDecorator(ranges)); len(have) != 1 {
+		t.Errorf("expected the hosts to be left alone, got %v", have)
+	}
+}
@@ -14,6 +14,9 @@ const (
 	InboundMinor  = "Inbound connections"
 	OutboundMinor = "Outbound connections"
 
+	NoSystemdUnitID    = "nosystemdunit"
+	NoSystemdUnitMajor = "Not in a systemd service"
+
 	// Topology for pseudo-nodes and IPs so we can differentiate them at the end
 	Pseudo = "pseudo"
 )
@@ -25,17 +28,55 @@ func renderProcesses(rpt report.Report) bool {
 // EndpointRenderer is a Renderer which produces a renderable endpoint graph.
 var EndpointRenderer = FilterNonProcspiedNorEBPF(SelectEndpoint)
 
+// PseudoStrategy says how endpoints which can't be attributed to a process
+// are represented in the process views.
+type PseudoStrategy string
+
+// The available PseudoStrategies
+const (
+	// PseudoInternetOnly only keeps endpoints outside the local networks, as
+	// internet and known service nodes. This is the default.
+	PseudoInternetOnly PseudoStrategy = "internet-only"
+	// PseudoGeneric additionally gives every other unattributed address its
+	// own pseudo node.
+	PseudoGeneric PseudoStrategy = "generic"
+	// PseudoNone drops all unattributed endpoints.
+	PseudoNone PseudoStrategy = "none"
+)
+
+// PseudoStrategies lists all the PseudoStrategies
+var PseudoStrategies = []PseudoStrategy{PseudoInternetOnly, PseudoGeneric, PseudoNone}
+
+var processRenderers = func() map[PseudoStrategy]Renderer {
+	pseudoMappers := map[PseudoStrategy]MapFunc{
+		PseudoInternetOnly: MapEndpoint2Pseudo,
+		PseudoGeneric:      MapEndpoint2GenericPseudo,
+		PseudoNone:         MapToEmpty,
+	}
+	result := map[PseudoStrategy]Renderer{}
+	for strategy, pseudoMapper := range pseudoMappers {
+		result[strategy] = ConditionalRenderer(renderProcesses,
+			ColorConnected(MakeReduce(
+				MakeMap(
+					mapEndpoint2Process(pseudoMapper),
+					EndpointRenderer,
+				),
+				SelectProcess,
+			)),
+		)
+	}
+	return result
+}()
+
 // ProcessRenderer is a Renderer which produces a renderable process
 // graph by merging the endpoint graph and the process topology.
-var ProcessRenderer = ConditionalRenderer(renderProcesses,
-	ColorConnected(MakeReduce(
-		MakeMap(
-			MapEndpoint2Process,
-			EndpointRenderer,
-		),
-		SelectProcess,
-	)),
-)
+var ProcessRenderer = ProcessRendererWith(PseudoInternetOnly)
+
+// ProcessRendererWith is like ProcessRenderer, but represents unattributed
+// endpoints according to the given strategy.
+func ProcessRendererWith(strategy PseudoStrategy) Renderer {
+	return processRenderers[strategy]
+}
 
 // processWithContainerNameRenderer is a Renderer which produces a process
 // graph enriched with container names where appropriate
@@ -69,16 +110,43 @@ func (r processWithContainerNameRenderer) Render(rpt report.Report, dct Decorato
 
 // ProcessWithContainerNameRenderer is a Renderer which produces a process
 // graph enriched with container names where appropriate
-var ProcessWithContainerNameRenderer = processWithContainerNameRenderer{ProcessRenderer}
+var ProcessWithContainerNameRenderer = ProcessWithContainerNameRendererWith(PseudoInternetOnly)
+
+// ProcessWithContainerNameRendererWith is like
+// ProcessWithContainerNameRenderer, with the given PseudoStrategy.
+func ProcessWithContainerNameRendererWith(strategy PseudoStrategy) Renderer {
+	return processWithContainerNameRenderer{ProcessRendererWith(strategy)}
+}
 
 // ProcessNameRenderer is a Renderer which produces a renderable process
 // name graph by munging the progess graph.
-var ProcessNameRenderer = ConditionalRenderer(renderProcesses,
-	MakeMap(
-		MapProcess2Name,
-		ProcessRenderer,
-	),
-)
+var ProcessNameRenderer = ProcessNameRendererWith(PseudoInternetOnly)
+
+// ProcessNameRendererWith is like ProcessNameRenderer, with the given
+// PseudoStrategy.
+func ProcessNameRendererWith(strategy PseudoStrategy) Renderer {
+	return ConditionalRenderer(renderProcesses,
+		MakeMap(
+			MapProcess2Name,
+			ProcessRendererWith(strategy),
+		),
+	)
+}
+
+// ProcessSystemdUnitRenderer is a Renderer which produces a renderable
+// graph of the systemd services processes run in.
+var ProcessSystemdUnitRenderer = ProcessSystemdUnitRendererWith(PseudoInternetOnly)
+
+// ProcessSystemdUnitRendererWith is like ProcessSystemdUnitRenderer, with
+// the given PseudoStrategy.
+func ProcessSystemdUnitRendererWith(strategy PseudoStrategy) Renderer {
+	return ConditionalRenderer(renderProcesses,
+		MakeMap(
+			MapProcessBySystemdUnit,
+			ProcessRendererWith(strategy),
+		),
+	)
+}
 
 // MapEndpoint2Pseudo makes internet of host pesudo nodes from a endpoint node.
 func MapEndpoint2Pseudo(n report.Node, local report.Networks) report.Nodes {
@@ -96,6 +164,29 @@ func MapEndpoint2Pseudo(n report.Node, local report.Networks) report.Nodes {
 	return report.Nodes{}
 }
 
+// MapEndpoint2GenericPseudo is like MapEndpoint2Pseudo, but makes a pseudo
+// node for every address, not just the external ones.
+func MapEndpoint2GenericPseudo(n report.Node, local report.Networks) report.Nodes {
+	addr, ok := n.Latest.Lookup(endpoint.Addr)
+	if !ok {
+		return report.Nodes{}
+	}
+
+	if externalNode, ok := NewDerivedExternalNode(n, addr, local); ok {
+		return report.Nodes{externalNode.ID: externalNode}
+	}
+
+	// Connections to an IPv4 address through NAT64 join those made to it
+	// directly.
+	if v4, ok := n.Latest.Lookup(endpoint.NAT64Addr); ok {
+		addr = v4
+	}
+	node := NewDerivedPseudoNode(MakePseudoNodeID(addr), n)
+	node = propagateLatest(endpoint.Addr, n, node)
+	node = propagateLatest(endpoint.NAT64Addr, n, node)
+	return report.Nodes{node.ID: node}
+}
+
 // MapEndpoint2Process maps endpoint Nodes to process
 // Nodes.
 //
@@ -108,21 +199,31 @@ func MapEndpoint2Pseudo(n report.Node, local report.Networks) report.Nodes {
 // It does not have enough info to do that, and the resulting graph
 // must be merged with a process graph to get that info.
 func MapEndpoint2Process(n report.Node, local report.Networks) report.Nodes {
-	// Nodes without a hostid are treated as pseudo nodes
-	if _, ok := n.Latest.Lookup(report.HostNodeID); !ok {
-		return MapEndpoint2Pseudo(n, local)
-	}
+	return mapEndpoint2Process(MapEndpoint2Pseudo)(n, local)
+}
 
-	pid, timestamp, ok := n.Latest.LookupEntry(process.PID)
-	if !ok {
-		return report.Nodes{}
-	}
+func mapEndpoint2Process(pseudoMapper MapFunc) MapFunc {
+	return func(n report.Node, local report.Networks) report.Nodes {
+		// Nodes without a hostid are treated as pseudo nodes
+		if _, ok := n.Latest.Lookup(report.HostNodeID); !ok {
+			return pseudoMapper(n, local)
+		}
 
-	id := report.MakeProcessNodeID(report.ExtractHostID(n), pid)
-	node := NewDerivedNode(id, n).WithTopology(report.Process)
-	node.Latest = node.Latest.Set(process.PID, timestamp, pid)
-	node.Counters = node.Counters.Add(n.Topology, 1)
-	return report.Nodes{id: node}
+		pid, timestamp, ok := n.Latest.LookupEntry(process.PID)
+		if !ok {
+			return report.Nodes{}
+		}
+
+		id := report.MakeProcessNodeID(report.ExtractHostID(n), pid)
+		node := NewDerivedNode(id, n).WithTopology(report.Process)
+		node.Latest = node.Latest.Set(process.PID, timestamp, pid)
+		// The probe may know the process' container from its cgroup, even
+		// without access to the Docker API.
+		node = propagateLatest(docker.ContainerID, n, node)
+		node = propagateLatest(endpoint.SystemdUnit, n, node)
+		node.Counters = node.Counters.Add(n.Topology, 1)
+		return report.Nodes{id: node}
+	}
 }
 
 // MapProcess2Name maps process Nodes to Nodes
@@ -145,3 +246,26 @@ func MapProcess2Name(n report.Node, _ report.Networks) report.Nodes {
 	node.Counters = node.Counters.Add(n.Topology, 1)
 	return report.Nodes{name: node}
 }
+
+// MapProcessBySystemdUnit maps process Nodes to Nodes for each systemd
+// service. The probe only knows the service of the processes with
+// connections, from their endpoints; the others are grouped into a single
+// pseudo node.
+func MapProcessBySystemdUnit(n report.Node, _ report.Networks) report.Nodes {
+	if n.Topology == Pseudo {
+		return report.Nodes{n.ID: n}
+	}
+
+	unit, timestamp, ok := n.Latest.LookupEntry(endpoint.SystemdUnit)
+	if !ok || unit == "" {
+		id := MakePseudoNodeID(NoSystemdUnitID)
+		node := NewDerivedPseudoNode(id, n)
+		node.Counters = node.Counters.Add(n.Topology, 1)
+		return report.Nodes{id: node}
+	}
+
+	node := NewDerivedNode(unit, n).WithTopology(MakeGroupNodeTopology(n.Topology, endpoint.SystemdUnit))
+	node.Latest = node.Latest.Set(endpoint.SystemdUnit, timestamp, unit)
+	node.Counters = node.Counters.Add(n.Topology, 1)
+	return report.Nodes{unit: node}
+}
@@ -4,8 +4,11 @@ import (
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 	"github.com/weaveworks/scope/test/reflect"
 	"github.com/weaveworks/scope/test/utils"
@@ -34,3 +37,59 @@ func TestProcessNameRenderer(t *testing.T) {
 		t.Error(test.Diff(want, have))
 	}
 }
+
+func TestProcessNameRendererKeepsOrigins(t *testing.T) {
+	// Both client processes are grouped by name; the group keeps each of
+	// them, with their metadata, as children for the details panel.
+	node, ok := render.ProcessNameRenderer.Render(fixture.Report, FilterNoop)[fixture.Client1Name]
+	if !ok {
+		t.Fatalf("expected a %s node", fixture.Client1Name)
+	}
+	have := map[string]string{}
+	node.Children.ForEach(func(child report.Node) {
+		if child.Topology == report.Process {
+			have[child.ID], _ = child.Latest.Lookup(process.PID)
+		}
+	})
+	want := map[string]string{
+		fixture.ClientProcess1NodeID: fixture.Client1PID,
+		fixture.ClientProcess2NodeID: fixture.Client2PID,
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestProcessSystemdUnitRenderer(t *testing.T) {
+	input := fixture.Report.Copy()
+	input.Endpoint.Nodes[fixture.Server80NodeID] = input.Endpoint.Nodes[fixture.Server80NodeID].WithLatests(map[string]string{
+		endpoint.SystemdUnit: "apache2.service",
+	})
+
+	have := render.ProcessSystemdUnitRenderer.Render(input, FilterNoop)
+	noUnitID := render.MakePseudoNodeID(render.NoSystemdUnitID)
+	for id, wantProcesses := range map[string][]string{
+		"apache2.service": {fixture.ServerProcessNodeID},
+		noUnitID:          {fixture.ClientProcess1NodeID, fixture.ClientProcess2NodeID},
+	} {
+		node, ok := have[id]
+		if !ok {
+			t.Errorf("expected group %s in %v", id, have)
+			continue
+		}
+		processes := map[string]bool{}
+		node.Children.ForEach(func(child report.Node) {
+			if child.Topology == report.Process {
+				processes[child.ID] = true
+			}
+		})
+		for _, want := range wantProcesses {
+			if !processes[want] {
+				t.Errorf("%s: expected process %s, have %v", id, want, processes)
+			}
+		}
+	}
+	if !have[noUnitID].Adjacency.Contains("apache2.service") {
+		t.Errorf("expected the clients' connections to the server to join the groups, got %v", have[noUnitID].Adjacency)
+	}
+}
@@ -0,0 +1,139 @@
+package render
+
+import (
+	"net"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Labels and counters for the collapsed pseudo node, and labels for the
+// address class and private network pseudo nodes.
+const (
+	OthersMajor = "Others"
+
+	MulticastMajor = "Multicast"
+	BroadcastMajor = "Broadcast"
+	LinkLocalMajor = "Link-local"
+
+	PrivateNetworkMajor = "Private network"
+
+	// CollapsedCount is the counter on the "others" node recording how many
+	// pseudo nodes were collapsed into it.
+	CollapsedCount = "collapsed_count"
+)
+
+// OthersPseudoID is the ID of the node pseudo nodes are collapsed into.
+var OthersPseudoID = MakePseudoNodeID("others")
+
+// IDs of the pseudo nodes grouping all multicast, broadcast and link-local
+// remotes respectively, however many addresses they are spread over.
+var (
+	MulticastPseudoID = MakePseudoNodeID("multicast")
+	BroadcastPseudoID = MakePseudoNodeID("broadcast")
+	LinkLocalPseudoID = MakePseudoNodeID("linklocal")
+)
+
+// PrivateNetworkPseudoID is the ID of the pseudo node grouping the remotes in
+// private ranges outside the networks local to the report, e.g. on a peered
+// VPC or behind a VPN. They aren't on the internet, but no probe reports them.
+var PrivateNetworkPseudoID = MakePseudoNodeID("privatenetwork")
+
+// addressClassPseudoID returns the ID of the pseudo node grouping ip with
+// other addresses of its class, if it is a broadcast, multicast or
+// link-local address. Directed broadcasts are recognised for the IPv4
+// networks local to the report.
+func addressClassPseudoID(ip net.IP, local report.Networks) (string, bool) {
+	if ip.Equal(net.IPv4bcast) {
+		return BroadcastPseudoID, true
+	}
+	if v4 := ip.To4(); v4 != nil {
+		for _, network := range local {
+			if network.IP.To4() == nil || !network.Contains(v4) {
+				continue
+			}
+			if ones, bits := network.Mask.Size(); bits == 32 && ones < 31 && isBroadcast(v4, network.Mask) {
+				return BroadcastPseudoID, true
+			}
+		}
+	}
+	if ip.IsMulticast() {
+		return MulticastPseudoID, true
+	}
+	if ip.IsLinkLocalUnicast() {
+		return LinkLocalPseudoID, true
+	}
+	return "", false
+}
+
+// isBroadcast tells whether all the host bits of ip are set.
+func isBroadcast(ip net.IP, mask net.IPMask) bool {
+	for i := range mask {
+		if ip[i]|mask[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// CollapsePseudo renders nodes with the given renderer, and if more than
+// threshold pseudo nodes are produced, merges them all into a single "others"
+// pseudo node. The internet, address class and private network nodes are
+// always kept, as they already aggregate many addresses. Edges to and from
+// the collapsed nodes are summed onto the new node; real nodes are otherwise
+// left untouched.
+func CollapsePseudo(threshold int, r Renderer) Renderer {
+	return CustomRenderer{
+		Renderer: r,
+		RenderFunc: func(input report.Nodes) report.Nodes {
+			collapsed := map[string]struct{}{}
+			for id, node := range input {
+				if node.Topology == Pseudo && !isAggregatePseudoNode(id) {
+					collapsed[id] = struct{}{}
+				}
+			}
+			if len(collapsed) <= threshold {
+				return input
+			}
+
+			rename := func(id string) string {
+				if _, ok := collapsed[id]; ok {
+					return OthersPseudoID
+				}
+				return id
+			}
+			others := report.MakeNode(OthersPseudoID).WithTopology(Pseudo)
+			output := report.Nodes{}
+			for id, node := range input {
+				adjacency := report.MakeIDList()
+				for _, adj := range node.Adjacency {
+					if adj = rename(adj); adj != rename(id) {
+						adjacency = adjacency.Add(adj)
+					}
+				}
+				edges := report.EmptyEdgeMetadatas
+				node.Edges.ForEach(func(dst string, md report.EdgeMetadata) {
+					edges = edges.Add(rename(dst), md)
+				})
+				node.Adjacency, node.Edges = adjacency, edges
+
+				if _, ok := collapsed[id]; ok {
+					node.ID = OthersPseudoID
+					others = others.Merge(node)
+					continue
+				}
+				output[id] = node
+			}
+			others.Counters = others.Counters.Add(CollapsedCount, len(collapsed))
+			output[OthersPseudoID] = others
+			return output
+		},
+	}
+}
+
+func isAggregatePseudoNode(id string) bool {
+	switch id {
+	case IncomingInternetID, OutgoingInternetID, MulticastPseudoID, BroadcastPseudoID, LinkLocalPseudoID, PrivateNetworkPseudoID:
+		return true
+	}
+	return false
+}
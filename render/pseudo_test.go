@@ -0,0 +1,116 @@
+package render_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func pseudoFanOut(count int) render.Renderer {
+	nodes := report.Nodes{}
+	client := report.MakeNode("client").WithTopology(report.Container)
+	for i := 0; i < count; i++ {
+		id := render.MakePseudoNodeID("service-" + fmt.Sprint(i))
+		bytes := uint64(10)
+		client = client.WithAdjacent(id).WithEdge(id, report.EdgeMetadata{EgressByteCount: &bytes})
+		nodes[id] = report.MakeNode(id).WithTopology(render.Pseudo)
+	}
+	nodes[client.ID] = client
+	nodes[render.IncomingInternetID] = report.MakeNode(render.IncomingInternetID).WithTopology(render.Pseudo)
+	return render.ConstantRenderer(nodes)
+}
+
+func TestCollapsePseudoAboveThreshold(t *testing.T) {
+	have := render.CollapsePseudo(3, pseudoFanOut(5)).Render(report.MakeReport(), FilterNoop)
+
+	if len(have) != 3 {
+		t.Fatalf("expected client, internet and others nodes, got %v", have)
+	}
+	if _, ok := have[render.IncomingInternetID]; !ok {
+		t.Errorf("internet node should not be collapsed")
+	}
+	others, ok := have[render.OthersPseudoID]
+	if !ok {
+		t.Fatalf("missing others node")
+	}
+	if count, _ := others.Counters.Lookup(render.CollapsedCount); count != 5 {
+		t.Errorf("expected 5 collapsed nodes, got %d", count)
+	}
+
+	client := have["client"]
+	if len(client.Adjacency) != 1 || !client.Adjacency.Contains(render.OthersPseudoID) {
+		t.Errorf("expected client to be adjacent to others only, got %v", client.Adjacency)
+	}
+	edge, ok := client.Edges.Lookup(render.OthersPseudoID)
+	if !ok || edge.EgressByteCount == nil || *edge.EgressByteCount != 50 {
+		t.Errorf("expected summed edge of 50 bytes, got %v", edge)
+	}
+}
+
+func TestCollapsePseudoBelowThreshold(t *testing.T) {
+	input := pseudoFanOut(3)
+	have := render.CollapsePseudo(3, input).Render(report.MakeReport(), FilterNoop)
+	want := input.Render(report.MakeReport(), FilterNoop)
+	if len(have) != len(want) {
+		t.Errorf("expected %d nodes, got %d", len(want), len(have))
+	}
+	if _, ok := have[render.OthersPseudoID]; ok {
+		t.Errorf("unexpected others node below threshold")
+	}
+}
+
+func TestMapEndpoint2PseudoAddressClasses(t *testing.T) {
+	_, localNet, _ := net.ParseCIDR("10.0.0.0/24")
+	local := report.Networks{localNet}
+
+	for addr, want := range map[string]string{
+		"224.0.0.251":     render.MulticastPseudoID,
+		"239.255.255.250": render.MulticastPseudoID,
+		"ff02::fb":        render.MulticastPseudoID,
+		"255.255.255.255": render.BroadcastPseudoID,
+		"10.0.0.255":      render.BroadcastPseudoID,
+		"169.254.169.254": render.LinkLocalPseudoID,
+		"10.0.0.1":        "",
+		"8.8.8.8":         render.OutgoingInternetID,
+	} {
+		n := report.MakeNodeWith(report.MakeEndpointNodeID("", "", addr, "5353"), map[string]string{
+			endpoint.Addr: addr,
+		})
+		have := render.MapEndpoint2Pseudo(n, local)
+		if want == "" {
+			if len(have) != 0 {
+				t.Errorf("%s: expected no pseudo node, got %v", addr, have)
+			}
+			continue
+		}
+		if _, ok := have[want]; !ok || len(have) != 1 {
+			t.Errorf("%s: expected %s, got %v", addr, want, have)
+		}
+	}
+}
+
+func TestAddressClassNodesNotCollapsed(t *testing.T) {
+	nodes := report.Nodes{}
+	for _, id := range []string{
+		render.MulticastPseudoID,
+		render.BroadcastPseudoID,
+		render.LinkLocalPseudoID,
+		render.MakePseudoNodeID("a"),
+		render.MakePseudoNodeID("b"),
+	} {
+		nodes[id] = report.MakeNode(id).WithTopology(render.Pseudo)
+	}
+	have := render.CollapsePseudo(1, render.ConstantRenderer(nodes)).Render(report.MakeReport(), FilterNoop)
+	for _, id := range []string{render.MulticastPseudoID, render.BroadcastPseudoID, render.LinkLocalPseudoID, render.OthersPseudoID} {
+		if _, ok := have[id]; !ok {
+			t.Errorf("expected %s in %v", id, have)
+		}
+	}
+	if len(have) != 4 {
+		t.Errorf("expected 4 nodes, got %v", have)
+	}
+}
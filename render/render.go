@@ -1,6 +1,8 @@
 package render
 
 import (
+	"time"
+
 	"github.com/weaveworks/scope/report"
 )
 
@@ -29,6 +31,12 @@ func (s Stats) merge(other Stats) Stats {
 
 // Reduce renderer is a Renderer which merges together the output of several
 // other renderers.
+//
+// Nodes with the same ID are merged with Node.Merge, so sets, adjacencies and
+// children are unioned and edge metadata and counters summed, none of which
+// depends on the order of the renderers. Where the renderers disagree on a
+// latest value with the same timestamp, the greatest value is kept, so the
+// output doesn't depend on their order either.
 type Reduce []Renderer
 
 // MakeReduce is the only sane way to produce a Reduce Renderer.
@@ -37,12 +45,46 @@ func MakeReduce(renderers ...Renderer) Renderer {
 	return Memoise(&r)
 }
 
-// Render produces a set of Nodes given a Report.
+// Render produces a set of Nodes given a Report. If only one of the
+// renderers produces any nodes, its output is returned as is.
 func (r *Reduce) Render(rpt report.Report, dct Decorator) report.Nodes {
-	result := report.Nodes{}
+	outputs := make([]report.Nodes, 0, len(*r))
 	for _, renderer := range *r {
-		result = result.Merge(renderer.Render(rpt, dct))
+		if output := renderer.Render(rpt, dct); len(output) > 0 {
+			outputs = append(outputs, output)
+		}
 	}
+	switch len(outputs) {
+	case 0:
+		return report.Nodes{}
+	case 1:
+		return outputs[0]
+	}
+
+	result := report.Nodes{}
+	for _, output := range outputs {
+		for id, node := range output {
+			if existing, ok := result[id]; ok {
+				node = mergeReduced(existing, node)
+			}
+			result[id] = node
+		}
+	}
+	return result
+}
+
+// mergeReduced merges two renderings of the same node, breaking ties between
+// latest values deterministically.
+func mergeReduced(a, b report.Node) report.Node {
+	result := a.Merge(b)
+	b.Latest.ForEach(func(key string, ts time.Time, value string) {
+		if other, otherTS, ok := a.Latest.LookupEntry(key); ok && otherTS.Equal(ts) && other != value {
+			if other > value {
+				value = other
+			}
+			result.Latest = result.Latest.Set(key, ts, value)
+		}
+	})
 	return result
 }
 
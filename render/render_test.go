@@ -3,6 +3,7 @@ package render_test
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/render"
@@ -37,6 +38,49 @@ func TestReduceRender(t *testing.T) {
 	}
 }
 
+func TestReduceRenderDeterministic(t *testing.T) {
+	now := time.Now()
+	var (
+		a = mockRenderer{Nodes: report.Nodes{
+			"shared": report.MakeNode("shared").
+				WithLatest("name", now, "alpha").
+				WithLatest("image", now, "nginx").
+				WithAdjacent("foo").
+				WithEdge("foo", report.EdgeMetadata{EgressPacketCount: newu64(1)}),
+			"foo": report.MakeNode("foo"),
+		}}
+		b = mockRenderer{Nodes: report.Nodes{
+			"shared": report.MakeNode("shared").
+				WithLatest("name", now, "beta").
+				WithLatest("image", now.Add(-time.Second), "redis").
+				WithAdjacent("foo").
+				WithAdjacent("bar").
+				WithEdge("foo", report.EdgeMetadata{EgressPacketCount: newu64(2)}),
+			"bar": report.MakeNode("bar"),
+		}}
+	)
+
+	ab := render.MakeReduce(a, b).Render(report.MakeReport(), FilterNoop)
+	ba := render.MakeReduce(b, a).Render(report.MakeReport(), FilterNoop)
+	if !reflect.DeepEqual(ab, ba) {
+		t.Errorf("merge depends on order: %s", test.Diff(ab, ba))
+	}
+
+	shared := ab["shared"]
+	if name, _ := shared.Latest.Lookup("name"); name != "beta" {
+		t.Errorf("expected the greater of two simultaneous values, got %q", name)
+	}
+	if image, _ := shared.Latest.Lookup("image"); image != "nginx" {
+		t.Errorf("expected the newer value, got %q", image)
+	}
+	if want := report.MakeIDList("bar", "foo"); !reflect.DeepEqual(want, shared.Adjacency) {
+		t.Errorf("want adjacency %v, have %v", want, shared.Adjacency)
+	}
+	if edge, _ := shared.Edges.Lookup("foo"); edge.EgressPacketCount == nil || *edge.EgressPacketCount != 3 {
+		t.Errorf("expected summed edge, got %v", edge)
+	}
+}
+
 func TestMapRender1(t *testing.T) {
 	// 1. Check when we return false, the node gets filtered out
 	mapper := render.Map{
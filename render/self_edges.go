@@ -0,0 +1,28 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// HideSelfEdges is a Decorator which drops the adjacencies, and their edge
+// metadata, from each node to itself. These come from processes talking to
+// themselves, but also from grouping, e.g. between two containers of the
+// same image, so they are only dropped on request.
+func HideSelfEdges(r Renderer) Renderer {
+	return CustomRenderer{
+		Renderer:   r,
+		RenderFunc: hideSelfEdges,
+	}
+}
+
+func hideSelfEdges(input report.Nodes) report.Nodes {
+	output := report.Nodes{}
+	for id, node := range input {
+		if node.Adjacency.Contains(id) {
+			node.Adjacency = node.Adjacency.Copy().Remove(id)
+			node.Edges = removeEdge(node.Edges, id)
+		}
+		output[id] = node
+	}
+	return output
+}
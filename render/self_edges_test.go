@@ -0,0 +1,42 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestHideSelfEdges(t *testing.T) {
+	input := render.ConstantRenderer(report.Nodes{
+		"a": report.MakeNode("a").
+			WithEdge("a", report.EdgeMetadata{}).
+			WithEdge("b", report.EdgeMetadata{}),
+		"b": report.MakeNode("b"),
+	})
+
+	// Self edges are kept unless asked for.
+	if have := input.Render(report.MakeReport(), nil); !have["a"].Adjacency.Contains("a") {
+		t.Errorf("expected the self edge without the decorator, got %v", have["a"])
+	}
+
+	have := render.ApplyDecorator(input).Render(report.MakeReport(), render.HideSelfEdges)
+	a := have["a"]
+	if a.Adjacency.Contains("a") {
+		t.Errorf("expected the self edge to be dropped, got %v", a.Adjacency)
+	}
+	if _, ok := a.Edges.Lookup("a"); ok {
+		t.Errorf("expected the self edge's metadata to be dropped, got %v", a.Edges)
+	}
+	if _, ok := a.Edges.Lookup("b"); !a.Adjacency.Contains("b") || !ok {
+		t.Errorf("expected a -> b to be kept, got %v", a)
+	}
+	if _, ok := have["b"]; !ok {
+		t.Errorf("expected b to be kept, got %v", have)
+	}
+
+	// The input isn't modified.
+	if again := input.Render(report.MakeReport(), nil); !again["a"].Adjacency.Contains("a") {
+		t.Errorf("expected the input to be left alone, got %v", again["a"])
+	}
+}
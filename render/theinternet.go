@@ -24,8 +24,25 @@ var (
 		// and having separate nodes for them makes visualizations worse
 		regexp.MustCompile(`^ec2.*\.amazonaws\.com$`),
 	}
+
+	// privateNetworks are the RFC1918 and RFC4193 (IPv6 unique local)
+	// ranges. Addresses in them can't be on the internet, even when they
+	// aren't in any network a probe reported as local.
+	privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
 )
 
+func mustParseCIDRs(cidrs ...string) report.Networks {
+	result := report.Networks{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, ipNet)
+	}
+	return result
+}
+
 // TODO: Make it user-customizable https://github.com/weaveworks/scope/issues/1876
 func isKnownService(hostname string) bool {
 	foundMatch := false
@@ -75,3 +92,15 @@ func LocalNetworks(r report.Report) report.Networks {
 	}
 	return result
 }
+
+// isInternetAddress reports whether ip belongs on the internet nodes: it is
+// neither in one of the local networks nor in a private range.
+func isInternetAddress(ip net.IP, local report.Networks) bool {
+	return ip != nil && !local.Contains(ip) && !privateNetworks.Contains(ip)
+}
+
+// isUnreportedPrivateAddress reports whether ip is in a private range, but
+// not in one of the local networks.
+func isUnreportedPrivateAddress(ip net.IP, local report.Networks) bool {
+	return ip != nil && !local.Contains(ip) && privateNetworks.Contains(ip)
+}
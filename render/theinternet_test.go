@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
@@ -41,6 +42,75 @@ func TestReportLocalNetworks(t *testing.T) {
 	}
 }
 
+func TestInternetClassification(t *testing.T) {
+	r := report.MakeReport()
+	r.Host.AddNode(report.MakeNode("host").WithSets(report.EmptySets.
+		Add(host.LocalNetworks, report.MakeStringSet("203.0.113.0/24", "10.0.0.0/24")),
+	))
+	local := render.LocalNetworks(r)
+
+	for addr, want := range map[string]string{
+		"10.0.0.3":     "", // reported as local by the host
+		"10.1.2.3":     render.PrivateNetworkPseudoID,
+		"172.16.0.1":   render.PrivateNetworkPseudoID,
+		"172.31.255.1": render.PrivateNetworkPseudoID,
+		"192.168.1.1":  render.PrivateNetworkPseudoID,
+		"fd00::1":      render.PrivateNetworkPseudoID,
+		"203.0.113.7":  "", // reported as local by the host
+		"172.32.0.1":   render.OutgoingInternetID,
+		"8.8.8.8":      render.OutgoingInternetID,
+		"2001:db8::1":  render.OutgoingInternetID,
+
+		// NAT64 addresses are classified by the IPv4 address they embed.
+		"64:ff9b::a01:203":   render.PrivateNetworkPseudoID,
+		"64:ff9b::cb00:7107": "",
+		"64:ff9b::808:808":   render.OutgoingInternetID,
+	} {
+		n := report.MakeNodeWith(report.MakeEndpointNodeID("", "", addr, "80"), map[string]string{
+			endpoint.Addr: addr,
+		})
+		have := render.MapEndpoint2Pseudo(n, local)
+		if want == "" {
+			if len(have) != 0 {
+				t.Errorf("%s: expected no pseudo node, got %v", addr, have)
+			}
+			continue
+		}
+		if _, ok := have[want]; !ok || len(have) != 1 {
+			t.Errorf("%s: expected %s, got %v", addr, want, have)
+		}
+	}
+}
+
+func TestMapEndpoint2GenericPseudoNAT64(t *testing.T) {
+	// Local, so that each address gets a pseudo node of its own.
+	r := report.MakeReport()
+	r.Host.AddNode(report.MakeNode("host").WithSets(report.EmptySets.
+		Add(host.LocalNetworks, report.MakeStringSet("10.0.0.0/8")),
+	))
+	local := render.LocalNetworks(r)
+
+	var (
+		direct = report.MakeNodeWith(report.MakeEndpointNodeID("", "", "10.1.2.3", "80"), map[string]string{
+			endpoint.Addr: "10.1.2.3",
+		})
+		nat64 = report.MakeNodeWith(report.MakeEndpointNodeID("", "", "64:ff9b::a01:203", "80"), map[string]string{
+			endpoint.Addr:      "64:ff9b::a01:203",
+			endpoint.NAT64Addr: "10.1.2.3",
+		})
+		want = render.MakePseudoNodeID("10.1.2.3")
+	)
+	for _, n := range []report.Node{direct, nat64} {
+		have := render.MapEndpoint2GenericPseudo(n, local)
+		if _, ok := have[want]; !ok || len(have) != 1 {
+			t.Errorf("%s: expected %s, got %v", n.ID, want, have)
+		}
+	}
+	if v4, _ := render.MapEndpoint2GenericPseudo(nat64, local)[want].Latest.Lookup(endpoint.NAT64Addr); v4 != "10.1.2.3" {
+		t.Errorf("expected the embedded IPv4 address to be kept, got %q", v4)
+	}
+}
+
 func mustParseCIDR(s string) *net.IPNet {
 	_, ipNet, err := net.ParseCIDR(s)
 	if err != nil {
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// Undirected is a Decorator which merges the adjacencies between each pair
+// of nodes in both directions into one. The merged adjacency is kept on the
+// non-pseudo node of the pair if there is one, and otherwise on the node with
+// the lower ID. Edge metadata from the other node is reversed and merged in,
+// so connection counts are summed while ingress and egress bytes are still
+// from the point of view of the node keeping the edge. Self edges, and
+// adjacencies to nodes which weren't rendered, are left alone.
+func Undirected(r Renderer) Renderer {
+	return CustomRenderer{
+		Renderer:   r,
+		RenderFunc: undirected,
+	}
+}
+
+func undirected(input report.Nodes) report.Nodes {
+	output := input.Copy()
+	for id, node := range input {
+		for _, dst := range node.Adjacency {
+			peer, ok := input[dst]
+			if !ok || dst == id || !peer.Adjacency.Contains(id) || keepsEdge(node, peer) {
+				continue
+			}
+			// The peer keeps the edge: move ours onto it, then drop ours.
+			kept := output[dst]
+			if md, ok := node.Edges.Lookup(dst); ok {
+				// The two directions are different connections, so
+				// flatten rather than merge them.
+				md = md.Reversed()
+				if existing, ok := kept.Edges.Lookup(id); ok {
+					md = existing.Flatten(md)
+				}
+				kept.Edges = removeEdge(kept.Edges, id).Add(id, md)
+			}
+			output[dst] = kept
+
+			dropped := output[id]
+			dropped.Adjacency = dropped.Adjacency.Copy().Remove(dst)
+			dropped.Edges = removeEdge(dropped.Edges, dst)
+			output[id] = dropped
+		}
+	}
+	return output
+}
+
+// keepsEdge says whether a, rather than b, keeps the merged edge between
+// them.
+func keepsEdge(a, b report.Node) bool {
+	if aPseudo, bPseudo := a.Topology == Pseudo, b.Topology == Pseudo; aPseudo != bPseudo {
+		return bPseudo
+	}
+	return a.ID < b.ID
+}
+
+func removeEdge(edges report.EdgeMetadatas, dst string) report.EdgeMetadatas {
+	if _, ok := edges.Lookup(dst); !ok {
+		return edges
+	}
+	result := report.EmptyEdgeMetadatas
+	edges.ForEach(func(id string, md report.EdgeMetadata) {
+		if id != dst {
+			result = result.Add(id, md)
+		}
+	})
+	return result
+}
@@ -0,0 +1,59 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestUndirected(t *testing.T) {
+	u64 := func(v uint64) *uint64 { return &v }
+	internet := report.MakeNode(render.IncomingInternetID).WithTopology(render.Pseudo)
+	input := render.ConstantRenderer(report.Nodes{
+		// a <-> b, with bytes both ways
+		"a": report.MakeNode("a").WithEdge("b", report.EdgeMetadata{
+			EgressByteCount: u64(10), IngressByteCount: u64(1),
+			WithTCP: true, TCPConnections: u64(1),
+		}),
+		"b": report.MakeNode("b").WithEdge("a", report.EdgeMetadata{
+			EgressByteCount: u64(20), IngressByteCount: u64(2),
+			WithTCP: true, TCPConnections: u64(2),
+		}),
+		// z <-> the internet, where z would otherwise lose the edge
+		"z":         report.MakeNode("z").WithAdjacent(internet.ID),
+		internet.ID: internet.WithAdjacent("z"),
+		// self edges and one-way edges are left alone
+		"s": report.MakeNode("s").WithAdjacent("s").WithAdjacent("a"),
+	})
+	have := render.ApplyDecorator(input).Render(report.MakeReport(), render.Undirected)
+
+	if have["b"].Adjacency.Contains("a") {
+		t.Errorf("expected b -> a to be merged into a -> b, got %v", have["b"].Adjacency)
+	}
+	edge, ok := have["a"].Edges.Lookup("b")
+	if !have["a"].Adjacency.Contains("b") || !ok {
+		t.Fatalf("expected a -> b to be kept, got %v", have["a"])
+	}
+	// b's egress is a's ingress.
+	if edge.EgressByteCount == nil || *edge.EgressByteCount != 12 ||
+		edge.IngressByteCount == nil || *edge.IngressByteCount != 21 ||
+		edge.TCPConnections == nil || *edge.TCPConnections != 3 {
+		t.Errorf("unexpected merged edge %v", edge)
+	}
+	if _, ok := have["b"].Edges.Lookup("a"); ok {
+		t.Error("expected b's edge metadata to a to be dropped")
+	}
+
+	if !have["z"].Adjacency.Contains(internet.ID) || have[internet.ID].Adjacency.Contains("z") {
+		t.Errorf("expected the edge to the internet to be kept on z, got %v and %v", have["z"], have[internet.ID])
+	}
+	if !have["s"].Adjacency.Contains("s") || !have["s"].Adjacency.Contains("a") {
+		t.Errorf("expected s's edges to be kept, got %v", have["s"].Adjacency)
+	}
+
+	// The input isn't modified.
+	if again := input.Render(report.MakeReport(), nil); !again["b"].Adjacency.Contains("a") {
+		t.Errorf("expected the input to be left alone, got %v", again["b"])
+	}
+}
@@ -0,0 +1,242 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// anonymizedKeys are the Latest keys whose values are replaced outright,
+// anonymizedPrefixes the prefixes of such keys, and anonymizedSets the Sets
+// keys whose values are. They are defined by the probe packages, which can't
+// be imported from here, so those packages test that their keys are listed.
+var (
+	anonymizedKeys = map[string]struct{}{
+		"host_name":                 {},
+		"docker_container_hostname": {},
+		"docker_container_command":  {},
+		"cmdline":                   {},
+		"endpoint:user":             {},
+		"endpoint:uid":              {},
+	}
+	anonymizedPrefixes = []string{
+		"docker_env_",
+		"endpoint:env_",
+	}
+	anonymizedSets = map[string]struct{}{
+		"endpoint:snooped_dns_names": {},
+		"endpoint:reverse_dns_names": {},
+	}
+)
+
+// Anonymize returns a copy of r with the IP addresses, host IDs, host names
+// and other identifying values in it replaced by salted hashes, so it can be
+// shared. Node IDs are rewritten consistently with the values which refer to
+// them, so adjacencies, edges and parents still line up, and the same salt
+// always gives the same result.
+//
+// Addresses are permuted a byte at a time, with a permutation keyed by the
+// salt and the bytes before it. Distinct addresses stay distinct, and networks
+// still contain their addresses afterwards as long as the network boundary
+// falls on a byte.
+func Anonymize(r Report, salt string) Report {
+	a := anonymizer{
+		salt:         salt,
+		hosts:        map[string]struct{}{},
+		permutations: map[string][]int{},
+	}
+	a.collectHosts(r)
+
+	result := r.Copy()
+	result.HostID = a.id(r.HostID)
+	result.WalkTopologies(func(t *Topology) {
+		nodes := make(Nodes, len(t.Nodes))
+		for _, n := range t.Nodes {
+			n = a.node(n)
+			nodes[n.ID] = n
+		}
+		t.Nodes = nodes
+	})
+	return result
+}
+
+type anonymizer struct {
+	salt         string
+	hosts        map[string]struct{}
+	permutations map[string][]int
+}
+
+// collectHosts finds the host IDs used in r, so they can be recognised when
+// they appear as components of other node IDs.
+func (a anonymizer) collectHosts(r Report) {
+	add := func(hostNodeID string) {
+		if hostID, ok := ParseHostNodeID(hostNodeID); ok && hostID != "" {
+			a.hosts[hostID] = struct{}{}
+		}
+	}
+	if r.HostID != "" {
+		a.hosts[r.HostID] = struct{}{}
+	}
+	r.WalkTopologies(func(t *Topology) {
+		for id, n := range t.Nodes {
+			add(id)
+			if hostNodeID, ok := n.Latest.Lookup(HostNodeID); ok {
+				add(hostNodeID)
+			}
+		}
+	})
+}
+
+func (a anonymizer) hash(parts ...string) []byte {
+	h := sha256.New()
+	h.Write([]byte(a.salt))
+	for _, part := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return h.Sum(nil)
+}
+
+// name replaces a host name, or any other opaque value.
+func (a anonymizer) name(s string) string {
+	if s == "" {
+		return s
+	}
+	return "anon-" + hex.EncodeToString(a.hash(s)[:6])
+}
+
+func (a anonymizer) ip(ip net.IP) net.IP {
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return ip
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	result := make(net.IP, len(ip))
+	for i := range ip {
+		result[i] = byte(a.permutation(ip[:i])[ip[i]])
+	}
+	return result
+}
+
+// permutation returns the permutation of byte values used for the byte of an
+// address following the given prefix.
+func (a anonymizer) permutation(prefix net.IP) []int {
+	key := string(prefix)
+	if perm, ok := a.permutations[key]; ok {
+		return perm
+	}
+	seed := int64(binary.BigEndian.Uint64(a.hash("ip", key)))
+	perm := rand.New(rand.NewSource(seed)).Perm(256)
+	a.permutations[key] = perm
+	return perm
+}
+
+// component replaces a single component of a node ID or value.
+func (a anonymizer) component(s string) string {
+	if _, ok := a.hosts[s]; ok {
+		return a.name(s)
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return a.ip(ip).String()
+	}
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		network.IP = a.ip(network.IP)
+		return network.String()
+	}
+	// Loopback addresses are scoped by host ID and network namespace.
+	if i := strings.LastIndex(s, "-"); i > 0 {
+		if _, ok := a.hosts[s[:i]]; ok {
+			return a.name(s[:i]) + s[i:]
+		}
+	}
+	return s
+}
+
+// id replaces the sensitive components of a node ID, or of any value which
+// might contain one.
+func (a anonymizer) id(s string) string {
+	components := strings.Split(s, ScopeDelim)
+	for i, c := range components {
+		components[i] = a.component(c)
+	}
+	return strings.Join(components, ScopeDelim)
+}
+
+func (a anonymizer) ids(ss StringSet) StringSet {
+	result := make([]string, 0, len(ss))
+	for _, s := range ss {
+		result = append(result, a.id(s))
+	}
+	return MakeStringSet(result...)
+}
+
+func (a anonymizer) names(ss StringSet) StringSet {
+	result := make([]string, 0, len(ss))
+	for _, s := range ss {
+		result = append(result, a.name(s))
+	}
+	return MakeStringSet(result...)
+}
+
+func (a anonymizer) sets(s Sets) Sets {
+	result := EmptySets
+	for _, key := range s.Keys() {
+		values, _ := s.Lookup(key)
+		if _, ok := anonymizedSets[key]; ok {
+			result = result.Add(key, a.names(values))
+		} else {
+			result = result.Add(key, a.ids(values))
+		}
+	}
+	return result
+}
+
+func (a anonymizer) value(key, value string) string {
+	if _, ok := anonymizedKeys[key]; ok {
+		return a.name(value)
+	}
+	for _, prefix := range anonymizedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return a.name(value)
+		}
+	}
+	return a.id(value)
+}
+
+func (a anonymizer) node(n Node) Node {
+	n.ID = a.id(n.ID)
+	n.Sets = a.sets(n.Sets)
+	n.Parents = a.sets(n.Parents)
+
+	adjacency := MakeIDList()
+	for _, dst := range n.Adjacency {
+		adjacency = adjacency.Add(a.id(dst))
+	}
+	n.Adjacency = adjacency
+
+	edges := EmptyEdgeMetadatas
+	n.Edges.ForEach(func(dst string, md EdgeMetadata) {
+		edges = edges.Add(a.id(dst), md)
+	})
+	n.Edges = edges
+
+	latest := EmptyStringLatestMap
+	n.Latest.ForEach(func(key string, ts time.Time, value string) {
+		latest = latest.Set(key, ts, a.value(key, value))
+	})
+	n.Latest = latest
+
+	if n.Children.Size() > 0 {
+		children := MakeNodeSet()
+		n.Children.ForEach(func(child Node) {
+			children = children.Add(a.node(child))
+		})
+		n.Children = children
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+package report_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func anonymizeFixture() report.Report {
+	var (
+		hostNodeID = report.MakeHostNodeID("client.example.com")
+		client     = report.MakeEndpointNodeID("client.example.com", "", "10.0.1.2", "54321")
+		server     = report.MakeEndpointNodeID("server.example.com", "", "10.0.1.3", "80")
+		loopback   = report.MakeEndpointNodeID("client.example.com", "4026531957", "127.0.0.1", "8080")
+	)
+	rpt := report.MakeReport()
+	rpt.HostID = "client.example.com"
+	rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{
+		"host_name": "client.example.com",
+	}).WithSets(report.MakeSets().Add("local_networks", report.MakeStringSet("10.0.1.0/24"))))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(client, map[string]string{
+		report.HostNodeID:         hostNodeID,
+		"addr":                    "10.0.1.2",
+		"endpoint:user":           "alice",
+		"endpoint:uid":            "4242",
+		"endpoint:env_DB_SECRET":  "hunter2",
+		"docker_env_API_PASSWORD": "swordfish",
+	}).WithEdge(server, report.EdgeMetadata{}))
+	rpt.Endpoint.AddNode(report.MakeNodeWith(server, map[string]string{
+		report.HostNodeID: report.MakeHostNodeID("server.example.com"),
+		"addr":            "10.0.1.3",
+	}).WithSets(report.MakeSets().
+		Add("endpoint:snooped_dns_names", report.MakeStringSet("www.example.com")).
+		Add("endpoint:reverse_dns_names", report.MakeStringSet("server.example.com"))))
+	rpt.Endpoint.AddNode(report.MakeNode(loopback))
+	return rpt
+}
+
+func TestAnonymize(t *testing.T) {
+	mtime.NowForce(time.Now())
+	defer mtime.NowReset()
+
+	rpt := anonymizeFixture()
+	have := report.Anonymize(rpt, "salt")
+
+	if len(have.Endpoint.Nodes) != 3 || len(have.Host.Nodes) != 1 {
+		t.Fatalf("expected the same number of nodes, got %v", have)
+	}
+
+	// Nothing identifying is left anywhere in the report.
+	var buf bytes.Buffer
+	if err := have.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String() + have.HostID
+	for _, secret := range []string{"example.com", "10.0.1.2", "10.0.1.3", "10.0.1.0", "alice", "hunter2", "swordfish"} {
+		if strings.Contains(encoded, secret) {
+			t.Errorf("anonymized report still contains %q: %s", secret, encoded)
+		}
+	}
+
+	// Edges still lead to the right nodes.
+	var client, server report.Node
+	for _, n := range have.Endpoint.Nodes {
+		if addr, _ := n.Latest.Lookup("addr"); addr != "" && len(n.Adjacency) > 0 {
+			client = n
+		} else if addr != "" {
+			server = n
+		}
+	}
+	if !client.Adjacency.Contains(server.ID) {
+		t.Errorf("expected %s to be adjacent to %s", client.ID, server.ID)
+	}
+	if _, ok := client.Edges.Lookup(server.ID); !ok {
+		t.Errorf("expected edge metadata from %s to %s", client.ID, server.ID)
+	}
+	if uid, _ := client.Latest.Lookup("endpoint:uid"); uid == "4242" {
+		t.Errorf("expected the uid to be anonymized")
+	}
+	hostNodeID, _ := client.Latest.Lookup(report.HostNodeID)
+	if _, ok := have.Host.Nodes[hostNodeID]; !ok {
+		t.Errorf("host node %s missing from %v", hostNodeID, have.Host.Nodes)
+	}
+	if want := report.MakeHostNodeID(have.HostID); want != hostNodeID {
+		t.Errorf("report host ID %s doesn't match host node %s", have.HostID, hostNodeID)
+	}
+
+	// Addresses stay within their (anonymized) networks.
+	addr, _ := client.Latest.Lookup("addr")
+	networks, _ := have.Host.Nodes[hostNodeID].Sets.Lookup("local_networks")
+	_, network, err := net.ParseCIDR(networks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !network.Contains(net.ParseIP(addr)) {
+		t.Errorf("expected %s to be within %s", addr, network)
+	}
+
+	// The same salt gives the same result; a different one doesn't.
+	if again := report.Anonymize(anonymizeFixture(), "salt"); !reflect.DeepEqual(have, again) {
+		t.Errorf("anonymization isn't stable: %s", test.Diff(have, again))
+	}
+	if other := report.Anonymize(anonymizeFixture(), "pepper"); other.HostID == have.HostID {
+		t.Errorf("expected a different salt to give a different host ID, got %s", other.HostID)
+	}
+}
+
+func TestAnonymizeIPsAreDistinct(t *testing.T) {
+	// Addresses which differ in any byte stay distinct, and keep their
+	// common prefix.
+	rpt := report.MakeReport()
+	for i := 0; i < 256; i++ {
+		rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID("", "", net.IPv4(10, 0, 1, byte(i)).String(), "80")))
+	}
+	have := report.Anonymize(rpt, "salt")
+	if len(have.Endpoint.Nodes) != 256 {
+		t.Fatalf("expected 256 distinct addresses, got %d", len(have.Endpoint.Nodes))
+	}
+	var prefix string
+	for id := range have.Endpoint.Nodes {
+		_, addr, _, _ := report.ParseEndpointNodeID(id)
+		ip := net.ParseIP(addr).To4()
+		if p := string(ip[:3]); prefix == "" {
+			prefix = p
+		} else if p != prefix {
+			t.Errorf("expected all addresses to share a prefix, got %v and %v", net.IP(prefix), net.IP(p))
+		}
+	}
+}
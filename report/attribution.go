@@ -0,0 +1,19 @@
+package report
+
+// Attribution records which probes reported each node, as the host IDs of
+// their reports, by node ID. It is kept apart from reports, so as not to
+// take up space on the wire, and only worked out when asked for.
+type Attribution map[string]StringSet
+
+// Add attributes the nodes of rpt to the probe on rpt.HostID. Reports
+// without a HostID, such as merged ones, can't be attributed.
+func (a Attribution) Add(rpt Report) {
+	if rpt.HostID == "" {
+		return
+	}
+	for _, topology := range rpt.Topologies() {
+		for id := range topology.Nodes {
+			a[id] = a[id].Add(rpt.HostID)
+		}
+	}
+}
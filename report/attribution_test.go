@@ -0,0 +1,35 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestAttributionAdd(t *testing.T) {
+	probe := func(hostID string, nodeIDs ...string) report.Report {
+		rpt := report.MakeReport()
+		rpt.HostID = hostID
+		for _, id := range nodeIDs {
+			rpt.Container.AddNode(report.MakeNode(id))
+		}
+		return rpt
+	}
+	attribution := report.Attribution{}
+	for _, rpt := range []report.Report{
+		probe("host1", "shared", "only1"),
+		probe("host2", "shared"),
+		probe("", "merged"), // can't be attributed
+	} {
+		attribution.Add(rpt)
+	}
+
+	want := report.Attribution{
+		"shared": report.MakeStringSet("host1", "host2"),
+		"only1":  report.MakeStringSet("host1"),
+	}
+	if !reflect.DeepEqual(want, attribution) {
+		t.Errorf("want %v, have %v", want, attribution)
+	}
+}
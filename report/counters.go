@@ -114,16 +114,14 @@ func (c Counters) String() string {
 	return buf.String()
 }
 
-// DeepEqual tests equality with other Counters
+// DeepEqual tests equality with other Counters. Nil and empty Counters are
+// equal, as for Sets.
 func (c Counters) DeepEqual(d Counters) bool {
-	if (c.psMap == nil) != (d.psMap == nil) {
+	if c.Size() != d.Size() {
 		return false
-	} else if c.psMap == nil && d.psMap == nil {
-		return true
 	}
-
-	if c.psMap.Size() != d.psMap.Size() {
-		return false
+	if c.Size() == 0 {
+		return true
 	}
 
 	equal := true
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/ugorji/go/codec"
 	"github.com/weaveworks/ps"
@@ -31,7 +32,8 @@ func (c EdgeMetadatas) Copy() EdgeMetadatas {
 	return c
 }
 
-// Add value to the counter 'key'
+// Add value to the counter 'key'. If 'key' is already present the values are
+// merged, keeping the earliest FirstSeen and the latest LastSeen.
 func (c EdgeMetadatas) Add(key string, value EdgeMetadata) EdgeMetadatas {
 	if c.psMap == nil {
 		c = EmptyEdgeMetadatas
@@ -44,6 +46,16 @@ func (c EdgeMetadatas) Add(key string, value EdgeMetadata) EdgeMetadatas {
 	}
 }
 
+// Delete the edge metadata for the given key.
+func (c EdgeMetadatas) Delete(key string) EdgeMetadatas {
+	if c.psMap == nil {
+		return EmptyEdgeMetadatas
+	}
+	return EdgeMetadatas{
+		c.psMap.Delete(key),
+	}
+}
+
 // Lookup the counter 'key'
 func (c EdgeMetadatas) Lookup(key string) (EdgeMetadata, bool) {
 	if c.psMap != nil {
@@ -93,8 +105,15 @@ func (c EdgeMetadatas) Merge(other EdgeMetadatas) EdgeMetadatas {
 // Flatten flattens all the EdgeMetadatas in this set and returns the result.
 // The original is not modified.
 func (c EdgeMetadatas) Flatten() EdgeMetadata {
-	result := EdgeMetadata{}
+	var (
+		result EdgeMetadata
+		first  = true
+	)
 	c.ForEach(func(_ string, e EdgeMetadata) {
+		if first {
+			result, first = e.Copy(), false
+			return
+		}
 		result = result.Flatten(e)
 	})
 	return result
@@ -221,9 +240,51 @@ type EdgeMetadata struct {
 	IngressPacketCount *uint64 `json:"ingress_packet_count,omitempty"`
 	EgressByteCount    *uint64 `json:"egress_byte_count,omitempty"`  // Transport layer
 	IngressByteCount   *uint64 `json:"ingress_byte_count,omitempty"` // Transport layer
+
+	// Protocol breakdown: how many TCP connections and UDP flows make up this
+	// edge. WithTCP and WithUDP are true if any of that protocol were seen,
+	// so a single edge can carry both. The counts are of the connections
+	// seen at one time: merging the same edge from different times keeps the
+	// larger count, instead of counting a long-lived connection once per
+	// report.
+	WithTCP        bool    `json:"with_tcp,omitempty"`
+	TCPConnections *uint64 `json:"tcp_connections,omitempty"`
+	WithUDP        bool    `json:"with_udp,omitempty"`
+	UDPFlows       *uint64 `json:"udp_flows,omitempty"`
+
+	// TLS is true if the connections behind this edge are thought to be
+	// encrypted. Unless TLSConfirmed is also true, this is only inferred,
+	// e.g. from one end being on a port usually used for TLS.
+	TLS          bool `json:"tls,omitempty"`
+	TLSConfirmed bool `json:"tls_confirmed,omitempty"`
+
+	// FirstSeen is when the probe first saw the connection behind this edge.
+	// It is zero if unknown.
+	FirstSeen time.Time `json:"first_seen,omitempty"`
+	// LastSeen is when the probe last saw that connection. It is zero if
+	// unknown.
+	LastSeen time.Time `json:"last_seen,omitempty"`
+
+	// ConnectionSamples are some of the connections behind this edge, so
+	// that they can be looked at individually; at most
+	// MaxConnectionSamples of them, however many the counts above add up to.
+	// They are only reported if the probe was asked to.
+	ConnectionSamples []ConnectionSample `json:"connection_samples,omitempty"`
 	dummySelfer
 }
 
+// MaxConnectionSamples is the most ConnectionSamples an EdgeMetadata keeps.
+const MaxConnectionSamples = 10
+
+// ConnectionSample is a single connection behind an edge, from the point of
+// view of the edge's source. State is the conntrack state of the connection,
+// and empty if unknown.
+type ConnectionSample struct {
+	LocalPort  uint16 `json:"local_port"`
+	RemotePort uint16 `json:"remote_port"`
+	State      string `json:"state,omitempty"`
+}
+
 // String returns a string representation of this EdgeMetadata
 // Helps with our use of Spew and diff.
 func (e EdgeMetadata) String() string {
@@ -239,11 +300,29 @@ EgressPacketCount:  %v,
 IngressPacketCount: %v,
 EgressByteCount:    %v,
 IngressByteCount:   %v,
+WithTCP:              %v,
+TCPConnections:       %v,
+WithUDP:              %v,
+UDPFlows:             %v,
+TLS:                  %v,
+TLSConfirmed:         %v,
+FirstSeen:            %v,
+LastSeen:             %v,
+ConnectionSamples:    %v,
 }`,
 		f(e.EgressPacketCount),
 		f(e.IngressPacketCount),
 		f(e.EgressByteCount),
-		f(e.IngressByteCount))
+		f(e.IngressByteCount),
+		e.WithTCP,
+		f(e.TCPConnections),
+		e.WithUDP,
+		f(e.UDPFlows),
+		e.TLS,
+		e.TLSConfirmed,
+		e.FirstSeen,
+		e.LastSeen,
+		e.ConnectionSamples)
 }
 
 // Copy returns a value copy of the EdgeMetadata.
@@ -253,6 +332,19 @@ func (e EdgeMetadata) Copy() EdgeMetadata {
 		IngressPacketCount: cpu64ptr(e.IngressPacketCount),
 		EgressByteCount:    cpu64ptr(e.EgressByteCount),
 		IngressByteCount:   cpu64ptr(e.IngressByteCount),
+
+		WithTCP:        e.WithTCP,
+		TCPConnections: cpu64ptr(e.TCPConnections),
+		WithUDP:        e.WithUDP,
+		UDPFlows:       cpu64ptr(e.UDPFlows),
+
+		TLS:          e.TLS,
+		TLSConfirmed: e.TLSConfirmed,
+
+		FirstSeen: e.FirstSeen,
+		LastSeen:  e.LastSeen,
+
+		ConnectionSamples: mergeConnectionSamples(nil, e.ConnectionSamples),
 	}
 }
 
@@ -263,6 +355,19 @@ func (e EdgeMetadata) Reversed() EdgeMetadata {
 		IngressPacketCount: cpu64ptr(e.EgressPacketCount),
 		EgressByteCount:    cpu64ptr(e.IngressByteCount),
 		IngressByteCount:   cpu64ptr(e.EgressByteCount),
+
+		WithTCP:        e.WithTCP,
+		TCPConnections: cpu64ptr(e.TCPConnections),
+		WithUDP:        e.WithUDP,
+		UDPFlows:       cpu64ptr(e.UDPFlows),
+
+		TLS:          e.TLS,
+		TLSConfirmed: e.TLSConfirmed,
+
+		FirstSeen: e.FirstSeen,
+		LastSeen:  e.LastSeen,
+
+		ConnectionSamples: reversedConnectionSamples(e.ConnectionSamples),
 	}
 }
 
@@ -283,6 +388,15 @@ func (e EdgeMetadata) Merge(other EdgeMetadata) EdgeMetadata {
 	cp.IngressPacketCount = merge(cp.IngressPacketCount, other.IngressPacketCount, sum)
 	cp.EgressByteCount = merge(cp.EgressByteCount, other.EgressByteCount, sum)
 	cp.IngressByteCount = merge(cp.IngressByteCount, other.IngressByteCount, sum)
+	cp.WithTCP = cp.WithTCP || other.WithTCP
+	cp.TCPConnections = merge(cp.TCPConnections, other.TCPConnections, max)
+	cp.WithUDP = cp.WithUDP || other.WithUDP
+	cp.UDPFlows = merge(cp.UDPFlows, other.UDPFlows, max)
+	cp.TLS = cp.TLS || other.TLS
+	cp.TLSConfirmed = cp.TLSConfirmed || other.TLSConfirmed
+	cp.FirstSeen = earliest(cp.FirstSeen, other.FirstSeen)
+	cp.LastSeen = latest(cp.LastSeen, other.LastSeen)
+	cp.ConnectionSamples = mergeConnectionSamples(cp.ConnectionSamples, other.ConnectionSamples)
 	return cp
 }
 
@@ -295,9 +409,67 @@ func (e EdgeMetadata) Flatten(other EdgeMetadata) EdgeMetadata {
 	cp.IngressPacketCount = merge(cp.IngressPacketCount, other.IngressPacketCount, sum)
 	cp.EgressByteCount = merge(cp.EgressByteCount, other.EgressByteCount, sum)
 	cp.IngressByteCount = merge(cp.IngressByteCount, other.IngressByteCount, sum)
+	cp.WithTCP = cp.WithTCP || other.WithTCP
+	cp.TCPConnections = merge(cp.TCPConnections, other.TCPConnections, sum)
+	cp.WithUDP = cp.WithUDP || other.WithUDP
+	cp.UDPFlows = merge(cp.UDPFlows, other.UDPFlows, sum)
+	// Unlike in Merge, these are different connections, so the edge is only
+	// encrypted if both of them are.
+	cp.TLS = cp.TLS && other.TLS
+	cp.TLSConfirmed = cp.TLSConfirmed && other.TLSConfirmed
+	cp.FirstSeen = earliest(cp.FirstSeen, other.FirstSeen)
+	cp.LastSeen = latest(cp.LastSeen, other.LastSeen)
+	cp.ConnectionSamples = mergeConnectionSamples(cp.ConnectionSamples, other.ConnectionSamples)
 	return cp
 }
 
+// Coalesce combines two EdgeMetadatas for the same connection seen as two
+// different edges, e.g. either side of NAT, and returns the result. The
+// receiver is not modified. As each edge already saw the whole connection,
+// counters take the larger value rather than adding up.
+func (e EdgeMetadata) Coalesce(other EdgeMetadata) EdgeMetadata {
+	cp := e.Merge(other)
+	cp.EgressPacketCount = merge(cpu64ptr(e.EgressPacketCount), other.EgressPacketCount, max)
+	cp.IngressPacketCount = merge(cpu64ptr(e.IngressPacketCount), other.IngressPacketCount, max)
+	cp.EgressByteCount = merge(cpu64ptr(e.EgressByteCount), other.EgressByteCount, max)
+	cp.IngressByteCount = merge(cpu64ptr(e.IngressByteCount), other.IngressByteCount, max)
+	return cp
+}
+
+// mergeConnectionSamples returns a new list of the samples in dst followed
+// by those in src, without duplicates, up to MaxConnectionSamples of them.
+func mergeConnectionSamples(dst, src []ConnectionSample) []ConnectionSample {
+	if len(dst) == 0 && len(src) == 0 {
+		return nil
+	}
+	result := make([]ConnectionSample, 0, MaxConnectionSamples)
+	seen := map[ConnectionSample]struct{}{}
+	for _, samples := range [][]ConnectionSample{dst, src} {
+		for _, sample := range samples {
+			if len(result) == MaxConnectionSamples {
+				return result
+			}
+			if _, ok := seen[sample]; ok {
+				continue
+			}
+			seen[sample] = struct{}{}
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+func reversedConnectionSamples(samples []ConnectionSample) []ConnectionSample {
+	if len(samples) == 0 {
+		return nil
+	}
+	result := make([]ConnectionSample, len(samples))
+	for i, sample := range samples {
+		result[i] = ConnectionSample{LocalPort: sample.RemotePort, RemotePort: sample.LocalPort, State: sample.State}
+	}
+	return result
+}
+
 func merge(dst, src *uint64, op func(uint64, uint64) uint64) *uint64 {
 	if src == nil {
 		return dst
@@ -309,6 +481,22 @@ func merge(dst, src *uint64, op func(uint64, uint64) uint64) *uint64 {
 	return dst
 }
 
+// earliest returns the earlier of two times, ignoring zero times.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// latest returns the later of two times.
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
 func sum(dst, src uint64) uint64 {
 	return dst + src
 }
@@ -3,6 +3,7 @@ package report
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/ugorji/go/codec"
 
@@ -193,6 +194,28 @@ func TestEdgeMetadataFlatten(t *testing.T) {
 	}
 }
 
+func TestEdgeMetadataCoalesce(t *testing.T) {
+	have := (EdgeMetadata{
+		EgressPacketCount: newu64(4),
+		EgressByteCount:   newu64(100),
+		TCPConnections:    newu64(1),
+	}).Coalesce(EdgeMetadata{
+		EgressPacketCount: newu64(2),
+		EgressByteCount:   newu64(50),
+		IngressByteCount:  newu64(10),
+		TCPConnections:    newu64(1),
+	})
+	want := EdgeMetadata{
+		EgressPacketCount: newu64(4),
+		EgressByteCount:   newu64(100),
+		IngressByteCount:  newu64(10),
+		TCPConnections:    newu64(1),
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
 func TestEdgeMetadataReversed(t *testing.T) {
 	have := EdgeMetadata{
 		EgressPacketCount: newu64(1),
@@ -310,3 +333,153 @@ func TestEdgeMetadataDeepEquals(t *testing.T) {
 		}
 	}
 }
+
+func TestEdgeMetadataMergeFirstSeen(t *testing.T) {
+	var (
+		earlier = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Minute)
+	)
+	for _, c := range []struct {
+		a, b, want time.Time
+	}{
+		{earlier, later, earlier},
+		{later, earlier, earlier},
+		{time.Time{}, later, later},
+		{later, time.Time{}, later},
+	} {
+		have := EdgeMetadata{FirstSeen: c.a}.Merge(EdgeMetadata{FirstSeen: c.b}).FirstSeen
+		if !have.Equal(c.want) {
+			t.Errorf("merging %v and %v: want %v, have %v", c.a, c.b, c.want, have)
+		}
+	}
+}
+
+func TestEdgeMetadataMergeLastSeen(t *testing.T) {
+	var (
+		earlier = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Minute)
+	)
+	for _, c := range []struct {
+		a, b, want time.Time
+	}{
+		{earlier, later, later},
+		{later, earlier, later},
+		{time.Time{}, earlier, earlier},
+		{later, time.Time{}, later},
+	} {
+		have := EdgeMetadata{LastSeen: c.a}.Merge(EdgeMetadata{LastSeen: c.b}).LastSeen
+		if !have.Equal(c.want) {
+			t.Errorf("merging %v and %v: want %v, have %v", c.a, c.b, c.want, have)
+		}
+	}
+}
+
+func TestEdgeMetadataMergeProtocols(t *testing.T) {
+	const pair = "hostA|:192.168.1.1:12345|:192.168.1.2:53"
+	have := EmptyEdgeMetadatas.
+		Add(pair, EdgeMetadata{WithTCP: true, TCPConnections: newu64(3)}).
+		Add(pair, EdgeMetadata{WithUDP: true, UDPFlows: newu64(12)})
+	if have.Size() != 1 {
+		t.Fatalf("expected a single edge, got %v", have)
+	}
+	edge, _ := have.Lookup(pair)
+	want := EdgeMetadata{
+		WithTCP:        true,
+		TCPConnections: newu64(3),
+		WithUDP:        true,
+		UDPFlows:       newu64(12),
+	}
+	if !reflect.DeepEqual(want, edge) {
+		t.Error(test.Diff(want, edge))
+	}
+
+	summed := edge.Flatten(EdgeMetadata{WithUDP: true, UDPFlows: newu64(1)})
+	if !summed.WithTCP || *summed.TCPConnections != 3 || *summed.UDPFlows != 13 {
+		t.Errorf("expected per-protocol sums, got %v", summed)
+	}
+	// Merging is of the same edge at different times, so counts don't add up.
+	merged := edge.Merge(EdgeMetadata{WithTCP: true, TCPConnections: newu64(2)})
+	if *merged.TCPConnections != 3 || *merged.UDPFlows != 12 {
+		t.Errorf("expected the larger counts, got %v", merged)
+	}
+	if (EdgeMetadata{WithTCP: true}).Merge(EdgeMetadata{}).WithUDP {
+		t.Error("WithUDP should stay false without UDP flows")
+	}
+}
+
+func TestEdgeMetadataMergeTLS(t *testing.T) {
+	var (
+		plaintext = EdgeMetadata{}
+		inferred  = EdgeMetadata{TLS: true}
+		confirmed = EdgeMetadata{TLS: true, TLSConfirmed: true}
+	)
+	// Merging the same connections keeps what is known of any of them, but
+	// flattening different ones is only encrypted if all of them are.
+	for _, c := range []struct {
+		a, b                    EdgeMetadata
+		merged, mergedConfirmed bool
+		flat, flatConfirmed     bool
+	}{
+		{plaintext, plaintext, false, false, false, false},
+		{plaintext, inferred, true, false, false, false},
+		{inferred, plaintext, true, false, false, false},
+		{inferred, inferred, true, false, true, false},
+		{inferred, confirmed, true, true, true, false},
+		{confirmed, confirmed, true, true, true, true},
+		{confirmed, plaintext, true, true, false, false},
+	} {
+		if have := c.a.Merge(c.b); have.TLS != c.merged || have.TLSConfirmed != c.mergedConfirmed {
+			t.Errorf("merging %v with %v: want TLS %v confirmed %v, have %v %v", c.a, c.b, c.merged, c.mergedConfirmed, have.TLS, have.TLSConfirmed)
+		}
+		if have := c.a.Flatten(c.b); have.TLS != c.flat || have.TLSConfirmed != c.flatConfirmed {
+			t.Errorf("flattening %v with %v: want TLS %v confirmed %v, have %v %v", c.a, c.b, c.flat, c.flatConfirmed, have.TLS, have.TLSConfirmed)
+		}
+	}
+	if have := EmptyEdgeMetadatas.Add("a", confirmed).Add("b", confirmed).Flatten(); !have.TLS || !have.TLSConfirmed {
+		t.Errorf("expected flattening encrypted edges to stay encrypted, got %v", have)
+	}
+	if have := confirmed.Reversed(); !have.TLS || !have.TLSConfirmed {
+		t.Errorf("expected reversing to keep TLS, got %v", have)
+	}
+}
+
+func TestEdgeMetadataConnectionSamples(t *testing.T) {
+	sampled := func(from, to int) EdgeMetadata {
+		var e EdgeMetadata
+		for port := from; port < to; port++ {
+			e = e.Flatten(EdgeMetadata{ConnectionSamples: []ConnectionSample{{LocalPort: uint16(port), RemotePort: 80, State: "ESTABLISHED"}}})
+		}
+		return e
+	}
+
+	// Each connection is kept, up to the cap.
+	few := sampled(40000, 40003)
+	if len(few.ConnectionSamples) != 3 {
+		t.Fatalf("expected 3 samples, got %v", few.ConnectionSamples)
+	}
+	full := sampled(50000, 50000+2*MaxConnectionSamples)
+	if len(full.ConnectionSamples) != MaxConnectionSamples {
+		t.Fatalf("expected %d samples, got %d", MaxConnectionSamples, len(full.ConnectionSamples))
+	}
+	if have := full.ConnectionSamples[0]; have.LocalPort != 50000 || have.RemotePort != 80 || have.State != "ESTABLISHED" {
+		t.Errorf("unexpected sample %v", have)
+	}
+
+	// Merging and flattening never go over it, and the same connection
+	// seen at different times is only sampled once.
+	for _, have := range []EdgeMetadata{few.Merge(full), full.Merge(few), few.Flatten(full)} {
+		if len(have.ConnectionSamples) != MaxConnectionSamples {
+			t.Errorf("expected %d samples, got %d", MaxConnectionSamples, len(have.ConnectionSamples))
+		}
+	}
+	if have := few.Merge(few); !reflect.DeepEqual(few.ConnectionSamples, have.ConnectionSamples) {
+		t.Error(test.Diff(few.ConnectionSamples, have.ConnectionSamples))
+	}
+	if len(full.ConnectionSamples) != MaxConnectionSamples || len(few.ConnectionSamples) != 3 {
+		t.Error("merging modified the receiver or argument")
+	}
+
+	if have := few.Reversed().ConnectionSamples[0]; have.LocalPort != 80 || have.RemotePort != 40000 {
+		t.Errorf("expected reversing to swap the ports, got %v", have)
+	}
+}
@@ -173,6 +173,20 @@ func ParseOverlayNodeID(id string) (overlayPrefix string, peerName string) {
 	return WeaveOverlayPeerPrefix, id
 }
 
+// MakeEdgeID produces an edge ID from the IDs of the nodes at either end.
+func MakeEdgeID(srcNodeID, dstNodeID string) string {
+	return srcNodeID + EdgeDelim + dstNodeID
+}
+
+// ParseEdgeID splits an edge ID into the IDs of the nodes at either end.
+func ParseEdgeID(edgeID string) (srcNodeID, dstNodeID string, ok bool) {
+	fields := strings.SplitN(edgeID, EdgeDelim, 2)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
 // ParseNodeID produces the host ID and remainder (typically an address) from
 // a node ID. Note that hostID may be blank.
 func ParseNodeID(nodeID string) (hostID string, remainder string, ok bool) {
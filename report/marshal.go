@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"io"
 	"io/ioutil"
+	"reflect"
+	"strconv"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/ugorji/go/codec"
@@ -30,6 +32,146 @@ func (rep Report) WriteBinary(w io.Writer, compressionLevel int) error {
 	return nil
 }
 
+// WriteBinaryCompact is like WriteBinary, but leaves out the empty fields of
+// topologies and nodes, such as the empty latest maps, sets and edges most
+// nodes carry. The output is read with MakeFromBinary as usual; the fields
+// left out are decoded as nil rather than empty.
+func (rep Report) WriteBinaryCompact(w io.Writer, compressionLevel int) error {
+	gzwriter, err := gzip.NewWriterLevel(w, compressionLevel)
+	if err != nil {
+		return err
+	}
+	if err = codec.NewEncoder(gzwriter, &codec.MsgpackHandle{}).Encode(rep.compact()); err != nil {
+		return err
+	}
+	gzwriter.Close() // otherwise the content won't get flushed to the output stream
+	return nil
+}
+
+// compact returns the fields of the report keyed by name, as they are
+// encoded, with compacted topologies.
+func (rep Report) compact() map[string]interface{} {
+	var (
+		result = map[string]interface{}{}
+		v      = reflect.ValueOf(&rep).Elem()
+		t      = v.Type()
+	)
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i).Addr().Interface()
+		if topology, ok := field.(*Topology); ok {
+			field = topology.compact()
+		}
+		result[t.Field(i).Name] = field
+	}
+	return result
+}
+
+func (t Topology) compact() map[string]interface{} {
+	var nodes map[string]interface{} // nil Nodes stay nil
+	if t.Nodes != nil {
+		nodes = make(map[string]interface{}, len(t.Nodes))
+		for id, n := range t.Nodes {
+			nodes[id] = n.compact()
+		}
+	}
+	result := map[string]interface{}{"nodes": nodes}
+	if t.Shape != "" {
+		result["shape"] = t.Shape
+	}
+	if t.Label != "" {
+		result["label"] = t.Label
+	}
+	if t.LabelPlural != "" {
+		result["label_plural"] = t.LabelPlural
+	}
+	if len(t.Controls) > 0 {
+		result["controls"] = t.Controls
+	}
+	if len(t.MetadataTemplates) > 0 {
+		result["metadata_templates"] = t.MetadataTemplates
+	}
+	if len(t.MetricTemplates) > 0 {
+		result["metric_templates"] = t.MetricTemplates
+	}
+	if len(t.TableTemplates) > 0 {
+		result["table_templates"] = t.TableTemplates
+	}
+	return result
+}
+
+// compact returns the non-empty fields of the node, keyed as they are
+// encoded. Those with their own encoders are passed by pointer, so the
+// encoders are used.
+func (n Node) compact() map[string]interface{} {
+	result := map[string]interface{}{}
+	if n.ID != "" {
+		result["id"] = n.ID
+	}
+	if n.Topology != "" {
+		result["topology"] = n.Topology
+	}
+	if n.Counters.Size() > 0 {
+		result["counters"] = &n.Counters
+	}
+	if n.Sets.Size() > 0 {
+		result["sets"] = &n.Sets
+	}
+	if len(n.Adjacency) > 0 {
+		result["adjacency"] = n.Adjacency
+	}
+	if n.Edges.Size() > 0 {
+		result["edges"] = &n.Edges
+	}
+	if len(n.Controls.Controls) > 0 || !n.Controls.Timestamp.IsZero() {
+		result["controls"] = &n.Controls
+	}
+	if n.LatestControls.Size() > 0 {
+		result["latestControls"] = &n.LatestControls
+	}
+	if n.Latest.Size() > 0 {
+		result["latest"] = &n.Latest
+	}
+	if len(n.Metrics) > 0 {
+		result["metrics"] = n.Metrics
+	}
+	if n.Parents.Size() > 0 {
+		result["parents"] = &n.Parents
+	}
+	if n.Children.Size() > 0 {
+		result["children"] = &n.Children
+	}
+	return result
+}
+
+// WriteJSON writes a Report as JSON, encoding one field at a time so that the
+// document for a large report is never held in memory in its entirety. The
+// output is the same as encoding the whole Report with a codec.JsonHandle.
+// If an error is returned, w will have been left with a truncated document.
+func (rep Report) WriteJSON(w io.Writer) error {
+	var (
+		handle = &codec.JsonHandle{}
+		v      = reflect.ValueOf(&rep).Elem()
+		t      = v.Type()
+	)
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sep := ","
+		if i == 0 {
+			sep = ""
+		}
+		if _, err := io.WriteString(w, sep+strconv.Quote(t.Field(i).Name)+":"); err != nil {
+			return err
+		}
+		if err := codec.NewEncoder(w, handle).Encode(v.Field(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
 type byteCounter struct {
 	next  io.Reader
 	count *uint64
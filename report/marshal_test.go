@@ -6,7 +6,12 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+	s_reflect "github.com/weaveworks/scope/test/reflect"
 )
 
 func TestRoundtrip(t *testing.T) {
@@ -55,3 +60,54 @@ func TestMoreCompressionMeansSmaller(t *testing.T) {
 		t.Errorf("Compression doesn't change size: %v >= %v", buf1.Len(), buf2.Len())
 	}
 }
+
+func TestWriteJSONMatchesBufferedEncoding(t *testing.T) {
+	r := fixture.Report
+
+	var buffered []byte
+	if err := codec.NewEncoderBytes(&buffered, &codec.JsonHandle{}).Encode(&r); err != nil {
+		t.Fatal(err)
+	}
+	var streamed bytes.Buffer
+	if err := r.WriteJSON(&streamed); err != nil {
+		t.Fatal(err)
+	}
+
+	want, have := report.MakeReport(), report.MakeReport()
+	if err := want.ReadBytes(buffered, &codec.JsonHandle{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := have.ReadBytes(streamed.Bytes(), &codec.JsonHandle{}); err != nil {
+		t.Fatalf("streamed JSON does not decode: %v", err)
+	}
+	if !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestWriteBinaryCompact(t *testing.T) {
+	r := fixture.Report
+
+	var standard, compact bytes.Buffer
+	if err := r.WriteBinary(&standard, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteBinaryCompact(&compact, 0); err != nil {
+		t.Fatal(err)
+	}
+	if compact.Len() >= standard.Len() {
+		t.Errorf("compact encoding isn't smaller: %d >= %d bytes", compact.Len(), standard.Len())
+	}
+
+	want, err := report.MakeFromBinary(&standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := report.MakeFromBinary(&compact)
+	if err != nil {
+		t.Fatalf("compact encoding does not decode: %v", err)
+	}
+	if !s_reflect.DeepEqual(*want, *have) {
+		t.Error(test.Diff(*want, *have))
+	}
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"time"
+)
+
+// MergePolicy decides which value to keep when both nodes being merged have
+// a latest value for the same key.
+type MergePolicy int
+
+// The merge policies understood by Node.MergeWith.
+const (
+	// NewestWins keeps the value with the later timestamp, as Merge does.
+	NewestWins MergePolicy = iota
+	// RightWins keeps the value from the node passed to MergeWith.
+	RightWins
+	// LeftWins keeps the value from the node MergeWith is called on.
+	LeftWins
+	// PreferNonEmpty keeps whichever value is non-empty, falling back to the
+	// newest if both or neither are. Use this when merging a sparse report
+	// into a richer one.
+	PreferNonEmpty
+)
+
+// MergeWith merges two nodes like Merge, but resolves conflicting latest
+// values according to policy rather than by timestamp alone.
+func (n Node) MergeWith(other Node, policy MergePolicy) Node {
+	merged := n.Merge(other)
+	if policy != NewestWins {
+		merged.Latest = n.Latest.mergeWith(other.Latest, policy)
+	}
+	return merged
+}
+
+func (m StringLatestMap) mergeWith(other StringLatestMap, policy MergePolicy) StringLatestMap {
+	output := m
+	other.ForEach(func(key string, timestamp time.Time, value string) {
+		existing, existingTimestamp, ok := output.LookupEntry(key)
+		if !ok || policy.preferRight(existing, existingTimestamp, value, timestamp) {
+			output = output.Set(key, timestamp, value)
+		}
+	})
+	return output
+}
+
+func (p MergePolicy) preferRight(left string, leftTimestamp time.Time, right string, rightTimestamp time.Time) bool {
+	switch p {
+	case RightWins:
+		return true
+	case LeftWins:
+		return false
+	case PreferNonEmpty:
+		if (left == "") != (right == "") {
+			return right != ""
+		}
+	}
+	return leftTimestamp.Before(rightTimestamp)
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"strings"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// NamespaceDelim separates the namespace of a node metadata key, naming the
+// reporter which wrote it, from the rest of the key: "endpoint:addr" is the
+// "addr" key in the "endpoint" namespace. Namespacing keys stops two
+// reporters both writing, say, "name" from clobbering each other's values
+// when their nodes are merged. Keys outside any namespace use "_" as a
+// separator, e.g. "docker_image_id", so never contain it.
+const NamespaceDelim = ":"
+
+// NamespacedKey returns key in the given namespace.
+func NamespacedKey(namespace, key string) string {
+	return namespace + NamespaceDelim + key
+}
+
+// ParseNamespacedKey splits a namespaced key into its namespace and the rest
+// of the key.
+func ParseNamespacedKey(key string) (namespace, rest string, ok bool) {
+	fields := strings.SplitN(key, NamespaceDelim, 2)
+	if len(fields) != 2 || fields[0] == "" {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// Set returns a fresh copy of n, with key in the given namespace set to
+// value in the Latest metadata.
+func (n Node) Set(namespace, key, value string) Node {
+	n.Latest = n.Latest.Set(NamespacedKey(namespace, key), mtime.Now(), value)
+	return n
+}
+
+// Get returns the latest value of key in the given namespace.
+func (n Node) Get(namespace, key string) (string, bool) {
+	return n.Latest.Lookup(NamespacedKey(namespace, key))
+}
+
+// The keys the endpoint reporter wrote in version 1 of the report format,
+// before they were namespaced. They are defined by the endpoint package,
+// which can't be imported from here.
+const (
+	v1EndpointNamespace = "endpoint"
+	v1EndpointEnvPrefix = "env_"
+	v1EndpointWarning   = "endpoint_warning"
+)
+
+var v1EndpointKeys = map[string]struct{}{
+	"addr":              {},
+	"port":              {},
+	"conntracked":       {},
+	"eBPF":              {},
+	"procspied":         {},
+	"reverse_dns_names": {},
+	"snooped_dns_names": {},
+	"service":           {},
+	"uid":               {},
+	"user":              {},
+	"start_time":        {},
+	"send_queue":        {},
+	"receive_queue":     {},
+	"congestion_window": {},
+	"network_namespace": {},
+}
+
+// UpgradeSchema returns r translated to the current version of the report
+// format, so reports from older probes render like new ones. Reports from
+// probes which predate SchemaVersion are taken to be version 1.
+func (r Report) UpgradeSchema() Report {
+	if r.SchemaVersion >= SchemaVersion {
+		return r
+	}
+	r.Endpoint = r.Endpoint.renameKeys(upgradeV1EndpointKey)
+	r.Host = r.Host.renameKeys(func(key string) string {
+		if key == v1EndpointWarning {
+			return NamespacedKey(v1EndpointNamespace, "warning")
+		}
+		return key
+	})
+	r.SchemaVersion = SchemaVersion
+	return r
+}
+
+func upgradeV1EndpointKey(key string) string {
+	if _, ok := v1EndpointKeys[key]; ok || strings.HasPrefix(key, v1EndpointEnvPrefix) {
+		return NamespacedKey(v1EndpointNamespace, key)
+	}
+	return key
+}
+
+// renameKeys returns a copy of t with the Latest and Sets keys of its nodes,
+// and its metadata templates, renamed.
+func (t Topology) renameKeys(rename func(string) string) Topology {
+	nodes := make(Nodes, len(t.Nodes))
+	for id, n := range t.Nodes {
+		latest := EmptyStringLatestMap
+		n.Latest.ForEach(func(key string, ts time.Time, value string) {
+			latest = latest.Set(rename(key), ts, value)
+		})
+		n.Latest = latest
+
+		sets := EmptySets
+		for _, key := range n.Sets.Keys() {
+			values, _ := n.Sets.Lookup(key)
+			sets = sets.Add(rename(key), values)
+		}
+		n.Sets = sets
+		nodes[id] = n
+	}
+	t.Nodes = nodes
+
+	if len(t.MetadataTemplates) > 0 {
+		templates := make(MetadataTemplates, len(t.MetadataTemplates))
+		for _, template := range t.MetadataTemplates {
+			template.ID = rename(template.ID)
+			templates[template.ID] = template
+		}
+		t.MetadataTemplates = templates
+	}
+	return t
+}
@@ -0,0 +1,110 @@
+package report_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestNamespacedKeysSurviveMerge(t *testing.T) {
+	mtime.NowForce(time.Now())
+	defer mtime.NowReset()
+
+	docker := report.MakeNode("a").Set("docker", "name", "web")
+	mtime.NowForce(mtime.Now().Add(time.Second))
+	process := report.MakeNode("a").Set("process", "name", "nginx")
+
+	for _, merged := range []report.Node{docker.Merge(process), process.Merge(docker)} {
+		if name, ok := merged.Get("docker", "name"); !ok || name != "web" {
+			t.Errorf("docker name: want %q, have %q", "web", name)
+		}
+		if name, ok := merged.Get("process", "name"); !ok || name != "nginx" {
+			t.Errorf("process name: want %q, have %q", "nginx", name)
+		}
+		if name, ok := merged.Latest.Lookup(report.NamespacedKey("docker", "name")); !ok || name != "web" {
+			t.Errorf("expected Set to write the namespaced key, got %v", merged.Latest)
+		}
+		if _, ok := merged.Latest.Lookup("name"); ok {
+			t.Errorf("expected no key outside a namespace, got %v", merged.Latest)
+		}
+	}
+}
+
+func TestParseNamespacedKey(t *testing.T) {
+	for _, c := range []struct {
+		key, namespace, rest string
+		ok                   bool
+	}{
+		{report.NamespacedKey("endpoint", "addr"), "endpoint", "addr", true},
+		{"docker_container_id", "", "", false},
+		{"host_node_id", "", "", false},
+		{"addr", "", "", false},
+		{":addr", "", "", false},
+	} {
+		namespace, rest, ok := report.ParseNamespacedKey(c.key)
+		if namespace != c.namespace || rest != c.rest || ok != c.ok {
+			t.Errorf("%q: want %q %q %v, have %q %q %v", c.key, c.namespace, c.rest, c.ok, namespace, rest, ok)
+		}
+	}
+}
+
+func TestUpgradeSchema(t *testing.T) {
+	mtime.NowForce(time.Now())
+	defer mtime.NowReset()
+
+	hostNodeID := report.MakeHostNodeID("host1")
+	rpt := report.MakeReport()
+	rpt.SchemaVersion = 1
+	rpt.Endpoint.AddNode(report.MakeNodeWith("a", map[string]string{
+		"addr":            "10.0.0.1",
+		"port":            "80",
+		"env_HOME":        "/root",
+		"pid":             "42",
+		report.HostNodeID: hostNodeID,
+	}).WithSet("snooped_dns_names", report.MakeStringSet("example.com")))
+	rpt.Host = rpt.Host.WithMetadataTemplates(report.MetadataTemplates{
+		"endpoint_warning": {ID: "endpoint_warning", Label: "Warning"},
+	})
+	rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{
+		"endpoint_warning": "partial",
+	}))
+
+	have := rpt.UpgradeSchema()
+	if have.SchemaVersion != report.SchemaVersion {
+		t.Errorf("want schema version %d, have %d", report.SchemaVersion, have.SchemaVersion)
+	}
+	endpoint := have.Endpoint.Nodes["a"]
+	for key, want := range map[string]string{
+		"endpoint:addr":     "10.0.0.1",
+		"endpoint:port":     "80",
+		"endpoint:env_HOME": "/root",
+		"pid":               "42",
+		report.HostNodeID:   hostNodeID,
+	} {
+		if value, _ := endpoint.Latest.Lookup(key); value != want {
+			t.Errorf("%s: want %q, have %q", key, want, value)
+		}
+	}
+	if _, ok := endpoint.Latest.Lookup("addr"); ok {
+		t.Errorf("expected the v1 key to be renamed, got %v", endpoint.Latest)
+	}
+	if _, ok := endpoint.Sets.Lookup("endpoint:snooped_dns_names"); !ok {
+		t.Errorf("expected the v1 set to be renamed, got %v", endpoint.Sets)
+	}
+	if value, _ := have.Host.Nodes[hostNodeID].Latest.Lookup("endpoint:warning"); value != "partial" {
+		t.Errorf("expected the warning to be renamed, got %v", have.Host.Nodes[hostNodeID].Latest)
+	}
+	if _, ok := have.Host.MetadataTemplates["endpoint:warning"]; !ok {
+		t.Errorf("expected the warning template to be renamed, got %v", have.Host.MetadataTemplates)
+	}
+
+	// Current reports are left alone.
+	current := report.MakeReport()
+	current.SchemaVersion = report.SchemaVersion
+	current.Endpoint.AddNode(report.MakeNodeWith("a", map[string]string{"addr": "10.0.0.1"}))
+	if _, ok := current.UpgradeSchema().Endpoint.Nodes["a"].Latest.Lookup("addr"); !ok {
+		t.Errorf("expected a current report to be left alone")
+	}
+}
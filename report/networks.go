@@ -30,6 +30,20 @@ func (n Networks) Contains(ip net.IP) bool {
 	return false
 }
 
+// nat64Prefix is the well-known prefix of RFC 6052, under which NAT64
+// gateways give IPv6-only hosts the addresses of the IPv4 internet.
+var nat64Prefix = net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+
+// NAT64Embedded returns the IPv4 address embedded in an IPv6 address in the
+// NAT64 well-known prefix, 64:ff9b::/96. Connections to such an address are
+// really to the IPv4 one.
+func NAT64Embedded(ip net.IP) (net.IP, bool) {
+	if ip.To4() != nil || !nat64Prefix.Contains(ip) {
+		return nil, false
+	}
+	return net.IPv4(ip[12], ip[13], ip[14], ip[15]), true
+}
+
 // LocalAddresses returns a list of the local IP addresses.
 func LocalAddresses() ([]net.IP, error) {
 	result := []net.IP{}
@@ -50,6 +50,25 @@ func (m mockAddr) String() string {
 	return string(m)
 }
 
+func TestNAT64Embedded(t *testing.T) {
+	for addr, want := range map[string]string{
+		"64:ff9b::808:808":   "8.8.8.8",
+		"64:ff9b::c000:221":  "192.0.2.33",
+		"64:ff9b:1::808:808": "",
+		"2001:db8::808:808":  "",
+		"8.8.8.8":            "",
+		"::ffff:8.8.8.8":     "",
+	} {
+		have := ""
+		if v4, ok := report.NAT64Embedded(net.ParseIP(addr)); ok {
+			have = v4.String()
+		}
+		if have != want {
+			t.Errorf("%s: want %q, have %q", addr, want, have)
+		}
+	}
+}
+
 func TestAddLocal(t *testing.T) {
 	oldInterfaceByNameStub := report.InterfaceByNameStub
 	defer func() { report.InterfaceByNameStub = oldInterfaceByNameStub }()
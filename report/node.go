@@ -125,7 +125,8 @@ func (n Node) WithAdjacent(a ...string) Node {
 }
 
 // WithEdge returns a fresh copy of n, with 'dst' added to Adjacency and md
-// added to EdgeMetadata.
+// added to EdgeMetadata. Re-adding an edge merges the metadata, so the
+// earliest FirstSeen and latest LastSeen are kept.
 func (n Node) WithEdge(dst string, md EdgeMetadata) Node {
 	n.Adjacency = n.Adjacency.Add(dst)
 	n.Edges = n.Edges.Add(dst, md)
@@ -186,8 +187,8 @@ func (n Node) WithChild(child Node) Node {
 	return n
 }
 
-// Merge mergses the individual components of a node and returns a
-// fresh node.
+// Merge merges the individual components of a node and returns a
+// fresh node. Neither n nor other is modified.
 func (n Node) Merge(other Node) Node {
 	id := n.ID
 	if id == "" {
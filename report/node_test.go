@@ -159,3 +159,110 @@ func TestMergeNodes(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeNodesWithPolicy(t *testing.T) {
+	var (
+		earlier = time.Now()
+		later   = earlier.Add(time.Second)
+		rich    = report.MakeNode("1").WithTopology(report.Process).
+			WithLatest(Name, later, "curl").
+			WithLatest(PID, earlier, "23128").
+			WithLatest(Domain, earlier, "example.com")
+		sparse = report.MakeNode("1").WithTopology(report.Process).
+			WithLatest(Name, earlier, "wget").
+			WithLatest(PID, later, "").
+			WithLatest("cmdline", earlier, "wget -q")
+	)
+	for _, c := range []struct {
+		policy report.MergePolicy
+		want   map[string]string
+	}{
+		{report.NewestWins, map[string]string{Name: "curl", PID: "", Domain: "example.com", "cmdline": "wget -q"}},
+		{report.RightWins, map[string]string{Name: "wget", PID: "", Domain: "example.com", "cmdline": "wget -q"}},
+		{report.LeftWins, map[string]string{Name: "curl", PID: "23128", Domain: "example.com", "cmdline": "wget -q"}},
+		{report.PreferNonEmpty, map[string]string{Name: "curl", PID: "23128", Domain: "example.com", "cmdline": "wget -q"}},
+	} {
+		have := rich.MergeWith(sparse, c.policy)
+		if have.Latest.Size() != len(c.want) {
+			t.Errorf("policy %d: expected %d latest values, got %v", c.policy, len(c.want), have.Latest)
+		}
+		for key, want := range c.want {
+			if value, ok := have.Latest.Lookup(key); !ok || value != want {
+				t.Errorf("policy %d: expected %s=%q, got %q", c.policy, key, want, value)
+			}
+		}
+	}
+
+	// An empty value on the left is replaced by a non-empty one on the
+	// right, even when it is newer.
+	have := sparse.MergeWith(rich, report.PreferNonEmpty)
+	if pid, _ := have.Latest.Lookup(PID); pid != "23128" {
+		t.Errorf("expected non-empty pid to be kept, got %q", pid)
+	}
+	if name, _ := have.Latest.Lookup(Name); name != "curl" {
+		t.Errorf("expected newest name when both are non-empty, got %q", name)
+	}
+
+	// Merge is unchanged.
+	if want, have := rich.Merge(sparse), rich.MergeWith(sparse, report.NewestWins); !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestNodeWithEdgeKeepsEarliestFirstSeen(t *testing.T) {
+	var (
+		earlier = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Minute)
+	)
+	for _, times := range [][2]time.Time{{earlier, later}, {later, earlier}} {
+		node := report.MakeNode("local").
+			WithEdge("remote", report.EdgeMetadata{FirstSeen: times[0]}).
+			WithEdge("remote", report.EdgeMetadata{FirstSeen: times[1]})
+		if want, have := report.MakeIDList("remote"), node.Adjacency; !reflect.DeepEqual(want, have) {
+			t.Errorf("adjacency: %s", test.Diff(want, have))
+		}
+		edge, ok := node.Edges.Lookup("remote")
+		if !ok {
+			t.Fatalf("missing edge to remote")
+		}
+		if !edge.FirstSeen.Equal(earlier) {
+			t.Errorf("adding %v then %v: want %v, have %v", times[0], times[1], earlier, edge.FirstSeen)
+		}
+	}
+}
+
+func TestMergeNodeLeavesInputsUnchanged(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	// Both nodes share every key, so that each part of the node is merged
+	// rather than just picked from one side.
+	node := func(pid string, count int, at time.Time, bytes uint64) report.Node {
+		return report.MakeNode("a").
+			WithLatest(PID, at, pid).
+			WithCounters(map[string]int{"count": count}).
+			WithSet("ips", report.MakeStringSet(pid)).
+			WithMetric("cpu", report.MakeSingletonMetric(at, float64(count))).
+			WithEdge("b", report.EdgeMetadata{EgressByteCount: &bytes}).
+			WithParents(report.EmptySets.Add("host", report.MakeStringSet(pid))).
+			WithChild(report.MakeNode(pid))
+	}
+	a := node("1", 1, now, 10)
+	b := node("2", 2, now.Add(time.Second), 20)
+
+	merged := a.Merge(b)
+	if pid, _ := merged.Latest.Lookup(PID); pid != "2" {
+		t.Errorf("expected newest pid, got %q", pid)
+	}
+	if count, _ := merged.Counters.Lookup("count"); count != 3 {
+		t.Errorf("expected summed counter, got %d", count)
+	}
+
+	if want, have := node("1", 1, now, 10), a; !reflect.DeepEqual(want, have) {
+		t.Errorf("receiver changed: %s", test.Diff(want, have))
+	}
+	if want, have := node("2", 2, now.Add(time.Second), 20), b; !reflect.DeepEqual(want, have) {
+		t.Errorf("argument changed: %s", test.Diff(want, have))
+	}
+}
@@ -0,0 +1,677 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// ProtobufContentType is the content type of reports encoded as protocol
+// buffers, as defined in report.proto.
+const ProtobufContentType = "application/x-protobuf"
+
+// WriteProto writes a Report as a protocol buffer (see report.proto),
+// gzipped unless compressionLevel is gzip.NoCompression.
+func (rep Report) WriteProto(w io.Writer, compressionLevel int) error {
+	buf, err := proto.Marshal(rep.toProto())
+	if err != nil {
+		return err
+	}
+	if compressionLevel == gzip.NoCompression {
+		_, err = w.Write(buf)
+		return err
+	}
+	gzwriter, err := gzip.NewWriterLevel(w, compressionLevel)
+	if err != nil {
+		return err
+	}
+	if _, err := gzwriter.Write(buf); err != nil {
+		return err
+	}
+	return gzwriter.Close()
+}
+
+// ReadProto reads a Report encoded as a protocol buffer, decompressing it
+// first if gzipped is true.
+func (rep *Report) ReadProto(r io.Reader, gzipped bool) error {
+	if gzipped {
+		var err error
+		if r, err = gzip.NewReader(r); err != nil {
+			return err
+		}
+	}
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var p protoReport
+	if err := proto.Unmarshal(buf, &p); err != nil {
+		return err
+	}
+	*rep = p.report()
+	return nil
+}
+
+// MakeFromProto constructs a Report from a gzipped protocol buffer.
+func MakeFromProto(buf []byte) (*Report, error) {
+	rep := MakeReport()
+	if err := rep.ReadProto(bytes.NewReader(buf), true); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// The types below are the messages in report.proto, in the shape
+// protoc-gen-go gives them, so that the proto package can encode them.
+
+type protoReport struct {
+	Endpoint       *protoTopology     `protobuf:"bytes,1,opt,name=endpoint"`
+	Process        *protoTopology     `protobuf:"bytes,2,opt,name=process"`
+	Container      *protoTopology     `protobuf:"bytes,3,opt,name=container"`
+	Pod            *protoTopology     `protobuf:"bytes,4,opt,name=pod"`
+	Service        *protoTopology     `protobuf:"bytes,5,opt,name=service"`
+	Deployment     *protoTopology     `protobuf:"bytes,6,opt,name=deployment"`
+	ReplicaSet     *protoTopology     `protobuf:"bytes,7,opt,name=replica_set"`
+	ContainerImage *protoTopology     `protobuf:"bytes,8,opt,name=container_image"`
+	Host           *protoTopology     `protobuf:"bytes,9,opt,name=host"`
+	ECSTask        *protoTopology     `protobuf:"bytes,10,opt,name=ecs_task"`
+	ECSService     *protoTopology     `protobuf:"bytes,11,opt,name=ecs_service"`
+	Overlay        *protoTopology     `protobuf:"bytes,12,opt,name=overlay"`
+	Sampling       *protoSampling     `protobuf:"bytes,13,opt,name=sampling"`
+	Window         int64              `protobuf:"varint,14,opt,name=window"`
+	Shortcut       bool               `protobuf:"varint,15,opt,name=shortcut"`
+	Plugins        []*protoPluginSpec `protobuf:"bytes,16,rep,name=plugins"`
+	Timestamp      int64              `protobuf:"varint,17,opt,name=timestamp"`
+	HostID         string             `protobuf:"bytes,18,opt,name=host_id"`
+	ProbeVersion   string             `protobuf:"bytes,19,opt,name=probe_version"`
+	SchemaVersion  int64              `protobuf:"varint,20,opt,name=schema_version"`
+	ID             string             `protobuf:"bytes,21,opt,name=id"`
+}
+
+type protoSampling struct {
+	Count uint64 `protobuf:"varint,1,opt,name=count"`
+	Total uint64 `protobuf:"varint,2,opt,name=total"`
+}
+
+type protoPluginSpec struct {
+	ID          string   `protobuf:"bytes,1,opt,name=id"`
+	Label       string   `protobuf:"bytes,2,opt,name=label"`
+	Description string   `protobuf:"bytes,3,opt,name=description"`
+	Interfaces  []string `protobuf:"bytes,4,rep,name=interfaces"`
+	APIVersion  string   `protobuf:"bytes,5,opt,name=api_version"`
+	Status      string   `protobuf:"bytes,6,opt,name=status"`
+}
+
+type protoTopology struct {
+	Shape             string                        `protobuf:"bytes,1,opt,name=shape"`
+	Label             string                        `protobuf:"bytes,2,opt,name=label"`
+	LabelPlural       string                        `protobuf:"bytes,3,opt,name=label_plural"`
+	Nodes             []*protoNodeEntry             `protobuf:"bytes,4,rep,name=nodes"`
+	Controls          []*protoControlEntry          `protobuf:"bytes,5,rep,name=controls"`
+	MetadataTemplates []*protoMetadataTemplateEntry `protobuf:"bytes,6,rep,name=metadata_templates"`
+	MetricTemplates   []*protoMetricTemplateEntry   `protobuf:"bytes,7,rep,name=metric_templates"`
+	TableTemplates    []*protoTableTemplateEntry    `protobuf:"bytes,8,rep,name=table_templates"`
+}
+
+type protoNodeEntry struct {
+	Key   string     `protobuf:"bytes,1,opt,name=key"`
+	Value *protoNode `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoControl struct {
+	ID    string `protobuf:"bytes,1,opt,name=id"`
+	Human string `protobuf:"bytes,2,opt,name=human"`
+	Icon  string `protobuf:"bytes,3,opt,name=icon"`
+	Rank  int64  `protobuf:"varint,4,opt,name=rank"`
+}
+
+type protoControlEntry struct {
+	Key   string        `protobuf:"bytes,1,opt,name=key"`
+	Value *protoControl `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoMetadataTemplate struct {
+	ID       string  `protobuf:"bytes,1,opt,name=id"`
+	Label    string  `protobuf:"bytes,2,opt,name=label"`
+	Truncate int64   `protobuf:"varint,3,opt,name=truncate"`
+	Datatype string  `protobuf:"bytes,4,opt,name=datatype"`
+	Priority float64 `protobuf:"fixed64,5,opt,name=priority"`
+	From     string  `protobuf:"bytes,6,opt,name=from"`
+}
+
+type protoMetadataTemplateEntry struct {
+	Key   string                 `protobuf:"bytes,1,opt,name=key"`
+	Value *protoMetadataTemplate `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoMetricTemplate struct {
+	ID       string  `protobuf:"bytes,1,opt,name=id"`
+	Label    string  `protobuf:"bytes,2,opt,name=label"`
+	Format   string  `protobuf:"bytes,3,opt,name=format"`
+	Group    string  `protobuf:"bytes,4,opt,name=group"`
+	Priority float64 `protobuf:"fixed64,5,opt,name=priority"`
+}
+
+type protoMetricTemplateEntry struct {
+	Key   string               `protobuf:"bytes,1,opt,name=key"`
+	Value *protoMetricTemplate `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoColumn struct {
+	ID       string `protobuf:"bytes,1,opt,name=id"`
+	Label    string `protobuf:"bytes,2,opt,name=label"`
+	DataType string `protobuf:"bytes,3,opt,name=data_type"`
+}
+
+type protoTableTemplate struct {
+	ID        string              `protobuf:"bytes,1,opt,name=id"`
+	Label     string              `protobuf:"bytes,2,opt,name=label"`
+	Prefix    string              `protobuf:"bytes,3,opt,name=prefix"`
+	Type      string              `protobuf:"bytes,4,opt,name=type"`
+	Columns   []*protoColumn      `protobuf:"bytes,5,rep,name=columns"`
+	FixedRows []*protoStringEntry `protobuf:"bytes,6,rep,name=fixed_rows"`
+}
+
+type protoTableTemplateEntry struct {
+	Key   string              `protobuf:"bytes,1,opt,name=key"`
+	Value *protoTableTemplate `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoStringEntry struct {
+	Key   string `protobuf:"bytes,1,opt,name=key"`
+	Value string `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoNode struct {
+	ID             string                     `protobuf:"bytes,1,opt,name=id"`
+	Topology       string                     `protobuf:"bytes,2,opt,name=topology"`
+	Counters       []*protoCounterEntry       `protobuf:"bytes,3,rep,name=counters"`
+	Sets           []*protoSetEntry           `protobuf:"bytes,4,rep,name=sets"`
+	Adjacency      []string                   `protobuf:"bytes,5,rep,name=adjacency"`
+	Edges          []*protoEdgeEntry          `protobuf:"bytes,6,rep,name=edges"`
+	Controls       *protoNodeControls         `protobuf:"bytes,7,opt,name=controls"`
+	LatestControls []*protoLatestControlEntry `protobuf:"bytes,8,rep,name=latest_controls"`
+	Latest         []*protoLatestEntry        `protobuf:"bytes,9,rep,name=latest"`
+	Metrics        []*protoMetricEntry        `protobuf:"bytes,10,rep,name=metrics"`
+	Parents        []*protoSetEntry           `protobuf:"bytes,11,rep,name=parents"`
+	Children       []*protoNode               `protobuf:"bytes,12,rep,name=children"`
+}
+
+type protoCounterEntry struct {
+	Key   string `protobuf:"bytes,1,opt,name=key"`
+	Value int64  `protobuf:"varint,2,opt,name=value"`
+}
+
+type protoSetEntry struct {
+	Key    string   `protobuf:"bytes,1,opt,name=key"`
+	Values []string `protobuf:"bytes,2,rep,name=values"`
+}
+
+type protoEdgeEntry struct {
+	Key   string             `protobuf:"bytes,1,opt,name=key"`
+	Value *protoEdgeMetadata `protobuf:"bytes,2,opt,name=value"`
+}
+
+type protoCount struct {
+	Value uint64 `protobuf:"varint,1,opt,name=value"`
+}
+
+type protoEdgeMetadata struct {
+	EgressPacketCount  *protoCount `protobuf:"bytes,1,opt,name=egress_packet_count"`
+	IngressPacketCount *protoCount `protobuf:"bytes,2,opt,name=ingress_packet_count"`
+	EgressByteCount    *protoCount `protobuf:"bytes,3,opt,name=egress_byte_count"`
+	IngressByteCount   *protoCount `protobuf:"bytes,4,opt,name=ingress_byte_count"`
+	WithTCP            bool        `protobuf:"varint,8,opt,name=with_tcp"`
+	TCPConnections     *protoCount `protobuf:"bytes,9,opt,name=tcp_connections"`
+	WithUDP            bool        `protobuf:"varint,10,opt,name=with_udp"`
+	UDPFlows           *protoCount `protobuf:"bytes,11,opt,name=udp_flows"`
+	FirstSeen          int64       `protobuf:"varint,12,opt,name=first_seen"`
+	LastSeen           int64       `protobuf:"varint,13,opt,name=last_seen"`
+	TLS                bool        `protobuf:"varint,14,opt,name=tls"`
+	TLSConfirmed       bool        `protobuf:"varint,15,opt,name=tls_confirmed"`
+
+	ConnectionSamples []*protoConnectionSample `protobuf:"bytes,16,rep,name=connection_samples"`
+}
+
+type protoConnectionSample struct {
+	LocalPort  uint32 `protobuf:"varint,1,opt,name=local_port"`
+	RemotePort uint32 `protobuf:"varint,2,opt,name=remote_port"`
+	State      string `protobuf:"bytes,3,opt,name=state"`
+}
+
+type protoNodeControls struct {
+	Timestamp int64    `protobuf:"varint,1,opt,name=timestamp"`
+	Controls  []string `protobuf:"bytes,2,rep,name=controls"`
+}
+
+type protoLatestControlEntry struct {
+	Key       string `protobuf:"bytes,1,opt,name=key"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp"`
+	Dead      bool   `protobuf:"varint,3,opt,name=dead"`
+}
+
+type protoLatestEntry struct {
+	Key       string `protobuf:"bytes,1,opt,name=key"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp"`
+	Value     string `protobuf:"bytes,3,opt,name=value"`
+}
+
+type protoSample struct {
+	Timestamp int64   `protobuf:"varint,1,opt,name=timestamp"`
+	Value     float64 `protobuf:"fixed64,2,opt,name=value"`
+}
+
+type protoMetric struct {
+	Samples []*protoSample `protobuf:"bytes,1,rep,name=samples"`
+	Min     float64        `protobuf:"fixed64,2,opt,name=min"`
+	Max     float64        `protobuf:"fixed64,3,opt,name=max"`
+	First   int64          `protobuf:"varint,4,opt,name=first"`
+	Last    int64          `protobuf:"varint,5,opt,name=last"`
+}
+
+type protoMetricEntry struct {
+	Key   string       `protobuf:"bytes,1,opt,name=key"`
+	Value *protoMetric `protobuf:"bytes,2,opt,name=value"`
+}
+
+// Only the top-level message needs to be a proto.Message.
+func (m *protoReport) Reset()         { *m = protoReport{} }
+func (m *protoReport) String() string { return proto.CompactTextString(m) }
+func (*protoReport) ProtoMessage()    {}
+
+// protoTime and fromProtoTime convert times to and from nanoseconds since
+// the epoch, with zero times as 0.
+func protoTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromProtoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func protoCountOf(c *uint64) *protoCount {
+	if c == nil {
+		return nil
+	}
+	return &protoCount{Value: *c}
+}
+
+func (c *protoCount) count() *uint64 {
+	if c == nil {
+		return nil
+	}
+	value := c.Value
+	return &value
+}
+
+func connectionSamplesToProto(samples []ConnectionSample) []*protoConnectionSample {
+	var result []*protoConnectionSample
+	for _, s := range samples {
+		result = append(result, &protoConnectionSample{LocalPort: uint32(s.LocalPort), RemotePort: uint32(s.RemotePort), State: s.State})
+	}
+	return result
+}
+
+func connectionSamplesFromProto(samples []*protoConnectionSample) []ConnectionSample {
+	var result []ConnectionSample
+	for _, s := range samples {
+		result = append(result, ConnectionSample{LocalPort: uint16(s.LocalPort), RemotePort: uint16(s.RemotePort), State: s.State})
+	}
+	return result
+}
+
+// sortedKeys returns the keys of a map with string keys, sorted, so the
+// encoding is stable.
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (rep Report) toProto() *protoReport {
+	p := &protoReport{
+		Endpoint:       rep.Endpoint.toProto(),
+		Process:        rep.Process.toProto(),
+		Container:      rep.Container.toProto(),
+		Pod:            rep.Pod.toProto(),
+		Service:        rep.Service.toProto(),
+		Deployment:     rep.Deployment.toProto(),
+		ReplicaSet:     rep.ReplicaSet.toProto(),
+		ContainerImage: rep.ContainerImage.toProto(),
+		Host:           rep.Host.toProto(),
+		ECSTask:        rep.ECSTask.toProto(),
+		ECSService:     rep.ECSService.toProto(),
+		Overlay:        rep.Overlay.toProto(),
+		Sampling:       &protoSampling{Count: rep.Sampling.Count, Total: rep.Sampling.Total},
+		Window:         int64(rep.Window),
+		Shortcut:       rep.Shortcut,
+		Timestamp:      protoTime(rep.Timestamp),
+		HostID:         rep.HostID,
+		ProbeVersion:   rep.ProbeVersion,
+		SchemaVersion:  int64(rep.SchemaVersion),
+		ID:             rep.ID,
+	}
+	rep.Plugins.ForEach(func(spec xfer.PluginSpec) {
+		p.Plugins = append(p.Plugins, &protoPluginSpec{
+			ID:          spec.ID,
+			Label:       spec.Label,
+			Description: spec.Description,
+			Interfaces:  spec.Interfaces,
+			APIVersion:  spec.APIVersion,
+			Status:      spec.Status,
+		})
+	})
+	return p
+}
+
+func (p *protoReport) report() Report {
+	rep := MakeReport()
+	for _, t := range []struct {
+		topology *Topology
+		proto    *protoTopology
+	}{
+		{&rep.Endpoint, p.Endpoint},
+		{&rep.Process, p.Process},
+		{&rep.Container, p.Container},
+		{&rep.Pod, p.Pod},
+		{&rep.Service, p.Service},
+		{&rep.Deployment, p.Deployment},
+		{&rep.ReplicaSet, p.ReplicaSet},
+		{&rep.ContainerImage, p.ContainerImage},
+		{&rep.Host, p.Host},
+		{&rep.ECSTask, p.ECSTask},
+		{&rep.ECSService, p.ECSService},
+		{&rep.Overlay, p.Overlay},
+	} {
+		if t.proto != nil {
+			*t.topology = t.proto.topology()
+		}
+	}
+	if p.Sampling != nil {
+		rep.Sampling = Sampling{Count: p.Sampling.Count, Total: p.Sampling.Total}
+	}
+	rep.Window = time.Duration(p.Window)
+	rep.Shortcut = p.Shortcut
+	specs := make([]xfer.PluginSpec, 0, len(p.Plugins))
+	for _, spec := range p.Plugins {
+		specs = append(specs, xfer.PluginSpec{
+			ID:          spec.ID,
+			Label:       spec.Label,
+			Description: spec.Description,
+			Interfaces:  spec.Interfaces,
+			APIVersion:  spec.APIVersion,
+			Status:      spec.Status,
+		})
+	}
+	rep.Plugins = xfer.MakePluginSpecs(specs...)
+	rep.Timestamp = fromProtoTime(p.Timestamp)
+	rep.HostID = p.HostID
+	rep.ProbeVersion = p.ProbeVersion
+	rep.SchemaVersion = int(p.SchemaVersion)
+	rep.ID = p.ID
+	return rep
+}
+
+func (t Topology) toProto() *protoTopology {
+	p := &protoTopology{
+		Shape:       t.Shape,
+		Label:       t.Label,
+		LabelPlural: t.LabelPlural,
+	}
+	for _, key := range sortedKeys(t.Nodes) {
+		p.Nodes = append(p.Nodes, &protoNodeEntry{Key: key, Value: t.Nodes[key].toProto()})
+	}
+	for _, key := range sortedKeys(t.Controls) {
+		c := t.Controls[key]
+		p.Controls = append(p.Controls, &protoControlEntry{Key: key, Value: &protoControl{
+			ID:    c.ID,
+			Human: c.Human,
+			Icon:  c.Icon,
+			Rank:  int64(c.Rank),
+		}})
+	}
+	for _, key := range sortedKeys(t.MetadataTemplates) {
+		m := t.MetadataTemplates[key]
+		p.MetadataTemplates = append(p.MetadataTemplates, &protoMetadataTemplateEntry{Key: key, Value: &protoMetadataTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Truncate: int64(m.Truncate),
+			Datatype: m.Datatype,
+			Priority: m.Priority,
+			From:     m.From,
+		}})
+	}
+	for _, key := range sortedKeys(t.MetricTemplates) {
+		m := t.MetricTemplates[key]
+		p.MetricTemplates = append(p.MetricTemplates, &protoMetricTemplateEntry{Key: key, Value: &protoMetricTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Format:   m.Format,
+			Group:    m.Group,
+			Priority: m.Priority,
+		}})
+	}
+	for _, key := range sortedKeys(t.TableTemplates) {
+		tt := t.TableTemplates[key]
+		template := &protoTableTemplate{
+			ID:     tt.ID,
+			Label:  tt.Label,
+			Prefix: tt.Prefix,
+			Type:   tt.Type,
+		}
+		for _, c := range tt.Columns {
+			template.Columns = append(template.Columns, &protoColumn{ID: c.ID, Label: c.Label, DataType: c.DataType})
+		}
+		for _, k := range sortedKeys(tt.FixedRows) {
+			template.FixedRows = append(template.FixedRows, &protoStringEntry{Key: k, Value: tt.FixedRows[k]})
+		}
+		p.TableTemplates = append(p.TableTemplates, &protoTableTemplateEntry{Key: key, Value: template})
+	}
+	return p
+}
+
+func (p *protoTopology) topology() Topology {
+	t := MakeTopology()
+	t.Shape = p.Shape
+	t.Label = p.Label
+	t.LabelPlural = p.LabelPlural
+	for _, entry := range p.Nodes {
+		t.Nodes[entry.Key] = entry.Value.node()
+	}
+	for _, entry := range p.Controls {
+		c := entry.Value
+		t.Controls[entry.Key] = Control{ID: c.ID, Human: c.Human, Icon: c.Icon, Rank: int(c.Rank)}
+	}
+	if len(p.MetadataTemplates) > 0 {
+		t.MetadataTemplates = MetadataTemplates{}
+		for _, entry := range p.MetadataTemplates {
+			m := entry.Value
+			t.MetadataTemplates[entry.Key] = MetadataTemplate{
+				ID:       m.ID,
+				Label:    m.Label,
+				Truncate: int(m.Truncate),
+				Datatype: m.Datatype,
+				Priority: m.Priority,
+				From:     m.From,
+			}
+		}
+	}
+	if len(p.MetricTemplates) > 0 {
+		t.MetricTemplates = MetricTemplates{}
+		for _, entry := range p.MetricTemplates {
+			m := entry.Value
+			t.MetricTemplates[entry.Key] = MetricTemplate{
+				ID:       m.ID,
+				Label:    m.Label,
+				Format:   m.Format,
+				Group:    m.Group,
+				Priority: m.Priority,
+			}
+		}
+	}
+	if len(p.TableTemplates) > 0 {
+		t.TableTemplates = TableTemplates{}
+		for _, entry := range p.TableTemplates {
+			tt := entry.Value
+			template := TableTemplate{
+				ID:        tt.ID,
+				Label:     tt.Label,
+				Prefix:    tt.Prefix,
+				Type:      tt.Type,
+				FixedRows: map[string]string{},
+			}
+			for _, c := range tt.Columns {
+				template.Columns = append(template.Columns, Column{ID: c.ID, Label: c.Label, DataType: c.DataType})
+			}
+			for _, row := range tt.FixedRows {
+				template.FixedRows[row.Key] = row.Value
+			}
+			t.TableTemplates[entry.Key] = template
+		}
+	}
+	return t
+}
+
+func (n Node) toProto() *protoNode {
+	p := &protoNode{
+		ID:        n.ID,
+		Topology:  n.Topology,
+		Sets:      setsToProto(n.Sets),
+		Adjacency: n.Adjacency,
+		Controls: &protoNodeControls{
+			Timestamp: protoTime(n.Controls.Timestamp),
+			Controls:  n.Controls.Controls,
+		},
+		Parents: setsToProto(n.Parents),
+	}
+	n.Counters.ForEach(func(key string, value int) {
+		p.Counters = append(p.Counters, &protoCounterEntry{Key: key, Value: int64(value)})
+	})
+	n.Edges.ForEach(func(key string, e EdgeMetadata) {
+		p.Edges = append(p.Edges, &protoEdgeEntry{Key: key, Value: &protoEdgeMetadata{
+			EgressPacketCount:  protoCountOf(e.EgressPacketCount),
+			IngressPacketCount: protoCountOf(e.IngressPacketCount),
+			EgressByteCount:    protoCountOf(e.EgressByteCount),
+			IngressByteCount:   protoCountOf(e.IngressByteCount),
+			WithTCP:            e.WithTCP,
+			TCPConnections:     protoCountOf(e.TCPConnections),
+			WithUDP:            e.WithUDP,
+			UDPFlows:           protoCountOf(e.UDPFlows),
+			FirstSeen:          protoTime(e.FirstSeen),
+			LastSeen:           protoTime(e.LastSeen),
+			TLS:                e.TLS,
+			TLSConfirmed:       e.TLSConfirmed,
+			ConnectionSamples:  connectionSamplesToProto(e.ConnectionSamples),
+		}})
+	})
+	n.LatestControls.ForEach(func(key string, ts time.Time, data NodeControlData) {
+		p.LatestControls = append(p.LatestControls, &protoLatestControlEntry{Key: key, Timestamp: protoTime(ts), Dead: data.Dead})
+	})
+	n.Latest.ForEach(func(key string, ts time.Time, value string) {
+		p.Latest = append(p.Latest, &protoLatestEntry{Key: key, Timestamp: protoTime(ts), Value: value})
+	})
+	for _, key := range sortedKeys(n.Metrics) {
+		m := n.Metrics[key]
+		metric := &protoMetric{Min: m.Min, Max: m.Max, First: protoTime(m.First), Last: protoTime(m.Last)}
+		for _, s := range m.Samples {
+			metric.Samples = append(metric.Samples, &protoSample{Timestamp: protoTime(s.Timestamp), Value: s.Value})
+		}
+		p.Metrics = append(p.Metrics, &protoMetricEntry{Key: key, Value: metric})
+	}
+	n.Children.ForEach(func(child Node) {
+		p.Children = append(p.Children, child.toProto())
+	})
+	return p
+}
+
+func (p *protoNode) node() Node {
+	n := MakeNode(p.ID)
+	n.Topology = p.Topology
+	for _, entry := range p.Counters {
+		n.Counters = n.Counters.Add(entry.Key, int(entry.Value))
+	}
+	n.Sets = setsFromProto(p.Sets)
+	n.Adjacency = MakeIDList(p.Adjacency...)
+	for _, entry := range p.Edges {
+		e := entry.Value
+		n.Edges = n.Edges.Add(entry.Key, EdgeMetadata{
+			EgressPacketCount:  e.EgressPacketCount.count(),
+			IngressPacketCount: e.IngressPacketCount.count(),
+			EgressByteCount:    e.EgressByteCount.count(),
+			IngressByteCount:   e.IngressByteCount.count(),
+			WithTCP:            e.WithTCP,
+			TCPConnections:     e.TCPConnections.count(),
+			WithUDP:            e.WithUDP,
+			UDPFlows:           e.UDPFlows.count(),
+			FirstSeen:          fromProtoTime(e.FirstSeen),
+			LastSeen:           fromProtoTime(e.LastSeen),
+			TLS:                e.TLS,
+			TLSConfirmed:       e.TLSConfirmed,
+			ConnectionSamples:  connectionSamplesFromProto(e.ConnectionSamples),
+		})
+	}
+	if p.Controls != nil {
+		n.Controls = NodeControls{
+			Timestamp: fromProtoTime(p.Controls.Timestamp),
+			Controls:  MakeStringSet(p.Controls.Controls...),
+		}
+	}
+	for _, entry := range p.LatestControls {
+		n.LatestControls = n.LatestControls.Set(entry.Key, fromProtoTime(entry.Timestamp), NodeControlData{Dead: entry.Dead})
+	}
+	for _, entry := range p.Latest {
+		n.Latest = n.Latest.Set(entry.Key, fromProtoTime(entry.Timestamp), entry.Value)
+	}
+	for _, entry := range p.Metrics {
+		m := entry.Value
+		metric := Metric{Min: m.Min, Max: m.Max, First: fromProtoTime(m.First), Last: fromProtoTime(m.Last)}
+		for _, s := range m.Samples {
+			metric.Samples = append(metric.Samples, Sample{Timestamp: fromProtoTime(s.Timestamp), Value: s.Value})
+		}
+		n.Metrics[entry.Key] = metric
+	}
+	n.Parents = setsFromProto(p.Parents)
+	if len(p.Children) > 0 {
+		children := make([]Node, 0, len(p.Children))
+		for _, child := range p.Children {
+			children = append(children, child.node())
+		}
+		n.Children = MakeNodeSet(children...)
+	}
+	return n
+}
+
+func setsToProto(s Sets) []*protoSetEntry {
+	var result []*protoSetEntry
+	for _, key := range s.Keys() {
+		values, _ := s.Lookup(key)
+		result = append(result, &protoSetEntry{Key: key, Values: values})
+	}
+	return result
+}
+
+func setsFromProto(entries []*protoSetEntry) Sets {
+	s := EmptySets
+	for _, entry := range entries {
+		s = s.Add(entry.Key, MakeStringSet(entry.Values...))
+	}
+	return s
+}
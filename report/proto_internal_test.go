@@ -0,0 +1,146 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+)
+
+var (
+	protoMessageRegexp = regexp.MustCompile(`^message (\w+) {$`)
+	protoFieldRegexp   = regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);$`)
+)
+
+// The Go types of the scalar types used in report.proto, and how they are
+// encoded. Messages are encoded as bytes.
+var protoScalars = map[string]struct {
+	kind reflect.Kind
+	wire string
+}{
+	"string": {reflect.String, "bytes"},
+	"bool":   {reflect.Bool, "varint"},
+	"int64":  {reflect.Int64, "varint"},
+	"uint64": {reflect.Uint64, "varint"},
+	"uint32": {reflect.Uint32, "varint"},
+	"double": {reflect.Float64, "fixed64"},
+}
+
+// readProtoFile lists the fields of each message in report.proto, as
+// "[repeated ]type name = number".
+func readProtoFile(t *testing.T) map[string][]string {
+	f, err := os.Open("report.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var (
+		messages = map[string][]string{}
+		current  string
+		scanner  = bufio.NewScanner(f)
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.Join(strings.Fields(line), " ")
+		switch {
+		case protoMessageRegexp.MatchString(line):
+			current = protoMessageRegexp.FindStringSubmatch(line)[1]
+			messages[current] = []string{}
+		case line == "}":
+			current = ""
+		case current != "" && protoFieldRegexp.MatchString(line):
+			messages[current] = append(messages[current], line[:len(line)-1])
+		case current != "" && line != "" && !strings.HasPrefix(line, "reserved "):
+			t.Fatalf("cannot parse %q in message %s", line, current)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, fields := range messages {
+		sort.Strings(fields)
+	}
+	return messages
+}
+
+// readProtoTypes lists the fields of the Go types for the messages reachable
+// from protoReport, as readProtoFile does, checking that their protobuf tags
+// agree with their types.
+func readProtoTypes(t *testing.T) map[string][]string {
+	messages := map[string][]string{}
+	var walk func(reflect.Type)
+	walk = func(typ reflect.Type) {
+		message := strings.TrimPrefix(typ.Name(), "proto")
+		if _, ok := messages[message]; ok {
+			return
+		}
+		messages[message] = []string{}
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			tag := strings.Split(field.Tag.Get("protobuf"), ",")
+			if len(tag) < 4 || !strings.HasPrefix(tag[3], "name=") {
+				t.Errorf("%s.%s: bad protobuf tag %q", typ.Name(), field.Name, field.Tag.Get("protobuf"))
+				continue
+			}
+			wire, number, label, name := tag[0], tag[1], tag[2], strings.TrimPrefix(tag[3], "name=")
+
+			elem, repeated := field.Type, false
+			if elem.Kind() == reflect.Slice {
+				elem, repeated = elem.Elem(), true
+			}
+			if repeated != (label == "rep") {
+				t.Errorf("%s.%s: tagged %s, but has type %s", typ.Name(), field.Name, label, field.Type)
+			}
+
+			var protoType string
+			if elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct {
+				walk(elem.Elem())
+				protoType = strings.TrimPrefix(elem.Elem().Name(), "proto")
+				if wire != "bytes" {
+					t.Errorf("%s.%s: message tagged %s", typ.Name(), field.Name, wire)
+				}
+			} else {
+				for scalar, want := range protoScalars {
+					if want.kind == elem.Kind() {
+						protoType = scalar
+						if wire != want.wire {
+							t.Errorf("%s.%s: %s tagged %s", typ.Name(), field.Name, scalar, wire)
+						}
+					}
+				}
+				if protoType == "" {
+					t.Errorf("%s.%s: unexpected type %s", typ.Name(), field.Name, field.Type)
+					continue
+				}
+			}
+
+			desc := fmt.Sprintf("%s %s = %s", protoType, name, number)
+			if repeated {
+				desc = "repeated " + desc
+			}
+			messages[message] = append(messages[message], desc)
+		}
+		sort.Strings(messages[message])
+	}
+	walk(reflect.TypeOf(protoReport{}))
+	return messages
+}
+
+// The types in proto.go are written by hand, so check they haven't drifted
+// from report.proto, which other probes compile.
+func TestProtoTypesMatchProtoFile(t *testing.T) {
+	want, have := readProtoFile(t), readProtoTypes(t)
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("proto.go and report.proto differ: %s", test.Diff(want, have))
+	}
+}
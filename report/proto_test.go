@@ -0,0 +1,51 @@
+package report_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+	s_reflect "github.com/weaveworks/scope/test/reflect"
+)
+
+// viaMsgpack normalises the times, and the empty versus nil fields, of a
+// report by passing it through msgpack, so reports can be compared.
+func viaMsgpack(t *testing.T, r report.Report) report.Report {
+	var buf bytes.Buffer
+	if err := r.WriteBinary(&buf, gzip.NoCompression); err != nil {
+		t.Fatal(err)
+	}
+	result, err := report.MakeFromBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *result
+}
+
+func TestProtoRoundtrip(t *testing.T) {
+	// The fixture leaves some topologies unset, where decoding makes them
+	// as MakeReport does.
+	want := fixture.Report.Copy()
+	want.WalkTopologies(func(topology *report.Topology) {
+		if topology.Nodes == nil {
+			*topology = report.MakeTopology()
+		}
+	})
+	want = viaMsgpack(t, want)
+	for _, level := range []int{gzip.NoCompression, gzip.BestCompression} {
+		var buf bytes.Buffer
+		if err := fixture.Report.WriteProto(&buf, level); err != nil {
+			t.Fatal(err)
+		}
+		have := report.MakeReport()
+		if err := have.ReadProto(&buf, level != gzip.NoCompression); err != nil {
+			t.Fatal(err)
+		}
+		if have := viaMsgpack(t, have); !s_reflect.DeepEqual(want, have) {
+			t.Error(test.Diff(want, have))
+		}
+	}
+}
@@ -36,6 +36,14 @@ const (
 	ContainersKey = "containers"
 )
 
+// SchemaVersion is the version of the report format. It is bumped whenever
+// Report changes such that apps and probes on either side of the change can
+// no longer understand each other's reports.
+//
+// Version 2 moved the endpoint keys into the "endpoint" namespace; see
+// UpgradeSchema for reading version 1 reports.
+const SchemaVersion = 2
+
 // Report is the core data type. It's produced by probes, and consumed and
 // stored by apps. It's composed of multiple topologies, each representing
 // a different (related, but not equivalent) view of the network.
@@ -114,6 +122,20 @@ type Report struct {
 
 	Plugins xfer.PluginSpecs
 
+	// Timestamp is when the probe published this report, and HostID the host
+	// it was published from. Both are set by the probe just before
+	// publishing. Merging keeps the latest Timestamp, and HostID only if it
+	// is the same on both sides, so a report merged from several probes has
+	// no HostID.
+	Timestamp time.Time
+	HostID    string
+
+	// ProbeVersion is the version of the probe which published this report,
+	// and SchemaVersion the version of the report format it used. Like
+	// HostID, merging only keeps them if they are the same on both sides.
+	ProbeVersion  string
+	SchemaVersion int
+
 	// ID a random identifier for this report, used when caching
 	// rendered views of the report.  Reports with the same id
 	// must be equal, but we don't require that equal reports have
@@ -195,13 +217,34 @@ func (r Report) Copy() Report {
 		Sampling:       r.Sampling,
 		Window:         r.Window,
 		Plugins:        r.Plugins.Copy(),
+		Timestamp:      r.Timestamp,
+		HostID:         r.HostID,
+		ProbeVersion:   r.ProbeVersion,
+		SchemaVersion:  r.SchemaVersion,
 		ID:             fmt.Sprintf("%d", rand.Int63()),
 	}
 }
 
 // Merge merges another Report into the receiver and returns the result. The
-// original is not modified.
+// original is not modified. Each latest value of the nodes carries the time
+// it was set, and the newer one wins (see Node.Merge), so the result doesn't
+// depend on the order the reports arrive in.
 func (r Report) Merge(other Report) Report {
+	timestamp := r.Timestamp
+	if other.Timestamp.After(timestamp) {
+		timestamp = other.Timestamp
+	}
+	hostID := r.HostID
+	if hostID != other.HostID {
+		hostID = ""
+	}
+	probeVersion, schemaVersion := r.ProbeVersion, r.SchemaVersion
+	if probeVersion != other.ProbeVersion {
+		probeVersion = ""
+	}
+	if schemaVersion != other.SchemaVersion {
+		schemaVersion = 0
+	}
 	return Report{
 		Endpoint:       r.Endpoint.Merge(other.Endpoint),
 		Process:        r.Process.Merge(other.Process),
@@ -218,10 +261,26 @@ func (r Report) Merge(other Report) Report {
 		Sampling:       r.Sampling.Merge(other.Sampling),
 		Window:         r.Window + other.Window,
 		Plugins:        r.Plugins.Merge(other.Plugins),
+		Timestamp:      timestamp,
+		HostID:         hostID,
+		ProbeVersion:   probeVersion,
+		SchemaVersion:  schemaVersion,
 		ID:             fmt.Sprintf("%d", rand.Int63()),
 	}
 }
 
+// CheckSchemaVersion returns an error if the report was published by a probe
+// using a newer version of the report format than this one. Older reports,
+// including those from probes which predate SchemaVersion, are let through,
+// as UpgradeSchema can translate them.
+func (r Report) CheckSchemaVersion() error {
+	if r.SchemaVersion <= SchemaVersion {
+		return nil
+	}
+	return fmt.Errorf("report schema version %d from probe version %q on host %q doesn't match %d; the probe may need upgrading",
+		r.SchemaVersion, r.ProbeVersion, r.HostID, SchemaVersion)
+}
+
 // Topologies returns a slice of Topologies in this report
 func (r Report) Topologies() []Topology {
 	result := []Topology{}
@@ -234,18 +293,7 @@ func (r Report) Topologies() []Topology {
 // WalkTopologies iterates through the Topologies of the report,
 // potentially modifying them
 func (r *Report) WalkTopologies(f func(*Topology)) {
-	f(&r.Endpoint)
-	f(&r.Process)
-	f(&r.Container)
-	f(&r.ContainerImage)
-	f(&r.Pod)
-	f(&r.Service)
-	f(&r.Deployment)
-	f(&r.ReplicaSet)
-	f(&r.Host)
-	f(&r.Overlay)
-	f(&r.ECSTask)
-	f(&r.ECSService)
+	r.WalkNamedTopologies(func(_ string, t *Topology) { f(t) })
 }
 
 // Topology gets a topology by name
@@ -267,6 +315,23 @@ func (r Report) Topology(name string) (Topology, bool) {
 	return t, ok
 }
 
+// WalkNamedTopologies is like WalkTopologies, but also passes the name of
+// each topology, as used by Topology.
+func (r *Report) WalkNamedTopologies(f func(string, *Topology)) {
+	f(Endpoint, &r.Endpoint)
+	f(Process, &r.Process)
+	f(Container, &r.Container)
+	f(ContainerImage, &r.ContainerImage)
+	f(Pod, &r.Pod)
+	f(Service, &r.Service)
+	f(Deployment, &r.Deployment)
+	f(ReplicaSet, &r.ReplicaSet)
+	f(Host, &r.Host)
+	f(Overlay, &r.Overlay)
+	f(ECSTask, &r.ECSTask)
+	f(ECSService, &r.ECSService)
+}
+
 // Validate checks the report for various inconsistencies.
 func (r Report) Validate() error {
 	var errs []string
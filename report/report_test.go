@@ -121,3 +121,76 @@ func TestReportUpgrade(t *testing.T) {
 		t.Error(test.Diff(expected, got))
 	}
 }
+
+func TestReportProvenance(t *testing.T) {
+	var (
+		earlier = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Second)
+		a       = report.MakeReport()
+		b       = report.MakeReport()
+	)
+	a.Timestamp, a.HostID = earlier, "host-a"
+	b.Timestamp, b.HostID = later, "host-b"
+	a.ProbeVersion, a.SchemaVersion = "1.2.3", report.SchemaVersion
+	b.ProbeVersion, b.SchemaVersion = "1.2.4", report.SchemaVersion
+
+	have := jsonRoundtrip(t, a)
+	if !have.Timestamp.Equal(a.Timestamp) || have.HostID != a.HostID {
+		t.Errorf("want %v %q, have %v %q", a.Timestamp, a.HostID, have.Timestamp, have.HostID)
+	}
+	if have.ProbeVersion != a.ProbeVersion || have.SchemaVersion != a.SchemaVersion {
+		t.Errorf("want version %q schema %d, have %q %d", a.ProbeVersion, a.SchemaVersion, have.ProbeVersion, have.SchemaVersion)
+	}
+
+	if merged := a.Merge(a.Copy()); merged.HostID != "host-a" || !merged.Timestamp.Equal(earlier) {
+		t.Errorf("expected host and timestamp to be kept, got %v %q", merged.Timestamp, merged.HostID)
+	}
+	if merged := a.Merge(b); merged.HostID != "" || !merged.Timestamp.Equal(later) {
+		t.Errorf("expected no host and the later timestamp, got %v %q", merged.Timestamp, merged.HostID)
+	}
+	if merged := a.Merge(b); merged.ProbeVersion != "" || merged.SchemaVersion != report.SchemaVersion {
+		t.Errorf("expected no probe version and the common schema, got %q %d", merged.ProbeVersion, merged.SchemaVersion)
+	}
+}
+
+func TestReportMergeNewestWins(t *testing.T) {
+	// A container's state, reported as running and then stopped; the
+	// report saying it is stopped may be merged first.
+	const state = "docker_container_state"
+	var (
+		earlier = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Second)
+		older   = report.MakeReport()
+		newer   = report.MakeReport()
+	)
+	older.Container.AddNode(report.MakeNode("c1").WithLatest(state, earlier, "running"))
+	newer.Container.AddNode(report.MakeNode("c1").WithLatest(state, later, "stopped"))
+
+	for name, merged := range map[string]report.Report{
+		"older into newer": newer.Merge(older),
+		"newer into older": older.Merge(newer),
+	} {
+		value, timestamp, ok := merged.Container.Nodes["c1"].Latest.LookupEntry(state)
+		if !ok || value != "stopped" || !timestamp.Equal(later) {
+			t.Errorf("%s: want stopped at %v, have %q at %v", name, later, value, timestamp)
+		}
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	for _, c := range []struct {
+		version int
+		ok      bool
+	}{
+		{0, true}, // predates versioning
+		{1, true}, // upgraded by UpgradeSchema
+		{report.SchemaVersion, true},
+		{report.SchemaVersion + 1, false},
+	} {
+		rpt := report.MakeReport()
+		rpt.SchemaVersion = c.version
+		if err := rpt.CheckSchemaVersion(); (err == nil) != c.ok {
+			t.Errorf("schema version %d: want ok=%v, got %v", c.version, c.ok, err)
+		}
+	}
+}
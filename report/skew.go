@@ -0,0 +1,68 @@
+package report
+
+import (
+	"time"
+)
+
+// ShiftTime returns a copy of r with all of the times in it moved by d: when
+// it was published, the timestamps of the nodes' latest values and metric
+// samples, and when the connections behind edges were first and last seen.
+// It is for correcting reports from a probe whose clock is off.
+func ShiftTime(r Report, d time.Duration) Report {
+	result := r.Copy()
+	if !result.Timestamp.IsZero() {
+		result.Timestamp = result.Timestamp.Add(d)
+	}
+	result.WalkTopologies(func(t *Topology) {
+		nodes := make(Nodes, len(t.Nodes))
+		for id, n := range t.Nodes {
+			nodes[id] = shiftNodeTime(n, d)
+		}
+		t.Nodes = nodes
+	})
+	return result
+}
+
+func shiftNodeTime(n Node, d time.Duration) Node {
+	latest := MakeStringLatestMap()
+	n.Latest.ForEach(func(k string, timestamp time.Time, v string) {
+		latest = latest.Set(k, timestamp.Add(d), v)
+	})
+	n.Latest = latest
+
+	latestControls := MakeNodeControlDataLatestMap()
+	n.LatestControls.ForEach(func(k string, timestamp time.Time, v NodeControlData) {
+		latestControls = latestControls.Set(k, timestamp.Add(d), v)
+	})
+	n.LatestControls = latestControls
+
+	if len(n.Metrics) > 0 {
+		metrics := make(Metrics, len(n.Metrics))
+		for k, m := range n.Metrics {
+			samples := make([]Sample, len(m.Samples))
+			for i, s := range m.Samples {
+				samples[i] = Sample{Timestamp: s.Timestamp.Add(d), Value: s.Value}
+			}
+			m.Samples = samples
+			m.First, m.Last = shiftTime(m.First, d), shiftTime(m.Last, d)
+			metrics[k] = m
+		}
+		n.Metrics = metrics
+	}
+
+	edges := MakeEdgeMetadatas()
+	n.Edges.ForEach(func(k string, e EdgeMetadata) {
+		e.FirstSeen, e.LastSeen = shiftTime(e.FirstSeen, d), shiftTime(e.LastSeen, d)
+		edges = edges.Add(k, e)
+	})
+	n.Edges = edges
+	return n
+}
+
+// shiftTime leaves zero times, meaning unknown, as they are.
+func shiftTime(t time.Time, d time.Duration) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.Add(d)
+}
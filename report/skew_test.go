@@ -0,0 +1,44 @@
+package report_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestShiftTime(t *testing.T) {
+	var (
+		then = time.Now().Round(time.Second)
+		d    = -time.Hour
+		rpt  = report.MakeReport()
+	)
+	mtime.NowForce(then)
+	defer mtime.NowReset()
+	rpt.Timestamp = then
+	rpt.Process.AddNode(report.MakeNodeWith("a", map[string]string{"pid": "1"}).
+		WithMetric("cpu", report.MakeSingletonMetric(then, 1)).
+		WithEdge("b", report.EdgeMetadata{FirstSeen: then}))
+
+	have := report.ShiftTime(rpt, d)
+	if !have.Timestamp.Equal(then.Add(d)) {
+		t.Errorf("want timestamp %v, have %v", then.Add(d), have.Timestamp)
+	}
+	node := have.Process.Nodes["a"]
+	if _, ts, _ := node.Latest.LookupEntry("pid"); !ts.Equal(then.Add(d)) {
+		t.Errorf("want latest at %v, have %v", then.Add(d), ts)
+	}
+	if metric := node.Metrics["cpu"]; !metric.Samples[0].Timestamp.Equal(then.Add(d)) || !metric.Last.Equal(then.Add(d)) {
+		t.Errorf("want metric at %v, have %v", then.Add(d), metric)
+	}
+	edge, _ := node.Edges.Lookup("b")
+	if !edge.FirstSeen.Equal(then.Add(d)) || !edge.LastSeen.IsZero() {
+		t.Errorf("want edge first seen at %v and never last seen, have %v", then.Add(d), edge)
+	}
+
+	// The original is left as it was.
+	if _, ts, _ := rpt.Process.Nodes["a"].Latest.LookupEntry("pid"); !ts.Equal(then) {
+		t.Errorf("expected the original report unchanged, got latest at %v", ts)
+	}
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// StreamWriter appends reports to a newline-delimited JSON stream, one
+// `{"timestamp": ..., "report": {...}}` object per line. It is safe for
+// concurrent use.
+type StreamWriter struct {
+	mtx sync.Mutex
+	w   *bufio.Writer
+}
+
+// NewStreamWriter makes a new StreamWriter writing to w.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{w: bufio.NewWriter(w)}
+}
+
+// Write appends rpt to the stream, stamped with ts. Each call flushes, so a
+// reader never sees a partial line unless the write itself failed.
+func (s *StreamWriter) Write(ts time.Time, rpt Report) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, err := fmt.Fprintf(s.w, `{"timestamp":%q,"report":`, ts.UTC().Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	if err := rpt.WriteJSON(s.w); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(s.w, "}\n"); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// StreamReader reads reports written by a StreamWriter.
+type StreamReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewStreamReader makes a new StreamReader reading from r.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next report in the stream and its timestamp. Blank lines
+// are skipped. It returns io.EOF when there are no more reports; a final line
+// without a trailing newline is still returned.
+func (s *StreamReader) Read() (time.Time, Report, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return time.Time{}, MakeReport(), err
+		}
+		s.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := struct {
+			Timestamp string `json:"timestamp"`
+			Report    Report `json:"report"`
+		}{Report: MakeReport()}
+		if err := codec.NewDecoderBytes(line, &codec.JsonHandle{}).Decode(&entry); err != nil {
+			return time.Time{}, MakeReport(), fmt.Errorf("line %d: %v", s.line, err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return time.Time{}, MakeReport(), fmt.Errorf("line %d: %v", s.line, err)
+		}
+		return ts, entry.Report, nil
+	}
+}
@@ -0,0 +1,72 @@
+package report_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	s_reflect "github.com/weaveworks/scope/test/reflect"
+)
+
+// jsonRoundtrip returns rpt as it looks after being encoded and decoded as
+// JSON, which e.g. loses time zones and empty maps.
+func jsonRoundtrip(t *testing.T, rpt report.Report) report.Report {
+	var buf bytes.Buffer
+	if err := rpt.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	result := report.MakeReport()
+	if err := result.ReadBytes(buf.Bytes(), &codec.JsonHandle{}); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestStreamRoundtrip(t *testing.T) {
+	var (
+		start      = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		timestamps []time.Time
+		reports    []report.Report
+		buf        bytes.Buffer
+		w          = report.NewStreamWriter(&buf)
+	)
+	for i, id := range []string{"a", "b", "c"} {
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNodeWith(id, map[string]string{"name": id}))
+		ts := start.Add(time.Duration(i) * 1500 * time.Millisecond)
+		if err := w.Write(ts, rpt); err != nil {
+			t.Fatal(err)
+		}
+		timestamps = append(timestamps, ts)
+		reports = append(reports, jsonRoundtrip(t, rpt))
+	}
+
+	r := report.NewStreamReader(&buf)
+	for i := range reports {
+		ts, have, err := r.Read()
+		if err != nil {
+			t.Fatalf("report %d: %v", i, err)
+		}
+		if !ts.Equal(timestamps[i]) {
+			t.Errorf("report %d: want timestamp %v, have %v", i, timestamps[i], ts)
+		}
+		if !s_reflect.DeepEqual(reports[i], have) {
+			t.Errorf("report %d: %s", i, test.Diff(reports[i], have))
+		}
+	}
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("want EOF after last report, have %v", err)
+	}
+}
+
+func TestStreamReaderBadLine(t *testing.T) {
+	r := report.NewStreamReader(bytes.NewBufferString("\n{\"timestamp\":\"nope\",\"report\":{}}\n"))
+	if _, _, err := r.Read(); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
@@ -189,35 +189,159 @@ func (n Nodes) Merge(other Nodes) Nodes {
 	return cp
 }
 
-// Validate checks the topology for various inconsistencies.
+// ValidationErrorKind classifies a ValidationError.
+type ValidationErrorKind string
+
+// The kinds of inconsistency found by Topology.Validate. Only ValidateNode
+// and ValidateEdge look for the nodes and edges they are given, so only they
+// return InvalidEdgeID, MissingNode and MissingEdge.
+const (
+	InvalidNodeID     ValidationErrorKind = "invalid_node_id"
+	DanglingAdjacency ValidationErrorKind = "dangling_adjacency"
+	DanglingEdge      ValidationErrorKind = "dangling_edge"
+	InvalidEdgeID     ValidationErrorKind = "invalid_edge_id"
+	MissingNode       ValidationErrorKind = "missing_node"
+	MissingEdge       ValidationErrorKind = "missing_edge"
+)
+
+// ValidationError is a single inconsistency in a topology. NodeID is the node
+// it was found on and, for adjacencies and edges, DstNodeID is the missing
+// node it refers to.
+type ValidationError struct {
+	Kind      ValidationErrorKind
+	NodeID    string
+	DstNodeID string
+}
+
+func (e ValidationError) Error() string {
+	switch e.Kind {
+	case InvalidNodeID:
+		return fmt.Sprintf("invalid node ID %q", e.NodeID)
+	case DanglingAdjacency:
+		return fmt.Sprintf("node missing from adjacency %q -> %q", e.NodeID, e.DstNodeID)
+	case DanglingEdge:
+		return fmt.Sprintf("node %s missing for edge %q", e.DstNodeID, e.NodeID)
+	case InvalidEdgeID:
+		return fmt.Sprintf("invalid edge ID %q", e.NodeID)
+	case MissingNode:
+		return fmt.Sprintf("node %q missing", e.NodeID)
+	case MissingEdge:
+		return fmt.Sprintf("edge %q -> %q missing", e.NodeID, e.DstNodeID)
+	}
+	return fmt.Sprintf("%s: %q -> %q", e.Kind, e.NodeID, e.DstNodeID)
+}
+
+// ValidationErrors is the error returned by Topology.Validate.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d error(s): %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// orNil returns nil rather than an empty ValidationErrors, so there is no
+// error.
+func (errs ValidationErrors) orNil() error {
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// CountByKind returns the number of errors of each kind.
+func (errs ValidationErrors) CountByKind() map[ValidationErrorKind]int {
+	counts := map[ValidationErrorKind]int{}
+	for _, err := range errs {
+		counts[err.Kind]++
+	}
+	return counts
+}
+
+// Validate checks the topology for various inconsistencies. Any error
+// returned is a ValidationErrors.
 func (t Topology) Validate() error {
-	errs := []string{}
+	errs := ValidationErrors{}
 
 	// Check all nodes are valid, and the keys are parseable, i.e.
-	// contain a scope.
+	// contain a scope. Every other check is a lookup in t.Nodes, so this is
+	// linear in the number of nodes and edges.
 	for nodeID, nmd := range t.Nodes {
-		if _, _, ok := ParseNodeID(nodeID); !ok {
-			errs = append(errs, fmt.Sprintf("invalid node ID %q", nodeID))
-		}
+		errs = t.validateNode(errs, nodeID, nmd)
+	}
+	return errs.orNil()
+}
 
-		// Check all adjancency keys has entries in Node.
-		for _, dstNodeID := range nmd.Adjacency {
-			if _, ok := t.Nodes[dstNodeID]; !ok {
-				errs = append(errs, fmt.Sprintf("node missing from adjacency %q -> %q", nodeID, dstNodeID))
-			}
-		}
+// ValidateNode checks a single node, as Validate would, without going
+// through the rest of the topology. Any error returned is a
+// ValidationErrors.
+func (t Topology) ValidateNode(nodeID string) error {
+	nmd, ok := t.Nodes[nodeID]
+	if !ok {
+		return ValidationErrors{{Kind: MissingNode, NodeID: nodeID}}
+	}
+	return t.validateNode(ValidationErrors{}, nodeID, nmd).orNil()
+}
+
+// ValidateEdge checks a single edge, given by an ID from MakeEdgeID: that
+// its source node is in the topology, has an adjacency or edge metadata for
+// the destination, and that the destination is in the topology too. Any
+// error returned is a ValidationErrors.
+func (t Topology) ValidateEdge(edgeID string) error {
+	nodeID, dstNodeID, ok := ParseEdgeID(edgeID)
+	if !ok {
+		return ValidationErrors{{Kind: InvalidEdgeID, NodeID: edgeID}}
+	}
+	nmd, ok := t.Nodes[nodeID]
+	if !ok {
+		return ValidationErrors{{Kind: MissingNode, NodeID: nodeID}}
+	}
+	var (
+		errs        = ValidationErrors{}
+		_, hasEdge  = nmd.Edges.Lookup(dstNodeID)
+		isAdjacency = nmd.Adjacency.Contains(dstNodeID)
+	)
+	if !hasEdge && !isAdjacency {
+		errs = append(errs, ValidationError{Kind: MissingEdge, NodeID: nodeID, DstNodeID: dstNodeID})
+	}
+	if isAdjacency {
+		errs = t.validateAdjacency(errs, nodeID, dstNodeID)
+	}
+	if hasEdge {
+		errs = t.validateEdge(errs, nodeID, dstNodeID)
+	}
+	return errs.orNil()
+}
 
-		// Check all the edge metadatas have entries in adjacencies
-		nmd.Edges.ForEach(func(dstNodeID string, _ EdgeMetadata) {
-			if _, ok := t.Nodes[dstNodeID]; !ok {
-				errs = append(errs, fmt.Sprintf("node %s missing for edge %q", dstNodeID, nodeID))
-			}
-		})
+func (t Topology) validateNode(errs ValidationErrors, nodeID string, nmd Node) ValidationErrors {
+	if !strings.Contains(nodeID, ScopeDelim) {
+		errs = append(errs, ValidationError{Kind: InvalidNodeID, NodeID: nodeID})
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("%d error(s): %s", len(errs), strings.Join(errs, "; "))
+	// Check all adjancency keys has entries in Node.
+	for _, dstNodeID := range nmd.Adjacency {
+		errs = t.validateAdjacency(errs, nodeID, dstNodeID)
 	}
 
-	return nil
+	// Check all the edge metadatas have entries in adjacencies
+	nmd.Edges.ForEach(func(dstNodeID string, _ EdgeMetadata) {
+		errs = t.validateEdge(errs, nodeID, dstNodeID)
+	})
+	return errs
+}
+
+func (t Topology) validateAdjacency(errs ValidationErrors, nodeID, dstNodeID string) ValidationErrors {
+	if _, ok := t.Nodes[dstNodeID]; !ok {
+		errs = append(errs, ValidationError{Kind: DanglingAdjacency, NodeID: nodeID, DstNodeID: dstNodeID})
+	}
+	return errs
+}
+
+func (t Topology) validateEdge(errs ValidationErrors, nodeID, dstNodeID string) ValidationErrors {
+	if _, ok := t.Nodes[dstNodeID]; !ok {
+		errs = append(errs, ValidationError{Kind: DanglingEdge, NodeID: nodeID, DstNodeID: dstNodeID})
+	}
+	return errs
 }
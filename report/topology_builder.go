@@ -0,0 +1,52 @@
+package report
+
+import "reflect"
+
+// TopologyBuilder builds a Topology a node and an edge at a time, keeping
+// the node IDs, adjacencies and edge metadata in step. It is meant for tests,
+// e.g.
+//
+//	topology, err := NewTopologyBuilder().
+//		AddNode(client, map[string]string{"addr": "10.0.0.1"}).
+//		AddEdge(client, server, EdgeMetadata{}).
+//		BuildValid()
+type TopologyBuilder struct {
+	topology Topology
+}
+
+// NewTopologyBuilder makes a TopologyBuilder for an empty Topology.
+func NewTopologyBuilder() *TopologyBuilder {
+	return &TopologyBuilder{topology: MakeTopology()}
+}
+
+// AddNode adds a node with the given latest values, merging them into the
+// node if it is already there.
+func (b *TopologyBuilder) AddNode(id string, latest map[string]string) *TopologyBuilder {
+	b.topology.AddNode(MakeNodeWith(id, latest))
+	return b
+}
+
+// AddEdge adds an edge from src to dst, adding either node if it isn't
+// there yet. Edges with metadata are merged into any already added between
+// the same nodes; zero metadata only adds the adjacency.
+func (b *TopologyBuilder) AddEdge(src, dst string, md EdgeMetadata) *TopologyBuilder {
+	node := MakeNode(src).WithAdjacent(dst)
+	if !reflect.DeepEqual(md, EdgeMetadata{}) {
+		node = node.WithEdge(dst, md)
+	}
+	b.topology.AddNode(node)
+	b.topology.AddNode(MakeNode(dst))
+	return b
+}
+
+// Build returns the Topology built so far. Building more doesn't change it.
+func (b *TopologyBuilder) Build() Topology {
+	return b.topology.Copy()
+}
+
+// BuildValid is like Build, but also returns any errors from validating the
+// Topology, e.g. for node IDs without a scope.
+func (b *TopologyBuilder) BuildValid() (Topology, error) {
+	t := b.Build()
+	return t, t.Validate()
+}
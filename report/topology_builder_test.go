@@ -0,0 +1,49 @@
+package report_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestTopologyBuilder(t *testing.T) {
+	mtime.NowForce(mtime.Now())
+	defer mtime.NowReset()
+
+	var (
+		client = report.MakeEndpointNodeID("host1", "", "10.0.0.1", "43210")
+		server = report.MakeEndpointNodeID("host2", "", "10.0.0.2", "80")
+		other  = report.MakeEndpointNodeID("host2", "", "10.0.0.3", "53")
+		bytes  = uint64(100)
+	)
+	builder := report.NewTopologyBuilder().
+		AddNode(client, map[string]string{"addr": "10.0.0.1"}).
+		AddEdge(client, server, report.EdgeMetadata{EgressByteCount: &bytes}).
+		AddEdge(server, other, report.EdgeMetadata{})
+	topology, err := builder.BuildValid()
+	if err != nil {
+		t.Fatalf("expected a valid topology: %v", err)
+	}
+
+	want := report.MakeTopology().
+		AddNode(report.MakeNodeWith(client, map[string]string{"addr": "10.0.0.1"}).
+			WithEdge(server, report.EdgeMetadata{EgressByteCount: &bytes})).
+		AddNode(report.MakeNode(server).WithAdjacent(other)).
+		AddNode(report.MakeNode(other))
+	if !reflect.DeepEqual(want.Nodes, topology.Nodes) {
+		t.Error(test.Diff(want.Nodes, topology.Nodes))
+	}
+
+	// Building more leaves what was built alone.
+	builder.AddEdge(other, client, report.EdgeMetadata{})
+	if len(topology.Nodes[other].Adjacency) != 0 {
+		t.Errorf("expected the built topology to be unchanged, got %v", topology.Nodes[other])
+	}
+
+	if _, err := report.NewTopologyBuilder().AddEdge("a", "b", report.EdgeMetadata{}).BuildValid(); err == nil {
+		t.Errorf("expected node IDs without a scope to be invalid")
+	}
+}
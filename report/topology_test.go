@@ -1,6 +1,7 @@
 package report_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/weaveworks/scope/report"
@@ -109,3 +110,157 @@ func TestNodeOrdering(t *testing.T) {
 		}
 	}
 }
+
+func TestTopologyValidateErrors(t *testing.T) {
+	var (
+		good    = report.MakeEndpointNodeID("", "", "1.2.3.4", "80")
+		missing = report.MakeEndpointNodeID("", "", "5.6.7.8", "80")
+		topo    = report.MakeTopology().
+			AddNode(report.MakeNode(good).WithAdjacent(missing)).
+			AddNode(report.MakeNode("bad").WithEdge(missing, report.EdgeMetadata{}))
+	)
+
+	err := topo.Validate()
+	errs, ok := err.(report.ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+	}
+	want := map[report.ValidationError]bool{
+		{Kind: report.InvalidNodeID, NodeID: "bad"}:                         true,
+		{Kind: report.DanglingAdjacency, NodeID: good, DstNodeID: missing}:  true,
+		{Kind: report.DanglingAdjacency, NodeID: "bad", DstNodeID: missing}: true,
+		{Kind: report.DanglingEdge, NodeID: "bad", DstNodeID: missing}:      true,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for _, e := range errs {
+		if !want[e] {
+			t.Errorf("unexpected error %#v", e)
+		}
+	}
+	if counts := errs.CountByKind(); counts[report.DanglingAdjacency] != 2 || counts[report.DanglingEdge] != 1 || counts[report.InvalidNodeID] != 1 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+
+	single := report.ValidationErrors{{Kind: report.DanglingEdge, NodeID: "a", DstNodeID: "b"}}
+	if have, want := single.Error(), `1 error(s): node b missing for edge "a"`; have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if err := report.MakeTopology().AddNode(report.MakeNode(good)).Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTopologyValidateNodeAndEdge(t *testing.T) {
+	var (
+		a       = report.MakeEndpointNodeID("", "", "1.2.3.4", "80")
+		b       = report.MakeEndpointNodeID("", "", "1.2.3.5", "80")
+		missing = report.MakeEndpointNodeID("", "", "5.6.7.8", "80")
+		topo    = report.MakeTopology().
+			AddNode(report.MakeNode(a).WithEdge(b, report.EdgeMetadata{}).WithAdjacent(missing)).
+			AddNode(report.MakeNode(b).WithEdge(missing, report.EdgeMetadata{}))
+	)
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want report.ValidationErrors
+	}{
+		{"valid edge", topo.ValidateEdge(report.MakeEdgeID(a, b)), nil},
+		{"dangling edge", topo.ValidateEdge(report.MakeEdgeID(b, missing)), report.ValidationErrors{
+			{Kind: report.DanglingAdjacency, NodeID: b, DstNodeID: missing},
+			{Kind: report.DanglingEdge, NodeID: b, DstNodeID: missing},
+		}},
+		{"dangling adjacency", topo.ValidateEdge(report.MakeEdgeID(a, missing)), report.ValidationErrors{
+			{Kind: report.DanglingAdjacency, NodeID: a, DstNodeID: missing},
+		}},
+		{"no such edge", topo.ValidateEdge(report.MakeEdgeID(b, a)), report.ValidationErrors{
+			{Kind: report.MissingEdge, NodeID: b, DstNodeID: a},
+		}},
+		{"no such source", topo.ValidateEdge(report.MakeEdgeID(missing, a)), report.ValidationErrors{
+			{Kind: report.MissingNode, NodeID: missing},
+		}},
+		{"invalid edge ID", topo.ValidateEdge(a), report.ValidationErrors{
+			{Kind: report.InvalidEdgeID, NodeID: a},
+		}},
+		{"node missing from adjacency", topo.ValidateNode(a), report.ValidationErrors{
+			{Kind: report.DanglingAdjacency, NodeID: a, DstNodeID: missing},
+		}},
+		{"no such node", topo.ValidateNode(missing), report.ValidationErrors{
+			{Kind: report.MissingNode, NodeID: missing},
+		}},
+	} {
+		if tc.want == nil {
+			if tc.err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, tc.err)
+			}
+			continue
+		}
+		if have, ok := tc.err.(report.ValidationErrors); !ok || !reflect.DeepEqual(tc.want, have) {
+			t.Errorf("%s: want %v, have %v", tc.name, tc.want, tc.err)
+		}
+	}
+
+	// A node is valid once the nodes it refers to are added.
+	topo.AddNode(report.MakeNode(missing))
+	if err := topo.ValidateNode(a); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// syntheticTopology makes a topology of size endpoints, each with an edge to
+// the next fanout endpoints, wrapping around.
+func syntheticTopology(size, fanout int) report.Topology {
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = report.MakeEndpointNodeID("", "", fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff), "80")
+	}
+	topo := report.MakeTopology()
+	for i, id := range ids {
+		node := report.MakeNode(id)
+		for j := 1; j <= fanout; j++ {
+			node = node.WithEdge(ids[(i+j)%size], report.EdgeMetadata{})
+		}
+		topo.AddNode(node)
+	}
+	return topo
+}
+
+func TestTopologyValidateLarge(t *testing.T) {
+	topo := syntheticTopology(1000, 10)
+	if err := topo.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Removing one node leaves an adjacency and an edge dangling from each
+	// of the fanout nodes before it.
+	for id := range topo.Nodes {
+		delete(topo.Nodes, id)
+		break
+	}
+	errs, ok := topo.Validate().(report.ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors")
+	}
+	if counts := errs.CountByKind(); counts[report.DanglingAdjacency] != 10 || counts[report.DanglingEdge] != 10 || counts[report.InvalidNodeID] != 0 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+}
+
+func BenchmarkTopologyValidate1k(b *testing.B)  { benchmarkTopologyValidate(b, 1000) }
+func BenchmarkTopologyValidate10k(b *testing.B) { benchmarkTopologyValidate(b, 10000) }
+
+// benchmarkTopologyValidate should scale linearly with size: every lookup is
+// into the node map, rather than a scan of the adjacencies.
+func benchmarkTopologyValidate(b *testing.B, size int) {
+	topo := syntheticTopology(size, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := topo.Validate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}